@@ -3,19 +3,43 @@ package main
 import (
 	"context"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/approval"
+	"github.com/pnocera/tailscale-mcp-server/internal/authkey"
 	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/completion"
 	"github.com/pnocera/tailscale-mcp-server/internal/config"
 	"github.com/pnocera/tailscale-mcp-server/internal/handlers"
+	"github.com/pnocera/tailscale-mcp-server/internal/keyexpiry"
+	"github.com/pnocera/tailscale-mcp-server/internal/logpoll"
+	"github.com/pnocera/tailscale-mcp-server/internal/mcplog"
+	"github.com/pnocera/tailscale-mcp-server/internal/policysync"
+	"github.com/pnocera/tailscale-mcp-server/internal/rbac"
+	"github.com/pnocera/tailscale-mcp-server/internal/redact"
+	"github.com/pnocera/tailscale-mcp-server/internal/webhookrecv"
+	"github.com/pnocera/tailscale-mcp-server/internal/writelock"
+	"github.com/pnocera/tailscale-mcp-server/pkg/tools"
 )
 
 func main() {
+	log.SetOutput(redact.NewWriter(os.Stderr))
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	// From here on, log lines also go to MCP clients; the forwarder masks
+	// secrets itself.
+	logForwarder := mcplog.NewForwarder(os.Stderr, mcp.LoggingLevel(cfg.LogLevel), cfg.Role)
+	log.SetOutput(logForwarder)
+
 	tailscaleClient, err := client.NewTailscaleClient(cfg)
 	if err != nil {
 		log.Fatalf("Failed to create Tailscale client: %v", err)
@@ -25,16 +49,154 @@ func main() {
 		log.Fatalf("Failed to validate Tailscale connection: %v", err)
 	}
 
+	catalog := handlers.NewCatalog()
+	router := handlers.NewRouter(cfg)
+	access := handlers.NewAccessControl(cfg, router)
+	auditor := handlers.NewAuditor(cfg, access)
+	limiter := handlers.NewResultLimiter(cfg)
+	dryRunner := handlers.NewDryRunner(cfg)
+	resourceWatcher := handlers.NewResourceWatcher()
+	undoTools := tools.NewUndoTools(tailscaleClient)
+	approvalBroker := approval.NewBroker(cfg)
+	writeLock := writelock.New(cfg)
+	changeWindows := handlers.NewChangeWindows(cfg)
 	mcpServer := server.NewMCPServer(
 		"tailscale-mcp-server",
 		"1.0.0",
 		server.WithLogging(),
+		server.WithHooks(logForwarder.Hooks()),
+		server.WithToolCapabilities(true),
+		server.WithToolFilter(access.Filter),
+		server.WithToolFilter(router.Filter),
+		server.WithToolFilter(catalog.Filter),
+		server.WithToolHandlerMiddleware(router.Middleware),
+		server.WithToolHandlerMiddleware(auditor.Middleware),
+		server.WithToolHandlerMiddleware(access.Middleware),
+		server.WithToolHandlerMiddleware(limiter.Middleware),
+		server.WithToolHandlerMiddleware(handlers.NewSessionQuotas(cfg).Middleware),
+		server.WithToolHandlerMiddleware(handlers.NewGuardrails(cfg, tools.ChangesetGuardrailCalls, undoTools.GuardrailCalls).Middleware),
+		server.WithToolHandlerMiddleware(catalog.Middleware),
+		server.WithToolHandlerMiddleware(dryRunner.Middleware),
+		server.WithToolHandlerMiddleware(changeWindows.Middleware),
+		server.WithToolHandlerMiddleware(handlers.NewConfirmer(tailscaleClient, cfg).Middleware),
+		server.WithToolHandlerMiddleware(handlers.NewApprovals(tailscaleClient, cfg, approvalBroker).Middleware),
+		server.WithToolHandlerMiddleware(changeWindows.Middleware),
+		server.WithToolHandlerMiddleware(handlers.NewBudgets(cfg).Middleware),
+		server.WithToolHandlerMiddleware(handlers.NewWriteLock(writeLock).Middleware),
+		server.WithToolHandlerMiddleware(undoTools.Middleware),
+		server.WithToolHandlerMiddleware(handlers.QuotaMiddleware),
+		server.WithToolHandlerMiddleware(handlers.RedactMiddleware),
+		server.WithToolHandlerMiddleware(handlers.LogMiddleware),
+		server.WithToolHandlerMiddleware(resourceWatcher.Middleware),
 	)
+	logForwarder.Attach(mcpServer)
+
+	var policySyncer *policysync.Syncer
+	if cfg.PolicyGitRepo != "" {
+		policySyncer = policysync.NewSyncer(tailscaleClient, cfg, mcpServer, writeLock, auditor)
+	}
+
+	// webhookEvents stays empty unless the webhook receiver is enabled.
+	webhookEvents := webhookrecv.NewLog(1000)
 
-	handler := handlers.NewHandler(tailscaleClient)
+	handler := handlers.NewHandler(tailscaleClient, cfg, policySyncer, webhookEvents)
 	handler.RegisterTools(mcpServer)
+	limiter.RegisterTools(mcpServer)
+	auditor.RegisterTools(mcpServer)
+	undoTools.RegisterTools(mcpServer)
+	handler.RegisterResources(mcpServer)
+	handler.RegisterPrompts(mcpServer)
+
+	dryRunner.Load(mcpServer)
+	catalog.Load(mcpServer)
+	// Router tools are added after the catalog is loaded, so read-only mode
+	// and feature probes hide the routed tools' actions, not whole routers.
+	router.Load(mcpServer)
+	readOnly := cfg.ReadOnly
+	catalog.SetReadOnly(readOnly)
+	go func() {
+		toggle := make(chan os.Signal, 1)
+		signal.Notify(toggle, syscall.SIGUSR1)
+		for range toggle {
+			readOnly = !readOnly
+			log.Printf("Read-only mode: %v", readOnly)
+			catalog.SetReadOnly(readOnly)
+			policySyncer.SetReadOnly(readOnly)
+		}
+	}()
+
+	go handler.WatchResources(context.Background(), resourceWatcher, mcpServer)
+
+	if cfg.FeatureProbeInterval > 0 {
+		go handler.ProbeFeatures(context.Background(), catalog)
+	}
+
+	completer := completion.NewCompleter(tailscaleClient)
+
+	if cfg.KeyExpiryCheckInterval > 0 {
+		go keyexpiry.NewWatcher(tailscaleClient, cfg, mcpServer).Run(context.Background())
+	}
+
+	if cfg.PolicySyncInterval > 0 {
+		go policySyncer.Run(context.Background())
+	}
+
+	if cfg.LogPollInterval > 0 {
+		go logpoll.NewPoller(tailscaleClient, cfg, mcpServer).Run(context.Background())
+	}
+
+	if len(cfg.WebhookSecrets) > 0 && cfg.WebhookAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/v1/webhook", webhookrecv.NewHandler(cfg, mcpServer, webhookEvents))
+			log.Printf("Receiving Tailscale webhooks on %s", cfg.WebhookAddr)
+			if err := http.ListenAndServe(cfg.WebhookAddr, mux); err != nil {
+				log.Fatalf("Webhook receiver error: %v", err)
+			}
+		}()
+	}
+
+	if len(cfg.ApprovalTools) > 0 && cfg.ApprovalAddr != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle(approval.Path, approvalBroker)
+			log.Printf("Receiving approval callbacks on %s", cfg.ApprovalAddr)
+			if err := http.ListenAndServe(cfg.ApprovalAddr, mux); err != nil {
+				log.Fatalf("Approval callback listener error: %v", err)
+			}
+		}()
+	}
+
+	if cfg.Transport == "http" {
+		mux := http.NewServeMux()
+		mux.Handle("/mcp", rbac.RequireToken(cfg, completer.WrapHTTP(server.NewStreamableHTTPServer(mcpServer))))
+		if len(cfg.AuthKeyTokens) > 0 {
+			mux.Handle("/v1/authkey", authkey.NewHandler(tailscaleClient, cfg))
+		}
+		if len(cfg.WebhookSecrets) > 0 && cfg.WebhookAddr == "" {
+			mux.Handle("/v1/webhook", webhookrecv.NewHandler(cfg, mcpServer, webhookEvents))
+		}
+		if len(cfg.ApprovalTools) > 0 && cfg.ApprovalAddr == "" {
+			mux.Handle(approval.Path, approvalBroker)
+		}
+
+		if len(cfg.Principals) == 0 && cfg.Role != rbac.Viewer {
+			log.Printf("warning: TAILSCALE_MCP_HTTP_TOKENS is not set, so anyone who can reach %s acts with role %s", cfg.HTTPAddr, cfg.Role)
+		}
+		log.Printf("Serving MCP over HTTP on %s", cfg.HTTPAddr)
+		if err := http.ListenAndServe(cfg.HTTPAddr, mux); err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
 
-	if err := server.ServeStdio(mcpServer); err != nil {
+	stdioServer := server.NewStdioServer(mcpServer)
+	stdioServer.SetErrorLogger(log.Default())
+	stdin, stdout := completer.WrapStdio(os.Stdin, os.Stdout)
+	if err := stdioServer.Listen(ctx, stdin, stdout); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
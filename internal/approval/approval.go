@@ -0,0 +1,220 @@
+// Package approval asks people outside the MCP session, through a Slack or
+// generic webhook, to approve or deny proposed changes, and receives their
+// decisions on callback links.
+package approval
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// Path is where the callback handler is served; decisions are posted to
+// Path + "<id>/approve" or Path + "<id>/deny".
+const Path = "/v1/approvals/"
+
+// Decision is how a person answered a proposal.
+type Decision struct {
+	Approved bool
+	// By is who decided, as they gave it on the approval page.
+	By string
+}
+
+// Proposal is a change waiting for a decision.
+type Proposal struct {
+	ID      string
+	Tool    string
+	summary string
+	decided chan Decision
+	token   string
+	broker  *Broker
+}
+
+// Decided delivers the decision once someone makes it.
+func (p *Proposal) Decided() <-chan Decision {
+	return p.decided
+}
+
+// Close withdraws the proposal; later callbacks for it are rejected.
+func (p *Proposal) Close() {
+	p.broker.mu.Lock()
+	defer p.broker.mu.Unlock()
+	delete(p.broker.pending, p.ID)
+}
+
+// Broker posts proposals to the approval webhook and serves the callback
+// links that decide them.
+type Broker struct {
+	webhookURL string
+	baseURL    string
+	http       *http.Client
+
+	mu      sync.Mutex
+	pending map[string]*Proposal
+}
+
+func NewBroker(cfg *config.Config) *Broker {
+	return &Broker{
+		webhookURL: cfg.ApprovalWebhookURL,
+		baseURL:    strings.TrimSuffix(cfg.ApprovalBaseURL, "/"),
+		http:       &http.Client{Timeout: 30 * time.Second},
+		pending:    make(map[string]*Proposal),
+	}
+}
+
+// Propose posts summary, a description of a call of tool, to the approval
+// webhook with links to approve or deny it, and returns the pending
+// proposal. Callers must Close it once they stop waiting.
+func (b *Broker) Propose(ctx context.Context, tool, summary string, expires time.Time) (*Proposal, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	token, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+	p := &Proposal{ID: id, Tool: tool, summary: summary, decided: make(chan Decision, 1), token: token, broker: b}
+
+	b.mu.Lock()
+	b.pending[id] = p
+	b.mu.Unlock()
+
+	if err := b.post(ctx, p, summary, expires); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// post sends a Slack-compatible payload: a "text" message with the links,
+// plus the structured proposal for other receivers.
+func (b *Broker) post(ctx context.Context, p *Proposal, summary string, expires time.Time) error {
+	link := fmt.Sprintf("%s%s%s?token=%s", b.baseURL, Path, p.ID, p.token)
+	payload, err := json.Marshal(map[string]any{
+		"text": fmt.Sprintf("Approval needed for %s (expires %s):\n%s\nReview: %s", p.Tool, expires.Format(time.RFC3339), summary, link),
+		"approval": map[string]any{
+			"id":          p.ID,
+			"tool":        p.Tool,
+			"summary":     summary,
+			"expires":     expires.UTC(),
+			"review_url":  link,
+			"approve_url": fmt.Sprintf("%s%s%s/approve?token=%s", b.baseURL, Path, p.ID, p.token),
+			"deny_url":    fmt.Sprintf("%s%s%s/deny?token=%s", b.baseURL, Path, p.ID, p.token),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("approval webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+var reviewPage = template.Must(template.New("review").Parse(`<!doctype html>
+<title>Approve {{.Tool}}?</title>
+<h1>Approve {{.Tool}}?</h1>
+<pre>{{.Summary}}</pre>
+<form method="post">
+<input type="hidden" name="token" value="{{.Token}}">
+<p><label>Your name <input name="by"></label></p>
+<p><button formaction="{{.ID}}/approve">Approve</button> <button formaction="{{.ID}}/deny">Deny</button></p>
+</form>
+`))
+
+// ServeHTTP serves Path. GET <id> shows a page to decide the proposal;
+// decisions must be POSTed, so link previews cannot approve anything. Every
+// request must carry the proposal's token.
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, Path), "/")
+	token := r.URL.Query().Get("token")
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid form")
+			return
+		}
+		token = r.FormValue("token")
+	}
+
+	b.mu.Lock()
+	p, ok := b.pending[id]
+	b.mu.Unlock()
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(p.token)) != 1 {
+		writeError(w, http.StatusNotFound, "unknown or expired approval")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && action == "":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		reviewPage.Execute(w, map[string]string{"ID": id, "Tool": p.Tool, "Summary": p.summary, "Token": token})
+		return
+	case r.Method != http.MethodPost:
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	case action != "approve" && action != "deny":
+		writeError(w, http.StatusNotFound, "unknown action "+action)
+		return
+	}
+
+	decision := Decision{Approved: action == "approve", By: strings.TrimSpace(r.FormValue("by"))}
+	if decision.By == "" {
+		decision.By = "unknown"
+	}
+	select {
+	case p.decided <- decision:
+	default:
+		writeError(w, http.StatusConflict, "approval was already decided")
+		return
+	}
+	p.Close()
+	verdict := "denied"
+	if decision.Approved {
+		verdict = "approved"
+	}
+	log.Printf("Approval %s for %s %s by %s", id, p.Tool, verdict, decision.By)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"id": id, "decision": action})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
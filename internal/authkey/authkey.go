@@ -0,0 +1,133 @@
+package authkey
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// defaultExpirySeconds applies to templates that do not set their own expiry,
+// keeping vended keys short-lived.
+const defaultExpirySeconds = 3600
+
+// Handler serves /v1/authkey, minting ephemeral, tagged auth keys from the
+// configured templates for callers presenting a known bearer token. It lets
+// CI jobs obtain join keys without holding Tailscale API credentials.
+type Handler struct {
+	client *client.TailscaleClient
+	config *config.Config
+}
+
+func NewHandler(client *client.TailscaleClient, cfg *config.Config) *Handler {
+	return &Handler{client: client, config: cfg}
+}
+
+type vendRequest struct {
+	Template string `json:"template"`
+}
+
+type vendResponse struct {
+	ID      string    `json:"id"`
+	Key     string    `json:"key"`
+	Expires time.Time `json:"expires"`
+	Tags    []string  `json:"tags"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	allowed, ok := h.authorize(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+		return
+	}
+
+	var req vendRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "request body must be JSON of the form {\"template\": \"<name>\"}")
+		return
+	}
+
+	if !slices.Contains(allowed, req.Template) {
+		writeError(w, http.StatusForbidden, "token is not allowed to mint template "+req.Template)
+		return
+	}
+
+	template := h.config.KeyTemplates[req.Template]
+	if len(template.Tags) == 0 {
+		writeError(w, http.StatusForbidden, "template "+req.Template+" has no tags and cannot be vended")
+		return
+	}
+
+	// Vended keys are always single-use and ephemeral, whatever the template says.
+	template.Reusable = false
+	template.Ephemeral = true
+	if template.ExpirySeconds <= 0 {
+		template.ExpirySeconds = h.config.CapKeyExpiry(defaultExpirySeconds)
+	}
+	if template.Description == "" {
+		template.Description = "vended: " + req.Template
+	}
+
+	createReq := template.CreateKeyRequest()
+	if _, err := h.config.EnforceKeyPolicy(&createReq); err != nil {
+		writeError(w, http.StatusForbidden, "template "+req.Template+" violates key policy: "+err.Error())
+		return
+	}
+
+	// The endpoint is outside the tool middleware, so it keeps dry-run mode
+	// itself.
+	if h.config.DryRun {
+		writeError(w, http.StatusServiceUnavailable, "server is in dry-run mode and does not create auth keys")
+		return
+	}
+
+	key, err := h.client.GetClient().Keys().Create(r.Context(), createReq)
+	if err != nil {
+		log.Printf("Failed to vend auth key from template %s: %v", req.Template, err)
+		writeError(w, http.StatusBadGateway, "failed to create auth key")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(vendResponse{
+		ID:      key.ID,
+		Key:     key.Key,
+		Expires: key.Expires,
+		Tags:    key.Capabilities.Devices.Create.Tags,
+	})
+}
+
+// authorize returns the templates the request's bearer token may mint.
+func (h *Handler) authorize(r *http.Request) ([]string, bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return nil, false
+	}
+
+	for candidate, templates := range h.config.AuthKeyTokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			return templates, true
+		}
+	}
+	return nil, false
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
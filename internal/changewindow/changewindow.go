@@ -0,0 +1,187 @@
+// Package changewindow parses the schedules of approved change windows and
+// reports whether a moment falls inside one.
+package changewindow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embedded so timezones resolve in images without a zoneinfo database.
+	_ "time/tzdata"
+)
+
+// Schedule is a set of change windows in one timezone.
+type Schedule struct {
+	windows  []window
+	exprs    []string
+	location *time.Location
+}
+
+// window is a cron expression: every minute it matches is inside the window.
+type window struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny record unrestricted day fields; as in cron, a
+	// window restricting both matches days that satisfy either.
+	domAny, dowAny bool
+}
+
+var fieldRanges = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// Parse parses a ';'-separated list of five-field cron expressions (minute,
+// hour, day of month, month, day of week), such as "* 9-16 * * 1-5" for
+// weekdays from 09:00 to 16:59, interpreted in location.
+func Parse(expr string, location *time.Location) (*Schedule, error) {
+	s := &Schedule{location: location}
+	for _, part := range strings.Split(expr, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Fields(part)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("change window %q must have 5 fields, got %d", part, len(fields))
+		}
+		var masks [5]uint64
+		for i, field := range fields {
+			mask, err := parseField(field, fieldRanges[i][0], fieldRanges[i][1])
+			if err != nil {
+				return nil, fmt.Errorf("change window %q: %w", part, err)
+			}
+			masks[i] = mask
+		}
+		// Sunday is both 0 and 7.
+		if masks[4]&(1<<7) != 0 {
+			masks[4] |= 1
+		}
+		s.windows = append(s.windows, window{
+			minute: masks[0], hour: masks[1], dom: masks[2], month: masks[3], dow: masks[4],
+			domAny: fields[2] == "*", dowAny: fields[4] == "*",
+		})
+		s.exprs = append(s.exprs, part)
+	}
+	if len(s.windows) == 0 {
+		return nil, fmt.Errorf("no change windows given")
+	}
+	return s, nil
+}
+
+// parseField parses one cron field of values between lo and hi: "*", a
+// value, a range "a-b", any of them with a "/step", or a ','-separated list.
+func parseField(field string, lo, hi int) (uint64, error) {
+	var mask uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+		}
+
+		start, end := lo, hi
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if start, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", item)
+			}
+			end = start
+			if isRange {
+				if end, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid value in %q", item)
+				}
+			} else if hasStep {
+				end = hi
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("%q is outside %d-%d", item, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			mask |= 1 << v
+		}
+	}
+	return mask, nil
+}
+
+func (w window) matches(t time.Time) bool {
+	if w.minute&(1<<t.Minute()) == 0 || w.hour&(1<<t.Hour()) == 0 || w.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	return w.matchesDay(t)
+}
+
+// matchesDay reports whether the day of t is one of the window's days.
+func (w window) matchesDay(t time.Time) bool {
+	dom := w.dom&(1<<t.Day()) != 0
+	dow := w.dow&(1<<int(t.Weekday())) != 0
+	switch {
+	case w.domAny && w.dowAny:
+		return true
+	case w.domAny:
+		return dow
+	case w.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+// Open reports whether t is inside one of the change windows.
+func (s *Schedule) Open(t time.Time) bool {
+	t = t.In(s.location)
+	for _, w := range s.windows {
+		if w.matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the start of the first minute after t that is inside a change
+// window, looking up to a year ahead, or false if there is none.
+func (s *Schedule) Next(t time.Time) (time.Time, bool) {
+	from := t.Truncate(time.Minute).Add(time.Minute).In(s.location)
+	limit := from.Add(366 * 24 * time.Hour)
+	var next time.Time
+	found := false
+	for _, w := range s.windows {
+		if opens, ok := w.next(from, limit); ok && (!found || opens.Before(next)) {
+			next, found = opens, true
+		}
+	}
+	return next, found
+}
+
+// next returns the first minute from t on, and before limit, that the
+// window matches. It skips a whole month, day, or hour at a time when that
+// field does not match, so the search takes at most a few thousand steps.
+// t is in the schedule's location and at the start of a minute.
+func (w window) next(t, limit time.Time) (time.Time, bool) {
+	for t.Before(limit) {
+		year, month, day := t.Date()
+		switch {
+		case w.month&(1<<int(month)) == 0:
+			t = time.Date(year, month+1, 1, 0, 0, 0, 0, t.Location())
+		case !w.matchesDay(t):
+			t = time.Date(year, month, day+1, 0, 0, 0, 0, t.Location())
+		case w.hour&(1<<t.Hour()) == 0:
+			// Stepped in elapsed time rather than normalized with
+			// time.Date, so the hour repeated when clocks go back is
+			// visited once each time and the search always moves on.
+			t = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+		case w.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// String returns the schedule as it was configured, with its timezone.
+func (s *Schedule) String() string {
+	return fmt.Sprintf("%s (%s)", strings.Join(s.exprs, "; "), s.location)
+}
@@ -0,0 +1,286 @@
+package changewindow
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, expr string, location *time.Location) *Schedule {
+	t.Helper()
+	s, err := Parse(expr, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		" ; ",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"* 17-9 * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"* 9-16 * * 1-5; * * *",
+	} {
+		if _, err := Parse(expr, time.UTC); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	// 2026-10-12 is a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		expr string
+		at   time.Time
+		open bool
+	}{
+		{"* 9-16 * * 1-5", at(12, 9, 0), true},
+		{"* 9-16 * * 1-5", at(12, 16, 59), true},
+		{"* 9-16 * * 1-5", at(12, 17, 0), false},
+		{"* 9-16 * * 1-5", at(12, 8, 59), false},
+		{"* 9-16 * * 1-5", at(17, 10, 0), false}, // Saturday
+		{"*/15 * * * *", at(12, 3, 45), true},
+		{"*/15 * * * *", at(12, 3, 46), false},
+		{"5/20 * * * *", at(12, 3, 25), true},
+		{"5/20 * * * *", at(12, 3, 20), false},
+		{"0-30/10 * * * *", at(12, 3, 30), true},
+		{"0-30/10 * * * *", at(12, 3, 40), false},
+		{"0,30 * * * *", at(12, 3, 30), true},
+		{"0,30 * * * *", at(12, 3, 15), false},
+		{"* 1,3-4 * * *", at(12, 4, 10), true},
+		{"* 1,3-4 * * *", at(12, 2, 10), false},
+		// Sunday is both 0 and 7.
+		{"* * * * 0", at(18, 12, 0), true},
+		{"* * * * 7", at(18, 12, 0), true},
+		{"* * * * 7", at(17, 12, 0), false},
+		// Day of month alone.
+		{"* * 1 * *", at(1, 12, 0), true},
+		{"* * 1 * *", at(12, 12, 0), false},
+		// Day of week alone.
+		{"* * * * 1", at(12, 12, 0), true},
+		{"* * * * 1", at(13, 12, 0), false},
+		// Both restricted: either matches, as in cron.
+		{"* * 1 * 1", at(1, 12, 0), true},  // Thursday the 1st
+		{"* * 1 * 1", at(12, 12, 0), true}, // Monday the 12th
+		{"* * 1 * 1", at(13, 12, 0), false},
+		{"* * * 10 *", at(12, 12, 0), true},
+		{"* * * 1-9,11-12 *", at(12, 12, 0), false},
+		// Any of several windows.
+		{"* 9 * * *; * 22-23 * * 6", at(17, 22, 30), true},
+		{"* 9 * * *; * 22-23 * * 6", at(17, 21, 30), false},
+	}
+	for _, tt := range tests {
+		if got := mustParse(t, tt.expr, time.UTC).Open(tt.at); got != tt.open {
+			t.Errorf("%q at %s: got open %v, want %v", tt.expr, tt.at.Format(time.RFC3339), got, tt.open)
+		}
+	}
+}
+
+func TestOpenTimezone(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	s := mustParse(t, "* 9-16 * * 1-5", berlin)
+
+	// 08:30 UTC is 10:30 in Berlin in summer, 09:30 in winter.
+	if !s.Open(time.Date(2026, 7, 6, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("08:30 UTC in July is 10:30 in Berlin, want open")
+	}
+	if !s.Open(time.Date(2026, 1, 5, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("08:30 UTC in January is 09:30 in Berlin, want open")
+	}
+	if s.Open(time.Date(2026, 1, 5, 7, 30, 0, 0, time.UTC)) {
+		t.Errorf("07:30 UTC in January is 08:30 in Berlin, want closed")
+	}
+	// Monday 23:30 UTC is already Tuesday in Berlin, but Sunday 23:30 UTC
+	// is Monday 00:30 there, outside the hours.
+	if s.Open(time.Date(2026, 1, 4, 23, 30, 0, 0, time.UTC)) {
+		t.Errorf("Sunday 23:30 UTC is Monday 00:30 in Berlin, want closed")
+	}
+}
+
+func TestNext(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	tests := []struct {
+		name     string
+		expr     string
+		location *time.Location
+		from     time.Time
+		want     time.Time
+	}{
+		{
+			name:     "later today",
+			expr:     "* 9-16 * * 1-5",
+			location: time.UTC,
+			from:     time.Date(2026, 10, 12, 7, 15, 30, 0, time.UTC),
+			want:     time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "next minute inside",
+			expr:     "* 9-16 * * 1-5",
+			location: time.UTC,
+			from:     time.Date(2026, 10, 12, 10, 15, 30, 0, time.UTC),
+			want:     time.Date(2026, 10, 12, 10, 16, 0, 0, time.UTC),
+		},
+		{
+			name:     "over the weekend",
+			expr:     "* 9-16 * * 1-5",
+			location: time.UTC,
+			from:     time.Date(2026, 10, 16, 17, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "step",
+			expr:     "*/20 3 * * *",
+			location: time.UTC,
+			from:     time.Date(2026, 10, 12, 3, 41, 0, 0, time.UTC),
+			want:     time.Date(2026, 10, 13, 3, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "next month",
+			expr:     "0 0 1 * *",
+			location: time.UTC,
+			from:     time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+			want:     time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "leap day",
+			expr:     "0 12 29 2 *",
+			location: time.UTC,
+			from:     time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC),
+			want:     time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "day of month or week",
+			expr:     "0 12 20 * 5",
+			location: time.UTC,
+			from:     time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC), // Friday
+		},
+		{
+			name:     "earliest of several windows",
+			expr:     "0 22 * * 6; 30 9 * * *",
+			location: time.UTC,
+			from:     time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 10, 17, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "timezone",
+			expr:     "0 9 * * *",
+			location: berlin,
+			from:     time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 1, 6, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			// Clocks go from 02:00 to 03:00 on 2026-03-29, so that day has
+			// no 02:xx.
+			name:     "skipped hour",
+			expr:     "* 2 * * *",
+			location: berlin,
+			from:     time.Date(2026, 3, 28, 23, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "after the skipped hour",
+			expr:     "* 3 * * *",
+			location: berlin,
+			from:     time.Date(2026, 3, 28, 23, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 3, 29, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			// Clocks go from 03:00 back to 02:00 on 2026-10-25: the second
+			// 02:00 follows the first 02:59.
+			name:     "repeated hour",
+			expr:     "* 2 * * *",
+			location: berlin,
+			from:     time.Date(2026, 10, 25, 0, 59, 0, 0, time.UTC),
+			want:     time.Date(2026, 10, 25, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "after the repeated hour",
+			expr:     "0 3 * * *",
+			location: berlin,
+			from:     time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC),
+			want:     time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := mustParse(t, tt.expr, tt.location).Next(tt.from)
+			if !ok {
+				t.Fatalf("Next found no window")
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("got %s, want %s", got.UTC().Format(time.RFC3339), tt.want.Format(time.RFC3339))
+			}
+			if got.Location() != tt.location {
+				t.Errorf("got a time in %s, want %s", got.Location(), tt.location)
+			}
+		})
+	}
+}
+
+func TestNextNone(t *testing.T) {
+	for _, expr := range []string{"* * 30 2 *", "0 9 31 4,6,9,11 *"} {
+		if next, ok := mustParse(t, expr, time.UTC).Next(time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)); ok {
+			t.Errorf("%q: got %s, want no window within a year", expr, next)
+		}
+	}
+}
+
+// TestNextMatchesScan checks Next against a minute-by-minute scan of Open.
+func TestNextMatchesScan(t *testing.T) {
+	berlin := mustLoad(t, "Europe/Berlin")
+	exprs := []string{
+		"* 9-16 * * 1-5",
+		"*/7 */5 * * *",
+		"15 2 * * 0",
+		"0 0 1,15 * 3",
+		"* 22-23 * * 6; 30 1 * 3,10 *",
+	}
+	starts := []time.Time{
+		time.Date(2026, 3, 27, 18, 11, 0, 0, time.UTC),
+		time.Date(2026, 10, 23, 21, 59, 30, 0, time.UTC),
+		time.Date(2026, 12, 31, 23, 58, 0, 0, time.UTC),
+	}
+	for _, expr := range exprs {
+		s := mustParse(t, expr, berlin)
+		for _, from := range starts {
+			var want time.Time
+			for m := from.Truncate(time.Minute).Add(time.Minute); m.Before(from.Add(40 * 24 * time.Hour)); m = m.Add(time.Minute) {
+				if s.Open(m) {
+					want = m
+					break
+				}
+			}
+			if want.IsZero() {
+				t.Fatalf("%q from %s: the scan found no window", expr, from)
+			}
+			if got, ok := s.Next(from); !ok || !got.Equal(want) {
+				t.Errorf("%q from %s: got %s, %v, want %s", expr, from.Format(time.RFC3339), got.UTC().Format(time.RFC3339), ok, want.Format(time.RFC3339))
+			}
+		}
+	}
+}
@@ -1,17 +1,37 @@
 package client
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pnocera/tailscale-mcp-server/internal/config"
 	"tailscale.com/client/tailscale/v2"
 )
 
+const defaultBaseURL = "https://api.tailscale.com"
+
 type TailscaleClient struct {
 	client *tailscale.Client
 	mu     sync.RWMutex
+	quota  *quotaTracker
+}
+
+// APIError is returned by Do for non-2xx responses from the Tailscale API.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.StatusCode)
 }
 
 func NewTailscaleClient(cfg *config.Config) (*TailscaleClient, error) {
@@ -28,10 +48,19 @@ func NewTailscaleClient(cfg *config.Config) (*TailscaleClient, error) {
 		client.HTTP = oauthConfig.HTTPClient()
 	} else {
 		client.APIKey = cfg.TailscaleAPIKey
+		client.HTTP = &http.Client{Timeout: time.Minute}
+	}
+
+	quota := &quotaTracker{quota: Quota{Limit: -1, Remaining: -1}}
+	base := client.HTTP.Transport
+	if base == nil {
+		base = http.DefaultTransport
 	}
+	client.HTTP.Transport = &dryRunTransport{base: &writeGateTransport{base: &quotaTransport{base: base, tracker: quota}}}
 
 	return &TailscaleClient{
 		client: client,
+		quota:  quota,
 	}, nil
 }
 
@@ -49,3 +78,126 @@ func (tc *TailscaleClient) ValidateConnection(ctx context.Context) error {
 	}
 	return nil
 }
+
+// TailnetPath returns the API path of a tailnet-scoped resource, e.g.
+// TailnetPath("user-invites") is "/tailnet/<tailnet>/user-invites".
+func (tc *TailscaleClient) TailnetPath(elem ...string) string {
+	parts := []string{"", "tailnet", url.PathEscape(tc.GetClient().Tailnet)}
+	for _, e := range elem {
+		parts = append(parts, url.PathEscape(e))
+	}
+	return strings.Join(parts, "/")
+}
+
+// Do calls an endpoint of the Tailscale API that the SDK does not wrap. path
+// is relative to /api/v2 and must be escaped by the caller. A non-nil body is
+// sent as JSON; the response is decoded into out unless out is nil.
+func (tc *TailscaleClient) Do(ctx context.Context, method, path string, body, out any) error {
+	var bodyJSON []byte
+	if body != nil {
+		var err error
+		if bodyJSON, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	resp, err := tc.DoRaw(ctx, method, path, bodyJSON)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var payload struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(resp.Body, &payload) == nil && payload.Message != "" {
+			apiErr.Message = payload.Message
+		} else {
+			apiErr.Message = strings.TrimSpace(string(resp.Body))
+		}
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return apiErr
+	}
+
+	if out == nil || len(resp.Body) == 0 {
+		return nil
+	}
+	return json.Unmarshal(resp.Body, out)
+}
+
+// RawResponse is a Tailscale API response as received.
+type RawResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// DoRaw sends body, which must be JSON if non-nil, to an API endpoint and
+// returns the response whatever its status. path is as for Do.
+func (tc *TailscaleClient) DoRaw(ctx context.Context, method, path string, body []byte) (*RawResponse, error) {
+	client := tc.GetClient()
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	baseURL := defaultBaseURL
+	if client.BaseURL != nil {
+		baseURL = strings.TrimSuffix(client.BaseURL.String(), "/")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+"/api/v2"+path, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if client.APIKey != "" {
+		req.SetBasicAuth(client.APIKey, "")
+	}
+
+	resp, err := client.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &RawResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody}, nil
+}
+
+// ListKeysWithDetails returns every key with its full metadata. Keys().List
+// only populates key identifiers, so each key is fetched individually.
+func (tc *TailscaleClient) ListKeysWithDetails(ctx context.Context) ([]tailscale.Key, error) {
+	client := tc.GetClient()
+	keys, err := client.Keys().List(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	detailed := make([]tailscale.Key, 0, len(keys))
+	for _, k := range keys {
+		key, err := client.Keys().Get(ctx, k.ID)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k.ID, err)
+		}
+		detailed = append(detailed, *key)
+	}
+
+	return detailed, nil
+}
+
+// NormalizeETag strips the quotes HTTP puts around an ETag, such as the
+// policy file's. The SDK adds them back when sending If-Match.
+func NormalizeETag(etag string) string {
+	return strings.Trim(strings.TrimPrefix(strings.TrimSpace(etag), "W/"), `"`)
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DryRunCall is an API request a dry run held back. Current is what a GET
+// of the same endpoint returned, for requests that replace or update
+// something that can be read back; it is empty otherwise.
+type DryRunCall struct {
+	Method      string `json:"method"`
+	Endpoint    string `json:"endpoint"`
+	ContentType string `json:"content_type,omitempty"`
+	Payload     string `json:"payload,omitempty"`
+	Current     string `json:"-"`
+}
+
+type dryRunKey struct{}
+
+// DryRun collects the API requests that would change the tailnet during a
+// single tool call, instead of sending them.
+type DryRun struct {
+	mu    sync.Mutex
+	calls []DryRunCall
+}
+
+// WithDryRun returns a context whose API requests other than reads are
+// recorded in the returned DryRun and answered with an empty success
+// instead of being sent.
+func WithDryRun(ctx context.Context) (context.Context, *DryRun) {
+	dr := &DryRun{}
+	return context.WithValue(ctx, dryRunKey{}, dr), dr
+}
+
+// IsDryRun reports whether ctx is a dry run. Only tool calls are made dry
+// runs, by the tool middleware; writers outside tool calls check the
+// configuration's DryRun themselves.
+func IsDryRun(ctx context.Context) bool {
+	_, ok := ctx.Value(dryRunKey{}).(*DryRun)
+	return ok
+}
+
+// Calls returns the requests held back so far, in the order they were made.
+func (dr *DryRun) Calls() []DryRunCall {
+	dr.mu.Lock()
+	defer dr.mu.Unlock()
+	return append([]DryRunCall(nil), dr.calls...)
+}
+
+// dryRunTransport holds back the writes of dry runs.
+type dryRunTransport struct {
+	base http.RoundTripper
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	dr, ok := req.Context().Value(dryRunKey{}).(*DryRun)
+	if !ok || req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+
+	call := DryRunCall{Method: req.Method, Endpoint: req.URL.RequestURI(), ContentType: req.Header.Get("Content-Type")}
+	var payload []byte
+	if req.Body != nil {
+		var err error
+		if payload, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		call.Payload = string(payload)
+	}
+	if req.Method == http.MethodPut || req.Method == http.MethodPatch || req.Method == http.MethodPost {
+		call.Current = t.current(req)
+	}
+	log.Printf("debug: Dry run held back API request %s %s", req.Method, req.URL.Path)
+
+	dr.mu.Lock()
+	dr.calls = append(dr.calls, call)
+	dr.mu.Unlock()
+
+	// Handlers decode the response as the object they sent or asked for;
+	// echoing a JSON payload, or an empty object, lets them carry on so
+	// every request of a multi-step change is recorded.
+	body := "{}"
+	if strings.Contains(call.ContentType, "json") && len(payload) > 0 {
+		body = call.Payload
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// current reads the endpoint req writes to, in the format req sends, or
+// returns "" if it cannot be read.
+func (t *dryRunTransport) current(req *http.Request) string {
+	get := req.Clone(req.Context())
+	get.Method = http.MethodGet
+	get.Body = nil
+	get.ContentLength = 0
+	get.Header.Del("Content-Type")
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		get.Header.Set("Accept", contentType)
+	}
+
+	resp, err := t.base.RoundTrip(get)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, resp.Body); err != nil || resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	return buf.String()
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// AuditLogActor identifies who made a configuration change.
+type AuditLogActor struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	LoginName   string `json:"loginName,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// AuditLogTarget identifies the resource a configuration change applied to.
+type AuditLogTarget struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Type     string `json:"type"`
+	Property string `json:"property,omitempty"`
+}
+
+// AuditLogEntry is a single configuration audit log event.
+type AuditLogEntry struct {
+	EventGroupID  string          `json:"eventGroupID"`
+	Origin        string          `json:"origin"`
+	Actor         AuditLogActor   `json:"actor"`
+	EventTime     time.Time       `json:"eventTime"`
+	Type          string          `json:"type"`
+	Target        AuditLogTarget  `json:"target"`
+	Old           json.RawMessage `json:"old,omitempty"`
+	New           json.RawMessage `json:"new,omitempty"`
+	ActionDetails string          `json:"actionDetails,omitempty"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// AuditLogs returns the configuration audit log events between start and end.
+// The SDK does not wrap this endpoint.
+func (tc *TailscaleClient) AuditLogs(ctx context.Context, start, end time.Time) ([]AuditLogEntry, error) {
+	query := url.Values{}
+	query.Set("start", start.UTC().Format(time.RFC3339))
+	query.Set("end", end.UTC().Format(time.RFC3339))
+
+	var resp struct {
+		Logs []AuditLogEntry `json:"logs"`
+	}
+	if err := tc.Do(ctx, http.MethodGet, tc.TailnetPath("logging", "configuration")+"?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Logs, nil
+}
+
+// LogstreamStatus reports how a log stream's deliveries are going.
+type LogstreamStatus struct {
+	LastActivity      *time.Time `json:"lastActivity,omitempty"`
+	LastError         string     `json:"lastError,omitempty"`
+	NumBytesSent      int64      `json:"numBytesSent"`
+	NumEntriesSent    int64      `json:"numEntriesSent"`
+	NumTotalRequests  int64      `json:"numTotalRequests"`
+	NumFailedRequests int64      `json:"numFailedRequests"`
+}
+
+// LogstreamStatus returns the delivery status of the log stream for logType
+// ("configuration" or "network"). The SDK does not wrap this endpoint.
+func (tc *TailscaleClient) LogstreamStatus(ctx context.Context, logType string) (*LogstreamStatus, error) {
+	var status LogstreamStatus
+	if err := tc.Do(ctx, http.MethodGet, tc.TailnetPath("logging", logType, "stream", "status"), nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// TrafficStats counts the traffic of one connection in a network flow log.
+// Src and Dst are "ip:port" addresses.
+type TrafficStats struct {
+	Proto   int    `json:"proto,omitempty"`
+	Src     string `json:"src,omitempty"`
+	Dst     string `json:"dst,omitempty"`
+	TxPkts  uint64 `json:"txPkts,omitempty"`
+	TxBytes uint64 `json:"txBytes,omitempty"`
+	RxPkts  uint64 `json:"rxPkts,omitempty"`
+	RxBytes uint64 `json:"rxBytes,omitempty"`
+}
+
+// NetworkLogEntry is one node's network flow log for a time window.
+type NetworkLogEntry struct {
+	Logged          time.Time      `json:"logged"`
+	NodeID          string         `json:"nodeId"`
+	Start           time.Time      `json:"start"`
+	End             time.Time      `json:"end"`
+	VirtualTraffic  []TrafficStats `json:"virtualTraffic,omitempty"`
+	SubnetTraffic   []TrafficStats `json:"subnetTraffic,omitempty"`
+	ExitTraffic     []TrafficStats `json:"exitTraffic,omitempty"`
+	PhysicalTraffic []TrafficStats `json:"physicalTraffic,omitempty"`
+}
+
+// NetworkLogs returns the network flow logs recorded between start and end.
+// Flow logging must be enabled in the tailnet settings. The SDK does not wrap
+// this endpoint.
+func (tc *TailscaleClient) NetworkLogs(ctx context.Context, start, end time.Time) ([]NetworkLogEntry, error) {
+	query := url.Values{}
+	query.Set("start", start.UTC().Format(time.RFC3339))
+	query.Set("end", end.UTC().Format(time.RFC3339))
+
+	var resp struct {
+		Logs []NetworkLogEntry `json:"logs"`
+	}
+	if err := tc.Do(ctx, http.MethodGet, tc.TailnetPath("logging", "network")+"?"+query.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Logs, nil
+}
@@ -0,0 +1,202 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Quota is what the API has said about rate limiting. Limit and Remaining
+// are -1 until a response has carried them.
+type Quota struct {
+	Limit         int        `json:"limit"`
+	Remaining     int        `json:"remaining"`
+	Reset         *time.Time `json:"reset,omitempty"`
+	RetryAfter    *time.Time `json:"retry_after,omitempty"`
+	Throttled     int        `json:"throttled_requests"`
+	LastThrottled *time.Time `json:"last_throttled,omitempty"`
+	UpdatedAt     *time.Time `json:"updated_at,omitempty"`
+}
+
+// Low reports whether less than a tenth of the quota remains.
+func (q Quota) Low() bool {
+	return q.Limit > 0 && q.Remaining >= 0 && q.Remaining*10 < q.Limit
+}
+
+// quotaTracker records the rate limit headers of every API response.
+type quotaTracker struct {
+	mu    sync.Mutex
+	quota Quota
+}
+
+// observe updates q from a response received at now.
+func (q *Quota) observe(resp *http.Response, now time.Time) {
+	q.UpdatedAt = &now
+	if v, ok := rateLimitHeader(resp.Header, "Limit"); ok {
+		q.Limit = v
+	}
+	if v, ok := rateLimitHeader(resp.Header, "Remaining"); ok {
+		q.Remaining = v
+	}
+	if v, ok := rateLimitHeader(resp.Header, "Reset"); ok {
+		// Servers send either seconds until the reset or its Unix time.
+		reset := time.Unix(int64(v), 0)
+		if v < 1e9 {
+			reset = now.Add(time.Duration(v) * time.Second)
+		}
+		q.Reset = &reset
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		q.Throttled++
+		q.LastThrottled = &now
+		retryAfter := now.Add(time.Second)
+		if raw := resp.Header.Get("Retry-After"); raw != "" {
+			if seconds, err := strconv.Atoi(raw); err == nil {
+				retryAfter = now.Add(time.Duration(seconds) * time.Second)
+			} else if t, err := http.ParseTime(raw); err == nil {
+				retryAfter = t
+			}
+		}
+		q.RetryAfter = &retryAfter
+	}
+}
+
+// rateLimitHeader reads RateLimit-<name> or X-RateLimit-<name>.
+func rateLimitHeader(h http.Header, name string) (int, bool) {
+	for _, key := range []string{"RateLimit-" + name, "X-RateLimit-" + name} {
+		if v, err := strconv.Atoi(h.Get(key)); err == nil {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+type callQuotaKey struct{}
+
+// CallQuota collects what the API said about rate limiting during a single
+// tool call.
+type CallQuota struct {
+	mu    sync.Mutex
+	quota Quota
+	seen  bool
+}
+
+// TrackQuota returns a context whose API requests are recorded in the
+// returned CallQuota as well as in the client-wide quota.
+func TrackQuota(ctx context.Context) (context.Context, *CallQuota) {
+	cq := &CallQuota{quota: Quota{Limit: -1, Remaining: -1}}
+	return context.WithValue(ctx, callQuotaKey{}, cq), cq
+}
+
+// Note describes the call's rate limit situation if the caller should slow
+// down, and is empty otherwise.
+func (cq *CallQuota) Note() string {
+	cq.mu.Lock()
+	defer cq.mu.Unlock()
+
+	q := cq.quota
+	switch {
+	case !cq.seen:
+		return ""
+	case q.Throttled > 0 && q.RetryAfter != nil:
+		return fmt.Sprintf("Tailscale API rate limit hit (%d requests throttled); retry after %s", q.Throttled, q.RetryAfter.UTC().Format(time.RFC3339))
+	case q.Low() && q.Reset != nil:
+		return fmt.Sprintf("Tailscale API rate limit nearly exhausted: %d of %d requests remaining until %s", q.Remaining, q.Limit, q.Reset.UTC().Format(time.RFC3339))
+	case q.Low():
+		return fmt.Sprintf("Tailscale API rate limit nearly exhausted: %d of %d requests remaining", q.Remaining, q.Limit)
+	}
+	return ""
+}
+
+type callLimitKey struct{}
+
+// ErrCallLimit is returned for API requests beyond the limit of a
+// CallCounter.
+var ErrCallLimit = errors.New("the session's Tailscale API call quota is used up")
+
+// CallCounter counts the API requests made during a tool call, refusing
+// those beyond its limit.
+type CallCounter struct {
+	limit int64
+	count atomic.Int64
+}
+
+// LimitCalls returns a context whose API requests are counted in the
+// returned CallCounter, and refused with ErrCallLimit once limit have been
+// made.
+func LimitCalls(ctx context.Context, limit int) (context.Context, *CallCounter) {
+	cc := &CallCounter{limit: int64(limit)}
+	return context.WithValue(ctx, callLimitKey{}, cc), cc
+}
+
+// Unlimited returns a context whose API requests are neither counted nor
+// refused by a CallCounter, for requests that must not fail halfway, such
+// as those undoing a partly applied change.
+func Unlimited(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callLimitKey{}, (*CallCounter)(nil))
+}
+
+// CallsLeft returns how many more API requests ctx may make, or false if
+// they are not limited.
+func CallsLeft(ctx context.Context) (int, bool) {
+	cc, ok := ctx.Value(callLimitKey{}).(*CallCounter)
+	if !ok || cc == nil {
+		return 0, false
+	}
+	return int(cc.limit - cc.count.Load()), true
+}
+
+// Count returns how many API requests were made.
+func (cc *CallCounter) Count() int {
+	return int(cc.count.Load())
+}
+
+// quotaTransport counts requests against the call's limit and records rate
+// limit headers before handing responses back.
+type quotaTransport struct {
+	base    http.RoundTripper
+	tracker *quotaTracker
+}
+
+func (t *quotaTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if cc, ok := req.Context().Value(callLimitKey{}).(*CallCounter); ok && cc != nil {
+		if cc.count.Add(1) > cc.limit {
+			cc.count.Add(-1)
+			return nil, ErrCallLimit
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		log.Printf("debug: API request %s %s failed: %v", req.Method, req.URL.Path, err)
+		return nil, err
+	}
+
+	now := time.Now()
+	log.Printf("debug: API request %s %s: %s in %s", req.Method, req.URL.Path, resp.Status, now.Sub(start).Round(time.Millisecond))
+	t.tracker.mu.Lock()
+	t.tracker.quota.observe(resp, now)
+	t.tracker.mu.Unlock()
+
+	if cq, ok := req.Context().Value(callQuotaKey{}).(*CallQuota); ok {
+		cq.mu.Lock()
+		cq.quota.observe(resp, now)
+		cq.seen = true
+		cq.mu.Unlock()
+	}
+	return resp, nil
+}
+
+// Quota returns the most recent rate limit information from the API.
+func (tc *TailscaleClient) Quota() Quota {
+	tc.quota.mu.Lock()
+	defer tc.quota.mu.Unlock()
+	return tc.quota.quota
+}
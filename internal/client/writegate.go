@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+type writeGateKey struct{}
+
+// WriteGate is consulted before each API request that may change the
+// tailnet, with its method and path. It returns an error to refuse the
+// request, or a function that is told whether the request succeeded.
+type WriteGate func(method, path string) (done func(ok bool), err error)
+
+// WithWriteGate returns a context whose API requests other than reads pass
+// through gate. Requests held back by a dry run never reach it.
+func WithWriteGate(ctx context.Context, gate WriteGate) context.Context {
+	return context.WithValue(ctx, writeGateKey{}, gate)
+}
+
+// writeGateTransport sends writes only when their context's gate allows.
+type writeGateTransport struct {
+	base http.RoundTripper
+}
+
+func (t *writeGateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	gate, ok := req.Context().Value(writeGateKey{}).(WriteGate)
+	if !ok || req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+
+	done, err := gate(req.Method, req.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	done(err == nil && resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices)
+	return resp, err
+}
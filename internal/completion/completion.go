@@ -0,0 +1,331 @@
+// Package completion answers MCP completion/complete requests with device,
+// user, key, and webhook IDs. mcp-go does not route completion requests to
+// handlers, so the package intercepts them at the transport, in front of the
+// MCP server, and adds the completions capability to its initialize result.
+package completion
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+)
+
+const (
+	methodComplete = "completion/complete"
+	// cacheTTL is how long a listing is reused; completion requests arrive on
+	// every keystroke, so they must not each cost an API call.
+	cacheTTL = 30 * time.Second
+	// maxValues is the most values a completion result may carry.
+	maxValues = 100
+	// requestTimeout bounds the listing behind a completion request.
+	requestTimeout = 10 * time.Second
+)
+
+// candidate is a completion value and the text a typed prefix is matched
+// against besides the value itself, such as a device name.
+type candidate struct {
+	value string
+	label string
+}
+
+type listing struct {
+	candidates []candidate
+	fetched    time.Time
+}
+
+// Completer completes ID arguments from cached listings.
+type Completer struct {
+	client *client.TailscaleClient
+
+	mu    sync.Mutex
+	cache map[string]listing
+}
+
+func NewCompleter(client *client.TailscaleClient) *Completer {
+	return &Completer{client: client, cache: make(map[string]listing)}
+}
+
+// argumentKinds maps argument names to the listing their values come from.
+var argumentKinds = map[string]string{
+	"device_id":   "devices",
+	"user_id":     "users",
+	"key_id":      "keys",
+	"endpoint_id": "webhooks",
+}
+
+// templateKinds maps resource template URIs, whose argument is "id", to the
+// listing their values come from.
+var templateKinds = map[string]string{
+	"tailscale://users/{id}":    "users",
+	"tailscale://keys/{id}":     "keys",
+	"tailscale://webhooks/{id}": "webhooks",
+}
+
+// Complete returns the IDs of the argument's kind whose ID or name starts
+// with the typed value. Arguments it does not know get no values.
+func (c *Completer) Complete(ctx context.Context, params mcp.CompleteParams) (*mcp.CompleteResult, error) {
+	result := &mcp.CompleteResult{}
+	result.Completion.Values = []string{}
+
+	kind, ok := argumentKinds[params.Argument.Name]
+	if ref, isMap := params.Ref.(map[string]any); isMap && params.Argument.Name == "id" {
+		uri, _ := ref["uri"].(string)
+		kind, ok = templateKinds[uri]
+	}
+	if !ok {
+		return result, nil
+	}
+
+	candidates, err := c.candidates(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strings.ToLower(params.Argument.Value)
+	for _, cand := range candidates {
+		if !strings.HasPrefix(strings.ToLower(cand.value), prefix) && !strings.HasPrefix(strings.ToLower(cand.label), prefix) {
+			continue
+		}
+		result.Completion.Total++
+		if len(result.Completion.Values) < maxValues {
+			result.Completion.Values = append(result.Completion.Values, cand.value)
+		}
+	}
+	result.Completion.HasMore = result.Completion.Total > len(result.Completion.Values)
+	return result, nil
+}
+
+// candidates returns the listing of kind, fetching it again once it is older
+// than cacheTTL.
+func (c *Completer) candidates(ctx context.Context, kind string) ([]candidate, error) {
+	c.mu.Lock()
+	cached, ok := c.cache[kind]
+	c.mu.Unlock()
+	if ok && time.Since(cached.fetched) < cacheTTL {
+		return cached.candidates, nil
+	}
+
+	var candidates []candidate
+	tsClient := c.client.GetClient()
+	switch kind {
+	case "devices":
+		devices, err := tsClient.Devices().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, device := range devices {
+			candidates = append(candidates, candidate{value: device.NodeID, label: device.Name})
+		}
+	case "users":
+		users, err := tsClient.Users().List(ctx, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			candidates = append(candidates, candidate{value: user.ID, label: user.LoginName})
+		}
+	case "keys":
+		keys, err := c.client.ListKeysWithDetails(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			candidates = append(candidates, candidate{value: key.ID, label: key.Description})
+		}
+	case "webhooks":
+		webhooks, err := tsClient.Webhooks().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, webhook := range webhooks {
+			candidates = append(candidates, candidate{value: webhook.EndpointID, label: webhook.EndpointURL})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].label < candidates[j].label })
+
+	c.mu.Lock()
+	c.cache[kind] = listing{candidates: candidates, fetched: time.Now()}
+	c.mu.Unlock()
+	return candidates, nil
+}
+
+type completeRequest struct {
+	ID     mcp.RequestId      `json:"id"`
+	Method string             `json:"method"`
+	Params mcp.CompleteParams `json:"params"`
+}
+
+// parseComplete returns raw as a completion request, or false if it is any
+// other message.
+func parseComplete(raw []byte) (*completeRequest, bool) {
+	var request completeRequest
+	if !bytes.Contains(raw, []byte(methodComplete)) || json.Unmarshal(raw, &request) != nil || request.Method != methodComplete {
+		return nil, false
+	}
+	return &request, true
+}
+
+// respond answers a completion request with a JSON-RPC response.
+func (c *Completer) respond(request *completeRequest) []byte {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	var response any
+	result, err := c.Complete(ctx, request.Params)
+	if err != nil {
+		response = mcp.NewJSONRPCError(request.ID, mcp.INTERNAL_ERROR, err.Error(), nil)
+	} else {
+		response = mcp.JSONRPCResponse{JSONRPC: mcp.JSONRPC_VERSION, ID: request.ID, Result: result}
+	}
+	data, err := json.Marshal(response)
+	if err != nil {
+		// The response holds only strings and numbers, so this cannot happen.
+		log.Printf("Failed to marshal completion response: %v", err)
+	}
+	return data
+}
+
+// advertise adds the completions capability to message if it is the
+// server's initialize result, and returns it unchanged otherwise.
+func advertise(message []byte) []byte {
+	if !bytes.Contains(message, []byte(`"protocolVersion"`)) {
+		return message
+	}
+	var response map[string]json.RawMessage
+	if json.Unmarshal(message, &response) != nil {
+		return message
+	}
+	var result map[string]json.RawMessage
+	if json.Unmarshal(response["result"], &result) != nil || result["protocolVersion"] == nil {
+		return message
+	}
+	var capabilities map[string]json.RawMessage
+	if json.Unmarshal(result["capabilities"], &capabilities) != nil || capabilities == nil {
+		capabilities = make(map[string]json.RawMessage)
+	}
+	capabilities["completions"] = json.RawMessage(`{}`)
+
+	var err error
+	if result["capabilities"], err = json.Marshal(capabilities); err != nil {
+		return message
+	}
+	if response["result"], err = json.Marshal(result); err != nil {
+		return message
+	}
+	patched, err := json.Marshal(response)
+	if err != nil {
+		return message
+	}
+	if bytes.HasSuffix(message, []byte("\n")) {
+		patched = append(patched, '\n')
+	}
+	return patched
+}
+
+// stdioWriter serializes writes to stdout, which the MCP server makes from
+// several goroutines, one message per write.
+type stdioWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (sw *stdioWriter) Write(p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if _, err := sw.w.Write(advertise(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// WrapStdio returns the streams to serve MCP over stdio with: completion
+// requests read from in are answered on out directly, and every other
+// message is passed on to the returned reader.
+func (c *Completer) WrapStdio(in io.Reader, out io.Writer) (io.Reader, io.Writer) {
+	writer := &stdioWriter{w: out}
+	pr, pw := io.Pipe()
+
+	go func() {
+		reader := bufio.NewReader(in)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				if request, ok := parseComplete(line); ok {
+					go writer.Write(append(c.respond(request), '\n'))
+				} else if _, werr := pw.Write(line); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	return pr, writer
+}
+
+// initializeRecorder buffers a JSON initialize response so the capability
+// can be added before it is sent.
+type initializeRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *initializeRecorder) WriteHeader(status int) { r.status = status }
+
+func (r *initializeRecorder) Write(p []byte) (int, error) { return r.body.Write(p) }
+
+// WrapHTTP answers completion requests posted to the MCP endpoint and passes
+// every other request on to next.
+func (c *Completer) WrapHTTP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if request, ok := parseComplete(body); ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(c.respond(request))
+			return
+		}
+
+		var message struct {
+			Method string `json:"method"`
+		}
+		if json.Unmarshal(body, &message) != nil || message.Method != string(mcp.MethodInitialize) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &initializeRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		data := recorder.body.Bytes()
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			data = advertise(data)
+			w.Header().Del("Content-Length")
+		}
+		w.WriteHeader(recorder.status)
+		w.Write(data)
+	})
+}
@@ -1,16 +1,235 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pnocera/tailscale-mcp-server/internal/changewindow"
+	"github.com/pnocera/tailscale-mcp-server/internal/guardrail"
+	"tailscale.com/client/tailscale/v2"
 )
 
 type Config struct {
-	TailscaleAPIKey    string
-	TailscaleTailnet   string
-	TailscaleClientID  string
+	TailscaleAPIKey       string
+	TailscaleTailnet      string
+	TailscaleClientID     string
 	TailscaleClientSecret string
-	UseOAuth           bool
+	UseOAuth              bool
+
+	SecretsDir string
+
+	Transport string
+	HTTPAddr  string
+
+	// Role is what sessions without an authenticated principal may do:
+	// "viewer", "operator", or "admin". Principals maps the bearer tokens
+	// required on /mcp, when set, to the principal each one authenticates.
+	Role       string
+	Principals map[string]Principal
+
+	KeyTemplates       map[string]KeyTemplate
+	RequireKeyTemplate bool
+
+	// MaxKeyExpirySeconds caps the lifetime of created auth keys; 0 means no cap.
+	MaxKeyExpirySeconds int64
+	// ClampKeyExpiry shortens over-long expiries to the cap instead of rejecting them.
+	ClampKeyExpiry         bool
+	AllowReusableKeys      bool
+	AllowPreauthorizedKeys bool
+
+	// KeyExpiryCheckInterval enables the background key expiry check; 0 disables it.
+	KeyExpiryCheckInterval time.Duration
+	KeyExpiryWindow        time.Duration
+	KeyExpiryWebhookURL    string
+	// AuthKeyTokens maps bearer tokens accepted by the /v1/authkey endpoint
+	// to the names of the key templates each token may mint.
+	AuthKeyTokens map[string][]string
+
+	// PolicyBackupLocation is the directory or s3://bucket/prefix URL that
+	// policy backups are written to; empty disables backups.
+	PolicyBackupLocation string
+
+	// PolicyGitRepo is the git URL the policy is synced from; empty disables
+	// the sync. PolicyGitPath is relative to the repository root.
+	PolicyGitRepo   string
+	PolicyGitBranch string
+	PolicyGitPath   string
+	// PolicySyncInterval enables periodic sync and apply; 0 means on demand only.
+	PolicySyncInterval time.Duration
+
+	// PolicyApprovalToken, when set, must be presented to apply a staged
+	// policy, so a change proposed by a model is approved by whoever holds it.
+	PolicyApprovalToken string
+
+	// TailscaledSocket is the LocalAPI socket of a tailscaled on this host,
+	// used for tailnet lock data and the DERP map, which the control API does
+	// not expose.
+	TailscaledSocket string
+
+	// WebhookSecrets are the signing secrets of the Tailscale webhook
+	// endpoints whose deliveries are accepted; empty disables the receiver.
+	// WebhookAddr is the receiver's own listen address, used instead of the
+	// MCP HTTP listener when set or when serving over stdio.
+	WebhookSecrets []string
+	WebhookAddr    string
+
+	// LogPollInterval enables the background log poller; 0 disables it.
+	// LogPollTypes are "audit" and/or "network". LogPollState is the file
+	// cursors are persisted to, and LogPollOutput the NDJSON file entries are
+	// appended to instead of being sent as MCP notifications.
+	LogPollInterval time.Duration
+	LogPollTypes    []string
+	LogPollState    string
+	LogPollOutput   string
+
+	// AuditLog is the JSONL file every tool call is appended to, and
+	// AuditWebhookURL an endpoint each call is also posted to; both empty
+	// disables the audit trail.
+	AuditLog        string
+	AuditWebhookURL string
+
+	// EnableRawAPI registers tailscale_api_request, which can call any API
+	// endpoint and so bypasses the guardrails of the dedicated tools.
+	EnableRawAPI bool
+
+	// ReadOnly hides every tool that changes the tailnet. SIGUSR1 toggles it
+	// while the server runs.
+	ReadOnly bool
+	// DryRun makes every tool that changes the tailnet describe the API
+	// requests it would make instead of making them.
+	DryRun bool
+	// Guardrails are the operator's rules that deny tool calls by their
+	// arguments.
+	Guardrails []*guardrail.Rule
+	// ChangeWindows, when set, are the only times tools that change the
+	// tailnet may run.
+	ChangeWindows *changewindow.Schedule
+	// FeatureProbeInterval enables periodic probing of plan-dependent
+	// features, hiding the tools of those the tailnet lacks; 0 disables it.
+	FeatureProbeInterval time.Duration
+	// ResourcePollInterval is how often the tailnet settings and DNS
+	// resources are checked for changes made outside this server; 0 limits
+	// the check to after this server's own changes.
+	ResourcePollInterval time.Duration
+	// ConfirmTools are the tools whose calls the end user must confirm
+	// through MCP elicitation; "destructive" stands for every tool annotated
+	// as destructive.
+	ConfirmTools []string
+	// ApprovalTools are the tools whose calls must be approved by someone
+	// outside the session before they run, with "destructive" standing for
+	// every tool annotated as destructive. Proposals are posted to
+	// ApprovalWebhookURL with links under ApprovalBaseURL, the server's
+	// external URL, and lapse after ApprovalTimeout. ApprovalAddr is the
+	// callback listener's own address, used instead of the MCP HTTP listener
+	// when set or when serving over stdio.
+	ApprovalTools      []string
+	ApprovalWebhookURL string
+	ApprovalBaseURL    string
+	ApprovalAddr       string
+	ApprovalTimeout    time.Duration
+	// WriteLock is an optional file:// or redis:// URL of a lock shared with
+	// other servers managing the tailnet, on top of the in-process lock that
+	// serializes changes. WriteLockTimeout is how long a change waits for it.
+	WriteLock        string
+	WriteLockTimeout time.Duration
+	// ToolBudgets caps how many calls of a tool each session may make per
+	// ToolBudgetWindow; "destructive" stands for every tool annotated as
+	// destructive, counted together.
+	ToolBudgets      map[string]int
+	ToolBudgetWindow time.Duration
+	// SessionAPIQuota caps how many Tailscale API requests each session may
+	// cause per hour, so one session cannot use up the tailnet's API rate
+	// limit. 0 means no cap.
+	SessionAPIQuota int
+	// ToolMode is "flat" to offer every tool, or "grouped" to offer one
+	// router tool per area, such as tailscale_devices, that runs the area's
+	// tools by an action argument, for clients that limit how many tools a
+	// server may offer.
+	ToolMode string
+	// MaxResultBytes is the most text a tool result may hold; longer results
+	// are truncated and the rest is kept for tailscale_result_continue. 0
+	// disables the limit.
+	MaxResultBytes int
+
+	// LogLevel is the lowest level of log lines written to stderr. Clients
+	// pick their own level for the lines they are sent.
+	LogLevel string
+
+	// ExportDir is the directory export tools may write files under; empty
+	// means exports are only returned in tool results.
+	ExportDir string
+}
+
+// Principal is a caller of the HTTP transport and its role.
+type Principal struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// KeyTemplate describes an approved shape for newly created auth keys.
+type KeyTemplate struct {
+	Description   string   `json:"description"`
+	Reusable      bool     `json:"reusable"`
+	Ephemeral     bool     `json:"ephemeral"`
+	Preauthorized bool     `json:"preauthorized"`
+	Tags          []string `json:"tags"`
+	ExpirySeconds int64    `json:"expiry_seconds"`
+}
+
+func (t KeyTemplate) CreateKeyRequest() tailscale.CreateKeyRequest {
+	var req tailscale.CreateKeyRequest
+	req.Description = t.Description
+	req.ExpirySeconds = t.ExpirySeconds
+	req.Capabilities.Devices.Create.Reusable = t.Reusable
+	req.Capabilities.Devices.Create.Ephemeral = t.Ephemeral
+	req.Capabilities.Devices.Create.Preauthorized = t.Preauthorized
+	req.Capabilities.Devices.Create.Tags = t.Tags
+	return req
+}
+
+// EnforceKeyPolicy checks req against the configured key policy. Expiries
+// above the cap are clamped when ClampKeyExpiry is set and rejected otherwise;
+// requests that leave the expiry unset get the cap. It returns a note
+// describing any adjustment made to req.
+func (c *Config) EnforceKeyPolicy(req *tailscale.CreateKeyRequest) (string, error) {
+	create := req.Capabilities.Devices.Create
+	if create.Reusable && !c.AllowReusableKeys {
+		return "", fmt.Errorf("reusable keys are not allowed by server policy")
+	}
+	if create.Preauthorized && !c.AllowPreauthorizedKeys {
+		return "", fmt.Errorf("preauthorized keys are not allowed by server policy")
+	}
+
+	if c.MaxKeyExpirySeconds == 0 {
+		return "", nil
+	}
+
+	switch {
+	case req.ExpirySeconds <= 0:
+		req.ExpirySeconds = c.MaxKeyExpirySeconds
+		return fmt.Sprintf("expiry set to the policy maximum of %d seconds", c.MaxKeyExpirySeconds), nil
+	case req.ExpirySeconds > c.MaxKeyExpirySeconds && c.ClampKeyExpiry:
+		requested := req.ExpirySeconds
+		req.ExpirySeconds = c.CapKeyExpiry(requested)
+		return fmt.Sprintf("expiry clamped from %d to the policy maximum of %d seconds", requested, c.MaxKeyExpirySeconds), nil
+	case req.ExpirySeconds > c.MaxKeyExpirySeconds:
+		return "", fmt.Errorf("expiry of %d seconds exceeds the policy maximum of %d seconds", req.ExpirySeconds, c.MaxKeyExpirySeconds)
+	}
+	return "", nil
+}
+
+// CapKeyExpiry returns seconds lowered to the policy's maximum key expiry,
+// if there is one.
+func (c *Config) CapKeyExpiry(seconds int64) int64 {
+	if c.MaxKeyExpirySeconds > 0 && seconds > c.MaxKeyExpirySeconds {
+		return c.MaxKeyExpirySeconds
+	}
+	return seconds
 }
 
 func LoadConfig() (*Config, error) {
@@ -19,6 +238,13 @@ func LoadConfig() (*Config, error) {
 		TailscaleTailnet:      os.Getenv("TAILSCALE_TAILNET"),
 		TailscaleClientID:     os.Getenv("TAILSCALE_CLIENT_ID"),
 		TailscaleClientSecret: os.Getenv("TAILSCALE_CLIENT_SECRET"),
+		SecretsDir:            os.Getenv("TAILSCALE_MCP_SECRETS_DIR"),
+		Transport:             os.Getenv("TAILSCALE_MCP_TRANSPORT"),
+		HTTPAddr:              os.Getenv("TAILSCALE_MCP_HTTP_ADDR"),
+		PolicyBackupLocation:  os.Getenv("TAILSCALE_MCP_POLICY_BACKUP_DIR"),
+		PolicyApprovalToken:   os.Getenv("TAILSCALE_MCP_POLICY_APPROVAL_TOKEN"),
+		TailscaledSocket:      os.Getenv("TAILSCALE_MCP_TAILSCALED_SOCKET"),
+		ExportDir:             os.Getenv("TAILSCALE_MCP_EXPORT_DIR"),
 	}
 
 	if cfg.TailscaleTailnet == "" {
@@ -31,5 +257,302 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("either TAILSCALE_API_KEY or both TAILSCALE_CLIENT_ID and TAILSCALE_CLIENT_SECRET must be set")
 	}
 
+	switch cfg.Transport {
+	case "":
+		cfg.Transport = "stdio"
+	case "stdio", "http":
+	default:
+		return nil, fmt.Errorf("TAILSCALE_MCP_TRANSPORT must be 'stdio' or 'http', got %q", cfg.Transport)
+	}
+
+	if cfg.HTTPAddr == "" {
+		cfg.HTTPAddr = ":8080"
+	}
+
+	if err := loadJSON("TAILSCALE_MCP_KEY_TEMPLATES", &cfg.KeyTemplates); err != nil {
+		return nil, err
+	}
+
+	cfg.RequireKeyTemplate = os.Getenv("TAILSCALE_MCP_REQUIRE_KEY_TEMPLATE") == "true"
+	if cfg.RequireKeyTemplate && len(cfg.KeyTemplates) == 0 {
+		return nil, fmt.Errorf("TAILSCALE_MCP_REQUIRE_KEY_TEMPLATE is set but TAILSCALE_MCP_KEY_TEMPLATES defines no templates")
+	}
+
+	if raw := os.Getenv("TAILSCALE_MCP_MAX_KEY_EXPIRY_SECONDS"); raw != "" {
+		maxExpiry, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || maxExpiry < 0 {
+			return nil, fmt.Errorf("invalid TAILSCALE_MCP_MAX_KEY_EXPIRY_SECONDS: %q", raw)
+		}
+		cfg.MaxKeyExpirySeconds = maxExpiry
+	}
+
+	switch mode := os.Getenv("TAILSCALE_MCP_KEY_EXPIRY_MODE"); mode {
+	case "", "reject":
+	case "clamp":
+		cfg.ClampKeyExpiry = true
+	default:
+		return nil, fmt.Errorf("TAILSCALE_MCP_KEY_EXPIRY_MODE must be 'reject' or 'clamp', got %q", mode)
+	}
+	cfg.AllowReusableKeys = os.Getenv("TAILSCALE_MCP_ALLOW_REUSABLE_KEYS") != "false"
+	cfg.AllowPreauthorizedKeys = os.Getenv("TAILSCALE_MCP_ALLOW_PREAUTHORIZED_KEYS") != "false"
+
+	if err := loadDuration("TAILSCALE_MCP_KEY_EXPIRY_CHECK_INTERVAL", &cfg.KeyExpiryCheckInterval); err != nil {
+		return nil, err
+	}
+	cfg.KeyExpiryWindow = 7 * 24 * time.Hour
+	if err := loadDuration("TAILSCALE_MCP_KEY_EXPIRY_WINDOW", &cfg.KeyExpiryWindow); err != nil {
+		return nil, err
+	}
+	cfg.KeyExpiryWebhookURL = os.Getenv("TAILSCALE_MCP_KEY_EXPIRY_WEBHOOK_URL")
+
+	if err := loadJSON("TAILSCALE_MCP_AUTHKEY_TOKENS", &cfg.AuthKeyTokens); err != nil {
+		return nil, err
+	}
+
+	cfg.PolicyGitRepo = os.Getenv("TAILSCALE_MCP_POLICY_GIT_REPO")
+	cfg.PolicyGitBranch = os.Getenv("TAILSCALE_MCP_POLICY_GIT_BRANCH")
+	if cfg.PolicyGitBranch == "" {
+		cfg.PolicyGitBranch = "main"
+	}
+	cfg.PolicyGitPath = os.Getenv("TAILSCALE_MCP_POLICY_GIT_PATH")
+	if cfg.PolicyGitPath == "" {
+		cfg.PolicyGitPath = "policy.hujson"
+	}
+	if err := loadDuration("TAILSCALE_MCP_POLICY_SYNC_INTERVAL", &cfg.PolicySyncInterval); err != nil {
+		return nil, err
+	}
+	if cfg.PolicySyncInterval > 0 && cfg.PolicyGitRepo == "" {
+		return nil, fmt.Errorf("TAILSCALE_MCP_POLICY_SYNC_INTERVAL is set but TAILSCALE_MCP_POLICY_GIT_REPO is not")
+	}
+	if cfg.PolicySyncInterval > 0 && cfg.PolicyBackupLocation == "" && cfg.ExportDir == "" {
+		return nil, fmt.Errorf("TAILSCALE_MCP_POLICY_SYNC_INTERVAL needs TAILSCALE_MCP_POLICY_BACKUP_DIR or TAILSCALE_MCP_EXPORT_DIR, to back up the policy before applying")
+	}
+
+	for _, secret := range strings.Split(os.Getenv("TAILSCALE_MCP_WEBHOOK_SECRET"), ",") {
+		if secret = strings.TrimSpace(secret); secret != "" {
+			cfg.WebhookSecrets = append(cfg.WebhookSecrets, secret)
+		}
+	}
+	cfg.WebhookAddr = os.Getenv("TAILSCALE_MCP_WEBHOOK_ADDR")
+	if cfg.WebhookAddr == "" && len(cfg.WebhookSecrets) > 0 && cfg.Transport == "stdio" {
+		cfg.WebhookAddr = "127.0.0.1:8081"
+	}
+
+	if err := loadDuration("TAILSCALE_MCP_LOG_POLL_INTERVAL", &cfg.LogPollInterval); err != nil {
+		return nil, err
+	}
+	cfg.LogPollTypes = []string{"audit"}
+	if raw := os.Getenv("TAILSCALE_MCP_LOG_POLL_TYPES"); raw != "" {
+		cfg.LogPollTypes = nil
+		for _, logType := range strings.Split(raw, ",") {
+			logType = strings.TrimSpace(logType)
+			if logType != "audit" && logType != "network" {
+				return nil, fmt.Errorf("TAILSCALE_MCP_LOG_POLL_TYPES must list 'audit' and/or 'network', got %q", logType)
+			}
+			cfg.LogPollTypes = append(cfg.LogPollTypes, logType)
+		}
+	}
+	cfg.LogPollState = os.Getenv("TAILSCALE_MCP_LOG_POLL_STATE")
+	cfg.LogPollOutput = os.Getenv("TAILSCALE_MCP_LOG_POLL_OUTPUT")
+
+	cfg.AuditLog = os.Getenv("TAILSCALE_MCP_AUDIT_LOG")
+	cfg.AuditWebhookURL = os.Getenv("TAILSCALE_MCP_AUDIT_WEBHOOK_URL")
+
+	cfg.EnableRawAPI = os.Getenv("TAILSCALE_MCP_ENABLE_RAW_API") == "true"
+
+	cfg.ReadOnly = os.Getenv("TAILSCALE_MCP_READ_ONLY") == "true"
+	cfg.DryRun = os.Getenv("TAILSCALE_MCP_DRY_RUN") == "true"
+	if raw := os.Getenv("TAILSCALE_MCP_GUARDRAILS"); raw != "" {
+		rules, err := guardrail.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TAILSCALE_MCP_GUARDRAILS: %w", err)
+		}
+		cfg.Guardrails = rules
+	}
+	if raw := os.Getenv("TAILSCALE_MCP_CHANGE_WINDOWS"); raw != "" {
+		location := time.Local
+		if name := os.Getenv("TAILSCALE_MCP_CHANGE_WINDOW_TZ"); name != "" {
+			var err error
+			if location, err = time.LoadLocation(name); err != nil {
+				return nil, fmt.Errorf("invalid TAILSCALE_MCP_CHANGE_WINDOW_TZ: %w", err)
+			}
+		}
+		schedule, err := changewindow.Parse(raw, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TAILSCALE_MCP_CHANGE_WINDOWS: %w", err)
+		}
+		cfg.ChangeWindows = schedule
+	}
+	if err := loadDuration("TAILSCALE_MCP_FEATURE_PROBE_INTERVAL", &cfg.FeatureProbeInterval); err != nil {
+		return nil, err
+	}
+	cfg.ResourcePollInterval = 5 * time.Minute
+	if err := loadDuration("TAILSCALE_MCP_RESOURCE_POLL_INTERVAL", &cfg.ResourcePollInterval); err != nil {
+		return nil, err
+	}
+
+	cfg.LogLevel = os.Getenv("TAILSCALE_MCP_LOG_LEVEL")
+	switch cfg.LogLevel {
+	case "":
+		cfg.LogLevel = "info"
+	case "debug", "info", "notice", "warning", "error", "critical", "alert", "emergency":
+	default:
+		return nil, fmt.Errorf("invalid TAILSCALE_MCP_LOG_LEVEL: %q", cfg.LogLevel)
+	}
+
+	cfg.ToolMode = os.Getenv("TAILSCALE_MCP_TOOL_MODE")
+	switch cfg.ToolMode {
+	case "":
+		cfg.ToolMode = "flat"
+	case "flat", "grouped":
+	default:
+		return nil, fmt.Errorf("TAILSCALE_MCP_TOOL_MODE must be 'flat' or 'grouped', got %q", cfg.ToolMode)
+	}
+
+	cfg.MaxResultBytes = 100000
+	if raw := os.Getenv("TAILSCALE_MCP_MAX_RESULT_BYTES"); raw != "" {
+		maxBytes, err := strconv.Atoi(raw)
+		if err != nil || maxBytes < 0 {
+			return nil, fmt.Errorf("invalid TAILSCALE_MCP_MAX_RESULT_BYTES: %q", raw)
+		}
+		cfg.MaxResultBytes = maxBytes
+	}
+
+	for _, name := range strings.Split(os.Getenv("TAILSCALE_MCP_CONFIRM_TOOLS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.ConfirmTools = append(cfg.ConfirmTools, name)
+		}
+	}
+
+	for _, name := range strings.Split(os.Getenv("TAILSCALE_MCP_APPROVAL_TOOLS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			cfg.ApprovalTools = append(cfg.ApprovalTools, name)
+		}
+	}
+	cfg.ApprovalWebhookURL = os.Getenv("TAILSCALE_MCP_APPROVAL_WEBHOOK_URL")
+	cfg.ApprovalBaseURL = os.Getenv("TAILSCALE_MCP_APPROVAL_BASE_URL")
+	cfg.ApprovalAddr = os.Getenv("TAILSCALE_MCP_APPROVAL_ADDR")
+	cfg.ApprovalTimeout = 15 * time.Minute
+	if err := loadDuration("TAILSCALE_MCP_APPROVAL_TIMEOUT", &cfg.ApprovalTimeout); err != nil {
+		return nil, err
+	}
+	if len(cfg.ApprovalTools) > 0 && (cfg.ApprovalWebhookURL == "" || cfg.ApprovalBaseURL == "") {
+		return nil, fmt.Errorf("TAILSCALE_MCP_APPROVAL_TOOLS requires TAILSCALE_MCP_APPROVAL_WEBHOOK_URL and TAILSCALE_MCP_APPROVAL_BASE_URL")
+	}
+	if len(cfg.ApprovalTools) > 0 && cfg.Transport != "http" && cfg.ApprovalAddr == "" {
+		return nil, fmt.Errorf("TAILSCALE_MCP_APPROVAL_TOOLS over stdio requires TAILSCALE_MCP_APPROVAL_ADDR for approval callbacks")
+	}
+
+	cfg.WriteLock = os.Getenv("TAILSCALE_MCP_WRITE_LOCK")
+	if cfg.WriteLock != "" {
+		u, err := url.Parse(cfg.WriteLock)
+		switch {
+		case err != nil:
+			return nil, fmt.Errorf("invalid TAILSCALE_MCP_WRITE_LOCK: %w", err)
+		case u.Scheme == "file" && u.Path == "":
+			return nil, fmt.Errorf("TAILSCALE_MCP_WRITE_LOCK file URL needs a path, e.g. file:///var/lock/tailscale-mcp.lock")
+		case (u.Scheme == "redis" || u.Scheme == "rediss") && u.Host == "":
+			return nil, fmt.Errorf("TAILSCALE_MCP_WRITE_LOCK redis URL needs a host, e.g. redis://localhost:6379/0")
+		case u.Scheme != "file" && u.Scheme != "redis" && u.Scheme != "rediss":
+			return nil, fmt.Errorf("TAILSCALE_MCP_WRITE_LOCK must be a file://, redis://, or rediss:// URL, got %q", cfg.WriteLock)
+		}
+	}
+	cfg.WriteLockTimeout = 2 * time.Minute
+	if err := loadDuration("TAILSCALE_MCP_WRITE_LOCK_TIMEOUT", &cfg.WriteLockTimeout); err != nil {
+		return nil, err
+	}
+
+	if err := loadJSON("TAILSCALE_MCP_TOOL_BUDGETS", &cfg.ToolBudgets); err != nil {
+		return nil, err
+	}
+	for name, limit := range cfg.ToolBudgets {
+		if limit < 0 {
+			return nil, fmt.Errorf("TAILSCALE_MCP_TOOL_BUDGETS gives %s a negative budget", name)
+		}
+	}
+	cfg.ToolBudgetWindow = time.Hour
+	if err := loadDuration("TAILSCALE_MCP_TOOL_BUDGET_WINDOW", &cfg.ToolBudgetWindow); err != nil {
+		return nil, err
+	}
+	if cfg.ToolBudgetWindow <= 0 {
+		return nil, fmt.Errorf("TAILSCALE_MCP_TOOL_BUDGET_WINDOW must be positive")
+	}
+
+	if raw := os.Getenv("TAILSCALE_MCP_SESSION_API_QUOTA"); raw != "" {
+		quota, err := strconv.Atoi(raw)
+		if err != nil || quota < 0 {
+			return nil, fmt.Errorf("invalid TAILSCALE_MCP_SESSION_API_QUOTA: %q", raw)
+		}
+		cfg.SessionAPIQuota = quota
+	}
+
+	if err := loadJSON("TAILSCALE_MCP_HTTP_TOKENS", &cfg.Principals); err != nil {
+		return nil, err
+	}
+	cfg.Role = os.Getenv("TAILSCALE_MCP_ROLE")
+	switch {
+	case cfg.Role != "":
+	case cfg.Transport == "http" && len(cfg.Principals) == 0:
+		// Anyone who can reach the listener gets this role, so it only
+		// reads unless a role is chosen explicitly.
+		cfg.Role = "viewer"
+	default:
+		cfg.Role = "admin"
+	}
+	if !validRole(cfg.Role) {
+		return nil, fmt.Errorf("TAILSCALE_MCP_ROLE must be 'viewer', 'operator', or 'admin', got %q", cfg.Role)
+	}
+	for token, principal := range cfg.Principals {
+		if token == "" || principal.Name == "" {
+			return nil, fmt.Errorf("TAILSCALE_MCP_HTTP_TOKENS entries need a token and a name")
+		}
+		if !validRole(principal.Role) {
+			return nil, fmt.Errorf("TAILSCALE_MCP_HTTP_TOKENS gives %s unknown role %q", principal.Name, principal.Role)
+		}
+	}
+
+	for token, templates := range cfg.AuthKeyTokens {
+		if token == "" {
+			return nil, fmt.Errorf("TAILSCALE_MCP_AUTHKEY_TOKENS contains an empty token")
+		}
+		for _, name := range templates {
+			if _, ok := cfg.KeyTemplates[name]; !ok {
+				return nil, fmt.Errorf("TAILSCALE_MCP_AUTHKEY_TOKENS references unknown key template %q", name)
+			}
+		}
+	}
+
 	return cfg, nil
-}
\ No newline at end of file
+}
+
+func validRole(role string) bool {
+	return role == "viewer" || role == "operator" || role == "admin"
+}
+
+// loadJSON decodes the JSON value of the named environment variable into v.
+// Unset variables leave v untouched.
+func loadJSON(name string, v any) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(raw), v); err != nil {
+		return fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return nil
+}
+
+// loadDuration parses the named environment variable as a time.Duration.
+// Unset variables leave d untouched.
+func loadDuration(name string, d *time.Duration) error {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := time.ParseDuration(raw)
+	if err != nil || parsed < 0 {
+		return fmt.Errorf("invalid %s: %q", name, raw)
+	}
+	*d = parsed
+	return nil
+}
@@ -0,0 +1,154 @@
+// Package guardrail evaluates operator-supplied CEL rules against tool calls
+// before they run.
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/google/cel-go/cel"
+	celast "github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+)
+
+// Rule denies the calls its Deny expression is true for. The expression
+// sees the tool's name as tool and its arguments as args, e.g.
+//
+//	args.routes.exists(r, r in ["0.0.0.0/0", "::/0"])
+//
+// Tools, when set, limits the rule to the named tools. A call lacking an
+// argument the expression reads, as args.name or args["name"], is not
+// covered by the rule, unless DenyMissing is set, in which case it is
+// denied. Arguments the expression tests for with has() are left to it.
+type Rule struct {
+	Name        string   `json:"name"`
+	Tools       []string `json:"tools,omitempty"`
+	Deny        string   `json:"deny"`
+	Message     string   `json:"message,omitempty"`
+	DenyMissing bool     `json:"deny_missing,omitempty"`
+
+	program cel.Program
+	// reads are the arguments the expression needs to be evaluated.
+	reads []string
+}
+
+// Call is a call of a tool with its arguments.
+type Call struct {
+	Tool string
+	Args map[string]any
+}
+
+// Expander returns the calls of dedicated tools that a call of tool with
+// args stands in for, such as the steps of a changeset, so rules on those
+// tools cover it too. It returns nothing for tools that stand in for none.
+type Expander func(ctx context.Context, tool string, args map[string]any) ([]Call, error)
+
+var env *cel.Env
+
+func init() {
+	var err error
+	env, err = cel.NewEnv(
+		cel.Variable("tool", cel.StringType),
+		cel.Variable("args", cel.MapType(cel.StringType, cel.DynType)),
+		cel.CrossTypeNumericComparisons(true),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Parse decodes a JSON list of rules and compiles their expressions.
+func Parse(raw string) ([]*Rule, error) {
+	var rules []*Rule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, err
+	}
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if rule.Deny == "" {
+			return nil, fmt.Errorf("guardrail %s has no deny expression", rule.Name)
+		}
+		ast, issues := env.Compile(rule.Deny)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("guardrail %s: %w", rule.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, fmt.Errorf("guardrail %s: deny expression must be a bool, not %s", rule.Name, ast.OutputType())
+		}
+		program, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("guardrail %s: %w", rule.Name, err)
+		}
+		rule.program = program
+		rule.reads = argumentsRead(ast)
+	}
+	return rules, nil
+}
+
+// argumentsRead returns the arguments expr reads by name, other than those
+// it tests for with has().
+func argumentsRead(ast *cel.Ast) []string {
+	var reads, tested []string
+	celast.PreOrderVisit(ast.NativeRep().Expr(), celast.NewExprVisitor(func(e celast.Expr) {
+		switch e.Kind() {
+		case celast.SelectKind:
+			sel := e.AsSelect()
+			if sel.Operand().Kind() != celast.IdentKind || sel.Operand().AsIdent() != "args" {
+				return
+			}
+			if sel.IsTestOnly() {
+				tested = append(tested, sel.FieldName())
+			} else {
+				reads = append(reads, sel.FieldName())
+			}
+		case celast.CallKind:
+			call := e.AsCall()
+			if call.FunctionName() != operators.Index || len(call.Args()) != 2 {
+				return
+			}
+			operand, index := call.Args()[0], call.Args()[1]
+			if operand.Kind() != celast.IdentKind || operand.AsIdent() != "args" || index.Kind() != celast.LiteralKind {
+				return
+			}
+			if name, ok := index.AsLiteral().Value().(string); ok {
+				reads = append(reads, name)
+			}
+		}
+	}))
+	slices.Sort(reads)
+	reads = slices.Compact(reads)
+	return slices.DeleteFunc(reads, func(name string) bool { return slices.Contains(tested, name) })
+}
+
+// Applies reports whether the rule covers calls of tool.
+func (r *Rule) Applies(tool string) bool {
+	return len(r.Tools) == 0 || slices.Contains(r.Tools, tool)
+}
+
+// Denies evaluates the rule against a call of tool with args. An expression
+// that reads an argument the call lacks does not deny it, unless the rule
+// is DenyMissing. One that cannot be evaluated denies the call, with the
+// error saying why.
+func (r *Rule) Denies(tool string, args map[string]any) (bool, error) {
+	for _, name := range r.reads {
+		if _, ok := args[name]; !ok {
+			return r.DenyMissing, nil
+		}
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	out, _, err := r.program.Eval(map[string]any{"tool": tool, "args": args})
+	if err != nil {
+		return true, err
+	}
+	denied, ok := out.Value().(bool)
+	if !ok {
+		return true, fmt.Errorf("deny expression returned %v, not a bool", out.Value())
+	}
+	return denied, nil
+}
@@ -0,0 +1,147 @@
+package guardrail
+
+import (
+	"slices"
+	"testing"
+)
+
+func parseRule(t *testing.T, raw string) *Rule {
+	t.Helper()
+	rules, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 {
+		t.Fatalf("got %d rules, want 1", len(rules))
+	}
+	return rules[0]
+}
+
+func TestRuleDenies(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		tool    string
+		args    map[string]any
+		denied  bool
+		wantErr bool
+	}{
+		{
+			name:   "denied",
+			rule:   `[{"deny": "args.routes.exists(r, r in ['0.0.0.0/0', '::/0'])"}]`,
+			args:   map[string]any{"routes": []any{"10.0.0.0/8", "0.0.0.0/0"}},
+			denied: true,
+		},
+		{
+			name: "allowed",
+			rule: `[{"deny": "args.routes.exists(r, r in ['0.0.0.0/0', '::/0'])"}]`,
+			args: map[string]any{"routes": []any{"10.0.0.0/8"}},
+		},
+		{
+			name:   "tool name",
+			rule:   `[{"deny": "tool == 'tailscale_device_delete'"}]`,
+			tool:   "tailscale_device_delete",
+			denied: true,
+		},
+		{
+			name: "missing argument",
+			rule: `[{"deny": "args.tags.exists(t, !t.startsWith('tag:dev-'))"}]`,
+			args: map[string]any{"device_id": "d1"},
+		},
+		{
+			name: "nil arguments",
+			rule: `[{"deny": "args.tags.size() > 0"}]`,
+		},
+		{
+			name:   "missing argument with deny_missing",
+			rule:   `[{"deny": "args.tags.exists(t, !t.startsWith('tag:dev-'))", "deny_missing": true}]`,
+			args:   map[string]any{"device_id": "d1"},
+			denied: true,
+		},
+		{
+			name:   "missing index argument with deny_missing",
+			rule:   `[{"deny": "args['tags'].size() > 0", "deny_missing": true}]`,
+			denied: true,
+		},
+		{
+			name:   "argument tested with has",
+			rule:   `[{"deny": "!has(args.reason)"}]`,
+			args:   map[string]any{"device_id": "d1"},
+			denied: true,
+		},
+		{
+			name: "argument tested with has and present",
+			rule: `[{"deny": "!has(args.reason)"}]`,
+			args: map[string]any{"reason": "decommissioned"},
+		},
+		{
+			name:    "nested key missing",
+			rule:    `[{"deny": "args.settings.devices_approval_on == false"}]`,
+			args:    map[string]any{"settings": map[string]any{}},
+			denied:  true,
+			wantErr: true,
+		},
+		{
+			name:    "type error",
+			rule:    `[{"deny": "args.expiry_seconds > 'long'"}]`,
+			args:    map[string]any{"expiry_seconds": 3600},
+			denied:  true,
+			wantErr: true,
+		},
+		{
+			name:   "cross-type numbers",
+			rule:   `[{"deny": "args.expiry_seconds > 86400"}]`,
+			args:   map[string]any{"expiry_seconds": 90000.0},
+			denied: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := parseRule(t, tt.rule)
+			denied, err := rule.Denies(tt.tool, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if denied != tt.denied {
+				t.Errorf("got denied %v, want %v", denied, tt.denied)
+			}
+		})
+	}
+}
+
+func TestArgumentsRead(t *testing.T) {
+	rule := parseRule(t, `[{"deny": "has(args.reason) || args.tags.exists(t, t == 'tag:prod') || args['routes'].size() > 0 || args.tags.size() > 3"}]`)
+	if want := []string{"routes", "tags"}; !slices.Equal(rule.reads, want) {
+		t.Errorf("got reads %v, want %v", rule.reads, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"not JSON", `{"deny": "true"}`},
+		{"no deny expression", `[{"name": "empty"}]`},
+		{"syntax error", `[{"deny": "args.tags.exists("}]`},
+		{"not a bool", `[{"deny": "tool + 'x'"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.raw); err == nil {
+				t.Errorf("Parse(%s) succeeded, want an error", tt.raw)
+			}
+		})
+	}
+
+	rules, err := Parse(`[{"deny": "true"}, {"name": "named", "deny": "false", "tools": ["tailscale_device_delete"]}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rules[0].Name != "#1" || rules[1].Name != "named" {
+		t.Errorf("got names %q and %q, want #1 and named", rules[0].Name, rules[1].Name)
+	}
+	if !rules[0].Applies("tailscale_device_list") || rules[1].Applies("tailscale_device_list") || !rules[1].Applies("tailscale_device_delete") {
+		t.Errorf("Applies does not follow the rules' tools")
+	}
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/rbac"
+)
+
+// AccessControl limits each session to the tools its principal's role may
+// call. Sessions authenticated over HTTP act for the principal of their
+// bearer token; others, such as the stdio session, get the configured role.
+type AccessControl struct {
+	role   string
+	router *Router
+}
+
+func NewAccessControl(cfg *config.Config, router *Router) *AccessControl {
+	return &AccessControl{role: cfg.Role, router: router}
+}
+
+// principal returns who ctx acts for.
+func (a *AccessControl) principal(ctx context.Context) config.Principal {
+	if p, ok := rbac.FromContext(ctx); ok {
+		return p
+	}
+	return config.Principal{Name: "default", Role: a.role}
+}
+
+// Filter leaves the tools the session may not call out of tools/list
+// results; a router tool is kept while any of its actions is permitted.
+// Register it with server.WithToolFilter, before the router's filter.
+func (a *AccessControl) Filter(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	role := a.principal(ctx).Role
+	permitted := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		permitted[tool.Name] = rbac.Permits(role, tool)
+	}
+	return slices.DeleteFunc(tools, func(tool mcp.Tool) bool {
+		if actions, ok := a.router.routes[tool.Name]; ok {
+			for _, name := range actions {
+				if permitted[name] {
+					return false
+				}
+			}
+			return true
+		}
+		return !permitted[tool.Name]
+	})
+}
+
+// Middleware refuses calls the session's role does not permit before they
+// reach the handler. Register it after the router's middleware, so routed
+// calls are checked against the tool they run.
+func (a *AccessControl) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mcpServer := server.ServerFromContext(ctx)
+		if mcpServer == nil {
+			return next(ctx, request)
+		}
+		tool := mcpServer.GetTool(request.Params.Name)
+		if tool == nil {
+			return next(ctx, request)
+		}
+
+		principal := a.principal(ctx)
+		if !rbac.Permits(principal.Role, tool.Tool) {
+			log.Printf("warning: Refused %s to %s (role %s)", request.Params.Name, principal.Name, principal.Role)
+			return mcp.NewToolResultError(fmt.Sprintf("Tool %s is not permitted for role %s", request.Params.Name, principal.Role)), nil
+		}
+		return next(ctx, request)
+	}
+}
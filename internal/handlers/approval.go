@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/approval"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/redact"
+)
+
+// approvalProgressInterval is how often a waiting call reports progress.
+const approvalProgressInterval = 15 * time.Second
+
+// Approvals holds calls to the configured tools until someone outside the
+// session approves them through the approval webhook, for changes too risky
+// to leave to the user of the MCP client.
+type Approvals struct {
+	describer
+	tools   toolSet
+	broker  *approval.Broker
+	timeout time.Duration
+}
+
+func NewApprovals(client *client.TailscaleClient, cfg *config.Config, broker *approval.Broker) *Approvals {
+	return &Approvals{
+		describer: describer{client: client},
+		tools:     newToolSet(cfg.ApprovalTools),
+		broker:    broker,
+		timeout:   cfg.ApprovalTimeout,
+	}
+}
+
+// Middleware posts calls to the configured tools for approval and blocks
+// until they are approved, denied, or time out, sending progress
+// notifications meanwhile to clients that asked for them. Dry runs change
+// nothing and are not held.
+func (a *Approvals) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.Params.Name
+		mcpServer := server.ServerFromContext(ctx)
+		if mcpServer == nil || client.IsDryRun(ctx) || !a.tools.contains(mcpServer, name) {
+			return next(ctx, request)
+		}
+
+		// The proposal leaves the session, so secret arguments are masked.
+		masked := request
+		masked.Params.Arguments = auditArguments(request.GetArguments())
+		summary := redact.String(a.describe(ctx, masked))
+
+		start := time.Now()
+		expires := start.Add(a.timeout)
+		proposal, err := a.broker.Propose(ctx, name, summary, expires)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to request approval for %s: %v", name, err)), nil
+		}
+		defer proposal.Close()
+		log.Printf("Waiting for approval %s of %s", proposal.ID, name)
+
+		timeout := time.NewTimer(a.timeout)
+		defer timeout.Stop()
+		ticker := time.NewTicker(approvalProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case decision := <-proposal.Decided():
+				if !decision.Approved {
+					return mcp.NewToolResultError(fmt.Sprintf("Tool %s was not run: %s denied approval %s", name, decision.By, proposal.ID)), nil
+				}
+				return next(ctx, request)
+			case <-timeout.C:
+				return mcp.NewToolResultError(fmt.Sprintf("Tool %s was not run: approval %s was not given within %s", name, proposal.ID, a.timeout)), nil
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-ticker.C:
+				a.progress(ctx, mcpServer, request, start, expires)
+			}
+		}
+	}
+}
+
+// progress tells the client the call is still waiting, if it sent a
+// progress token.
+func (a *Approvals) progress(ctx context.Context, mcpServer *server.MCPServer, request mcp.CallToolRequest, start, expires time.Time) {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return
+	}
+	waited := time.Since(start)
+	err := mcpServer.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+		"progressToken": request.Params.Meta.ProgressToken,
+		"progress":      waited.Seconds(),
+		"total":         expires.Sub(start).Seconds(),
+		"message":       fmt.Sprintf("Waiting for approval (%s of %s)", waited.Round(time.Second), a.timeout),
+	})
+	if err != nil {
+		log.Printf("debug: Failed to send approval progress for %s: %v", request.Params.Name, err)
+	}
+}
@@ -0,0 +1,586 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/redact"
+)
+
+// auditSecretArguments are tool arguments whose values are never recorded,
+// along with arguments whose names end in one of them after an underscore,
+// such as s3_secret_access_key.
+var auditSecretArguments = map[string]bool{
+	"approval_token":    true,
+	"client_secret":     true,
+	"password":          true,
+	"secret":            true,
+	"secret_access_key": true,
+	"token":             true,
+}
+
+// isAuditSecret reports whether the value of argument name is masked in
+// audit records.
+func isAuditSecret(name string) bool {
+	if auditSecretArguments[name] {
+		return true
+	}
+	for secret := range auditSecretArguments {
+		if strings.HasSuffix(name, "_"+secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// auditVerifyTool checks the hash chain of the audit log.
+const auditVerifyTool = "tailscale_audit_verify"
+
+// Auditor records every tool call, with who made it and how it ended, to an
+// append-only JSONL file and/or an HTTP endpoint, so changes made through
+// the server can be attributed. Records are hash-chained, so editing or
+// removing one breaks the chain from there on.
+type Auditor struct {
+	path       string
+	webhookURL string
+	dryRun     bool
+	access     *AccessControl
+	http       *http.Client
+
+	mu sync.Mutex
+	// lastHash is the hash of the latest record, which the next one links to.
+	lastHash string
+	// anchored is whether the log's chain anchor has been written.
+	anchored bool
+}
+
+type auditRecord struct {
+	Time      time.Time      `json:"time"`
+	Tool      string         `json:"tool"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+	Principal string         `json:"principal"`
+	Role      string         `json:"role"`
+	Session   string         `json:"session,omitempty"`
+	DryRun    bool           `json:"dry_run,omitempty"`
+	// Status is "ok", "error" for error results, or "failed" for calls
+	// that returned no result.
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+	// PrevHash is the hash of the record before this one, empty for the
+	// first. Hash is the SHA-256 of the record as marshaled without it, and
+	// must stay the last field: verification strips it from the line.
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// auditChainStart starts the hash chain of an audit log whose earlier lines
+// are not hash-chained, such as records written before chaining was added.
+// It fixes those lines by their SHA-256, so they cannot be changed without
+// breaking it either, and verification starts from the last one.
+type auditChainStart struct {
+	Time            time.Time `json:"time"`
+	ChainStart      bool      `json:"chain_start"`
+	PrecedingLines  int       `json:"preceding_lines"`
+	PrecedingSHA256 string    `json:"preceding_sha256"`
+	PrevHash        string    `json:"prev_hash"`
+	Hash            string    `json:"hash,omitempty"`
+}
+
+// auditAnchorPath is the file next to the audit log at path that holds the
+// hash of its first chained line, written once the log is chained. A log
+// with an anchor is never given a new chain start, and verifying it fails
+// if its chain no longer begins with the anchor, so the chain cannot be
+// stripped from the records or replaced to make edited lines look like
+// records from before chaining.
+func auditAnchorPath(path string) string {
+	return path + ".anchor"
+}
+
+// readAuditAnchor returns the chain anchor of the audit log at path, or ""
+// if it has none.
+func readAuditAnchor(path string) (string, error) {
+	anchor, err := os.ReadFile(auditAnchorPath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(anchor)), err
+}
+
+// NewAuditor returns an Auditor for cfg, or nil if neither an audit log nor
+// an audit webhook is configured.
+func NewAuditor(cfg *config.Config, access *AccessControl) *Auditor {
+	if cfg.AuditLog == "" && cfg.AuditWebhookURL == "" {
+		return nil
+	}
+	a := &Auditor{
+		path:       cfg.AuditLog,
+		webhookURL: cfg.AuditWebhookURL,
+		dryRun:     cfg.DryRun,
+		access:     access,
+		http:       &http.Client{Timeout: 10 * time.Second},
+	}
+	if a.path != "" {
+		// Continue the chain of an existing log.
+		verification, err := verifyAuditLog(a.path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("warning: Failed to read audit log %s: %v", a.path, err)
+		}
+		a.anchored = verification.anchored
+		switch {
+		case verification.Problem != "" && !verification.chained && !verification.anchored:
+			// A log from before records were chained.
+			if err := a.startChain(verification); err != nil {
+				log.Printf("error: Failed to start the hash chain of audit log %s: %v", a.path, err)
+			} else {
+				log.Printf("warning: Audit log %s has %d records from before they were hash-chained; the chain starts after them", a.path, verification.lines)
+			}
+		case verification.Problem != "":
+			log.Printf("error: Audit log %s fails verification at line %d: %s; it will keep failing until the log is investigated and restored", a.path, verification.BrokenAtLine, verification.Problem)
+			a.lastHash = verification.LastHash
+		default:
+			a.lastHash = verification.LastHash
+		}
+		if verification.chained && !a.anchored {
+			// A log chained before anchors were written.
+			a.writeAnchor(verification.firstHash)
+		}
+	}
+	return a
+}
+
+// RegisterTools registers tailscale_audit_verify when there is an audit log
+// to verify.
+func (a *Auditor) RegisterTools(mcpServer *server.MCPServer) {
+	if a == nil || a.path == "" {
+		return
+	}
+	tool := mcp.NewTool(
+		auditVerifyTool,
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			ReadOnlyHint:    mcp.ToBoolPtr(true),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription("Verify the hash chain of the server's audit log, proving no record was edited, inserted, or removed after it was written. Reports the number of records, the first line that breaks the chain and why, and the hash of the latest record, which can be kept elsewhere to also detect records removed from the end. Makes no API calls."),
+	)
+	mcpServer.AddTool(tool, a.Verify)
+}
+
+// Middleware records each call once it returns. Register it after the
+// router's middleware, so routed calls are recorded as the tool they run,
+// and before access control, so refused calls are recorded too. A nil
+// Auditor records nothing.
+func (a *Auditor) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if a == nil {
+		return next
+	}
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := next(ctx, request)
+
+		principal := a.access.principal(ctx)
+		args := request.GetArguments()
+		requested, _ := args[dryRunArgument].(bool)
+		record := auditRecord{
+			Time:      start.UTC(),
+			Tool:      request.Params.Name,
+			Arguments: auditArguments(args),
+			Principal: principal.Name,
+			Role:      principal.Role,
+			DryRun:    a.dryRun || requested,
+			Status:    "ok",
+			LatencyMS: time.Since(start).Milliseconds(),
+		}
+		if session := server.ClientSessionFromContext(ctx); session != nil {
+			record.Session = session.SessionID()
+		}
+		switch {
+		case err != nil:
+			record.Status = "failed"
+			record.Error = redact.String(err.Error())
+		case result != nil && result.IsError:
+			record.Status = "error"
+			record.Error = redact.String(errorText(result))
+		}
+
+		a.record(record)
+		return result, err
+	}
+}
+
+// RecordBackground records a change the server made by itself rather than
+// in a tool call, such as a scheduled policy sync, as a call of tool by the
+// principal "server". A nil Auditor records nothing.
+func (a *Auditor) RecordBackground(tool string, args map[string]any, start time.Time, err error) {
+	if a == nil {
+		return
+	}
+	record := auditRecord{
+		Time:      start.UTC(),
+		Tool:      tool,
+		Arguments: auditArguments(args),
+		Principal: "server",
+		Status:    "ok",
+		LatencyMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		record.Status = "failed"
+		record.Error = redact.String(err.Error())
+	}
+	a.record(record)
+}
+
+// auditArguments returns args with secret values masked.
+func auditArguments(args map[string]any) map[string]any {
+	if len(args) == 0 {
+		return nil
+	}
+	masked := make(map[string]any, len(args))
+	for name, value := range args {
+		if isAuditSecret(name) {
+			value = "[REDACTED]"
+		}
+		masked[name] = value
+	}
+	// Round-trip through redact so Tailscale keys nested anywhere in the
+	// arguments, such as in a policy, are masked as well.
+	raw, err := json.Marshal(masked)
+	if err != nil {
+		return masked
+	}
+	var redacted map[string]any
+	if err := json.Unmarshal([]byte(redact.String(string(raw))), &redacted); err != nil {
+		return masked
+	}
+	return redacted
+}
+
+// errorText returns the text of an error result.
+func errorText(result *mcp.CallToolResult) string {
+	var text []string
+	for _, content := range result.Content {
+		if c, ok := content.(mcp.TextContent); ok {
+			text = append(text, c.Text)
+		}
+	}
+	return strings.Join(text, "\n")
+}
+
+// record chains r to the previous record, appends it to the audit log, and
+// posts it to the audit webhook. Failures are logged rather than failing the
+// call, which has already run.
+func (a *Auditor) record(r auditRecord) {
+	a.mu.Lock()
+	r.PrevHash = a.lastHash
+	line, err := chainRecord(&r)
+	if err != nil {
+		a.mu.Unlock()
+		log.Printf("error: Failed to marshal audit record for %s: %v", r.Tool, err)
+		return
+	}
+	if a.path == "" {
+		a.lastHash = r.Hash
+	} else if err := a.appendLine(line); err != nil {
+		log.Printf("error: Failed to write audit record for %s: %v", r.Tool, err)
+	} else {
+		a.lastHash = r.Hash
+		a.writeAnchor(r.Hash)
+	}
+	a.mu.Unlock()
+
+	if a.webhookURL != "" {
+		go func() {
+			if err := a.post(line); err != nil {
+				log.Printf("error: Failed to send audit record for %s: %v", r.Tool, err)
+			}
+		}()
+	}
+}
+
+// chainRecord sets r.Hash and returns r marshaled with it.
+func chainRecord(r *auditRecord) ([]byte, error) {
+	r.Hash = ""
+	body, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+	r.Hash = hex.EncodeToString(sum[:])
+	return json.Marshal(r)
+}
+
+// startChain appends a chain start covering the lines v read, and chains
+// the following records to it.
+func (a *Auditor) startChain(v auditVerification) error {
+	start := auditChainStart{
+		Time:            time.Now().UTC(),
+		ChainStart:      true,
+		PrecedingLines:  v.lines,
+		PrecedingSHA256: v.sha256,
+	}
+	body, err := json.Marshal(start)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(body)
+	start.Hash = hex.EncodeToString(sum[:])
+	line, err := json.Marshal(start)
+	if err != nil {
+		return err
+	}
+	if err := a.appendLine(line); err != nil {
+		return err
+	}
+	a.lastHash = start.Hash
+	a.writeAnchor(start.Hash)
+	return nil
+}
+
+// writeAnchor records hash as the anchor of the audit log, unless it has
+// one already. The caller holds a.mu, or is NewAuditor.
+func (a *Auditor) writeAnchor(hash string) {
+	if a.anchored {
+		return
+	}
+	f, err := os.OpenFile(auditAnchorPath(a.path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil && !errors.Is(err, fs.ErrExist) {
+		log.Printf("error: Failed to write the chain anchor of audit log %s: %v", a.path, err)
+		return
+	}
+	if err == nil {
+		_, err = fmt.Fprintln(f, hash)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			log.Printf("error: Failed to write the chain anchor of audit log %s: %v", a.path, err)
+			return
+		}
+	}
+	a.anchored = true
+}
+
+// appendLine writes line to the audit log. The caller holds a.mu.
+func (a *Auditor) appendLine(line []byte) error {
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (a *Auditor) post(payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, a.webhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("audit webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// auditVerification is the result of checking an audit log's hash chain.
+type auditVerification struct {
+	File    string `json:"file"`
+	Records int    `json:"records"`
+	Valid   bool   `json:"valid"`
+	// ChainStartLine is the line of the log's chain start, if it has one,
+	// and UnverifiedLines the lines before it, which it fixes by their
+	// SHA-256 but whose records cannot be verified one by one.
+	ChainStartLine  int `json:"chain_start_line,omitempty"`
+	UnverifiedLines int `json:"unverified_lines,omitempty"`
+	// LastHash is the hash of the last record that verified.
+	LastHash     string `json:"last_hash,omitempty"`
+	BrokenAtLine int    `json:"broken_at_line,omitempty"`
+	Problem      string `json:"problem,omitempty"`
+
+	// lines and sha256 are the number and SHA-256 of the lines read,
+	// chained is whether any of them was hash-chained, and firstHash the
+	// hash of the first that was. anchored is whether the log has a chain
+	// anchor.
+	lines     int
+	sha256    string
+	chained   bool
+	firstHash string
+	anchored  bool
+}
+
+func (a *Auditor) Verify(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Hold the lock so no record is half-written while the file is read.
+	a.mu.Lock()
+	verification, err := verifyAuditLog(a.path)
+	a.mu.Unlock()
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read audit log: %v", err)), nil
+	}
+
+	result, err := json.MarshalIndent(verification, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal verification: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+// verifyAuditLog checks that each record of the audit log at path hashes to
+// its hash and links to the record before it, and that the chain begins
+// with the log's anchor. Unchained lines are only accepted before a chain
+// start that fixes them, at the top of the log. It reports the first line
+// that breaks the chain; nothing after it resets that.
+func verifyAuditLog(path string) (auditVerification, error) {
+	v := auditVerification{File: path}
+	anchor, err := readAuditAnchor(path)
+	if err != nil {
+		return v, err
+	}
+	v.anchored = anchor != ""
+	f, err := os.Open(path)
+	if err != nil {
+		return v, err
+	}
+	defer f.Close()
+
+	broken := func(lineNumber int, problem string) {
+		if v.Problem == "" {
+			v.BrokenAtLine, v.Problem = lineNumber, problem
+		}
+	}
+	// chainBegins checks the first chained line, whose hash is hash.
+	chainBegins := func(lineNumber int, hash string) {
+		v.chained, v.firstHash = true, hash
+		if anchor != "" && hash != anchor {
+			broken(lineNumber, "chain does not begin with the log's anchor, so it was replaced")
+		}
+	}
+
+	reader := bufio.NewReader(f)
+	preceding := sha256.New()
+	unchained := 0
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return v, err
+		}
+		precedingSHA256 := hex.EncodeToString(preceding.Sum(nil))
+		preceding.Write(line)
+		v.lines, v.sha256 = lineNumber, hex.EncodeToString(preceding.Sum(nil))
+		line = bytes.TrimSuffix(line, []byte("\n"))
+
+		var start auditChainStart
+		if json.Unmarshal(line, &start) == nil && start.ChainStart {
+			if v.chained {
+				broken(lineNumber, "chain start after hash-chained records, so the chain was restarted")
+				continue
+			}
+			chainBegins(lineNumber, start.Hash)
+			problem := verifyAuditLine(line, "", &start.Hash)
+			if problem == "" && (start.PrecedingLines != lineNumber-1 || start.PrecedingSHA256 != precedingSHA256) {
+				problem = "chain start does not match the lines before it, which were altered"
+			}
+			if problem != "" {
+				broken(lineNumber, problem)
+				continue
+			}
+			v.ChainStartLine, v.UnverifiedLines = lineNumber, unchained
+			if v.Problem == "" {
+				v.Records, v.LastHash = 1, start.Hash
+			}
+			continue
+		}
+		var r struct {
+			PrevHash *string `json:"prev_hash"`
+			Hash     string  `json:"hash"`
+		}
+		isChained := json.Unmarshal(line, &r) == nil && r.PrevHash != nil
+		if !v.chained {
+			if !isChained {
+				// Unverified until a chain start fixes it.
+				unchained++
+				continue
+			}
+			if unchained > 0 {
+				broken(1, "record is not hash-chained")
+			}
+			chainBegins(lineNumber, r.Hash)
+		}
+		if v.Problem != "" {
+			continue
+		}
+		if problem := verifyAuditLine(line, v.LastHash, &v.LastHash); problem != "" {
+			broken(lineNumber, problem)
+			continue
+		}
+		v.Records++
+	}
+	switch {
+	case !v.chained && anchor != "":
+		broken(1, "log has no hash-chained records though it had them, so they were removed or stripped of their chain")
+	case !v.chained && v.lines > 0:
+		broken(1, "record is not hash-chained")
+	}
+	v.Valid = v.Problem == ""
+	return v, nil
+}
+
+// verifyAuditLine checks one record against the hash of the previous one,
+// and stores its own hash in hash. It returns what is wrong, if anything.
+func verifyAuditLine(line []byte, prevHash string, hash *string) string {
+	var r struct {
+		PrevHash *string `json:"prev_hash"`
+		Hash     string  `json:"hash"`
+	}
+	if err := json.Unmarshal(line, &r); err != nil {
+		return fmt.Sprintf("not a JSON record: %v", err)
+	}
+	if r.PrevHash == nil || r.Hash == "" {
+		return "record is not hash-chained"
+	}
+	suffix := []byte(fmt.Sprintf(`,"hash":%q}`, r.Hash))
+	if !bytes.HasSuffix(line, suffix) {
+		return "hash is not the last field of the record"
+	}
+	body := append(bytes.TrimSuffix(line, suffix), '}')
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != r.Hash {
+		return "record was modified: its content does not match its hash"
+	}
+	if *r.PrevHash != prevHash {
+		return "chain is broken: prev_hash does not match the previous record, which was removed or altered"
+	}
+	*hash = r.Hash
+	return ""
+}
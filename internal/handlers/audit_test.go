@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// writeAuditLog records n calls to a new audit log and returns its path.
+func writeAuditLog(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	a := NewAuditor(&config.Config{AuditLog: path}, nil)
+	for i := 0; i < n; i++ {
+		a.record(auditRecord{Tool: "tailscale_device_list", Principal: "default", Role: "admin", Status: "ok"})
+	}
+	return path
+}
+
+func readAuditLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func writeAuditLines(t *testing.T, path string, lines []string) {
+	t.Helper()
+	content := strings.Join(lines, "")
+	if !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// forgeChainStart appends a valid-looking chain start over the current
+// content of the log, as a writer of the file could.
+func forgeChainStart(t *testing.T, path string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	forger := &Auditor{path: path, anchored: true}
+	v := auditVerification{lines: strings.Count(string(data), "\n"), sha256: hex.EncodeToString(sum[:])}
+	if err := forger.startChain(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func verifyAudit(t *testing.T, path string) auditVerification {
+	t.Helper()
+	v, err := verifyAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVerifyAuditLogValid(t *testing.T) {
+	path := writeAuditLog(t, 3)
+	v := verifyAudit(t, path)
+	if !v.Valid || v.Records != 3 {
+		t.Errorf("got %+v, want a valid log of 3 records", v)
+	}
+
+	// Restarting continues the chain.
+	a := NewAuditor(&config.Config{AuditLog: path}, nil)
+	a.record(auditRecord{Tool: "tailscale_device_list", Status: "ok"})
+	if v := verifyAudit(t, path); !v.Valid || v.Records != 4 {
+		t.Errorf("after a restart got %+v, want a valid log of 4 records", v)
+	}
+}
+
+func TestVerifyAuditLogTamperedLine(t *testing.T) {
+	path := writeAuditLog(t, 3)
+	lines := readAuditLines(t, path)
+	lines[1] = strings.Replace(lines[1], "tailscale_device_list", "tailscale_device_delete", 1)
+	writeAuditLines(t, path, lines)
+
+	v := verifyAudit(t, path)
+	if v.Valid || v.BrokenAtLine != 2 || !strings.Contains(v.Problem, "modified") {
+		t.Errorf("got %+v, want a modified record at line 2", v)
+	}
+}
+
+func TestVerifyAuditLogDeletedLine(t *testing.T) {
+	path := writeAuditLog(t, 3)
+	lines := readAuditLines(t, path)
+	writeAuditLines(t, path, []string{lines[0], lines[2]})
+
+	v := verifyAudit(t, path)
+	if v.Valid || v.BrokenAtLine != 2 || !strings.Contains(v.Problem, "chain is broken") {
+		t.Errorf("got %+v, want a broken chain at line 2", v)
+	}
+}
+
+func TestVerifyAuditLogStrippedChain(t *testing.T) {
+	path := writeAuditLog(t, 3)
+	lines := readAuditLines(t, path)
+	for i, line := range lines {
+		line = line[:strings.Index(line, `,"prev_hash"`)] + "}\n"
+		lines[i] = strings.Replace(line, "tailscale_device_list", "tailscale_device_delete", 1)
+	}
+	writeAuditLines(t, path, lines)
+
+	if v := verifyAudit(t, path); v.Valid || v.BrokenAtLine != 1 {
+		t.Errorf("got %+v, want the log to fail at line 1", v)
+	}
+
+	// A restart must not start a chain over the stripped records.
+	NewAuditor(&config.Config{AuditLog: path}, nil)
+	if got := readAuditLines(t, path); len(got) != len(lines) {
+		t.Errorf("restart appended %d lines to a stripped log", len(got)-len(lines))
+	}
+	if v := verifyAudit(t, path); v.Valid {
+		t.Errorf("got %+v after a restart, want the log to stay invalid", v)
+	}
+}
+
+func TestVerifyAuditLogForgedChainStart(t *testing.T) {
+	t.Run("over stripped records", func(t *testing.T) {
+		path := writeAuditLog(t, 3)
+		lines := readAuditLines(t, path)
+		for i, line := range lines {
+			lines[i] = line[:strings.Index(line, `,"prev_hash"`)] + "}\n"
+		}
+		writeAuditLines(t, path, lines)
+		forgeChainStart(t, path)
+
+		if v := verifyAudit(t, path); v.Valid || v.BrokenAtLine != 4 {
+			t.Errorf("got %+v, want the forged chain start at line 4 to fail", v)
+		}
+	})
+
+	t.Run("after chained records", func(t *testing.T) {
+		path := writeAuditLog(t, 3)
+		lines := readAuditLines(t, path)
+		lines[0] = strings.Replace(lines[0], "tailscale_device_list", "tailscale_device_delete", 1)
+		writeAuditLines(t, path, lines)
+		forgeChainStart(t, path)
+
+		v := verifyAudit(t, path)
+		if v.Valid || v.BrokenAtLine != 1 {
+			t.Errorf("got %+v, want the edit at line 1 to stay reported", v)
+		}
+	})
+
+	t.Run("without an anchor", func(t *testing.T) {
+		path := writeAuditLog(t, 3)
+		if err := os.Remove(auditAnchorPath(path)); err != nil {
+			t.Fatal(err)
+		}
+		forgeChainStart(t, path)
+
+		if v := verifyAudit(t, path); v.Valid || v.BrokenAtLine != 4 {
+			t.Errorf("got %+v, want the chain start after chained records to fail", v)
+		}
+	})
+}
+
+func TestVerifyAuditLogLegacyUpgrade(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	legacy := []string{
+		`{"time":"2026-01-01T00:00:00Z","tool":"tailscale_device_list","principal":"default","role":"admin","status":"ok","latency_ms":1}` + "\n",
+		`{"time":"2026-01-01T00:01:00Z","tool":"tailscale_device_delete","principal":"default","role":"admin","status":"ok","latency_ms":1}` + "\n",
+	}
+	writeAuditLines(t, path, legacy)
+	if v := verifyAudit(t, path); v.Valid {
+		t.Errorf("got %+v, want an unchained log to fail before it is upgraded", v)
+	}
+
+	a := NewAuditor(&config.Config{AuditLog: path}, nil)
+	a.record(auditRecord{Tool: "tailscale_device_list", Status: "ok"})
+	v := verifyAudit(t, path)
+	if !v.Valid || v.ChainStartLine != 3 || v.UnverifiedLines != 2 || v.Records != 2 {
+		t.Errorf("got %+v, want a valid log with a chain start at line 3 after 2 unverified lines", v)
+	}
+
+	// The upgrade happens once: another restart continues the chain.
+	a = NewAuditor(&config.Config{AuditLog: path}, nil)
+	a.record(auditRecord{Tool: "tailscale_device_list", Status: "ok"})
+	if got := readAuditLines(t, path); len(got) != 5 {
+		t.Errorf("got %d lines after a second restart, want 5", len(got))
+	}
+
+	lines := readAuditLines(t, path)
+	lines[1] = strings.Replace(lines[1], "tailscale_device_delete", "tailscale_device_list", 1)
+	writeAuditLines(t, path, lines)
+	if v := verifyAudit(t, path); v.Valid || v.BrokenAtLine != 3 {
+		t.Errorf("got %+v, want an edited legacy line to fail the chain start at line 3", v)
+	}
+}
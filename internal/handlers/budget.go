@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// budgetDestructive in the configured budgets stands for every tool
+// annotated as destructive, counted together.
+const budgetDestructive = "destructive"
+
+// budgetRequests are the API requests that change one object the way a
+// tool does, keyed by the tool. The budget of such a tool counts these
+// requests whichever tool makes them, so a bulk deletion or a changeset
+// uses up the budget of the single-object tool once per object it changes.
+var budgetRequests = map[string]*regexp.Regexp{
+	"tailscale_device_delete":           regexp.MustCompile(`^DELETE /api/v2/device/[^/]+$`),
+	"tailscale_device_expire":           regexp.MustCompile(`^POST /api/v2/device/[^/]+/expire$`),
+	"tailscale_device_authorize":        regexp.MustCompile(`^POST /api/v2/device/[^/]+/authorized$`),
+	"tailscale_device_set_name":         regexp.MustCompile(`^POST /api/v2/device/[^/]+/name$`),
+	"tailscale_device_set_tags":         regexp.MustCompile(`^POST /api/v2/device/[^/]+/tags$`),
+	"tailscale_device_routes_set":       regexp.MustCompile(`^POST /api/v2/device/[^/]+/routes$`),
+	"tailscale_key_create":              regexp.MustCompile(`^POST /api/v2/tailnet/[^/]+/keys$`),
+	"tailscale_key_delete":              regexp.MustCompile(`^DELETE /api/v2/tailnet/[^/]+/keys/[^/]+$`),
+	"tailscale_user_suspend":            regexp.MustCompile(`^POST /api/v2/users/[^/]+/suspend$`),
+	"tailscale_user_delete":             regexp.MustCompile(`^POST /api/v2/users/[^/]+/delete$`),
+	"tailscale_user_invite_delete":      regexp.MustCompile(`^DELETE /api/v2/user-invites/[^/]+$`),
+	"tailscale_webhook_delete":          regexp.MustCompile(`^DELETE /api/v2/webhooks/[^/]+$`),
+	"tailscale_service_delete":          regexp.MustCompile(`^DELETE /api/v2/tailnet/[^/]+/vip-services/[^/]+$`),
+	"tailscale_policy_set":              regexp.MustCompile(`^POST /api/v2/tailnet/[^/]+/acl$`),
+	"tailscale_tailnet_settings_update": regexp.MustCompile(`^PATCH /api/v2/tailnet/[^/]+/settings$`),
+	"tailscale_dns_nameservers_set":     regexp.MustCompile(`^POST /api/v2/tailnet/[^/]+/dns/nameservers$`),
+	"tailscale_dns_searchpaths_set":     regexp.MustCompile(`^POST /api/v2/tailnet/[^/]+/dns/searchpaths$`),
+	"tailscale_dns_split_set":           regexp.MustCompile(`^(PUT|PATCH) /api/v2/tailnet/[^/]+/dns/split-dns$`),
+}
+
+// Budgets caps how many changes of the configured kinds each session may
+// make within a sliding window, such as five device deletions and one
+// policy set per hour, so a runaway agent cannot empty the tailnet before
+// someone notices. Changes are counted per object, however many objects a
+// call changes, and changes over budget are refused with the caller told
+// to hand over to a human.
+type Budgets struct {
+	limits map[string]int
+	window time.Duration
+
+	mu sync.Mutex
+	// changes holds, per session and budget, when the changes counted
+	// against the budget were made.
+	changes map[string]map[string][]time.Time
+}
+
+func NewBudgets(cfg *config.Config) *Budgets {
+	return &Budgets{
+		limits:  cfg.ToolBudgets,
+		window:  cfg.ToolBudgetWindow,
+		changes: make(map[string]map[string][]time.Time),
+	}
+}
+
+// budgetCall tracks the API writes of one call.
+type budgetCall struct {
+	budgets *Budgets
+	server  *server.MCPServer
+	session string
+
+	mu sync.Mutex
+	// writes and applied count the call's write requests and those that
+	// succeeded; destructive counts the applied ones that were counted
+	// against the destructive budget.
+	writes, applied, destructive int
+}
+
+// Middleware refuses changes that would exceed one of their session's
+// budgets: calls of a tool whose budget is used up before they run, and
+// API requests of any call that change an object of a kind whose budget is
+// used up as they are made. Register it after the Confirmer, so calls the
+// user declines are not counted; dry runs and requests that fail are not
+// counted either, since they change nothing.
+func (b *Budgets) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mcpServer := server.ServerFromContext(ctx)
+		if len(b.limits) == 0 || mcpServer == nil || client.IsDryRun(ctx) {
+			return next(ctx, request)
+		}
+		name := request.Params.Name
+		session := ""
+		if s := server.ClientSessionFromContext(ctx); s != nil {
+			session = s.SessionID()
+		}
+
+		// Budgets of tools whose requests are not recognized count the call
+		// itself. Other budgets count requests as they are made, so the call
+		// is only refused when they are already used up. The destructive
+		// budget counts a destructive call once it has run if none of its
+		// requests were counted.
+		_, limitsDestructive := b.limits[budgetDestructive]
+		destructive := limitsDestructive && isDestructive(mcpServer, name)
+		var perCall, perRequest []string
+		if _, ok := b.limits[name]; ok && budgetRequests[name] == nil {
+			perCall = append(perCall, name)
+		} else if ok {
+			perRequest = append(perRequest, name)
+		}
+		if destructive {
+			perRequest = append(perRequest, budgetDestructive)
+		}
+		now := time.Now()
+		exceeded, ok := b.reserve(session, perCall, now)
+		if ok {
+			if exceeded, ok = b.usedUp(session, perRequest, now); !ok {
+				b.release(session, perCall, now)
+			}
+		}
+		if !ok {
+			log.Printf("warning: Refused %s: session over its %s budget of %d per %s", name, exceeded, b.limits[exceeded], b.window)
+			return mcp.NewToolResultError(fmt.Sprintf(
+				"Tool %s was not run: this session has used its budget of %d %s changes per %s. Stop and ask a human to review the changes made so far; they can make further changes themselves or raise the budget.",
+				name, b.limits[exceeded], exceeded, b.window)), nil
+		}
+
+		call := &budgetCall{budgets: b, server: mcpServer, session: session}
+		result, err := next(client.WithWriteGate(ctx, call.gate), request)
+
+		call.mu.Lock()
+		// A call that made no requests the gate saw, such as one that writes
+		// elsewhere, changed something if it succeeded.
+		changed := call.applied > 0 || (call.writes == 0 && err == nil && result != nil && !result.IsError)
+		countedDestructive := call.destructive > 0
+		call.mu.Unlock()
+		if !changed {
+			b.release(session, perCall, now)
+		} else if destructive && !countedDestructive {
+			b.add(session, budgetDestructive, time.Now())
+		}
+		return result, err
+	}
+}
+
+// gate counts a write request of the call against the budgets of the
+// objects it changes, refusing it if one of them is used up.
+func (c *budgetCall) gate(method, path string) (func(bool), error) {
+	b := c.budgets
+	request := method + " " + path
+	var matched []string
+	destructive := false
+	for tool, pattern := range budgetRequests {
+		if !pattern.MatchString(request) {
+			continue
+		}
+		if _, ok := b.limits[tool]; ok {
+			matched = append(matched, tool)
+		}
+		if _, ok := b.limits[budgetDestructive]; ok && isDestructive(c.server, tool) && !destructive {
+			matched = append(matched, budgetDestructive)
+			destructive = true
+		}
+	}
+
+	now := time.Now()
+	if exceeded, ok := b.reserve(c.session, matched, now); !ok {
+		log.Printf("warning: Refused %s: session over its %s budget of %d per %s", request, exceeded, b.limits[exceeded], b.window)
+		return nil, fmt.Errorf("this session has used its budget of %d %s changes per %s; stop and ask a human to review the changes made so far", b.limits[exceeded], exceeded, b.window)
+	}
+	return func(ok bool) {
+		c.mu.Lock()
+		c.writes++
+		if ok {
+			c.applied++
+			if destructive {
+				c.destructive++
+			}
+		}
+		c.mu.Unlock()
+		if !ok {
+			b.release(c.session, matched, now)
+		}
+	}, nil
+}
+
+func isDestructive(mcpServer *server.MCPServer, name string) bool {
+	tool := mcpServer.GetTool(name)
+	if tool == nil {
+		return false
+	}
+	destructive := tool.Tool.Annotations.DestructiveHint
+	return destructive == nil || *destructive
+}
+
+// reserve counts a change made at now against each of budgets, unless one
+// of them is used up, in which case it returns that budget and false.
+func (b *Budgets) reserve(session string, budgets []string, now time.Time) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.prune(now)
+	used := b.changes[session]
+	for _, budget := range budgets {
+		if len(used[budget]) >= b.limits[budget] {
+			return budget, false
+		}
+	}
+	if len(budgets) == 0 {
+		return "", true
+	}
+	if used == nil {
+		used = make(map[string][]time.Time)
+		b.changes[session] = used
+	}
+	for _, budget := range budgets {
+		used[budget] = append(used[budget], now)
+	}
+	return "", true
+}
+
+// add counts a change made at now against budget, used or not.
+func (b *Budgets) add(session, budget string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.changes[session] == nil {
+		b.changes[session] = make(map[string][]time.Time)
+	}
+	b.changes[session][budget] = append(b.changes[session][budget], now)
+}
+
+// usedUp returns a budget the session has no changes left in, if any.
+func (b *Budgets) usedUp(session string, budgets []string, now time.Time) (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.prune(now)
+	for _, budget := range budgets {
+		if len(b.changes[session][budget]) >= b.limits[budget] {
+			return budget, false
+		}
+	}
+	return "", true
+}
+
+// prune forgets changes that have left the window, and sessions with none
+// left. The caller holds b.mu.
+func (b *Budgets) prune(now time.Time) {
+	for session, used := range b.changes {
+		for budget, times := range used {
+			times = slices.DeleteFunc(times, func(t time.Time) bool {
+				return now.Sub(t) >= b.window
+			})
+			if len(times) == 0 {
+				delete(used, budget)
+			} else {
+				used[budget] = times
+			}
+		}
+		if len(used) == 0 {
+			delete(b.changes, session)
+		}
+	}
+}
+
+// release takes back a change reserved at now.
+func (b *Budgets) release(session string, budgets []string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, budget := range budgets {
+		times := b.changes[session][budget]
+		if i := slices.Index(times, now); i >= 0 {
+			b.changes[session][budget] = slices.Delete(times, i, i+1)
+		}
+	}
+}
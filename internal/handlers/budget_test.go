@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// newBudgetServer returns a server with budgets and dry runs, and tools
+// that change devices through tc: one device, several, or a changeset of
+// tag changes.
+func newBudgetServer(t *testing.T, tc *client.TailscaleClient, budgets map[string]int) *server.MCPServer {
+	t.Helper()
+	cfg := &config.Config{ToolBudgets: budgets, ToolBudgetWindow: time.Hour}
+	mcpServer := server.NewMCPServer("test", "1.0.0",
+		server.WithToolCapabilities(true),
+		server.WithToolHandlerMiddleware(NewDryRunner(cfg).Middleware),
+		server.WithToolHandlerMiddleware(NewBudgets(cfg).Middleware),
+	)
+	destructive := mcp.WithDestructiveHintAnnotation(true)
+	fail := func(err error) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	mcpServer.AddTool(mcp.NewTool("tailscale_device_delete", destructive), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := tc.GetClient().Devices().Delete(ctx, request.GetString("device_id", "")); err != nil {
+			return fail(err)
+		}
+		return mcp.NewToolResultText("deleted"), nil
+	})
+	mcpServer.AddTool(mcp.NewTool("tailscale_devices_delete_bulk", destructive), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		for i, id := range request.GetStringSlice("device_ids", nil) {
+			if err := tc.GetClient().Devices().Delete(ctx, id); err != nil {
+				return fail(fmt.Errorf("deleted %d devices, then: %w", i, err))
+			}
+		}
+		return mcp.NewToolResultText("deleted"), nil
+	})
+	mcpServer.AddTool(mcp.NewTool("tailscale_apply_changeset", destructive), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		for i, id := range request.GetStringSlice("device_ids", nil) {
+			if err := tc.GetClient().Devices().SetTags(ctx, id, []string{"tag:server"}); err != nil {
+				return fail(fmt.Errorf("applied %d steps, then: %w", i, err))
+			}
+		}
+		return mcp.NewToolResultText("applied"), nil
+	})
+	return mcpServer
+}
+
+func TestBudgetSingleCalls(t *testing.T) {
+	tc, api := newTestAPI(t)
+	s := newBudgetServer(t, tc, map[string]int{"tailscale_device_delete": 2})
+
+	for i, id := range []string{"d1", "d2"} {
+		if result := callTool(t, s, nil, "tailscale_device_delete", map[string]any{"device_id": id}); result.IsError {
+			t.Fatalf("delete %d was refused: %s", i+1, resultText(result))
+		}
+	}
+	result := callTool(t, s, nil, "tailscale_device_delete", map[string]any{"device_id": "d3"})
+	if !result.IsError || !strings.Contains(resultText(result), "budget of 2 tailscale_device_delete changes") {
+		t.Errorf("third delete got %q, want it refused over budget", resultText(result))
+	}
+	if n := api.count("DELETE /api/v2/device/d3"); n != 0 {
+		t.Errorf("the refused delete was sent %d times", n)
+	}
+}
+
+func TestBudgetSessions(t *testing.T) {
+	tc, _ := newTestAPI(t)
+	s := newBudgetServer(t, tc, map[string]int{"tailscale_device_delete": 1})
+
+	if result := callTool(t, s, newTestSession("a"), "tailscale_device_delete", map[string]any{"device_id": "d1"}); result.IsError {
+		t.Fatalf("session a's delete was refused: %s", resultText(result))
+	}
+	if result := callTool(t, s, newTestSession("b"), "tailscale_device_delete", map[string]any{"device_id": "d2"}); result.IsError {
+		t.Errorf("session b's delete was refused by session a's budget: %s", resultText(result))
+	}
+	if result := callTool(t, s, newTestSession("a"), "tailscale_device_delete", map[string]any{"device_id": "d3"}); !result.IsError {
+		t.Errorf("session a's second delete was not refused")
+	}
+}
+
+func TestBudgetBulk(t *testing.T) {
+	tc, api := newTestAPI(t)
+	s := newBudgetServer(t, tc, map[string]int{"tailscale_device_delete": 2})
+
+	result := callTool(t, s, nil, "tailscale_devices_delete_bulk", map[string]any{"device_ids": []any{"d1", "d2", "d3"}})
+	if !result.IsError || !strings.Contains(resultText(result), "deleted 2 devices") {
+		t.Errorf("bulk delete got %q, want it stopped after 2 devices", resultText(result))
+	}
+	if n := api.count("DELETE /api/v2/device/d3"); n != 0 {
+		t.Errorf("the delete over budget was sent %d times", n)
+	}
+
+	// The bulk call used up the single-device tool's budget too.
+	if result := callTool(t, s, nil, "tailscale_device_delete", map[string]any{"device_id": "d4"}); !result.IsError {
+		t.Errorf("delete after the bulk call was not refused")
+	}
+}
+
+func TestBudgetChangeset(t *testing.T) {
+	tc, api := newTestAPI(t)
+	s := newBudgetServer(t, tc, map[string]int{"tailscale_device_set_tags": 1})
+
+	result := callTool(t, s, nil, "tailscale_apply_changeset", map[string]any{"device_ids": []any{"d1", "d2"}})
+	if !result.IsError || !strings.Contains(resultText(result), "applied 1 steps") {
+		t.Errorf("changeset got %q, want it stopped after one tag change", resultText(result))
+	}
+	if n := api.count("POST /api/v2/device/d2/tags"); n != 0 {
+		t.Errorf("the tag change over budget was sent %d times", n)
+	}
+}
+
+func TestBudgetDestructive(t *testing.T) {
+	tc, api := newTestAPI(t)
+	s := newBudgetServer(t, tc, map[string]int{budgetDestructive: 2})
+
+	// Each object the bulk deletion changes counts once.
+	result := callTool(t, s, nil, "tailscale_devices_delete_bulk", map[string]any{"device_ids": []any{"d1", "d2", "d3"}})
+	if !result.IsError || !strings.Contains(resultText(result), "budget of 2 destructive changes") {
+		t.Errorf("bulk delete got %q, want it refused over the destructive budget", resultText(result))
+	}
+	if n := api.count("DELETE /api/v2/device/d3"); n != 0 {
+		t.Errorf("the delete over budget was sent %d times", n)
+	}
+	if result := callTool(t, s, nil, "tailscale_apply_changeset", map[string]any{"device_ids": []any{"d1"}}); !result.IsError {
+		t.Errorf("destructive call after the budget was used up was not refused")
+	}
+}
+
+func TestBudgetDryRun(t *testing.T) {
+	tc, api := newTestAPI(t)
+	s := newBudgetServer(t, tc, map[string]int{"tailscale_device_delete": 1, budgetDestructive: 1})
+
+	for range 3 {
+		result := callTool(t, s, nil, "tailscale_devices_delete_bulk", map[string]any{"device_ids": []any{"d1", "d2"}, "dry_run": true})
+		if result.IsError {
+			t.Fatalf("dry run was refused: %s", resultText(result))
+		}
+	}
+	if n := api.count("DELETE /api/v2/device/d1"); n != 0 {
+		t.Errorf("dry runs sent %d deletes", n)
+	}
+	if result := callTool(t, s, nil, "tailscale_device_delete", map[string]any{"device_id": "d1"}); result.IsError {
+		t.Errorf("dry runs used up the budget: %s", resultText(result))
+	}
+}
+
+func TestBudgetFailedRequests(t *testing.T) {
+	tc, api := newTestAPI(t)
+	s := newBudgetServer(t, tc, map[string]int{"tailscale_device_delete": 1})
+
+	api.setStatus(func(r *http.Request) int { return http.StatusInternalServerError })
+	if result := callTool(t, s, nil, "tailscale_device_delete", map[string]any{"device_id": "d1"}); !result.IsError {
+		t.Fatalf("delete succeeded against a failing API")
+	}
+	api.setStatus(nil)
+	if result := callTool(t, s, nil, "tailscale_device_delete", map[string]any{"device_id": "d1"}); result.IsError {
+		t.Errorf("a failed request used up the budget: %s", resultText(result))
+	}
+}
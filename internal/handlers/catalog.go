@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/pkg/tools"
+)
+
+// Reasons a tool can be hidden for.
+const (
+	hiddenReadOnly    = "read_only"
+	hiddenUnavailable = "unavailable"
+)
+
+// Catalog decides which registered tools are offered to clients. Tools are
+// hidden for a reason, such as read-only mode or a feature the tailnet's plan
+// lacks; a tool is offered while no reason hides it. Whenever the offered set
+// changes, clients are sent notifications/tools/list_changed so they refresh
+// their tool list instead of calling tools that are gone.
+type Catalog struct {
+	mu        sync.RWMutex
+	mcpServer *server.MCPServer
+	tools     map[string]mcp.Tool
+	hidden    map[string]map[string]bool
+}
+
+func NewCatalog() *Catalog {
+	return &Catalog{hidden: make(map[string]map[string]bool)}
+}
+
+// Load records the tools registered on mcpServer. Call it once, after all
+// tools are registered and before anything is hidden.
+func (c *Catalog) Load(mcpServer *server.MCPServer) {
+	registered := mcpServer.ListTools()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mcpServer = mcpServer
+	c.tools = make(map[string]mcp.Tool, len(registered))
+	for name, tool := range registered {
+		c.tools[name] = tool.Tool
+	}
+}
+
+// Filter removes hidden tools from tools/list results; register it with
+// server.WithToolFilter.
+func (c *Catalog) Filter(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.DeleteFunc(tools, func(tool mcp.Tool) bool { return c.hiddenLocked(tool.Name) != "" })
+}
+
+// Middleware refuses calls to hidden tools, which clients may still have in
+// a tool list fetched before the tool was hidden.
+func (c *Catalog) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		c.mu.RLock()
+		reason := c.hiddenLocked(request.Params.Name)
+		c.mu.RUnlock()
+		switch reason {
+		case "":
+			return next(ctx, request)
+		case hiddenReadOnly:
+			return mcp.NewToolResultError(fmt.Sprintf("Tool %s is disabled: the server is in read-only mode", request.Params.Name)), nil
+		default:
+			return mcp.NewToolResultError(fmt.Sprintf("Tool %s is disabled: the feature it needs is not available on this tailnet", request.Params.Name)), nil
+		}
+	}
+}
+
+// hiddenLocked returns a reason name is hidden for, or "".
+func (c *Catalog) hiddenLocked(name string) string {
+	for _, reason := range slices.Sorted(maps.Keys(c.hidden)) {
+		if c.hidden[reason][name] {
+			return reason
+		}
+	}
+	return ""
+}
+
+// Hide sets the tools hidden for reason, replacing those it hid before, and
+// notifies clients if the offered tools changed.
+func (c *Catalog) Hide(reason string, names []string) {
+	c.mu.Lock()
+	before := c.offeredLocked()
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	c.hidden[reason] = set
+	after := c.offeredLocked()
+	mcpServer, total := c.mcpServer, len(c.tools)
+	c.mu.Unlock()
+
+	if slices.Equal(before, after) || mcpServer == nil {
+		return
+	}
+	log.Printf("Offering %d of %d tools (%s changed)", len(after), total, reason)
+	mcpServer.SendNotificationToAllClients(mcp.MethodNotificationToolsListChanged, nil)
+}
+
+func (c *Catalog) offeredLocked() []string {
+	var names []string
+	for _, name := range slices.Sorted(maps.Keys(c.tools)) {
+		if c.hiddenLocked(name) == "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// SetReadOnly hides every tool not annotated as read-only, or shows them
+// again.
+func (c *Catalog) SetReadOnly(on bool) {
+	var names []string
+	if on {
+		c.mu.RLock()
+		for name, tool := range c.tools {
+			if readOnly := tool.Annotations.ReadOnlyHint; readOnly == nil || !*readOnly {
+				names = append(names, name)
+			}
+		}
+		c.mu.RUnlock()
+	}
+	c.Hide(hiddenReadOnly, names)
+}
+
+// ProbeFeatures hides the tools of features the tailnet lacks, checking
+// immediately and then on every tick until ctx is done, so tools appear once
+// a plan upgrade or new credentials make their feature available.
+func (h *Handler) ProbeFeatures(ctx context.Context, catalog *Catalog) {
+	apiTools := tools.NewAPITools(h.client, h.config)
+	ticker := time.NewTicker(h.config.FeatureProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		catalog.Hide(hiddenUnavailable, apiTools.UnavailableTools(ctx))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
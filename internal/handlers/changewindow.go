@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/changewindow"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// ChangeWindows keeps changes to the tailnet inside the approved change
+// windows: outside them, calls of mutating tools are refused with when the
+// next window opens. Read-only tools are always available.
+type ChangeWindows struct {
+	schedule *changewindow.Schedule
+}
+
+func NewChangeWindows(cfg *config.Config) *ChangeWindows {
+	return &ChangeWindows{schedule: cfg.ChangeWindows}
+}
+
+// Middleware refuses mutating calls made outside the change windows.
+// Register it after the DryRunner, since dry runs change nothing and are
+// allowed at any time, and before the Confirmer, so the user is not asked
+// to confirm calls that will be refused. Register it again after the
+// Approvals too: waiting for a confirmation or an approval can outlast the
+// window, so the call is checked once more just before it runs.
+func (w *ChangeWindows) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mcpServer := server.ServerFromContext(ctx)
+		if w.schedule == nil || mcpServer == nil || client.IsDryRun(ctx) {
+			return next(ctx, request)
+		}
+		name := request.Params.Name
+		tool := mcpServer.GetTool(name)
+		if tool == nil {
+			return next(ctx, request)
+		}
+		if readOnly := tool.Tool.Annotations.ReadOnlyHint; readOnly != nil && *readOnly {
+			return next(ctx, request)
+		}
+
+		now := time.Now()
+		if w.schedule.Open(now) {
+			return next(ctx, request)
+		}
+		log.Printf("warning: Refused %s outside the change windows", name)
+		message := fmt.Sprintf("Tool %s was not run: changes are only allowed during the change windows %s.", name, w.schedule)
+		if opens, ok := w.schedule.Next(now); ok {
+			message += fmt.Sprintf(" The next window opens at %s; try again then, or use dry_run to preview the change now.", opens.Format(time.RFC3339))
+		}
+		return mcp.NewToolResultError(message), nil
+	}
+}
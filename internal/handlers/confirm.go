@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+const (
+	// confirmDestructive in the configured tool list stands for every tool
+	// annotated as destructive.
+	confirmDestructive = "destructive"
+	// confirmTimeout is how long the user has to answer a confirmation.
+	confirmTimeout = 5 * time.Minute
+	// maxArgumentLength is the longest argument value quoted in a
+	// confirmation; longer values, such as policy files, are summarized.
+	maxArgumentLength = 120
+)
+
+// confirmSchema is the form shown to the user: one checkbox.
+var confirmSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"confirm": map[string]any{
+			"type":        "boolean",
+			"title":       "Run this tool",
+			"description": "Check to let the tool make this change",
+		},
+	},
+	"required": []string{"confirm"},
+}
+
+// targetArguments are the arguments whose values are looked up to describe
+// what a call affects.
+var targetArguments = []string{"device_id", "user_id", "key_id", "endpoint_id"}
+
+// Confirmer asks the end user, through MCP elicitation, to confirm calls to
+// the configured tools before they run, describing what the call affects.
+type Confirmer struct {
+	describer
+	tools toolSet
+}
+
+func NewConfirmer(client *client.TailscaleClient, cfg *config.Config) *Confirmer {
+	return &Confirmer{describer: describer{client: client}, tools: newToolSet(cfg.ConfirmTools)}
+}
+
+// toolSet is a configured list of tool names, in which "destructive" stands
+// for every tool annotated as destructive.
+type toolSet struct {
+	names       map[string]bool
+	destructive bool
+}
+
+func newToolSet(names []string) toolSet {
+	s := toolSet{names: make(map[string]bool)}
+	for _, name := range names {
+		if name == confirmDestructive {
+			s.destructive = true
+		} else {
+			s.names[name] = true
+		}
+	}
+	return s
+}
+
+// contains reports whether the named tool is in the set.
+func (s toolSet) contains(mcpServer *server.MCPServer, name string) bool {
+	if s.names[name] {
+		return true
+	}
+	if !s.destructive {
+		return false
+	}
+	tool := mcpServer.GetTool(name)
+	if tool == nil {
+		return false
+	}
+	destructive := tool.Tool.Annotations.DestructiveHint
+	return destructive == nil || *destructive
+}
+
+// describer describes tool calls to the people asked to let them run.
+type describer struct {
+	client *client.TailscaleClient
+}
+
+// Middleware holds calls to the configured tools until the user confirms
+// them. Calls the user declines, or that cannot be confirmed because the
+// client does not support elicitation, are refused. Dry runs change nothing
+// and are not held.
+func (c *Confirmer) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.Params.Name
+		mcpServer := server.ServerFromContext(ctx)
+		if mcpServer == nil || client.IsDryRun(ctx) || !c.tools.contains(mcpServer, name) {
+			return next(ctx, request)
+		}
+
+		if session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo); !ok || session.GetClientCapabilities().Elicitation == nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Tool %s requires confirmation by the user, but this client does not support elicitation", name)), nil
+		}
+
+		elicitCtx, cancel := context.WithTimeout(ctx, confirmTimeout)
+		defer cancel()
+		result, err := mcpServer.RequestElicitation(elicitCtx, mcp.ElicitationRequest{
+			Params: mcp.ElicitationParams{
+				Message:         c.describe(ctx, request),
+				RequestedSchema: confirmSchema,
+			},
+		})
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get confirmation for %s: %v", name, err)), nil
+		}
+		if content, _ := result.Content.(map[string]any); result.Action != mcp.ElicitationResponseActionAccept || content["confirm"] != true {
+			return mcp.NewToolResultError(fmt.Sprintf("Tool %s was not run: the user did not confirm it", name)), nil
+		}
+		return next(ctx, request)
+	}
+}
+
+// describe returns the question put to the user, such as "Really run
+// tailscale_device_delete on device laptop (owner alice@example.com, last
+// seen 2h ago)?", followed by the call's other arguments.
+func (d describer) describe(ctx context.Context, request mcp.CallToolRequest) string {
+	args := request.GetArguments()
+
+	var targets []string
+	for _, argument := range targetArguments {
+		if id, ok := args[argument].(string); ok && id != "" {
+			targets = append(targets, d.describeTarget(ctx, argument, id))
+		}
+	}
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "Really run %s", request.Params.Name)
+	if len(targets) > 0 {
+		fmt.Fprintf(&message, " on %s", strings.Join(targets, " and "))
+	}
+	message.WriteString("?")
+
+	names := make([]string, 0, len(args))
+	for name := range args {
+		if !slices.Contains(targetArguments, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := fmt.Sprint(args[name])
+		if len(value) > maxArgumentLength {
+			value = fmt.Sprintf("(%d characters)", len(value))
+		}
+		fmt.Fprintf(&message, "\n%s: %s", name, value)
+	}
+	return message.String()
+}
+
+// describeTarget names the device, user, key, or webhook id refers to, with
+// the details that tell it apart from similar ones. Lookup failures fall back
+// to the bare ID, since they must not keep the user from being asked.
+func (d describer) describeTarget(ctx context.Context, argument, id string) string {
+	tsClient := d.client.GetClient()
+	switch argument {
+	case "device_id":
+		device, err := tsClient.Devices().Get(ctx, id)
+		if err != nil {
+			break
+		}
+		return fmt.Sprintf("device %s (owner %s, %s)", device.Name, device.User, lastSeen(device.LastSeen.Time, false))
+	case "user_id":
+		user, err := tsClient.Users().Get(ctx, id)
+		if err != nil {
+			break
+		}
+		return fmt.Sprintf("user %s (%s, %d devices, %s)", user.LoginName, user.Role, user.DeviceCount, lastSeen(user.LastSeen, user.CurrentlyConnected))
+	case "key_id":
+		key, err := tsClient.Keys().Get(ctx, id)
+		if err != nil {
+			break
+		}
+		description := key.Description
+		if description == "" {
+			description = "no description"
+		}
+		return fmt.Sprintf("key %s (%s, created %s)", key.ID, description, key.Created.Format(time.DateOnly))
+	case "endpoint_id":
+		webhook, err := tsClient.Webhooks().Get(ctx, id)
+		if err != nil {
+			break
+		}
+		return fmt.Sprintf("webhook %s (%s)", webhook.EndpointID, webhook.EndpointURL)
+	}
+	return fmt.Sprintf("%s %s", strings.TrimSuffix(argument, "_id"), id)
+}
+
+// lastSeen describes how long ago t was, in the largest whole unit.
+func lastSeen(t time.Time, connected bool) string {
+	since := time.Since(t)
+	switch {
+	case connected:
+		return "connected now"
+	case t.IsZero():
+		return "never seen"
+	case since < time.Hour:
+		return fmt.Sprintf("last seen %dm ago", int(since.Minutes()))
+	case since < 48*time.Hour:
+		return fmt.Sprintf("last seen %dh ago", int(since.Hours()))
+	default:
+		return fmt.Sprintf("last seen %dd ago", int(since.Hours()/24))
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/redact"
+	"github.com/pnocera/tailscale-mcp-server/internal/textdiff"
+)
+
+const dryRunArgument = "dry_run"
+
+// dryRunUnsupported are mutating tools whose effects are not only API
+// requests, so holding the requests back would not make them harmless.
+var dryRunUnsupported = map[string]string{
+	"tailscale_policy_backup":   "it writes to the backup store, not the Tailscale API",
+	"tailscale_policy_rollback": "it backs up the current policy before applying; call it without confirm to preview the rollback",
+	"tailscale_policy_sync":     "it pulls from git and updates the sync status; call it without apply to preview the diff",
+}
+
+// DryRunner lets every mutating tool be dry-run: through a dry_run
+// argument, or for every call when the server runs in dry-run mode. In a
+// dry run the tool's API requests other than reads are held back, and the
+// result describes them instead. Tools with a dry_run argument of their own
+// keep their own preview.
+type DryRunner struct {
+	global bool
+	// native are the tools that declared dry_run themselves.
+	native map[string]bool
+}
+
+func NewDryRunner(cfg *config.Config) *DryRunner {
+	return &DryRunner{global: cfg.DryRun, native: make(map[string]bool)}
+}
+
+// Load adds the dry_run argument to the mutating tools registered on
+// mcpServer that lack one. Call it once, after all tools are registered and
+// before the catalog and router load them.
+func (d *DryRunner) Load(mcpServer *server.MCPServer) {
+	for name, registered := range mcpServer.ListTools() {
+		tool := registered.Tool
+		if readOnly := tool.Annotations.ReadOnlyHint; readOnly != nil && *readOnly {
+			continue
+		}
+		if _, ok := tool.InputSchema.Properties[dryRunArgument]; ok {
+			d.native[name] = true
+			continue
+		}
+		if _, ok := dryRunUnsupported[name]; ok {
+			continue
+		}
+
+		properties := maps.Clone(tool.InputSchema.Properties)
+		if properties == nil {
+			properties = make(map[string]any)
+		}
+		properties[dryRunArgument] = map[string]any{
+			"type":        "boolean",
+			"description": "Describe the API requests this call would make, with a diff against the current state, without making them",
+		}
+		tool.InputSchema.Properties = properties
+		mcpServer.AddTool(tool, registered.Handler)
+	}
+}
+
+// Middleware runs dry-run calls of mutating tools with their API writes
+// held back. Register it before the Confirmer, which does not ask the user
+// about dry runs.
+func (d *DryRunner) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.Params.Name
+		args := request.GetArguments()
+		requested, _ := args[dryRunArgument].(bool)
+		mcpServer := server.ServerFromContext(ctx)
+		if (!d.global && !requested) || mcpServer == nil {
+			return next(ctx, request)
+		}
+		tool := mcpServer.GetTool(name)
+		if tool == nil {
+			return next(ctx, request)
+		}
+		if readOnly := tool.Tool.Annotations.ReadOnlyHint; readOnly != nil && *readOnly {
+			return next(ctx, request)
+		}
+		if reason, ok := dryRunUnsupported[name]; ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Tool %s cannot be dry-run: %s", name, reason)), nil
+		}
+
+		args = maps.Clone(args)
+		if args == nil {
+			args = make(map[string]any)
+		}
+		if d.native[name] {
+			args[dryRunArgument] = true
+		} else {
+			delete(args, dryRunArgument)
+		}
+		request.Params.Arguments = args
+
+		ctx, dryRun := client.WithDryRun(ctx)
+		result, err := next(ctx, request)
+		if err != nil {
+			return result, err
+		}
+		calls := dryRun.Calls()
+		if len(calls) == 0 {
+			// The tool's own preview, or an error found before any change.
+			if result != nil && !result.IsError && !d.native[name] {
+				result.Content = append(result.Content, mcp.NewTextContent("Dry run: this call would make no API changes."))
+			}
+			return result, nil
+		}
+		return describeDryRun(name, calls), nil
+	}
+}
+
+type dryRunRequest struct {
+	Method      string `json:"method"`
+	Endpoint    string `json:"endpoint"`
+	ContentType string `json:"content_type,omitempty"`
+	Payload     any    `json:"payload,omitempty"`
+	Diff        string `json:"diff,omitempty"`
+}
+
+// describeDryRun returns the result of a dry run that held back calls.
+func describeDryRun(name string, calls []client.DryRunCall) *mcp.CallToolResult {
+	requests := make([]dryRunRequest, 0, len(calls))
+	for _, call := range calls {
+		request := dryRunRequest{Method: call.Method, Endpoint: call.Endpoint, ContentType: call.ContentType}
+		if json.Valid([]byte(call.Payload)) {
+			request.Payload = json.RawMessage(call.Payload)
+		} else if call.Payload != "" {
+			request.Payload = call.Payload
+		}
+		if diff, ok := payloadDiff(call); ok {
+			request.Diff = diff
+			if diff == "" {
+				request.Diff = "no change"
+			}
+		}
+		requests = append(requests, request)
+	}
+
+	dryRunJSON, err := json.MarshalIndent(struct {
+		DryRun   bool            `json:"dry_run"`
+		Tool     string          `json:"tool"`
+		Requests []dryRunRequest `json:"requests"`
+		Note     string          `json:"note"`
+	}{
+		DryRun:   true,
+		Tool:     name,
+		Requests: requests,
+		Note:     "Nothing was changed. Responses to held-back requests were simulated, so requests that depend on them may differ in a real run.",
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal dry run: %v", err))
+	}
+	return mcp.NewToolResultText(redact.String(string(dryRunJSON)))
+}
+
+// payloadDiff diffs the current state of an endpoint against the payload
+// sent to it, if they describe the same thing. A POST that creates something
+// reads back as a list of a different shape, which is not compared, and a
+// PATCH only touches the fields it sends, so only those are.
+func payloadDiff(call client.DryRunCall) (string, bool) {
+	current, proposed := call.Current, call.Payload
+	if current == "" || proposed == "" {
+		return "", false
+	}
+	var currentObject, proposedObject map[string]any
+	if json.Unmarshal([]byte(current), &currentObject) == nil && json.Unmarshal([]byte(proposed), &proposedObject) == nil {
+		shared := false
+		for field := range proposedObject {
+			_, ok := currentObject[field]
+			shared = shared || ok
+		}
+		if !shared {
+			return "", false
+		}
+		if call.Method == http.MethodPatch {
+			for field := range currentObject {
+				if _, ok := proposedObject[field]; !ok {
+					delete(currentObject, field)
+				}
+			}
+		}
+		current, proposed = indentJSON(currentObject), indentJSON(proposedObject)
+	} else if json.Valid([]byte(current)) && json.Valid([]byte(proposed)) {
+		current, proposed = indentRaw(current), indentRaw(proposed)
+	}
+	return textdiff.Unified("current", "proposed", current, proposed), true
+}
+
+func indentJSON(v any) string {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	return string(b) + "\n"
+}
+
+func indentRaw(s string) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(s), "", "  "); err != nil {
+		return s
+	}
+	return buf.String() + "\n"
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/guardrail"
+)
+
+// Guardrails refuses tool calls that one of the operator's rules denies,
+// such as routes that include 0.0.0.0/0 or tags outside tag:dev-*.
+type Guardrails struct {
+	rules []*guardrail.Rule
+	// expanders turn calls of tools that stand in for others, such as
+	// tailscale_apply_changeset, into the calls they stand in for.
+	expanders []guardrail.Expander
+}
+
+func NewGuardrails(cfg *config.Config, expanders ...guardrail.Expander) *Guardrails {
+	return &Guardrails{rules: cfg.Guardrails, expanders: expanders}
+}
+
+// Middleware evaluates the rules against each call before it runs, and
+// against every call of a dedicated tool it stands in for; a rule that
+// cannot be evaluated refuses the call too. Register it after the router's
+// middleware, so routed calls are checked as the tool they run.
+func (g *Guardrails) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if len(g.rules) == 0 {
+			return next(ctx, request)
+		}
+		name := request.Params.Name
+		calls := []guardrail.Call{{Tool: name, Args: request.GetArguments()}}
+		for _, expand := range g.expanders {
+			standIns, err := expand(ctx, name, request.GetArguments())
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Tool %s was not run: guardrails could not be evaluated: %v", name, err)), nil
+			}
+			calls = append(calls, standIns...)
+		}
+
+		for _, call := range calls {
+			// What the call does, for messages about a stand-in.
+			what := "this call"
+			if call.Tool != name {
+				what = "its " + call.Tool + " change"
+			}
+			for _, rule := range g.rules {
+				if !rule.Applies(call.Tool) {
+					continue
+				}
+				denied, err := rule.Denies(call.Tool, call.Args)
+				if err != nil {
+					log.Printf("warning: Guardrail %s failed on %s: %v", rule.Name, name, err)
+					return mcp.NewToolResultError(fmt.Sprintf("Tool %s was not run: guardrail %s could not be evaluated on %s: %v", name, rule.Name, what, err)), nil
+				}
+				if !denied {
+					continue
+				}
+				log.Printf("warning: Guardrail %s denied %s", rule.Name, name)
+				message := fmt.Sprintf("Tool %s was not run: guardrail %s denies %s", name, rule.Name, what)
+				if rule.Message != "" {
+					message += ": " + rule.Message
+				}
+				return mcp.NewToolResultError(message), nil
+			}
+		}
+		return next(ctx, request)
+	}
+}
@@ -3,16 +3,27 @@ package handlers
 import (
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/policysync"
+	"github.com/pnocera/tailscale-mcp-server/internal/webhookrecv"
 	"github.com/pnocera/tailscale-mcp-server/pkg/tools"
 )
 
 type Handler struct {
 	client *client.TailscaleClient
+	config *config.Config
+	sync   *policysync.Syncer
+	events *webhookrecv.Log
+	// resources is set by RegisterResources.
+	resources *tools.Resources
 }
 
-func NewHandler(client *client.TailscaleClient) *Handler {
+func NewHandler(client *client.TailscaleClient, cfg *config.Config, sync *policysync.Syncer, events *webhookrecv.Log) *Handler {
 	return &Handler{
 		client: client,
+		config: cfg,
+		sync:   sync,
+		events: events,
 	}
 }
 
@@ -20,15 +31,46 @@ func (h *Handler) RegisterTools(mcpServer *server.MCPServer) {
 	deviceTools := tools.NewDeviceTools(h.client)
 	deviceTools.RegisterTools(mcpServer)
 
-	keyTools := tools.NewKeyTools(h.client)
+	keyTools := tools.NewKeyTools(h.client, h.config)
 	keyTools.RegisterTools(mcpServer)
 
-	userTools := tools.NewUserTools(h.client)
+	userTools := tools.NewUserTools(h.client, h.config)
 	userTools.RegisterTools(mcpServer)
 
-	dnsTools := tools.NewDNSTools(h.client)
+	dnsTools := tools.NewDNSTools(h.client, h.config)
 	dnsTools.RegisterTools(mcpServer)
 
+	policyTools := tools.NewPolicyTools(h.client, h.config, h.sync)
+	policyTools.RegisterTools(mcpServer)
+
+	serviceTools := tools.NewServiceTools(h.client)
+	serviceTools.RegisterTools(mcpServer)
+
+	changesetTools := tools.NewChangesetTools(h.client)
+	changesetTools.RegisterTools(mcpServer)
+
+	lockTools := tools.NewTailnetLockTools(h.client, h.config)
+	lockTools.RegisterTools(mcpServer)
+
+	derpTools := tools.NewDERPTools(h.client, h.config)
+	derpTools.RegisterTools(mcpServer)
+
+	logTools := tools.NewLogTools(h.client, h.config, h.events)
+	logTools.RegisterTools(mcpServer)
+
 	additionalTools := tools.NewAdditionalTools(h.client)
 	additionalTools.RegisterTools(mcpServer)
+
+	apiTools := tools.NewAPITools(h.client, h.config)
+	apiTools.RegisterTools(mcpServer)
+}
+
+func (h *Handler) RegisterResources(mcpServer *server.MCPServer) {
+	h.resources = tools.NewResources(h.client)
+	h.resources.RegisterResources(mcpServer)
+}
+
+func (h *Handler) RegisterPrompts(mcpServer *server.MCPServer) {
+	prompts := tools.NewPrompts(h.client, h.config)
+	prompts.RegisterPrompts(mcpServer)
 }
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// testAPI is a fake Tailscale API that records the requests it receives.
+type testAPI struct {
+	mu       sync.Mutex
+	requests []string
+	// status, if set, returns the status to answer a request with.
+	status func(r *http.Request) int
+}
+
+// newTestAPI starts a fake API and returns a client for it.
+func newTestAPI(t *testing.T) (*client.TailscaleClient, *testAPI) {
+	t.Helper()
+	api := &testAPI{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		api.requests = append(api.requests, r.Method+" "+r.URL.Path)
+		status := api.status
+		api.mu.Unlock()
+		code := http.StatusOK
+		if status != nil {
+			code = status(r)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{TailscaleAPIKey: "test", TailscaleTailnet: "-"}
+	tc, err := client.NewTailscaleClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.GetClient().BaseURL, err = url.Parse(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	return tc, api
+}
+
+// setStatus answers later requests with the status returned by status, or
+// with 200 if it is nil.
+func (api *testAPI) setStatus(status func(r *http.Request) int) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.status = status
+}
+
+// count returns how many requests were "METHOD /path".
+func (api *testAPI) count(request string) int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	n := 0
+	for _, r := range api.requests {
+		if r == request {
+			n++
+		}
+	}
+	return n
+}
+
+func (api *testAPI) total() int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	return len(api.requests)
+}
+
+// testSession is a session with an ID and nothing else.
+type testSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func newTestSession(id string) *testSession {
+	return &testSession{id: id, notifications: make(chan mcp.JSONRPCNotification, 100)}
+}
+
+func (s *testSession) Initialize()                                         {}
+func (s *testSession) Initialized() bool                                   { return true }
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return s.notifications }
+func (s *testSession) SessionID() string                                   { return s.id }
+
+// callTool calls a tool of mcpServer as a client would, through its
+// middleware, in session if it is not nil.
+func callTool(t *testing.T, mcpServer *server.MCPServer, session server.ClientSession, name string, args map[string]any) *mcp.CallToolResult {
+	t.Helper()
+	message, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name, "arguments": args},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if session != nil {
+		ctx = mcpServer.WithContext(ctx, session)
+	}
+	switch response := mcpServer.HandleMessage(ctx, message).(type) {
+	case mcp.JSONRPCResponse:
+		result, ok := response.Result.(mcp.CallToolResult)
+		if !ok {
+			t.Fatalf("%s returned %T, not a tool result", name, response.Result)
+		}
+		return &result
+	case mcp.JSONRPCError:
+		t.Fatalf("%s failed: %v", name, response.Error.Message)
+	default:
+		t.Fatalf("%s returned %T", name, response)
+	}
+	return nil
+}
+
+// resultText returns the text of result.
+func resultText(result *mcp.CallToolResult) string {
+	return errorText(result)
+}
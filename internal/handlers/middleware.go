@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+)
+
+// QuotaMiddleware adds a note to a tool's result when the API throttled the
+// call or reported that little of the rate limit remains, so the caller can
+// pace itself.
+func QuotaMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx, quota := client.TrackQuota(ctx)
+		result, err := next(ctx, request)
+		if result != nil {
+			if note := quota.Note(); note != "" {
+				result.Content = append(result.Content, mcp.NewTextContent(note))
+			}
+		}
+		return result, err
+	}
+}
+
+// LogMiddleware logs every tool call at debug level, with how long it took
+// and the error it returned, if any.
+func LogMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := next(ctx, request)
+		elapsed := time.Since(start).Round(time.Millisecond)
+		switch {
+		case err != nil:
+			log.Printf("debug: Tool %s failed after %s: %v", request.Params.Name, elapsed, err)
+		case result != nil && result.IsError:
+			log.Printf("debug: Tool %s returned an error after %s", request.Params.Name, elapsed)
+		default:
+			log.Printf("debug: Tool %s finished in %s", request.Params.Name, elapsed)
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// resourceToolPrefixes are the prefixes of tools that change what the
+// tailnet settings and DNS resources hold.
+var resourceToolPrefixes = []string{"tailscale_tailnet_settings_", "tailscale_setting_", "tailscale_dns_"}
+
+// ResourceWatcher requests a refresh of the static resources after a tool
+// may have changed them, so clients hear about the change right away rather
+// than at the next poll.
+type ResourceWatcher struct {
+	refresh chan struct{}
+}
+
+func NewResourceWatcher() *ResourceWatcher {
+	return &ResourceWatcher{refresh: make(chan struct{}, 1)}
+}
+
+// Middleware asks for a refresh after a successful call to a tool that
+// changes settings or DNS.
+func (w *ResourceWatcher) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err != nil || result == nil || result.IsError || !changesResources(ctx, request.Params.Name) {
+			return result, err
+		}
+		select {
+		case w.refresh <- struct{}{}:
+		default:
+			// A refresh is already pending.
+		}
+		return result, err
+	}
+}
+
+func changesResources(ctx context.Context, name string) bool {
+	if !slices.ContainsFunc(resourceToolPrefixes, func(prefix string) bool { return strings.HasPrefix(name, prefix) }) {
+		return false
+	}
+	mcpServer := server.ServerFromContext(ctx)
+	if mcpServer == nil {
+		return true
+	}
+	tool := mcpServer.GetTool(name)
+	return tool == nil || tool.Tool.Annotations.ReadOnlyHint == nil || !*tool.Tool.Annotations.ReadOnlyHint
+}
+
+// WatchResources refreshes the static resources on every tick of
+// h.config.ResourcePollInterval, and whenever watcher asks for it, until ctx
+// is done. Clients are notified of the resources whose content changed.
+func (h *Handler) WatchResources(ctx context.Context, watcher *ResourceWatcher, mcpServer *server.MCPServer) {
+	var tick <-chan time.Time
+	if h.config.ResourcePollInterval > 0 {
+		ticker := time.NewTicker(h.config.ResourcePollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		if err := h.resources.Refresh(ctx, mcpServer); err != nil {
+			log.Printf("Resource refresh failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-watcher.refresh:
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+const (
+	continueTool = "tailscale_result_continue"
+	// resultTTL is how long the rest of a truncated result can be fetched.
+	resultTTL = 10 * time.Minute
+)
+
+type storedResult struct {
+	tool    string
+	text    string
+	expires time.Time
+}
+
+// ResultLimiter keeps tool results from flooding the model's context. Text
+// beyond the configured size is cut at a line break and kept, and the result
+// says how to fetch the rest with tailscale_result_continue.
+type ResultLimiter struct {
+	max int
+
+	mu      sync.Mutex
+	results map[string]storedResult
+}
+
+func NewResultLimiter(cfg *config.Config) *ResultLimiter {
+	return &ResultLimiter{max: cfg.MaxResultBytes, results: make(map[string]storedResult)}
+}
+
+// RegisterTools registers tailscale_result_continue.
+func (l *ResultLimiter) RegisterTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		continueTool,
+		mcp.WithToolAnnotation(mcp.ToolAnnotation{
+			ReadOnlyHint:    mcp.ToBoolPtr(true),
+			DestructiveHint: mcp.ToBoolPtr(false),
+			IdempotentHint:  mcp.ToBoolPtr(true),
+			OpenWorldHint:   mcp.ToBoolPtr(false),
+		}),
+		mcp.WithDescription(fmt.Sprintf("Get the next part of a tool result that was truncated for size. Results longer than %d bytes end with a note holding a continuation token; pass it here to get the following part, which again ends with a token while more remains. Tokens expire after %s. Makes no API calls.", l.max, resultTTL)),
+		mcp.WithString("token", mcp.Description("Continuation token from the truncation note"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, l.Continue)
+}
+
+// Middleware truncates results whose text exceeds the limit. Structured
+// content of a truncated result is dropped, since it repeats the text.
+func (l *ResultLimiter) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if l.max <= 0 || err != nil || result == nil || request.Params.Name == continueTool {
+			return result, err
+		}
+
+		var texts []string
+		size := 0
+		for _, content := range result.Content {
+			if text, ok := content.(mcp.TextContent); ok {
+				texts = append(texts, text.Text)
+				size += len(text.Text)
+			}
+		}
+		if size <= l.max {
+			return result, nil
+		}
+
+		full := strings.Join(texts, "\n")
+		id, err := l.store(request.Params.Name, full)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to store truncated result: %v", err)), nil
+		}
+		part, end := cutText(full, 0, l.max)
+
+		// The text parts are replaced by the part that fits; other content,
+		// such as images and resources, is kept.
+		content := []mcp.Content{mcp.NewTextContent(part)}
+		for _, c := range result.Content {
+			if _, ok := c.(mcp.TextContent); !ok {
+				content = append(content, c)
+			}
+		}
+		content = append(content, mcp.NewTextContent(continuationNote(request.Params.Name, 0, end, len(full), encodeResultToken(id, end))))
+		result.Content = content
+		result.StructuredContent = nil
+		return result, nil
+	}
+}
+
+func (l *ResultLimiter) Continue(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Token string `json:"token"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	id, offset, ok := decodeResultToken(args.Token)
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid continuation token: %s", args.Token)), nil
+	}
+	stored, ok := l.load(id)
+	if !ok || offset > len(stored.text) {
+		return mcp.NewToolResultError("Continuation token has expired; call the original tool again"), nil
+	}
+
+	part, end := cutText(stored.text, offset, l.max)
+	result := mcp.NewToolResultText(part)
+	if end < len(stored.text) {
+		result.Content = append(result.Content, mcp.NewTextContent(continuationNote(stored.tool, offset, end, len(stored.text), encodeResultToken(id, end))))
+	}
+	return result, nil
+}
+
+func (l *ResultLimiter) store(tool, text string) (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	for key, stored := range l.results {
+		if now.After(stored.expires) {
+			delete(l.results, key)
+		}
+	}
+	key := hex.EncodeToString(id)
+	l.results[key] = storedResult{tool: tool, text: text, expires: now.Add(resultTTL)}
+	return key, nil
+}
+
+func (l *ResultLimiter) load(id string) (storedResult, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stored, ok := l.results[id]
+	if !ok || time.Now().After(stored.expires) {
+		return storedResult{}, false
+	}
+	return stored, true
+}
+
+// cutText returns up to max bytes of text from offset and the offset after
+// them. The part ends at the last line break that fits, so the same result
+// is always cut in the same places, unless that would leave less than half
+// of max, as in long lines of minified JSON.
+func cutText(text string, offset, max int) (string, int) {
+	end := offset + max
+	if end >= len(text) {
+		return text[offset:], len(text)
+	}
+	if newline := strings.LastIndexByte(text[offset:end], '\n'); newline >= max/2 {
+		end = offset + newline + 1
+	}
+	for end > offset && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	return text[offset:end], end
+}
+
+func continuationNote(tool string, start, end, total int, token string) string {
+	return fmt.Sprintf("Result of %s truncated: showing bytes %d-%d of %d. Call %s with token %q for the next part.", tool, start+1, end, total, continueTool, token)
+}
+
+// Tokens are opaque to clients; they encode the stored result and the
+// offset of the next part.
+func encodeResultToken(id string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id + ":" + strconv.Itoa(offset)))
+}
+
+func decodeResultToken(token string) (string, int, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", 0, false
+	}
+	id, rawOffset, ok := strings.Cut(string(raw), ":")
+	offset, err := strconv.Atoi(rawOffset)
+	if !ok || err != nil || offset < 0 {
+		return "", 0, false
+	}
+	return id, offset, true
+}
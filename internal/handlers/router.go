@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// routerAction is the argument of a router tool naming the tool it runs.
+const routerAction = "action"
+
+// routerGroups are the router tools offered in grouped mode. A registered
+// tool belongs to the first group with a prefix of its name, and its action
+// is its name without that prefix; the last group takes every other tool.
+var routerGroups = []struct {
+	name        string
+	description string
+	prefixes    []string
+}{
+	{"tailscale_devices", "Manage devices: list and inspect them, authorize, rename, tag, expire, and delete them, and manage their routes and posture", []string{"tailscale_device_", "tailscale_devices_", "tailscale_topology_", "tailscale_posture_"}},
+	{"tailscale_keys", "Manage auth keys: create, rotate, audit, export, and delete them", []string{"tailscale_key_", "tailscale_keys_"}},
+	{"tailscale_users", "Manage users, invites, contacts, and groups", []string{"tailscale_user_", "tailscale_users_", "tailscale_contact_", "tailscale_contacts_", "tailscale_group_"}},
+	{"tailscale_dns", "Manage DNS: nameservers, search paths, split DNS, MagicDNS, and DNS preferences", []string{"tailscale_dns_"}},
+	{"tailscale_policy", "Read, edit, test, stage, back up, and apply the policy file, and check access", []string{"tailscale_policy_", "tailscale_access_"}},
+	{"tailscale_services", "Manage Tailscale Services", []string{"tailscale_service_", "tailscale_services_"}},
+	{"tailscale_webhooks", "Manage webhooks", []string{"tailscale_webhook_", "tailscale_webhooks_"}},
+	{"tailscale_logs", "Read audit and network logs and manage log streaming", []string{"tailscale_logging_", "tailscale_audit_", "tailscale_network_"}},
+	{"tailscale_tailnet", "Manage tailnet settings, tailnet lock, and changesets, and inspect the DERP map, available features, security events, and API quota", []string{"tailscale_"}},
+}
+
+// Router offers the registered tools through a few router tools when the
+// server runs in grouped mode, for clients that cap the number of tools. A
+// call to a router tool is turned into a call to the tool its action names
+// before any other middleware sees it, so read-only mode, confirmation, and
+// logging apply to the routed tool as if it had been called directly.
+type Router struct {
+	grouped bool
+	// routes maps each router tool's actions to the tools they run.
+	routes map[string]map[string]string
+	// routed are the tools reachable through a router tool, which are left
+	// out of tools/list.
+	routed map[string]bool
+}
+
+func NewRouter(cfg *config.Config) *Router {
+	return &Router{
+		grouped: cfg.ToolMode == "grouped",
+		routes:  make(map[string]map[string]string),
+		routed:  make(map[string]bool),
+	}
+}
+
+// Load adds the router tools for the tools registered on mcpServer. Call it
+// once, after all tools are registered; in flat mode it does nothing. The
+// routed tools stay registered, so clients that call them by name still
+// reach them.
+func (r *Router) Load(mcpServer *server.MCPServer) {
+	if !r.grouped {
+		return
+	}
+
+	registered := mcpServer.ListTools()
+	members := make(map[string][]string)
+	for _, name := range slices.Sorted(maps.Keys(registered)) {
+		for _, group := range routerGroups {
+			if slices.ContainsFunc(group.prefixes, func(prefix string) bool { return strings.HasPrefix(name, prefix) }) {
+				members[group.name] = append(members[group.name], name)
+				break
+			}
+		}
+	}
+
+	for _, group := range routerGroups {
+		if len(members[group.name]) == 0 {
+			continue
+		}
+		actions := routerActions(group.prefixes, members[group.name])
+		tools := make(map[string]mcp.Tool, len(actions))
+		for action, name := range actions {
+			tools[action] = registered[name].Tool
+			r.routed[name] = true
+		}
+		r.routes[group.name] = actions
+		mcpServer.AddTool(routerTool(group.name, group.description, tools), r.dispatch)
+	}
+}
+
+// routerActions names the actions of a router tool's tools. A tool's action
+// is its name without the group prefix, or without just "tailscale_" when
+// that would be ambiguous.
+func routerActions(prefixes, names []string) map[string]string {
+	short := make(map[string]string, len(names))
+	uses := make(map[string]int)
+	for _, name := range names {
+		for _, prefix := range prefixes {
+			if action, ok := strings.CutPrefix(name, prefix); ok {
+				short[name] = action
+				uses[action]++
+				break
+			}
+		}
+	}
+
+	actions := make(map[string]string, len(names))
+	for _, name := range names {
+		action := short[name]
+		if uses[action] > 1 {
+			action = strings.TrimPrefix(name, "tailscale_")
+		}
+		actions[action] = name
+	}
+	return actions
+}
+
+// routerTool declares a router tool. Its arguments are the action and those
+// of all its tools, and it is only as safe as its least safe tool.
+func routerTool(name, description string, tools map[string]mcp.Tool) mcp.Tool {
+	actions := slices.Sorted(maps.Keys(tools))
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s. Set %s to one of the actions below and pass that action's arguments alongside it. Actions:", description, routerAction)
+	readOnly, destructive, idempotent := true, false, true
+	properties := map[string]any{
+		routerAction: map[string]any{
+			"type":        "string",
+			"description": "The action to run",
+			"enum":        actions,
+		},
+	}
+	for _, action := range actions {
+		tool := tools[action]
+		fmt.Fprintf(&text, "\n- %s: %s", action, firstSentence(tool.Description))
+		if arguments := slices.Sorted(maps.Keys(tool.InputSchema.Properties)); len(arguments) > 0 {
+			fmt.Fprintf(&text, " Arguments: %s.", strings.Join(arguments, ", "))
+		}
+		if len(tool.InputSchema.Required) > 0 {
+			fmt.Fprintf(&text, " Required: %s.", strings.Join(tool.InputSchema.Required, ", "))
+		}
+
+		for argument, schema := range tool.InputSchema.Properties {
+			if _, ok := properties[argument]; !ok {
+				properties[argument] = schema
+			}
+		}
+		hints := tool.Annotations
+		readOnly = readOnly && hints.ReadOnlyHint != nil && *hints.ReadOnlyHint
+		destructive = destructive || hints.DestructiveHint == nil || *hints.DestructiveHint
+		idempotent = idempotent && hints.IdempotentHint != nil && *hints.IdempotentHint
+	}
+
+	return mcp.Tool{
+		Name:        name,
+		Description: text.String(),
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: properties,
+			Required:   []string{routerAction},
+		},
+		Annotations: mcp.ToolAnnotation{
+			ReadOnlyHint:    mcp.ToBoolPtr(readOnly),
+			DestructiveHint: mcp.ToBoolPtr(destructive),
+			IdempotentHint:  mcp.ToBoolPtr(idempotent),
+			OpenWorldHint:   mcp.ToBoolPtr(true),
+		},
+	}
+}
+
+// firstSentence returns the first sentence of a tool description, which is
+// enough to pick an action by.
+func firstSentence(description string) string {
+	for i := 0; i+2 < len(description); i++ {
+		if description[i] == '.' && description[i+1] == ' ' && unicode.IsUpper(rune(description[i+2])) {
+			return description[:i+1]
+		}
+	}
+	return description
+}
+
+// Filter leaves routed tools out of tools/list results, so only the router
+// tools count against the client's limit; register it with
+// server.WithToolFilter.
+func (r *Router) Filter(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	if !r.grouped {
+		return tools
+	}
+	return slices.DeleteFunc(tools, func(tool mcp.Tool) bool { return r.routed[tool.Name] })
+}
+
+// Middleware turns calls to router tools into calls to the tools their
+// action names. Register it before every other tool middleware.
+func (r *Router) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		actions, ok := r.routes[request.Params.Name]
+		if !ok {
+			return next(ctx, request)
+		}
+
+		args := maps.Clone(request.GetArguments())
+		action, _ := args[routerAction].(string)
+		name, ok := actions[action]
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid action %q for %s: must be one of %s", action, request.Params.Name, strings.Join(slices.Sorted(maps.Keys(actions)), ", "))), nil
+		}
+		delete(args, routerAction)
+		request.Params.Name = name
+		request.Params.Arguments = args
+		return next(ctx, request)
+	}
+}
+
+// dispatch is the handler of every router tool. By the time it runs,
+// Middleware has replaced the router tool's name with the routed tool's.
+func (r *Router) dispatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	mcpServer := server.ServerFromContext(ctx)
+	if mcpServer == nil || !r.routed[request.Params.Name] {
+		return mcp.NewToolResultError(fmt.Sprintf("Tool %s cannot be called: the router middleware is not installed", request.Params.Name)), nil
+	}
+	tool := mcpServer.GetTool(request.Params.Name)
+	if tool == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Tool %s is not registered", request.Params.Name)), nil
+	}
+	return tool.Handler(ctx, request)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/redact"
+)
+
+const revealSecretsArgument = "reveal_secrets"
+
+// RedactMiddleware masks secrets, such as auth keys, OAuth client secrets,
+// webhook secrets, and log streaming tokens, in every tool result. Tools
+// that declare a reveal_secrets argument return them unmasked when it is
+// set. Register it after the ResultLimiter, so results are masked before
+// they are cut and no secret is split across parts.
+func RedactMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if result == nil || revealsSecrets(ctx, request) {
+			return result, err
+		}
+
+		for i, content := range result.Content {
+			switch c := content.(type) {
+			case mcp.TextContent:
+				c.Text = redact.String(c.Text)
+				result.Content[i] = c
+			case mcp.EmbeddedResource:
+				if text, ok := c.Resource.(mcp.TextResourceContents); ok {
+					text.Text = redact.String(text.Text)
+					c.Resource = text
+					result.Content[i] = c
+				}
+			}
+		}
+		if result.StructuredContent != nil {
+			result.StructuredContent = redactValue(result.StructuredContent)
+		}
+		return result, err
+	}
+}
+
+// revealsSecrets reports whether the call asked for its secrets unmasked,
+// through an argument its tool declares.
+func revealsSecrets(ctx context.Context, request mcp.CallToolRequest) bool {
+	if reveal, _ := request.GetArguments()[revealSecretsArgument].(bool); !reveal {
+		return false
+	}
+	mcpServer := server.ServerFromContext(ctx)
+	if mcpServer == nil {
+		return false
+	}
+	tool := mcpServer.GetTool(request.Params.Name)
+	if tool == nil {
+		return false
+	}
+	_, declared := tool.Tool.InputSchema.Properties[revealSecretsArgument]
+	return declared
+}
+
+// redactValue masks the secrets in a structured result. Values that do not
+// round-trip through JSON are dropped rather than passed on unmasked.
+func redactValue(v any) any {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var redacted any
+	if err := json.Unmarshal([]byte(redact.String(string(raw))), &redacted); err != nil {
+		return nil
+	}
+	return redacted
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+const (
+	// sessionQuotaWindow is the sliding window session API quotas apply to.
+	sessionQuotaWindow = time.Hour
+	// sessionQuotaCacheSize is how many read-only results are kept to serve
+	// sessions whose quota runs low.
+	sessionQuotaCacheSize = 256
+)
+
+type cachedResult struct {
+	result *mcp.CallToolResult
+	at     time.Time
+}
+
+// SessionQuotas caps the Tailscale API requests each session may cause per
+// hour, so one chat cannot use up the tailnet's API rate limit. Once less
+// than a tenth of a session's quota is left, read-only tools are answered
+// from the session's results of earlier calls where possible, keeping the
+// rest for changes, and the session is warned.
+type SessionQuotas struct {
+	limit int
+
+	mu sync.Mutex
+	// calls holds, per session, when each of its API requests was made.
+	calls map[string][]time.Time
+	// cache holds the latest result of read-only calls by session, tool,
+	// and arguments.
+	cache map[string]cachedResult
+}
+
+func NewSessionQuotas(cfg *config.Config) *SessionQuotas {
+	return &SessionQuotas{
+		limit: cfg.SessionAPIQuota,
+		calls: make(map[string][]time.Time),
+		cache: make(map[string]cachedResult),
+	}
+}
+
+// Middleware counts the API requests of each call against its session's
+// quota and refuses requests beyond it. Register it after access control
+// and the ResultLimiter, so cached results are only served to callers
+// allowed the tool and are truncated afresh, and before the middleware that
+// reads the tailnet itself, such as the Confirmer and UndoTools, so their
+// requests count too.
+func (q *SessionQuotas) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mcpServer := server.ServerFromContext(ctx)
+		if q.limit == 0 || mcpServer == nil {
+			return next(ctx, request)
+		}
+		name := request.Params.Name
+		readOnly := false
+		if tool := mcpServer.GetTool(name); tool != nil {
+			readOnly = tool.Tool.Annotations.ReadOnlyHint != nil && *tool.Tool.Annotations.ReadOnlyHint
+		}
+		session := ""
+		if s := server.ClientSessionFromContext(ctx); s != nil {
+			session = s.SessionID()
+		}
+		key := cacheKey(session, name, request.GetArguments())
+
+		remaining, frees := q.remaining(session, time.Now())
+		if readOnly && q.low(remaining) {
+			if cached, ok := q.cached(key); ok {
+				log.Printf("debug: Served %s from cache for a session low on API quota", name)
+				return withNote(copyResult(cached.result), fmt.Sprintf(
+					"This result is cached from %s ago: this session has %d of its %d Tailscale API calls per hour left, which are kept for changes. Fresh requests are available again from %s.",
+					time.Since(cached.at).Round(time.Second), remaining, q.limit, frees.UTC().Format(time.RFC3339))), nil
+			}
+		}
+		if remaining <= 0 {
+			log.Printf("warning: Refused %s: session used its API quota of %d per hour", name, q.limit)
+			return mcp.NewToolResultError(fmt.Sprintf(
+				"Tool %s was not run: this session has made its %d Tailscale API calls for this hour. Requests are available again from %s; until then, work with the results you already have.",
+				name, q.limit, frees.UTC().Format(time.RFC3339))), nil
+		}
+
+		ctx, counter := client.LimitCalls(ctx, remaining)
+		result, err := next(ctx, request)
+		remaining = q.add(session, counter.Count(), time.Now())
+		if result == nil {
+			return result, err
+		}
+		if readOnly && err == nil && !result.IsError {
+			q.store(key, result)
+		}
+		if q.low(remaining) {
+			result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf(
+				"This session has %d of its %d Tailscale API calls per hour left. Read-only tools now answer from earlier results where they can; avoid repeating calls.",
+				remaining, q.limit)))
+		}
+		return result, err
+	}
+}
+
+// low reports whether less than a tenth of the quota remains.
+func (q *SessionQuotas) low(remaining int) bool {
+	return remaining*10 < q.limit
+}
+
+// remaining returns how many requests the session may still make at now,
+// and when its oldest counted request leaves the window.
+func (q *SessionQuotas) remaining(session string, now time.Time) (int, time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Sessions that ended leave their requests behind; drop those that
+	// have left the window, for every session at once.
+	for s, calls := range q.calls {
+		calls = slices.DeleteFunc(calls, func(t time.Time) bool {
+			return now.Sub(t) >= sessionQuotaWindow
+		})
+		if len(calls) == 0 {
+			delete(q.calls, s)
+		} else {
+			q.calls[s] = calls
+		}
+	}
+	calls := q.calls[session]
+	if len(calls) == 0 {
+		return q.limit, now
+	}
+	return q.limit - len(calls), calls[0].Add(sessionQuotaWindow)
+}
+
+// add counts n requests made at now and returns how many remain.
+func (q *SessionQuotas) add(session string, n int, now time.Time) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for range n {
+		q.calls[session] = append(q.calls[session], now)
+	}
+	return q.limit - len(q.calls[session])
+}
+
+func (q *SessionQuotas) cached(key string) (cachedResult, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	cached, ok := q.cache[key]
+	return cached, ok
+}
+
+// store keeps a copy of result, since later middleware modifies it,
+// evicting the oldest result if the cache is full.
+func (q *SessionQuotas) store(key string, result *mcp.CallToolResult) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.cache[key]; !ok && len(q.cache) >= sessionQuotaCacheSize {
+		oldest := ""
+		for k, c := range q.cache {
+			if oldest == "" || c.at.Before(q.cache[oldest].at) {
+				oldest = k
+			}
+		}
+		delete(q.cache, oldest)
+	}
+	q.cache[key] = cachedResult{result: copyResult(result), at: time.Now()}
+}
+
+// cacheKey identifies a call by its session, tool, and arguments.
+func cacheKey(session, name string, args map[string]any) string {
+	raw, _ := json.Marshal(args)
+	return session + " " + name + " " + string(raw)
+}
+
+// copyResult returns a copy of result that shares nothing modifiable with
+// it.
+func copyResult(result *mcp.CallToolResult) *mcp.CallToolResult {
+	copied := *result
+	copied.Content = slices.Clone(result.Content)
+	if result.StructuredContent != nil {
+		// Through JSON, since structured content is whatever the tool
+		// returned, typically maps and slices.
+		var structured any
+		if raw, err := json.Marshal(result.StructuredContent); err == nil && json.Unmarshal(raw, &structured) == nil {
+			copied.StructuredContent = structured
+		} else {
+			copied.StructuredContent = nil
+		}
+	}
+	return &copied
+}
+
+// withNote appends note to result.
+func withNote(result *mcp.CallToolResult, note string) *mcp.CallToolResult {
+	result.Content = append(result.Content, mcp.NewTextContent(note))
+	return result
+}
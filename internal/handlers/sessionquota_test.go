@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// newQuotaServer returns a server with session quotas of limit and tools
+// that use tc: a read-only listing, which reports how often it ran, a
+// deletion, and a routes change on several devices that is rolled back if
+// any of them fails.
+func newQuotaServer(t *testing.T, tc *client.TailscaleClient, limit int) *server.MCPServer {
+	t.Helper()
+	cfg := &config.Config{SessionAPIQuota: limit}
+	mcpServer := server.NewMCPServer("test", "1.0.0",
+		server.WithToolCapabilities(true),
+		server.WithToolHandlerMiddleware(NewSessionQuotas(cfg).Middleware),
+	)
+	fail := func(err error) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var lists atomic.Int64
+	mcpServer.AddTool(mcp.NewTool("tailscale_device_list", mcp.WithReadOnlyHintAnnotation(true)), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if _, err := tc.GetClient().Devices().List(ctx); err != nil {
+			return fail(err)
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("list %d", lists.Add(1))), nil
+	})
+	mcpServer.AddTool(mcp.NewTool("tailscale_device_delete"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := tc.GetClient().Devices().Delete(ctx, request.GetString("device_id", "")); err != nil {
+			return fail(err)
+		}
+		return mcp.NewToolResultText("deleted"), nil
+	})
+	mcpServer.AddTool(mcp.NewTool("tailscale_devices_routes_set"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ids := request.GetStringSlice("device_ids", nil)
+		for i, id := range ids {
+			if err := tc.GetClient().Devices().SetSubnetRoutes(ctx, id, []string{"10.0.0.0/24"}); err != nil {
+				for _, done := range ids[:i] {
+					if err := tc.GetClient().Devices().SetSubnetRoutes(client.Unlimited(ctx), done, nil); err != nil {
+						return fail(fmt.Errorf("rolling back %s: %w", done, err))
+					}
+				}
+				return fail(fmt.Errorf("set %d devices, then rolled back: %w", i, err))
+			}
+		}
+		return mcp.NewToolResultText("set"), nil
+	})
+	return mcpServer
+}
+
+func TestSessionQuotaRefuses(t *testing.T) {
+	tc, api := newTestAPI(t)
+	s := newQuotaServer(t, tc, 3)
+	a := newTestSession("a")
+
+	for i := range 3 {
+		if result := callTool(t, s, a, "tailscale_device_delete", map[string]any{"device_id": fmt.Sprintf("d%d", i)}); result.IsError {
+			t.Fatalf("delete %d was refused: %s", i+1, resultText(result))
+		}
+	}
+	result := callTool(t, s, a, "tailscale_device_delete", map[string]any{"device_id": "d3"})
+	if !result.IsError || !strings.Contains(resultText(result), "has made its 3 Tailscale API calls") {
+		t.Errorf("fourth delete got %q, want it refused over quota", resultText(result))
+	}
+	if n := api.count("DELETE /api/v2/device/d3"); n != 0 {
+		t.Errorf("the refused delete was sent %d times", n)
+	}
+
+	// Other sessions have quotas of their own.
+	if result := callTool(t, s, newTestSession("b"), "tailscale_device_delete", map[string]any{"device_id": "d3"}); result.IsError {
+		t.Errorf("session b's delete was refused by session a's quota: %s", resultText(result))
+	}
+}
+
+func TestSessionQuotaCache(t *testing.T) {
+	tc, api := newTestAPI(t)
+	s := newQuotaServer(t, tc, 20)
+	a, b := newTestSession("a"), newTestSession("b")
+	list := func(session server.ClientSession, args map[string]any) string {
+		t.Helper()
+		result := callTool(t, s, session, "tailscale_device_list", args)
+		if result.IsError {
+			t.Fatalf("list was refused: %s", resultText(result))
+		}
+		return resultText(result)
+	}
+
+	// With most of the quota left, reads are fresh.
+	list(a, nil)
+	if text := list(a, nil); !strings.HasPrefix(text, "list 2") {
+		t.Errorf("got %q with quota left, want a fresh list", text)
+	}
+
+	// Use up the quota but for one request, which is less than a tenth.
+	for i := range 17 {
+		callTool(t, s, a, "tailscale_device_delete", map[string]any{"device_id": fmt.Sprintf("d%d", i)})
+	}
+	text := list(a, nil)
+	if !strings.HasPrefix(text, "list 2") || !strings.Contains(text, "cached from") || !strings.Contains(text, "1 of its 20") {
+		t.Errorf("got %q low on quota, want the earlier list from cache", text)
+	}
+	if n := api.count("GET /api/v2/tailnet/-/devices"); n != 2 {
+		t.Errorf("got %d list requests, want 2", n)
+	}
+
+	// Calls with other arguments are not in the cache, and make the
+	// session's last request.
+	if text := list(a, map[string]any{"filter": "tag:prod"}); !strings.HasPrefix(text, "list 3") || !strings.Contains(text, "0 of its 20") {
+		t.Errorf("got %q for other arguments, want a fresh list and a warning", text)
+	}
+
+	// Cached reads are served even once the quota is used up; others are
+	// refused.
+	if text := list(a, nil); !strings.HasPrefix(text, "list 2") {
+		t.Errorf("got %q without quota, want the earlier list from cache", text)
+	}
+	if result := callTool(t, s, a, "tailscale_device_list", map[string]any{"filter": "tag:dev"}); !result.IsError {
+		t.Errorf("an uncached list without quota was not refused")
+	}
+
+	// Another session, low on quota too, is not served a's results.
+	for i := range 19 {
+		callTool(t, s, b, "tailscale_device_delete", map[string]any{"device_id": fmt.Sprintf("d%d", i)})
+	}
+	if text := list(b, nil); strings.Contains(text, "cached from") {
+		t.Errorf("session b got %q, want a fresh list of its own", text)
+	}
+}
+
+func TestSessionQuotaRollback(t *testing.T) {
+	tc, api := newTestAPI(t)
+	s := newQuotaServer(t, tc, 2)
+	a := newTestSession("a")
+
+	// The third device is over quota; the first two are set back anyway.
+	result := callTool(t, s, a, "tailscale_devices_routes_set", map[string]any{"device_ids": []any{"d1", "d2", "d3"}})
+	if !result.IsError || !strings.Contains(resultText(result), "set 2 devices, then rolled back") {
+		t.Fatalf("got %q, want the change stopped by the quota and rolled back", resultText(result))
+	}
+	for _, id := range []string{"d1", "d2"} {
+		if n := api.count("POST /api/v2/device/" + id + "/routes"); n != 2 {
+			t.Errorf("got %d routes requests for %s, want the change and its rollback", n, id)
+		}
+	}
+	if n := api.count("POST /api/v2/device/d3/routes"); n != 0 {
+		t.Errorf("the request over quota was sent %d times", n)
+	}
+
+	if result := callTool(t, s, a, "tailscale_device_delete", map[string]any{"device_id": "d1"}); !result.IsError {
+		t.Errorf("delete after the quota was used up was not refused")
+	}
+}
+
+func TestSessionQuotaRollbackUncounted(t *testing.T) {
+	tc, api := newTestAPI(t)
+	s := newQuotaServer(t, tc, 5)
+	a := newTestSession("a")
+
+	api.setStatus(func(r *http.Request) int {
+		if r.URL.Path == "/api/v2/device/d3/routes" {
+			return http.StatusInternalServerError
+		}
+		return http.StatusOK
+	})
+	if result := callTool(t, s, a, "tailscale_devices_routes_set", map[string]any{"device_ids": []any{"d1", "d2", "d3"}}); !result.IsError {
+		t.Fatalf("the change succeeded against a failing device")
+	}
+	if n := api.total(); n != 5 {
+		t.Fatalf("got %d requests, want 3 and 2 to roll back", n)
+	}
+
+	// Only the three requests of the change count, leaving two.
+	for i := range 2 {
+		if result := callTool(t, s, a, "tailscale_device_delete", map[string]any{"device_id": "d1"}); result.IsError {
+			t.Fatalf("delete %d was refused: the rollback was counted: %s", i+1, resultText(result))
+		}
+	}
+	if result := callTool(t, s, a, "tailscale_device_delete", map[string]any{"device_id": "d1"}); !result.IsError {
+		t.Errorf("delete after the quota was used up was not refused")
+	}
+}
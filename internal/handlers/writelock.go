@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/writelock"
+)
+
+// WriteLock runs calls of mutating tools one at a time, so two sessions
+// cannot interleave the reads and writes of, say, a route or policy update
+// and silently undo each other's change.
+type WriteLock struct {
+	lock *writelock.Lock
+}
+
+// NewWriteLock returns a WriteLock holding lock, which the server's other
+// writers share.
+func NewWriteLock(lock *writelock.Lock) *WriteLock {
+	return &WriteLock{lock: lock}
+}
+
+// Middleware holds the tailnet's write lock for the duration of mutating
+// calls. Register it after the Confirmer and Approvals, so the lock is not
+// held while waiting for people, and before the UndoTools, so the state it
+// records is read under the lock. A call whose shared lock is lost while it
+// runs is cancelled, so it makes no further changes.
+func (l *WriteLock) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		mcpServer := server.ServerFromContext(ctx)
+		if mcpServer == nil || client.IsDryRun(ctx) {
+			return next(ctx, request)
+		}
+		name := request.Params.Name
+		tool := mcpServer.GetTool(name)
+		if tool == nil {
+			return next(ctx, request)
+		}
+		if readOnly := tool.Tool.Annotations.ReadOnlyHint; readOnly != nil && *readOnly {
+			return next(ctx, request)
+		}
+
+		lockedCtx, unlock, err := l.lock.Acquire(ctx)
+		if errors.Is(err, writelock.ErrTimeout) {
+			return mcp.NewToolResultError(fmt.Sprintf("Tool %s was not run: another session is changing the tailnet. Try again in a moment.", name)), nil
+		}
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to lock the tailnet for %s: %v", name, err)), nil
+		}
+		defer unlock()
+
+		result, err := next(lockedCtx, request)
+		if errors.Is(context.Cause(lockedCtx), writelock.ErrLeaseLost) {
+			return mcp.NewToolResultError(fmt.Sprintf("Tool %s was stopped: %v, so another session may be changing the tailnet. Some of its changes may have been made; check the tailnet before trying again.", name, writelock.ErrLeaseLost)), nil
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,161 @@
+package keyexpiry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// Watcher periodically looks for auth keys that expire within the configured
+// window and reports each of them once, as an MCP log notification to every
+// connected client and optionally to a webhook.
+type Watcher struct {
+	client    *client.TailscaleClient
+	config    *config.Config
+	mcpServer *server.MCPServer
+	http      *http.Client
+	notified  map[string]bool
+}
+
+type expiringKey struct {
+	ID          string    `json:"id"`
+	Description string    `json:"description"`
+	Tags        []string  `json:"tags"`
+	Expires     time.Time `json:"expires"`
+}
+
+func NewWatcher(client *client.TailscaleClient, cfg *config.Config, mcpServer *server.MCPServer) *Watcher {
+	return &Watcher{
+		client:    client,
+		config:    cfg,
+		mcpServer: mcpServer,
+		http:      &http.Client{Timeout: 30 * time.Second},
+		notified:  make(map[string]bool),
+	}
+}
+
+// Run checks immediately and then on every tick until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.KeyExpiryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.check(ctx); err != nil {
+			log.Printf("Key expiry check failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Watcher) check(ctx context.Context) error {
+	keys, err := w.client.ListKeysWithDetails(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	deadline := now.Add(w.config.KeyExpiryWindow)
+	current := make(map[string]bool)
+	var expiring []expiringKey
+	for _, key := range keys {
+		if key.Expires.IsZero() || !key.Revoked.IsZero() || key.Expires.Before(now) || key.Expires.After(deadline) {
+			continue
+		}
+		if w.notified[key.ID] {
+			current[key.ID] = true
+			continue
+		}
+		expiring = append(expiring, expiringKey{
+			ID:          key.ID,
+			Description: key.Description,
+			Tags:        key.Capabilities.Devices.Create.Tags,
+			Expires:     key.Expires,
+		})
+	}
+	// Forget keys that left the window so a replacement with the same ID is
+	// reported again. New keys are only remembered once they were reported.
+	w.notified = current
+
+	if len(expiring) == 0 {
+		return nil
+	}
+
+	summary := summarize(expiring)
+	log.Print(summary)
+
+	w.mcpServer.SendNotificationToAllClients("notifications/message", map[string]any{
+		"level":  mcp.LoggingLevelWarning,
+		"logger": "tailscale.key_expiry",
+		"data": map[string]any{
+			"message": summary,
+			"keys":    expiring,
+		},
+	})
+
+	if w.config.KeyExpiryWebhookURL != "" {
+		if err := w.postWebhook(ctx, summary, expiring); err != nil {
+			// Report the keys again on the next check.
+			return fmt.Errorf("failed to post key expiry webhook: %w", err)
+		}
+	}
+	for _, key := range expiring {
+		w.notified[key.ID] = true
+	}
+	return nil
+}
+
+func summarize(keys []expiringKey) string {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		label := key.ID
+		if key.Description != "" {
+			label += " (" + key.Description + ")"
+		}
+		parts = append(parts, fmt.Sprintf("%s expires %s", label, key.Expires.UTC().Format(time.RFC3339)))
+	}
+	return fmt.Sprintf("%d Tailscale auth key(s) expiring soon: %s", len(keys), strings.Join(parts, "; "))
+}
+
+// postWebhook sends a Slack-compatible payload: a "text" summary plus the
+// structured key list.
+func (w *Watcher) postWebhook(ctx context.Context, summary string, keys []expiringKey) error {
+	payload, err := json.Marshal(map[string]any{
+		"text": summary,
+		"keys": keys,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.KeyExpiryWebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,211 @@
+// Package localapi is a minimal client for the LocalAPI of a tailscaled
+// running on the same host, for data the control plane API does not expose.
+package localapi
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Client talks to tailscaled over its Unix socket.
+type Client struct {
+	http *http.Client
+}
+
+func NewClient(socket string) *Client {
+	return &Client{
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// Get fetches /localapi/v0/<path> and decodes the JSON response into out.
+func (c *Client) Get(ctx context.Context, path string, out any) error {
+	// tailscaled only accepts LocalAPI requests addressed to this host name.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://local-tailscaled.sock/localapi/v0/"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("tailscaled returned %s: %s", resp.Status, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Hash is a 32-byte hash, sent by tailscaled as an array of numbers and
+// shown as hex.
+type Hash [32]byte
+
+func (h Hash) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h[:])), nil
+}
+
+func (h *Hash) UnmarshalJSON(b []byte) error {
+	var bytes [32]byte
+	if err := json.Unmarshal(b, &bytes); err != nil {
+		return err
+	}
+	*h = bytes
+	return nil
+}
+
+// TrustedKey is a tailnet lock signing key.
+type TrustedKey struct {
+	Key      string            `json:"key"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Votes    uint              `json:"votes"`
+}
+
+// LockPeer is a peer as seen by tailnet lock.
+type LockPeer struct {
+	Name         string   `json:"name"`
+	StableID     string   `json:"stable_id"`
+	TailscaleIPs []string `json:"tailscale_ips"`
+	NodeKey      string   `json:"node_key"`
+}
+
+// LockStatus is the tailnet lock state reported by tailscaled's tka/status.
+type LockStatus struct {
+	Enabled       bool         `json:"enabled"`
+	Head          *Hash        `json:"head,omitempty"`
+	PublicKey     string       `json:"public_key"`
+	NodeKey       string       `json:"node_key,omitempty"`
+	NodeKeySigned bool         `json:"node_key_signed"`
+	TrustedKeys   []TrustedKey `json:"trusted_keys"`
+	FilteredPeers []LockPeer   `json:"filtered_peers"`
+	StateID       uint64       `json:"state_id,omitempty"`
+}
+
+func (s *LockStatus) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Enabled       bool
+		Head          *Hash
+		PublicKey     string
+		NodeKey       string
+		NodeKeySigned bool
+		TrustedKeys   []struct {
+			Key      string
+			Metadata map[string]string
+			Votes    uint
+		}
+		FilteredPeers []struct {
+			Name         string
+			StableID     string
+			TailscaleIPs []string
+			NodeKey      string
+		}
+		StateID uint64
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	*s = LockStatus{
+		Enabled:       raw.Enabled,
+		Head:          raw.Head,
+		PublicKey:     raw.PublicKey,
+		NodeKey:       raw.NodeKey,
+		NodeKeySigned: raw.NodeKeySigned,
+		TrustedKeys:   []TrustedKey{},
+		FilteredPeers: []LockPeer{},
+		StateID:       raw.StateID,
+	}
+	for _, k := range raw.TrustedKeys {
+		s.TrustedKeys = append(s.TrustedKeys, TrustedKey{Key: k.Key, Metadata: k.Metadata, Votes: k.Votes})
+	}
+	for _, p := range raw.FilteredPeers {
+		s.FilteredPeers = append(s.FilteredPeers, LockPeer{Name: p.Name, StableID: p.StableID, TailscaleIPs: p.TailscaleIPs, NodeKey: p.NodeKey})
+	}
+	return nil
+}
+
+// LockUpdate is an entry of the tailnet lock log.
+type LockUpdate struct {
+	Hash   Hash   `json:"hash"`
+	Change string `json:"change"`
+}
+
+func (u *LockUpdate) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Hash   Hash
+		Change string
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*u = LockUpdate{Hash: raw.Hash, Change: raw.Change}
+	return nil
+}
+
+// LockStatus returns the tailnet lock status.
+func (c *Client) LockStatus(ctx context.Context) (*LockStatus, error) {
+	var status LockStatus
+	if err := c.Get(ctx, "tka/status", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// LockLog returns up to limit of the most recent tailnet lock log entries.
+func (c *Client) LockLog(ctx context.Context, limit int) ([]LockUpdate, error) {
+	var updates []LockUpdate
+	if err := c.Get(ctx, fmt.Sprintf("tka/log?limit=%d", limit), &updates); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// DERPRegion is a region of DERP relay servers. Like the rest of the DERP
+// map it is sent with Go field names.
+type DERPRegion struct {
+	RegionID   int
+	RegionCode string
+	RegionName string
+	Avoid      bool
+	Nodes      []DERPNode
+}
+
+// DERPNode is a single DERP relay server.
+type DERPNode struct {
+	Name     string
+	RegionID int
+	HostName string
+	IPv4     string
+	IPv6     string
+}
+
+// DERPMap describes the DERP relay servers available to the tailnet.
+type DERPMap struct {
+	Regions map[int]DERPRegion
+}
+
+// DERPMap returns the DERP map tailscaled is using, which includes any
+// customizations from the tailnet policy file.
+func (c *Client) DERPMap(ctx context.Context) (*DERPMap, error) {
+	var derpMap DERPMap
+	if err := c.Get(ctx, "derpmap", &derpMap); err != nil {
+		return nil, err
+	}
+	return &derpMap, nil
+}
@@ -0,0 +1,200 @@
+// Package logpoll tails the tailnet's configuration audit logs and network
+// flow logs, handing each new entry to connected MCP clients or appending it
+// to an NDJSON file for a SIEM to pick up.
+package logpoll
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+const (
+	// lag keeps each poll this far behind now, giving entries time to be
+	// ingested before their window is read and the cursor moves past it.
+	lag = 2 * time.Minute
+	// maxWindow bounds a single request when catching up after downtime.
+	maxWindow = time.Hour
+)
+
+// Log types that can be polled.
+const (
+	Audit   = "audit"
+	Network = "network"
+)
+
+// Poller reads each configured log type in consecutive windows. The end of
+// the last window read is the type's cursor; cursors are persisted to the
+// state file, when one is configured, so a restart resumes where it stopped
+// rather than skipping or repeating entries.
+type Poller struct {
+	client    *client.TailscaleClient
+	config    *config.Config
+	mcpServer *server.MCPServer
+	cursors   map[string]time.Time
+}
+
+func NewPoller(client *client.TailscaleClient, cfg *config.Config, mcpServer *server.MCPServer) *Poller {
+	return &Poller{
+		client:    client,
+		config:    cfg,
+		mcpServer: mcpServer,
+		cursors:   make(map[string]time.Time),
+	}
+}
+
+// Run polls immediately and then on every tick until ctx is done.
+func (p *Poller) Run(ctx context.Context) {
+	if err := p.loadState(); err != nil {
+		log.Printf("Failed to load log poll state, starting from now: %v", err)
+	}
+
+	ticker := time.NewTicker(p.config.LogPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, logType := range p.config.LogPollTypes {
+			if err := p.poll(ctx, logType); err != nil {
+				log.Printf("Polling %s logs failed: %v", logType, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll reads logType from its cursor up to now, less the lag, in windows of
+// at most maxWindow, advancing and saving the cursor after each window.
+func (p *Poller) poll(ctx context.Context, logType string) error {
+	until := time.Now().Add(-lag).Truncate(time.Second)
+	cursor, ok := p.cursors[logType]
+	if !ok {
+		// Without a saved cursor, start from now instead of replaying history.
+		p.cursors[logType] = until
+		return p.saveState()
+	}
+
+	for cursor.Before(until) {
+		end := cursor.Add(maxWindow)
+		if end.After(until) {
+			end = until
+		}
+
+		var entries []any
+		switch logType {
+		case Audit:
+			logs, err := p.client.AuditLogs(ctx, cursor, end)
+			if err != nil {
+				return err
+			}
+			for _, entry := range logs {
+				entries = append(entries, entry)
+			}
+		case Network:
+			logs, err := p.client.NetworkLogs(ctx, cursor, end)
+			if err != nil {
+				return err
+			}
+			for _, entry := range logs {
+				entries = append(entries, entry)
+			}
+		default:
+			return fmt.Errorf("unknown log type %q", logType)
+		}
+
+		if err := p.emit(logType, entries); err != nil {
+			return err
+		}
+		cursor = end
+		p.cursors[logType] = cursor
+		if err := p.saveState(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emit appends entries to the output file or, without one, sends each of
+// them to every connected client.
+func (p *Poller) emit(logType string, entries []any) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if p.config.LogPollOutput == "" {
+		for _, entry := range entries {
+			p.mcpServer.SendNotificationToAllClients("notifications/message", map[string]any{
+				"level":  mcp.LoggingLevelInfo,
+				"logger": "tailscale." + logType + "_log",
+				"data":   entry,
+			})
+		}
+		return nil
+	}
+
+	f, err := os.OpenFile(p.config.LogPollOutput, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(map[string]any{"log_type": logType, "entry": entry}); err != nil {
+			return err
+		}
+	}
+	return f.Close()
+}
+
+func (p *Poller) loadState() error {
+	if p.config.LogPollState == "" {
+		return nil
+	}
+	data, err := os.ReadFile(p.config.LogPollState)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &p.cursors)
+}
+
+// saveState writes the cursors through a temporary file so a crash never
+// leaves a truncated state file behind.
+func (p *Poller) saveState() error {
+	if p.config.LogPollState == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(p.cursors, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p.config.LogPollState), ".logpoll-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p.config.LogPollState)
+}
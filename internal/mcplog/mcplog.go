@@ -0,0 +1,135 @@
+// Package mcplog sends the server's log output to admin MCP clients as
+// notifications/message, at the level each client picked with
+// logging/setLevel, so an operator can watch the server from their client
+// while troubleshooting.
+package mcplog
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/rbac"
+	"github.com/pnocera/tailscale-mcp-server/internal/redact"
+)
+
+// logger names the server's own log in notifications.
+const logger = "tailscale.server"
+
+// Log lines are given a level by a "debug: ", "warning: ", or "error: "
+// prefix. Lines without one are errors if they report a failure and info
+// otherwise, which suits the existing log messages.
+var prefixes = []struct {
+	prefix string
+	level  mcp.LoggingLevel
+}{
+	{"debug: ", mcp.LoggingLevelDebug},
+	{"warning: ", mcp.LoggingLevelWarning},
+	{"error: ", mcp.LoggingLevelError},
+}
+
+// timestampLength is the length of the date and time the standard logger
+// starts lines with.
+var timestampLength = len("2006/01/02 15:04:05 ")
+
+// Forwarder is the standard logger's output. It writes lines at or above its
+// level to out and sends every line to the admin clients whose level admits
+// it. The log covers every session's calls, so other roles get none of it.
+type Forwarder struct {
+	out   io.Writer
+	level mcp.LoggingLevel
+	role  string
+
+	mu        sync.Mutex
+	mcpServer *server.MCPServer
+	sessions  map[string]bool
+}
+
+// NewForwarder returns a Forwarder writing lines at level or above to out.
+// Sessions without an authenticated principal act with role.
+func NewForwarder(out io.Writer, level mcp.LoggingLevel, role string) *Forwarder {
+	return &Forwarder{out: out, level: level, role: role, sessions: make(map[string]bool)}
+}
+
+// Hooks tracks the sessions log lines are sent to; pass it to the MCP server
+// with server.WithHooks.
+func (f *Forwarder) Hooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddOnRegisterSession(func(ctx context.Context, session server.ClientSession) {
+		role := f.role
+		if p, ok := rbac.FromContext(ctx); ok {
+			role = p.Role
+		}
+		if role != rbac.Admin {
+			return
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.sessions[session.SessionID()] = true
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.sessions, session.SessionID())
+	})
+	return hooks
+}
+
+// Attach starts sending log lines to the clients of mcpServer.
+func (f *Forwarder) Attach(mcpServer *server.MCPServer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mcpServer = mcpServer
+}
+
+func (f *Forwarder) Write(p []byte) (int, error) {
+	line := strings.TrimSuffix(redact.String(string(p)), "\n")
+	message := line
+	if len(message) >= timestampLength {
+		if _, err := time.Parse("2006/01/02 15:04:05", message[:timestampLength-1]); err == nil {
+			message = message[timestampLength:]
+		}
+	}
+	level, message := levelOf(message)
+
+	if level.ShouldSendTo(f.level) {
+		if _, err := io.WriteString(f.out, line+"\n"); err != nil {
+			return 0, err
+		}
+	}
+
+	f.mu.Lock()
+	mcpServer := f.mcpServer
+	sessions := make([]string, 0, len(f.sessions))
+	for id := range f.sessions {
+		sessions = append(sessions, id)
+	}
+	f.mu.Unlock()
+	if mcpServer != nil {
+		notification := mcp.NewLoggingMessageNotification(level, logger, message)
+		for _, id := range sessions {
+			// Sessions that are not initialized, or whose notifications are
+			// backed up, miss the line.
+			_ = mcpServer.SendLogMessageToSpecificClient(id, notification)
+		}
+	}
+	return len(p), nil
+}
+
+// levelOf returns the level of message and the message without its level
+// prefix.
+func levelOf(message string) (mcp.LoggingLevel, string) {
+	for _, p := range prefixes {
+		if rest, ok := strings.CutPrefix(message, p.prefix); ok {
+			return p.level, rest
+		}
+	}
+	if strings.HasPrefix(message, "Failed") || strings.Contains(message, " failed") {
+		return mcp.LoggingLevelError, message
+	}
+	return mcp.LoggingLevelInfo, message
+}
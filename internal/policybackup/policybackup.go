@@ -0,0 +1,223 @@
+// Package policybackup stores timestamped copies of the tailnet policy file
+// in a local directory or an S3 bucket, independent of the admin console's
+// own history.
+package policybackup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DefaultDir is where backups go under the export root when no backup
+// location is configured.
+const DefaultDir = "tailscale-policy-backups"
+
+const (
+	namePrefix = "policy-"
+	nameSuffix = ".hujson"
+	timeLayout = "20060102T150405.000Z"
+)
+
+// Backup describes a stored copy of the policy file.
+type Backup struct {
+	Name string    `json:"name"`
+	Time time.Time `json:"time"`
+	Size int64     `json:"size"`
+}
+
+// Store saves and retrieves policy backups.
+type Store interface {
+	// Location describes where backups are kept.
+	Location() string
+	// Save stores policy as a backup taken at the given time.
+	Save(ctx context.Context, policy []byte, at time.Time) (Backup, error)
+	// List returns the stored backups, newest first.
+	List(ctx context.Context) ([]Backup, error)
+	// Load returns the content of the named backup.
+	Load(ctx context.Context, name string) ([]byte, error)
+}
+
+// New returns the store for location, which is either a local directory or
+// an S3 URL of the form s3://bucket/prefix. S3 credentials and region come
+// from the standard AWS environment and configuration files.
+func New(ctx context.Context, location string) (Store, error) {
+	if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(rest, "/")
+		if bucket == "" {
+			return nil, fmt.Errorf("invalid S3 location %q: missing bucket", location)
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+		}
+		return &s3Store{client: s3.NewFromConfig(cfg), bucket: bucket, prefix: strings.Trim(prefix, "/")}, nil
+	}
+	return &dirStore{dir: location}, nil
+}
+
+func backupName(at time.Time) string {
+	return namePrefix + at.UTC().Format(timeLayout) + nameSuffix
+}
+
+// parseName returns the time encoded in a backup name.
+func parseName(name string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(name, namePrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	stamp, ok = strings.CutSuffix(stamp, nameSuffix)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(timeLayout, stamp)
+	return t, err == nil
+}
+
+// validName rejects names that are not backups, which also keeps Load from
+// reading outside the store.
+func validName(name string) error {
+	if _, ok := parseName(name); !ok {
+		return fmt.Errorf("invalid backup name %q", name)
+	}
+	return nil
+}
+
+func sortNewestFirst(backups []Backup) {
+	slices.SortFunc(backups, func(a, b Backup) int { return b.Time.Compare(a.Time) })
+}
+
+type dirStore struct {
+	dir string
+}
+
+func (s *dirStore) Location() string {
+	return s.dir
+}
+
+func (s *dirStore) Save(ctx context.Context, policy []byte, at time.Time) (Backup, error) {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return Backup{}, err
+	}
+	name := backupName(at)
+	if err := os.WriteFile(filepath.Join(s.dir, name), policy, 0o600); err != nil {
+		return Backup{}, err
+	}
+	return Backup{Name: name, Time: at.UTC(), Size: int64(len(policy))}, nil
+}
+
+func (s *dirStore) List(ctx context.Context) ([]Backup, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, entry := range entries {
+		at, ok := parseName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{Name: entry.Name(), Time: at, Size: info.Size()})
+	}
+	sortNewestFirst(backups)
+	return backups, nil
+}
+
+func (s *dirStore) Load(ctx context.Context, name string) ([]byte, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	return os.ReadFile(filepath.Join(s.dir, name))
+}
+
+type s3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func (s *s3Store) Location() string {
+	return "s3://" + path.Join(s.bucket, s.prefix)
+}
+
+func (s *s3Store) key(name string) string {
+	return path.Join(s.prefix, name)
+}
+
+func (s *s3Store) Save(ctx context.Context, policy []byte, at time.Time) (Backup, error) {
+	name := backupName(at)
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(s.key(name)),
+		Body:        bytes.NewReader(policy),
+		ContentType: aws.String("application/hujson"),
+	})
+	if err != nil {
+		return Backup{}, err
+	}
+	return Backup{Name: name, Time: at.UTC(), Size: int64(len(policy))}, nil
+}
+
+func (s *s3Store) List(ctx context.Context) ([]Backup, error) {
+	prefix := namePrefix
+	if s.prefix != "" {
+		prefix = s.prefix + "/" + namePrefix
+	}
+
+	var backups []Backup
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			name := path.Base(aws.ToString(object.Key))
+			at, ok := parseName(name)
+			if !ok {
+				continue
+			}
+			backups = append(backups, Backup{Name: name, Time: at, Size: aws.ToInt64(object.Size)})
+		}
+	}
+	sortNewestFirst(backups)
+	return backups, nil
+}
+
+func (s *s3Store) Load(ctx context.Context, name string) ([]byte, error) {
+	if err := validName(name); err != nil {
+		return nil, err
+	}
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer out.Body.Close()
+	return io.ReadAll(out.Body)
+}
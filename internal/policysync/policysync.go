@@ -0,0 +1,350 @@
+// Package policysync keeps the tailnet policy file in step with a copy kept
+// in a git repository: it pulls the file, validates it, diffs it against the
+// live policy, and applies it on demand or on a schedule.
+package policysync
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/policybackup"
+	"github.com/pnocera/tailscale-mcp-server/internal/textdiff"
+	"github.com/pnocera/tailscale-mcp-server/internal/writelock"
+)
+
+// syncTool is the tool scheduled applies are recorded as in the audit log.
+const syncTool = "tailscale_policy_sync"
+
+// Auditor records changes the server makes outside tool calls in its audit
+// trail.
+type Auditor interface {
+	RecordBackground(tool string, args map[string]any, start time.Time, err error)
+}
+
+// Result describes the outcome of a single sync.
+type Result struct {
+	Commit  string    `json:"commit"`
+	Time    time.Time `json:"time"`
+	Changed bool      `json:"changed"`
+	Applied bool      `json:"applied"`
+	Diff    string    `json:"diff,omitempty"`
+	ETag    string    `json:"etag,omitempty"`
+	// Backup names the backup of the policy an apply replaced.
+	Backup string `json:"backup,omitempty"`
+}
+
+// Status reports the sync configuration and the most recent runs.
+type Status struct {
+	Repo        string    `json:"repo"`
+	Branch      string    `json:"branch"`
+	Path        string    `json:"path"`
+	Interval    string    `json:"interval,omitempty"`
+	LastRun     *Result   `json:"last_run,omitempty"`
+	LastApplied *Result   `json:"last_applied,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitzero"`
+	Running     bool      `json:"running"`
+}
+
+// Syncer pulls the policy from git and applies it to the tailnet. Syncs are
+// serialized so a periodic run never overlaps one requested through a tool.
+// Syncs requested through a tool pass the tool middleware; scheduled ones
+// take the same write lock and make the same checks themselves.
+type Syncer struct {
+	client    *client.TailscaleClient
+	config    *config.Config
+	mcpServer *server.MCPServer
+	lock      *writelock.Lock
+	auditor   Auditor
+	readOnly  atomic.Bool
+
+	run     sync.Mutex // held for the duration of a sync
+	backups policybackup.Store
+	mu      sync.Mutex // guards status
+	status  Status
+}
+
+func NewSyncer(client *client.TailscaleClient, cfg *config.Config, mcpServer *server.MCPServer, lock *writelock.Lock, auditor Auditor) *Syncer {
+	s := &Syncer{
+		client:    client,
+		config:    cfg,
+		mcpServer: mcpServer,
+		lock:      lock,
+		auditor:   auditor,
+		status: Status{
+			Repo:   cfg.PolicyGitRepo,
+			Branch: cfg.PolicyGitBranch,
+			Path:   cfg.PolicyGitPath,
+		},
+	}
+	s.readOnly.Store(cfg.ReadOnly)
+	return s
+}
+
+// SetReadOnly turns read-only mode on or off for scheduled syncs, which
+// then only diff. A nil Syncer ignores it.
+func (s *Syncer) SetReadOnly(on bool) {
+	if s != nil {
+		s.readOnly.Store(on)
+	}
+}
+
+// Run syncs immediately and then on every tick until ctx is done, applying
+// any change found when it may.
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.PolicySyncInterval)
+	defer ticker.Stop()
+
+	for {
+		s.scheduledSync(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduledSync runs one scheduled sync. It applies a change only when the
+// server is in neither dry-run nor read-only mode and inside the change
+// windows, holding the write lock, and records the apply in the audit log;
+// otherwise it only diffs and reports the change it held back.
+func (s *Syncer) scheduledSync(ctx context.Context) {
+	if reason := s.holdReason(time.Now()); reason != "" {
+		result, err := s.Sync(ctx, false)
+		switch {
+		case err != nil:
+			log.Printf("Policy sync failed: %v", err)
+			s.notify(mcp.LoggingLevelError, fmt.Sprintf("Policy sync from %s failed: %v", s.config.PolicyGitRepo, err), nil)
+		case result.Changed:
+			message := fmt.Sprintf("Policy at commit %s of %s differs from the tailnet's and was not applied: %s", result.Commit, s.config.PolicyGitRepo, reason)
+			log.Printf("warning: %s", message)
+			s.notify(mcp.LoggingLevelWarning, message, result)
+		}
+		return
+	}
+
+	lockedCtx, unlock, err := s.lock.Acquire(ctx)
+	if err != nil {
+		log.Printf("Policy sync failed: failed to take the write lock: %v", err)
+		s.notify(mcp.LoggingLevelError, fmt.Sprintf("Policy sync from %s failed: failed to take the write lock: %v", s.config.PolicyGitRepo, err), nil)
+		return
+	}
+	start := time.Now()
+	result, err := s.Sync(lockedCtx, true)
+	if err != nil && errors.Is(context.Cause(lockedCtx), writelock.ErrLeaseLost) {
+		err = fmt.Errorf("%w: %w", writelock.ErrLeaseLost, err)
+	}
+	unlock()
+
+	switch {
+	case err != nil:
+		s.auditor.RecordBackground(syncTool, map[string]any{"apply": true, "scheduled": true}, start, err)
+		log.Printf("Policy sync failed: %v", err)
+		s.notify(mcp.LoggingLevelError, fmt.Sprintf("Policy sync from %s failed: %v", s.config.PolicyGitRepo, err), nil)
+	case result.Applied:
+		s.auditor.RecordBackground(syncTool, map[string]any{"apply": true, "scheduled": true, "commit": result.Commit, "backup": result.Backup}, start, nil)
+		message := fmt.Sprintf("Policy updated from %s at commit %s", s.config.PolicyGitRepo, result.Commit)
+		log.Print(message)
+		s.notify(mcp.LoggingLevelNotice, message, result)
+	}
+}
+
+// holdReason returns why a scheduled sync at now may not apply a change,
+// or "" if it may.
+func (s *Syncer) holdReason(now time.Time) string {
+	switch {
+	case s.config.DryRun:
+		return "the server is in dry-run mode"
+	case s.readOnly.Load():
+		return "the server is in read-only mode"
+	case s.config.ChangeWindows != nil && !s.config.ChangeWindows.Open(now):
+		return fmt.Sprintf("it is outside the change windows %s", s.config.ChangeWindows)
+	}
+	return ""
+}
+
+func (s *Syncer) notify(level mcp.LoggingLevel, message string, result *Result) {
+	data := map[string]any{"message": message}
+	if result != nil {
+		data["result"] = result
+	}
+	s.mcpServer.SendNotificationToAllClients("notifications/message", map[string]any{
+		"level":  level,
+		"logger": "tailscale.policy_sync",
+		"data":   data,
+	})
+}
+
+// Status returns a snapshot of the sync status.
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status
+	if s.config.PolicySyncInterval > 0 {
+		status.Interval = s.config.PolicySyncInterval.String()
+	}
+	return status
+}
+
+// Sync pulls the policy from git, validates it, and diffs it against the live
+// policy. When apply is set and the policies differ, the git copy is written
+// to the tailnet, guarded by the ETag of the policy it was diffed against.
+func (s *Syncer) Sync(ctx context.Context, apply bool) (*Result, error) {
+	s.run.Lock()
+	defer s.run.Unlock()
+
+	s.setRunning(true)
+	result, err := s.sync(ctx, apply)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.status.LastErrorAt = time.Now().UTC()
+		return nil, err
+	}
+	s.status.LastRun = result
+	if result.Applied {
+		s.status.LastApplied = result
+	}
+	return result, nil
+}
+
+func (s *Syncer) setRunning(running bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = running
+}
+
+func (s *Syncer) sync(ctx context.Context, apply bool) (*Result, error) {
+	policy, commit, err := s.pull(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tsClient := s.client.GetClient()
+	if err := tsClient.PolicyFile().Validate(ctx, policy); err != nil {
+		return nil, fmt.Errorf("policy at commit %s is invalid: %w", commit, err)
+	}
+
+	current, err := tsClient.PolicyFile().Raw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current policy: %w", err)
+	}
+	etag := client.NormalizeETag(current.ETag)
+
+	result := &Result{
+		Commit: commit,
+		Time:   time.Now().UTC(),
+		Diff:   textdiff.Unified("tailnet", s.config.PolicyGitPath+"@"+commit, current.HuJSON, policy),
+		ETag:   etag,
+	}
+	result.Changed = result.Diff != ""
+	if !result.Changed || !apply {
+		return result, nil
+	}
+
+	// Back up the policy being replaced, as a manual rollback does.
+	store, err := s.backupStore(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("policy at commit %s not applied: %w", commit, err)
+	}
+	backup, err := store.Save(ctx, []byte(current.HuJSON), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("policy at commit %s not applied: failed to back up the current policy: %w", commit, err)
+	}
+	result.Backup = backup.Name
+
+	if err := tsClient.PolicyFile().Set(ctx, policy, etag); err != nil {
+		return nil, fmt.Errorf("failed to apply policy at commit %s: %w", commit, err)
+	}
+	result.Applied = true
+	if updated, err := tsClient.PolicyFile().Raw(ctx); err == nil {
+		result.ETag = client.NormalizeETag(updated.ETag)
+	}
+	return result, nil
+}
+
+// backupStore returns the store policies are backed up to before an apply:
+// the configured backup location, or the default directory under the export
+// directory. It is created on first use; the caller holds s.run.
+func (s *Syncer) backupStore(ctx context.Context) (policybackup.Store, error) {
+	if s.backups != nil {
+		return s.backups, nil
+	}
+	location := s.config.PolicyBackupLocation
+	if location == "" {
+		if s.config.ExportDir == "" {
+			return nil, fmt.Errorf("there is nowhere to back up the current policy to; set TAILSCALE_MCP_POLICY_BACKUP_DIR or TAILSCALE_MCP_EXPORT_DIR")
+		}
+		location = filepath.Join(s.config.ExportDir, policybackup.DefaultDir)
+	}
+	store, err := policybackup.New(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	s.backups = store
+	return store, nil
+}
+
+// pull makes a shallow clone of the configured branch and returns the policy
+// file and the commit it was read at. Credentials come from the environment's
+// git configuration (SSH keys, credential helpers).
+func (s *Syncer) pull(ctx context.Context) (string, string, error) {
+	dir, err := os.MkdirTemp("", "tailscale-policy-sync-")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err := git(ctx, "", "clone", "--quiet", "--depth", "1", "--single-branch", "--branch", s.config.PolicyGitBranch, "--", s.config.PolicyGitRepo, dir); err != nil {
+		return "", "", fmt.Errorf("failed to clone %s: %w", s.config.PolicyGitRepo, err)
+	}
+	commit, err := git(ctx, dir, "rev-parse", "--short=12", "HEAD")
+	if err != nil {
+		return "", "", err
+	}
+
+	path := filepath.Join(dir, filepath.FromSlash(s.config.PolicyGitPath))
+	if !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("policy path %q is outside the repository", s.config.PolicyGitPath)
+	}
+	policy, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s at commit %s: %w", s.config.PolicyGitPath, commit, err)
+	}
+	return string(policy), commit, nil
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
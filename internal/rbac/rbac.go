@@ -0,0 +1,90 @@
+// Package rbac identifies who a session acts for and decides which tools
+// their role may call.
+package rbac
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// Roles, from least to most privileged.
+const (
+	// Viewer may call read-only tools.
+	Viewer = "viewer"
+	// Operator may also call tools that create or update without deleting
+	// or overwriting anything.
+	Operator = "operator"
+	// Admin may call every tool.
+	Admin = "admin"
+)
+
+type principalKey struct{}
+
+// WithPrincipal returns a context acting for p.
+func WithPrincipal(ctx context.Context, p config.Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal ctx acts for, if it was authenticated.
+func FromContext(ctx context.Context) (config.Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(config.Principal)
+	return p, ok
+}
+
+// Permits reports whether role may call tool, judged by its annotations.
+// Tools without annotations count as destructive.
+func Permits(role string, tool mcp.Tool) bool {
+	hints := tool.Annotations
+	switch role {
+	case Admin:
+		return true
+	case Operator:
+		readOnly := hints.ReadOnlyHint != nil && *hints.ReadOnlyHint
+		destructive := hints.DestructiveHint == nil || *hints.DestructiveHint
+		return readOnly || !destructive
+	case Viewer:
+		return hints.ReadOnlyHint != nil && *hints.ReadOnlyHint
+	}
+	return false
+}
+
+// RequireToken wraps the MCP HTTP handler so every request must carry a
+// bearer token from cfg.Principals, and runs it as that token's principal.
+// Without configured tokens, requests pass through unauthenticated and get
+// the configured role, which is then viewer unless set explicitly.
+func RequireToken(cfg *config.Config, next http.Handler) http.Handler {
+	if len(cfg.Principals) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := authenticate(cfg, r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "missing or invalid bearer token"})
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+	})
+}
+
+func authenticate(cfg *config.Config, r *http.Request) (config.Principal, bool) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found || token == "" {
+		return config.Principal{}, false
+	}
+
+	for candidate, principal := range cfg.Principals {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			return principal, true
+		}
+	}
+	return config.Principal{}, false
+}
@@ -0,0 +1,41 @@
+package redact
+
+import (
+	"io"
+	"regexp"
+)
+
+// Tailscale secrets share the "tskey-<kind>-" prefix, e.g. tskey-auth-...,
+// tskey-api-..., tskey-client-....
+var tailscaleKeyPattern = regexp.MustCompile(`tskey-([a-z]+)-[A-Za-z0-9_-]+`)
+
+// secretFieldPattern matches JSON fields whose values are secrets that do
+// not carry the tskey prefix, such as log streaming tokens, posture
+// integration client secrets, and S3 secret access keys.
+var secretFieldPattern = regexp.MustCompile(`("(?i:token|secret|client_?secret|s3_?secret_?access_?key|password)"\s*:\s*)"(?:[^"\\]|\\.)+"`)
+
+const placeholder = "[REDACTED]"
+
+// String masks every Tailscale secret found in s, and the values of JSON
+// fields that hold secrets.
+func String(s string) string {
+	s = tailscaleKeyPattern.ReplaceAllString(s, "tskey-$1-"+placeholder)
+	return secretFieldPattern.ReplaceAllString(s, `$1"`+placeholder+`"`)
+}
+
+type writer struct {
+	w io.Writer
+}
+
+// NewWriter returns an io.Writer that masks secrets before writing to w.
+// It is intended for line-oriented output such as the standard logger.
+func NewWriter(w io.Writer) io.Writer {
+	return &writer{w: w}
+}
+
+func (rw *writer) Write(p []byte) (int, error) {
+	if _, err := rw.w.Write([]byte(String(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
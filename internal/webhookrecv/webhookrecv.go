@@ -0,0 +1,186 @@
+// Package webhookrecv receives Tailscale webhook deliveries and forwards the
+// events to connected MCP clients.
+package webhookrecv
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+const (
+	// maxBodyBytes bounds a delivery; Tailscale batches events, but a batch
+	// is far smaller than this.
+	maxBodyBytes = 1 << 20
+	// maxSkew is how far a delivery's signed timestamp may be from now,
+	// limiting how long a captured request can be replayed.
+	maxSkew = 5 * time.Minute
+)
+
+// Event is a Tailscale webhook event.
+type Event struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Version   int             `json:"version"`
+	Type      string          `json:"type"`
+	Tailnet   string          `json:"tailnet"`
+	Message   string          `json:"message"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+// warningEvents are the event types that need someone to act, which are
+// forwarded at warning level rather than info.
+var warningEvents = map[string]bool{
+	"nodeNeedsApproval":              true,
+	"nodeKeyExpiringInOneDay":        true,
+	"nodeKeyExpired":                 true,
+	"userNeedsApproval":              true,
+	"subnetIPForwardingNotEnabled":   true,
+	"exitNodeIPForwardingNotEnabled": true,
+}
+
+// Log keeps the most recent events received, for tools that correlate them
+// with other sources. It is safe for concurrent use.
+type Log struct {
+	mu       sync.Mutex
+	events   []Event
+	capacity int
+}
+
+func NewLog(capacity int) *Log {
+	return &Log{capacity: capacity}
+}
+
+func (l *Log) add(event Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+	if len(l.events) > l.capacity {
+		l.events = slices.Delete(l.events, 0, len(l.events)-l.capacity)
+	}
+}
+
+// Between returns the retained events with timestamps in [start, end).
+func (l *Log) Between(start, end time.Time) []Event {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var events []Event
+	for _, event := range l.events {
+		if !event.Timestamp.Before(start) && event.Timestamp.Before(end) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Handler accepts POSTs from a Tailscale webhook endpoint, checks the
+// Tailscale-Webhook-Signature header against the configured secrets, and
+// sends every event as an MCP log notification to all connected clients,
+// recording it in the event log.
+type Handler struct {
+	config    *config.Config
+	mcpServer *server.MCPServer
+	log       *Log
+}
+
+func NewHandler(cfg *config.Config, mcpServer *server.MCPServer, events *Log) *Handler {
+	return &Handler{config: cfg, mcpServer: mcpServer, log: events}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := h.verify(r.Header.Get("Tailscale-Webhook-Signature"), body); err != nil {
+		log.Printf("Rejected webhook delivery from %s: %v", r.RemoteAddr, err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var events []Event
+	if err := json.Unmarshal(body, &events); err != nil {
+		http.Error(w, "request body must be a JSON array of events", http.StatusBadRequest)
+		return
+	}
+
+	for _, event := range events {
+		h.log.add(event)
+		level := mcp.LoggingLevelInfo
+		if warningEvents[event.Type] {
+			level = mcp.LoggingLevelWarning
+		}
+		h.mcpServer.SendNotificationToAllClients("notifications/message", map[string]any{
+			"level":  level,
+			"logger": "tailscale.webhook",
+			"data":   event,
+		})
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// verify checks a signature header of the form "t=<unix time>,v1=<hex>",
+// where v1 is the HMAC-SHA256 of "<unix time>.<body>" keyed by the
+// endpoint's secret.
+func (h *Handler) verify(header string, body []byte) error {
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			if sig, err := hex.DecodeString(value); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return errors.New("missing or malformed Tailscale-Webhook-Signature header")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("malformed signature timestamp")
+	}
+	if skew := time.Since(time.Unix(unix, 0)).Abs(); skew > maxSkew {
+		return errors.New("signature timestamp is too old or in the future")
+	}
+
+	// Several secrets may be configured so one can be rotated without
+	// dropping deliveries signed with the other.
+	for _, secret := range h.config.WebhookSecrets {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(timestamp + "."))
+		mac.Write(body)
+		expected := mac.Sum(nil)
+		for _, sig := range signatures {
+			if hmac.Equal(sig, expected) {
+				return nil
+			}
+		}
+	}
+	return errors.New("signature does not match any configured secret")
+}
@@ -0,0 +1,15 @@
+package writelock
+
+import (
+	"testing"
+	"time"
+)
+
+// SetLeaseTTL shortens leaseTTL for the duration of t, so tests can see
+// leases expire and go stale.
+func SetLeaseTTL(t *testing.T, ttl time.Duration) {
+	t.Helper()
+	previous := leaseTTL
+	leaseTTL = ttl
+	t.Cleanup(func() { leaseTTL = previous })
+}
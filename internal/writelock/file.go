@@ -0,0 +1,105 @@
+package writelock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// fileLease is held by whoever created the lock file, which contains the
+// holder's token. Holders refresh its modification time; a file not
+// refreshed for leaseTTL is stale and may be taken over.
+type fileLease struct {
+	path string
+}
+
+func (f *fileLease) tryAcquire(ctx context.Context, token string) (bool, error) {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, fs.ErrExist) {
+		return false, f.takeOverStale(token)
+	}
+	if err != nil {
+		return false, err
+	}
+	if _, err := file.WriteString(token); err != nil {
+		file.Close()
+		os.Remove(f.path)
+		return false, err
+	}
+	return true, file.Close()
+}
+
+// takeOverStale removes the lock file if its holder stopped refreshing it,
+// letting the next attempt race for a new one. Removing it by path could
+// remove a file another server created after taking over the stale one
+// itself, so the file is first renamed aside, which only one server can do,
+// and checked to still be the stale one; a live file moved aside by
+// mistake is put back unless a new one was created in the meantime.
+func (f *fileLease) takeOverStale(token string) error {
+	holder, stale, err := f.stale()
+	if err != nil || !stale {
+		return nil
+	}
+
+	aside := f.path + "." + token + ".stale"
+	if err := os.Rename(f.path, aside); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer os.Remove(aside)
+
+	content, err := os.ReadFile(aside)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(aside)
+	if err != nil {
+		return err
+	}
+	if string(content) == holder && time.Since(info.ModTime()) >= leaseTTL {
+		return nil
+	}
+	if err := os.Link(aside, f.path); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+	return nil
+}
+
+// stale returns the token in the lock file and whether it has not been
+// refreshed for leaseTTL.
+func (f *fileLease) stale() (string, bool, error) {
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", false, err
+	}
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", false, err
+	}
+	return string(content), time.Since(info.ModTime()) >= leaseTTL, nil
+}
+
+func (f *fileLease) holds(token string) bool {
+	content, err := os.ReadFile(f.path)
+	return err == nil && string(content) == token
+}
+
+func (f *fileLease) refresh(ctx context.Context, token string) error {
+	if !f.holds(token) {
+		return fmt.Errorf("lock file %s was taken over: %w", f.path, ErrLeaseLost)
+	}
+	now := time.Now()
+	return os.Chtimes(f.path, now, now)
+}
+
+func (f *fileLease) release(ctx context.Context, token string) error {
+	if !f.holds(token) {
+		return nil
+	}
+	return os.Remove(f.path)
+}
@@ -0,0 +1,98 @@
+package writelock_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/handlers"
+	"github.com/pnocera/tailscale-mcp-server/internal/writelock"
+)
+
+// callTool calls a tool of mcpServer as a client would, through its
+// middleware, and returns the text of its result.
+func callTool(t *testing.T, mcpServer *server.MCPServer, name string) (string, bool) {
+	t.Helper()
+	message, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, ok := mcpServer.HandleMessage(context.Background(), message).(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("%s did not return a result", name)
+	}
+	result := response.Result.(mcp.CallToolResult)
+	var text []string
+	for _, content := range result.Content {
+		if content, ok := content.(mcp.TextContent); ok {
+			text = append(text, content.Text)
+		}
+	}
+	return strings.Join(text, "\n"), result.IsError
+}
+
+func TestMiddleware(t *testing.T) {
+	writelock.SetLeaseTTL(t, 300*time.Millisecond)
+	path := filepath.Join(t.TempDir(), "lock")
+	cfg := &config.Config{WriteLock: "file://" + path, WriteLockTimeout: 500 * time.Millisecond}
+	mcpServer := server.NewMCPServer("test", "1.0.0",
+		server.WithToolCapabilities(true),
+		server.WithToolHandlerMiddleware(handlers.NewWriteLock(writelock.New(cfg)).Middleware),
+	)
+
+	mcpServer.AddTool(mcp.NewTool("tailscale_device_list", mcp.WithReadOnlyHintAnnotation(true)), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("listed"), nil
+	})
+	mcpServer.AddTool(mcp.NewTool("tailscale_device_delete"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("deleted"), nil
+	})
+	// The lock is taken over by another server while this tool runs.
+	mcpServer.AddTool(mcp.NewTool("tailscale_devices_delete_bulk"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if err := os.WriteFile(path, []byte("other"), 0o600); err != nil {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return mcp.NewToolResultError(ctx.Err().Error()), nil
+		case <-time.After(5 * time.Second):
+			return mcp.NewToolResultText("deleted"), nil
+		}
+	})
+
+	if text, isError := callTool(t, mcpServer, "tailscale_device_delete"); isError {
+		t.Errorf("delete got %q, want it run under the lock", text)
+	}
+
+	// Another server holds the lock: changes wait and time out, reads run.
+	_, unlock, err := writelock.New(cfg).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, isError := callTool(t, mcpServer, "tailscale_device_delete"); !isError || !strings.Contains(text, "another session is changing the tailnet") {
+		t.Errorf("delete got %q while another server held the lock, want it not run", text)
+	}
+	if text, isError := callTool(t, mcpServer, "tailscale_device_list"); isError {
+		t.Errorf("list got %q while another server held the lock, want it run", text)
+	}
+	unlock()
+
+	text, isError := callTool(t, mcpServer, "tailscale_devices_delete_bulk")
+	if !isError || !strings.Contains(text, "was stopped") || !strings.Contains(text, writelock.ErrLeaseLost.Error()) {
+		t.Errorf("bulk delete got %q after losing the lock, want it stopped", text)
+	}
+	if holder, _ := os.ReadFile(path); string(holder) != "other" {
+		t.Errorf("the lock file taken over now holds %q", holder)
+	}
+}
@@ -0,0 +1,159 @@
+package writelock
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Scripts that only touch the key while it still holds the caller's token,
+// so a holder whose lease expired cannot extend or delete its successor's.
+const (
+	refreshScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("pexpire", KEYS[1], ARGV[2]) else return 0 end`
+	releaseScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+)
+
+// redisLease is held by whoever set the key to their token. It speaks just
+// enough of the Redis protocol for SET NX and EVAL, on a connection per
+// operation.
+type redisLease struct {
+	addr     string
+	tls      bool
+	username string
+	password string
+	db       string
+	key      string
+}
+
+func newRedisLease(u *url.URL, key string) *redisLease {
+	r := &redisLease{addr: u.Host, tls: u.Scheme == "rediss", db: strings.Trim(u.Path, "/"), key: key}
+	if _, _, err := net.SplitHostPort(r.addr); err != nil {
+		r.addr = net.JoinHostPort(r.addr, "6379")
+	}
+	if u.User != nil {
+		r.username = u.User.Username()
+		r.password, _ = u.User.Password()
+	}
+	if k := u.Query().Get("key"); k != "" {
+		r.key = k
+	}
+	return r
+}
+
+func (r *redisLease) tryAcquire(ctx context.Context, token string) (bool, error) {
+	reply, err := r.do(ctx, "SET", r.key, token, "NX", "PX", strconv.FormatInt(leaseTTL.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+	return reply == "OK", nil
+}
+
+func (r *redisLease) refresh(ctx context.Context, token string) error {
+	reply, err := r.do(ctx, "EVAL", refreshScript, "1", r.key, token, strconv.FormatInt(leaseTTL.Milliseconds(), 10))
+	if err != nil {
+		return err
+	}
+	if reply != "1" {
+		return fmt.Errorf("redis key %s was taken over: %w", r.key, ErrLeaseLost)
+	}
+	return nil
+}
+
+func (r *redisLease) release(ctx context.Context, token string) error {
+	_, err := r.do(ctx, "EVAL", releaseScript, "1", r.key, token)
+	return err
+}
+
+// do runs command after authenticating and selecting the database, and
+// returns its reply: a status, integer or bulk string, or "" for nil.
+func (r *redisLease) do(ctx context.Context, command ...string) (string, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if r.tls {
+		host, _, _ := net.SplitHostPort(r.addr)
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", r.addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", r.addr)
+	}
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(10 * time.Second))
+	}
+
+	var commands [][]string
+	if r.password != "" {
+		if r.username != "" {
+			commands = append(commands, []string{"AUTH", r.username, r.password})
+		} else {
+			commands = append(commands, []string{"AUTH", r.password})
+		}
+	}
+	if r.db != "" {
+		commands = append(commands, []string{"SELECT", r.db})
+	}
+	commands = append(commands, command)
+
+	var request strings.Builder
+	for _, args := range commands {
+		fmt.Fprintf(&request, "*%d\r\n", len(args))
+		for _, arg := range args {
+			fmt.Fprintf(&request, "$%d\r\n%s\r\n", len(arg), arg)
+		}
+	}
+	if _, err := conn.Write([]byte(request.String())); err != nil {
+		return "", err
+	}
+
+	reader := bufio.NewReader(conn)
+	var reply string
+	for range commands {
+		if reply, err = readReply(reader); err != nil {
+			return "", err
+		}
+	}
+	return reply, nil
+}
+
+func readReply(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("empty redis reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("invalid redis reply %q", line)
+		}
+		if n < 0 {
+			return "", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(reader, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+	return "", fmt.Errorf("unexpected redis reply %q", line)
+}
@@ -0,0 +1,178 @@
+// Package writelock serializes changes to a tailnet, within this server and,
+// through a lease on a shared file or Redis key, across servers.
+package writelock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// retryInterval is how often a taken external lease is tried again.
+const retryInterval = 250 * time.Millisecond
+
+// leaseTTL is how long an external lease outlives a holder that stops
+// refreshing it, for example because it crashed. Tests shorten it.
+var leaseTTL = time.Minute
+
+// ErrTimeout is returned when the lock could not be taken in time.
+var ErrTimeout = errors.New("timed out waiting for another change to the tailnet to finish")
+
+// ErrLeaseLost is the cause of the cancellation of a held lock's context
+// when its external lease was taken over or could not be refreshed before
+// it expired.
+var ErrLeaseLost = errors.New("the shared write lock was lost")
+
+// lease is an exclusive claim on a shared resource that expires unless it
+// is refreshed.
+type lease interface {
+	// tryAcquire claims the lease for token, reporting false if someone
+	// else holds it.
+	tryAcquire(ctx context.Context, token string) (bool, error)
+	// refresh extends the lease held by token, returning an error wrapping
+	// ErrLeaseLost if token no longer holds it.
+	refresh(ctx context.Context, token string) error
+	release(ctx context.Context, token string) error
+}
+
+// Lock is the write lock of one tailnet.
+type Lock struct {
+	local    chan struct{}
+	external lease
+	timeout  time.Duration
+}
+
+// New returns the write lock configured by cfg. It is always held within
+// the process; cfg.WriteLock adds a file:// or redis:// lease shared with
+// other servers managing the same tailnet.
+func New(cfg *config.Config) *Lock {
+	l := &Lock{local: make(chan struct{}, 1), timeout: cfg.WriteLockTimeout}
+	if cfg.WriteLock == "" {
+		return l
+	}
+	// The URL was validated when the configuration was loaded.
+	u, _ := url.Parse(cfg.WriteLock)
+	switch u.Scheme {
+	case "file":
+		l.external = &fileLease{path: u.Path}
+	case "redis", "rediss":
+		l.external = newRedisLease(u, "tailscale-mcp:write-lock:"+cfg.TailscaleTailnet)
+	}
+	return l
+}
+
+// Acquire waits until the lock is free, or the configured timeout passes,
+// and takes it. Changes made under the lock should use the returned
+// context, which is cancelled with ErrLeaseLost as its cause if the lock is
+// lost while held. Call the returned function to release it.
+func (l *Lock) Acquire(ctx context.Context) (context.Context, func(), error) {
+	waitCtx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	select {
+	case l.local <- struct{}{}:
+	case <-waitCtx.Done():
+		return nil, nil, waitError(waitCtx)
+	}
+	if l.external == nil {
+		return ctx, func() { <-l.local }, nil
+	}
+
+	token, err := newToken()
+	if err != nil {
+		<-l.local
+		return nil, nil, err
+	}
+	for {
+		ok, err := l.external.tryAcquire(waitCtx, token)
+		if err != nil && waitCtx.Err() != nil {
+			// The attempt was cut short by the wait running out.
+			<-l.local
+			return nil, nil, waitError(waitCtx)
+		}
+		if err != nil {
+			<-l.local
+			return nil, nil, fmt.Errorf("failed to take the shared write lock: %w", err)
+		}
+		if ok {
+			break
+		}
+		select {
+		case <-time.After(retryInterval):
+		case <-waitCtx.Done():
+			<-l.local
+			return nil, nil, waitError(waitCtx)
+		}
+	}
+
+	heldCtx, lost := context.WithCancelCause(ctx)
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		l.keepAlive(token, done, lost)
+	}()
+	return heldCtx, func() {
+		// A refresh still running could otherwise outlast the release.
+		close(done)
+		<-stopped
+		lost(nil)
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := l.external.release(releaseCtx, token); err != nil {
+			log.Printf("warning: Failed to release the shared write lock: %v", err)
+		}
+		<-l.local
+	}, nil
+}
+
+// keepAlive refreshes the external lease until done is closed. It calls
+// lost once the lease was taken over, or once it would expire before the
+// next refresh, since another server may then make changes of its own.
+func (l *Lock) keepAlive(token string, done chan struct{}, lost context.CancelCauseFunc) {
+	interval := leaseTTL / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	refreshed := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			err := l.external.refresh(ctx, token)
+			cancel()
+			if err == nil {
+				refreshed = time.Now()
+				continue
+			}
+			if errors.Is(err, ErrLeaseLost) || time.Since(refreshed)+interval >= leaseTTL {
+				log.Printf("error: Lost the shared write lock, stopping the change made under it: %v", err)
+				lost(ErrLeaseLost)
+				return
+			}
+			log.Printf("warning: Failed to refresh the shared write lock: %v", err)
+		}
+	}
+}
+
+func waitError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
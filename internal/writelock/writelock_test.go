@@ -0,0 +1,437 @@
+package writelock
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+func newFileLock(t *testing.T, path string) *Lock {
+	t.Helper()
+	return New(&config.Config{WriteLock: "file://" + path, WriteLockTimeout: 500 * time.Millisecond})
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+// waitLost waits for ctx to be cancelled because its lease was lost.
+func waitLost(t *testing.T, ctx context.Context) {
+	t.Helper()
+	select {
+	case <-ctx.Done():
+		if cause := context.Cause(ctx); !errors.Is(cause, ErrLeaseLost) {
+			t.Errorf("got cause %v, want ErrLeaseLost", cause)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the held context was not cancelled")
+	}
+}
+
+func TestLocal(t *testing.T) {
+	l := New(&config.Config{WriteLockTimeout: 100 * time.Millisecond})
+	_, unlock, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := l.Acquire(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("got %v while the lock was held, want ErrTimeout", err)
+	}
+	unlock()
+	_, unlock, err = l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("got %v after the lock was released", err)
+	}
+	unlock()
+}
+
+func TestFileLease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	a, b := newFileLock(t, path), newFileLock(t, path)
+
+	ctx, unlock, err := a.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readFile(t, path) == "" {
+		t.Errorf("the lock file holds no token")
+	}
+	if _, _, err := b.Acquire(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("got %v while another server held the file, want ErrTimeout", err)
+	}
+	unlock()
+	if ctx.Err() == nil {
+		t.Errorf("the held context was not cancelled on release")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the lock file was not removed on release: %v", err)
+	}
+
+	_, unlock, err = b.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("got %v after the file was released", err)
+	}
+	unlock()
+}
+
+func TestFileLeaseStale(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lock")
+	if err := os.WriteFile(path, []byte("crashed"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * leaseTTL)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	_, unlock, err := newFileLock(t, path).Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("the stale file was not taken over: %v", err)
+	}
+	if holder := readFile(t, path); holder == "crashed" {
+		t.Errorf("the lock file still holds the crashed server's token")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want only the lock file left", len(entries))
+	}
+	unlock()
+}
+
+func TestFileLeaseLive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	if err := os.WriteFile(path, []byte("other"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := newFileLock(t, path).Acquire(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("got %v for a live lock file, want ErrTimeout", err)
+	}
+	if holder := readFile(t, path); holder != "other" {
+		t.Errorf("the live lock file now holds %q", holder)
+	}
+}
+
+func TestFileLeaseTakeOverStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	f := &fileLease{path: path}
+
+	// Another server that took the stale file over first leaves a live one,
+	// which is not taken over again.
+	if err := os.WriteFile(path, []byte("successor"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.takeOverStale("token"); err != nil {
+		t.Fatal(err)
+	}
+	if holder := readFile(t, path); holder != "successor" {
+		t.Errorf("the successor's file now holds %q", holder)
+	}
+
+	// Nor is a missing file an error.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.takeOverStale("token"); err != nil {
+		t.Errorf("got %v for a missing file", err)
+	}
+}
+
+func TestFileLeaseLost(t *testing.T) {
+	SetLeaseTTL(t, 300*time.Millisecond)
+	path := filepath.Join(t.TempDir(), "lock")
+	ctx, unlock, err := newFileLock(t, path).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Held leases are refreshed, so they do not go stale.
+	time.Sleep(2 * leaseTTL)
+	if ctx.Err() != nil {
+		t.Fatalf("the lease was lost while refreshed: %v", context.Cause(ctx))
+	}
+
+	if err := os.WriteFile(path, []byte("other"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	waitLost(t, ctx)
+	unlock()
+	if holder := readFile(t, path); holder != "other" {
+		t.Errorf("releasing a lost lease changed the lock file to %q", holder)
+	}
+}
+
+// fakeRedis is a Redis server that knows the commands redisLease sends.
+type fakeRedis struct {
+	listener net.Listener
+	password string
+
+	mu       sync.Mutex
+	values   map[string]string
+	expiries map[string]time.Time
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &fakeRedis{listener: listener, password: password, values: map[string]string{}, expiries: map[string]time.Time{}}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go r.serve(conn)
+		}
+	}()
+	return r
+}
+
+func (r *fakeRedis) url() string {
+	if r.password != "" {
+		return "redis://:" + r.password + "@" + r.listener.Addr().String() + "/2"
+	}
+	return "redis://" + r.listener.Addr().String()
+}
+
+func (r *fakeRedis) set(key, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[key] = value
+	r.expiries[key] = time.Now().Add(time.Hour)
+}
+
+func (r *fakeRedis) get(key string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookup(key)
+}
+
+// lookup returns the value of key if it has not expired; r.mu is held.
+func (r *fakeRedis) lookup(key string) (string, bool) {
+	if time.Now().After(r.expiries[key]) {
+		delete(r.values, key)
+	}
+	value, ok := r.values[key]
+	return value, ok
+}
+
+// sent returns the commands run after authenticating.
+func (r *fakeRedis) sent() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.commands, " ")
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	authenticated := r.password == ""
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+		var reply string
+		switch {
+		case args[0] == "AUTH":
+			authenticated = args[len(args)-1] == r.password
+			reply = "+OK"
+			if !authenticated {
+				reply = "-WRONGPASS invalid password"
+			}
+		case !authenticated:
+			reply = "-NOAUTH Authentication required."
+		default:
+			reply = r.run(args)
+		}
+		if _, err := io.WriteString(conn, reply+"\r\n"); err != nil {
+			return
+		}
+	}
+}
+
+func (r *fakeRedis) run(args []string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands = append(r.commands, args[0])
+	switch args[0] {
+	case "SELECT":
+		return "+OK"
+	case "SET":
+		// SET key value NX PX ms
+		if _, ok := r.lookup(args[1]); ok {
+			return "$-1"
+		}
+		ms, _ := strconv.Atoi(args[5])
+		r.values[args[1]] = args[2]
+		r.expiries[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return "+OK"
+	case "EVAL":
+		// EVAL script 1 key token [ms]
+		key, token := args[3], args[4]
+		if value, ok := r.lookup(key); !ok || value != token {
+			return ":0"
+		}
+		switch args[1] {
+		case refreshScript:
+			ms, _ := strconv.Atoi(args[5])
+			r.expiries[key] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		case releaseScript:
+			delete(r.values, key)
+		default:
+			return "-ERR unknown script"
+		}
+		return ":1"
+	}
+	return "-ERR unknown command " + args[0]
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+	if err != nil || line[0] != '*' {
+		return nil, fmt.Errorf("invalid command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		if args[i], err = readReply(reader); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+func newRedisLock(t *testing.T, r *fakeRedis) *Lock {
+	t.Helper()
+	return New(&config.Config{WriteLock: r.url(), WriteLockTimeout: 500 * time.Millisecond, TailscaleTailnet: "example.com"})
+}
+
+const redisKey = "tailscale-mcp:write-lock:example.com"
+
+func TestRedisLease(t *testing.T) {
+	r := newFakeRedis(t, "secret")
+	a, b := newRedisLock(t, r), newRedisLock(t, r)
+
+	_, unlock, err := a.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.get(redisKey); !ok {
+		t.Errorf("the lease key %s was not set", redisKey)
+	}
+	if _, _, err := b.Acquire(context.Background()); !errors.Is(err, ErrTimeout) {
+		t.Errorf("got %v while another server held the key, want ErrTimeout", err)
+	}
+	unlock()
+	if value, ok := r.get(redisKey); ok {
+		t.Errorf("the lease key still holds %q after release", value)
+	}
+
+	_, unlock, err = b.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("got %v after the key was released", err)
+	}
+	unlock()
+	if commands := r.sent(); !strings.Contains(commands, "SELECT") {
+		t.Errorf("got commands %s, want the database selected", commands)
+	}
+}
+
+func TestRedisLeaseAuth(t *testing.T) {
+	r := newFakeRedis(t, "secret")
+	u := strings.Replace(r.url(), "secret", "wrong", 1)
+	l := New(&config.Config{WriteLock: u, WriteLockTimeout: 500 * time.Millisecond, TailscaleTailnet: "example.com"})
+	if _, _, err := l.Acquire(context.Background()); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("got %v with a wrong password, want the server's error", err)
+	}
+}
+
+func TestRedisLeaseTakenOver(t *testing.T) {
+	SetLeaseTTL(t, 300*time.Millisecond)
+	r := newFakeRedis(t, "")
+	ctx, unlock, err := newRedisLock(t, r).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(2 * leaseTTL)
+	if ctx.Err() != nil {
+		t.Fatalf("the lease was lost while refreshed: %v", context.Cause(ctx))
+	}
+
+	r.set(redisKey, "other")
+	waitLost(t, ctx)
+	unlock()
+	if value, _ := r.get(redisKey); value != "other" {
+		t.Errorf("releasing a lost lease changed the key to %q", value)
+	}
+}
+
+func TestRedisLeaseUnreachable(t *testing.T) {
+	SetLeaseTTL(t, 300*time.Millisecond)
+	r := newFakeRedis(t, "")
+	ctx, unlock, err := newRedisLock(t, r).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+
+	// Once refreshes fail for as long as the lease lasts, another server
+	// may have taken it.
+	r.listener.Close()
+	waitLost(t, ctx)
+}
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		reply   string
+		want    string
+		wantErr bool
+	}{
+		{"+OK\r\n", "OK", false},
+		{":1\r\n", "1", false},
+		{"$5\r\nhello\r\n", "hello", false},
+		{"$0\r\n\r\n", "", false},
+		{"$-1\r\n", "", false},
+		{"-ERR wrong\r\n", "", true},
+		{"\r\n", "", true},
+		{"$x\r\n", "", true},
+		{"*1\r\n", "", true},
+		{"$5\r\nhel", "", true},
+	}
+	for _, tt := range tests {
+		got, err := readReply(bufio.NewReader(strings.NewReader(tt.reply)))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("readReply(%q) = %q, %v, want %q, error %v", tt.reply, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
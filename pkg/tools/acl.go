@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"tailscale.com/client/tailscale/v2"
+)
+
+// expandGroup returns the users in a policy group, following nested group
+// references. Cycles are ignored.
+func expandGroup(policy *tailscale.ACL, group string) []string {
+	members := make(map[string]bool)
+	seen := make(map[string]bool)
+
+	var walk func(name string)
+	walk = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		for _, member := range policy.Groups[name] {
+			if strings.HasPrefix(member, "group:") {
+				walk(member)
+				continue
+			}
+			members[member] = true
+		}
+	}
+	walk(group)
+
+	return slices.Sorted(maps.Keys(members))
+}
+
+// userGroups returns the policy groups, including groups reached through
+// nesting, that contain the given login name.
+func userGroups(policy *tailscale.ACL, loginName string) []string {
+	var groups []string
+	for _, group := range slices.Sorted(maps.Keys(policy.Groups)) {
+		if slices.ContainsFunc(expandGroup(policy, group), func(member string) bool {
+			return strings.EqualFold(member, loginName)
+		}) {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// userAutogroups returns the role- and type-based autogroups a user belongs to.
+func userAutogroups(user tailscale.User) []string {
+	var autogroups []string
+	switch user.Type {
+	case tailscale.UserTypeShared:
+		autogroups = append(autogroups, "autogroup:shared")
+	default:
+		autogroups = append(autogroups, "autogroup:member")
+		if user.Role != "" && user.Role != tailscale.UserRoleMember {
+			autogroups = append(autogroups, "autogroup:"+string(user.Role))
+		}
+	}
+	return autogroups
+}
+
+// userPrincipals returns every policy principal that refers to the user: the
+// login name itself, the groups containing it, and its autogroups.
+func userPrincipals(policy *tailscale.ACL, user tailscale.User) []string {
+	principals := []string{user.LoginName}
+	principals = append(principals, userGroups(policy, user.LoginName)...)
+	return append(principals, userAutogroups(user)...)
+}
+
+// tagsOwnedBy returns the tags whose tagOwners include any of the principals.
+func tagsOwnedBy(policy *tailscale.ACL, principals []string) []string {
+	var tags []string
+	for _, tag := range slices.Sorted(maps.Keys(policy.TagOwners)) {
+		for _, owner := range policy.TagOwners[tag] {
+			if slices.ContainsFunc(principals, func(p string) bool { return strings.EqualFold(p, owner) }) {
+				tags = append(tags, tag)
+				break
+			}
+		}
+	}
+	return tags
+}
+
+// nestedGroups returns the groups reachable from group through nesting, not
+// including group itself.
+func nestedGroups(policy *tailscale.ACL, group string) []string {
+	seen := map[string]bool{group: true}
+	var nested []string
+
+	var walk func(name string)
+	walk = func(name string) {
+		for _, member := range policy.Groups[name] {
+			if strings.HasPrefix(member, "group:") && !seen[member] {
+				seen[member] = true
+				nested = append(nested, member)
+				walk(member)
+			}
+		}
+	}
+	walk(group)
+
+	slices.Sort(nested)
+	return nested
+}
+
+// principalUsers expands a policy source principal into user login names.
+// Tags, hosts, and IP ranges identify devices rather than users and expand to
+// nothing.
+func principalUsers(policy *tailscale.ACL, users []tailscale.User, principal string) []string {
+	var logins []string
+	switch {
+	case principal == "*":
+		for _, user := range users {
+			logins = append(logins, user.LoginName)
+		}
+	case strings.HasPrefix(principal, "group:"):
+		logins = expandGroup(policy, principal)
+	case strings.HasPrefix(principal, "autogroup:"):
+		for _, user := range users {
+			if slices.Contains(userAutogroups(user), principal) {
+				logins = append(logins, user.LoginName)
+			}
+		}
+	case strings.Contains(principal, "@"):
+		logins = []string{principal}
+	}
+	return logins
+}
+
+// deviceMatchesTarget reports whether a policy destination selects device.
+// owner is the device's owning user, or nil for tagged or external devices.
+// autogroup:self depends on the source and is left to the caller.
+func deviceMatchesTarget(policy *tailscale.ACL, device tailscale.Device, owner *tailscale.User, target string) bool {
+	switch {
+	case target == "*":
+		return true
+	case strings.HasPrefix(target, "tag:"):
+		return slices.Contains(device.Tags, target)
+	case target == "autogroup:tagged":
+		return len(device.Tags) > 0
+	case len(device.Tags) > 0 || owner == nil:
+		// Tagged devices are not owned by users, groups, or autogroups.
+		return false
+	case strings.HasPrefix(target, "group:"):
+		return slices.Contains(userGroups(policy, owner.LoginName), target)
+	case strings.HasPrefix(target, "autogroup:"):
+		return slices.Contains(userAutogroups(*owner), target)
+	default:
+		return strings.EqualFold(target, owner.LoginName)
+	}
+}
@@ -1,9 +1,16 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -23,54 +30,149 @@ func (at *AdditionalTools) RegisterTools(mcpServer *server.MCPServer) {
 	// Webhook tools
 	tool := mcp.NewTool(
 		"tailscale_webhooks_list",
+		readOnlyTool,
+		outputSchema[listOutput[tailscale.Webhook]](),
+		withPagination,
 		mcp.WithDescription("List all webhook endpoints configured for the tailnet. Returns webhook endpoint URLs, subscription types, and status information. Use this to manage and monitor event notifications sent to external systems. OAuth Scope: webhooks:read."),
 	)
 	mcpServer.AddTool(tool, at.ListWebhooks)
 
 	tool = mcp.NewTool(
 		"tailscale_webhook_create",
+		createTool,
 		mcp.WithDescription("Create a new webhook endpoint to receive tailnet events. Configure the endpoint URL and specify which event types to subscribe to (e.g., device changes, user events). Essential for integrating Tailscale with external monitoring and automation systems. OAuth Scope: webhooks:write."),
 		mcp.WithString("endpoint_url", mcp.Description("The URL where webhook events will be sent"), mcp.Required()),
-		mcp.WithArray("subscriptions", mcp.Description("List of event types to subscribe to"), mcp.WithStringItems(), mcp.Required()),
+		mcp.WithArray("subscriptions", mcp.Description("List of event types to subscribe to (see tailscale_webhook_subscription_types)"), mcp.WithStringItems(), mcp.Required()),
+		withRevealSecrets,
 	)
 	mcpServer.AddTool(tool, at.CreateWebhook)
 
 	tool = mcp.NewTool(
 		"tailscale_webhook_get",
+		readOnlyTool,
+		outputSchema[tailscale.Webhook](),
 		mcp.WithDescription("Get detailed information about a specific webhook endpoint. Returns endpoint configuration, subscription types, delivery status, and webhook statistics. Use this to monitor webhook performance and troubleshoot delivery issues. OAuth Scope: webhooks:read."),
 		mcp.WithString("endpoint_id", mcp.Description("The webhook endpoint ID"), mcp.Required()),
 	)
 	mcpServer.AddTool(tool, at.GetWebhook)
 
+	tool = mcp.NewTool(
+		"tailscale_webhook_update",
+		updateTool,
+		mcp.WithDescription("Update the event types a webhook endpoint is subscribed to. The given list replaces the current subscriptions. Unlike deleting and recreating the endpoint, this keeps its ID and signing secret, so receivers that verify signatures keep working. OAuth Scope: webhooks:write."),
+		mcp.WithString("endpoint_id", mcp.Description("The webhook endpoint ID"), mcp.Required()),
+		mcp.WithArray("subscriptions", mcp.Description("Complete list of event types to subscribe to (see tailscale_webhook_subscription_types)"), mcp.WithStringItems(), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, at.UpdateWebhook)
+
+	tool = mcp.NewTool(
+		"tailscale_webhook_test",
+		createTool,
+		mcp.WithDescription("Send a synthetic test event to a webhook endpoint. The event is signed like a real one, so this verifies that the receiver is reachable and that its Tailscale-Webhook-Signature validation works before relying on the endpoint. Delivery happens asynchronously; check the receiver to confirm it arrived. OAuth Scope: webhooks:write."),
+		mcp.WithString("endpoint_id", mcp.Description("The webhook endpoint ID to test"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, at.TestWebhook)
+
 	tool = mcp.NewTool(
 		"tailscale_webhook_delete",
+		destructiveTool,
 		mcp.WithDescription("Delete a webhook endpoint permanently. This stops all event notifications to the specified endpoint. Use this to remove unused or misconfigured webhooks. Essential for maintaining clean webhook configurations. OAuth Scope: webhooks:write."),
 		mcp.WithString("endpoint_id", mcp.Description("The webhook endpoint ID to delete"), mcp.Required()),
 	)
 	mcpServer.AddTool(tool, at.DeleteWebhook)
 
+	tool = mcp.NewTool(
+		"tailscale_webhook_subscription_types",
+		readOnlyTool,
+		mcp.WithDescription("List the event types a webhook endpoint can subscribe to, grouped by category, with what triggers each. Subscribing to a category also subscribes to event types added to it later. tailscale_webhook_create and tailscale_webhook_update only accept these names."),
+	)
+	mcpServer.AddTool(tool, at.ListWebhookSubscriptionTypes)
+
 	// Logging tools
 	tool = mcp.NewTool(
 		"tailscale_logging_configuration_get",
+		readOnlyTool,
 		mcp.WithDescription("Get configuration audit logs for the tailnet. Returns log streaming configuration for administrative and policy changes. Essential for compliance, security auditing, and troubleshooting configuration issues. Learn more about logging at /kb/1349/log-events. OAuth Scope: logging:read."),
 	)
 	mcpServer.AddTool(tool, at.GetConfigurationLogs)
 
 	tool = mcp.NewTool(
 		"tailscale_logging_network_get",
+		readOnlyTool,
 		mcp.WithDescription("Get network flow logs for the tailnet. Returns log streaming configuration for network traffic and connection data. Essential for network monitoring, security analysis, and troubleshooting connectivity issues. Learn more about logging at /kb/1349/log-events. OAuth Scope: logging:read."),
 	)
 	mcpServer.AddTool(tool, at.GetNetworkLogs)
 
+	tool = mcp.NewTool(
+		"tailscale_logging_stream_set",
+		destructiveTool,
+		mcp.WithDescription("Configure streaming of configuration audit logs or network flow logs to a SIEM or log platform (Splunk, Elastic, Panther, Cribl, Datadog, or Axiom) or to an Amazon S3 bucket. Replaces any existing stream for the log type. For S3 with role authentication, first get an external ID with tailscale_logging_aws_external_id_get and allow Tailscale to assume the role with it. Network flow logs must also be enabled in the tailnet settings. Learn more about log streaming at /kb/1255/log-streaming. OAuth Scope: logging:write."),
+		mcp.WithString("log_type", mcp.Description("Log type to stream"), mcp.Enum("configuration", "network"), mcp.Required()),
+		mcp.WithString("destination_type", mcp.Description("Destination platform"), mcp.Enum("splunk", "elastic", "panther", "cribl", "datadog", "axiom", "s3"), mcp.Required()),
+		mcp.WithString("url", mcp.Description("URL of the destination's ingestion endpoint (required except for s3)")),
+		mcp.WithString("token", mcp.Description("Token or API key used to authenticate to the destination (required except for s3)")),
+		mcp.WithString("user", mcp.Description("User name, for destinations that authenticate with a user and token (Elastic, Cribl)")),
+		mcp.WithString("s3_bucket", mcp.Description("S3 bucket name (s3 only)")),
+		mcp.WithString("s3_region", mcp.Description("AWS region of the bucket, e.g. 'us-east-1' (s3 only)")),
+		mcp.WithString("s3_key_prefix", mcp.Description("Prefix for the object keys logs are written to (s3 only)")),
+		mcp.WithString("s3_authentication_type", mcp.Description("How Tailscale authenticates to AWS (s3 only, default: rolearn)"), mcp.Enum("rolearn", "accesskey")),
+		mcp.WithString("s3_role_arn", mcp.Description("ARN of the IAM role Tailscale assumes (rolearn)")),
+		mcp.WithString("s3_external_id", mcp.Description("External ID from tailscale_logging_aws_external_id_get, required by the role's trust policy (rolearn)")),
+		mcp.WithString("s3_access_key_id", mcp.Description("AWS access key ID (accesskey)")),
+		mcp.WithString("s3_secret_access_key", mcp.Description("AWS secret access key (accesskey)")),
+		mcp.WithNumber("upload_period_minutes", mcp.Description("How often logs are uploaded, in minutes (s3 only)")),
+		mcp.WithString("compression_format", mcp.Description("Compression of uploaded log objects (s3 only)"), mcp.Enum("none", "zstd", "gzip")),
+	)
+	mcpServer.AddTool(tool, at.SetLogstream)
+
+	tool = mcp.NewTool(
+		"tailscale_logging_stream_delete",
+		destructiveTool,
+		mcp.WithDescription("Stop streaming a log type and delete its logstream configuration. Logs remain available in the admin console for the retention period; only the external destination is removed. Use this to retire or fix a misconfigured destination. OAuth Scope: logging:write."),
+		mcp.WithString("log_type", mcp.Description("Log type whose stream to delete"), mcp.Enum("configuration", "network"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, at.DeleteLogstream)
+
+	tool = mcp.NewTool(
+		"tailscale_logging_stream_validate",
+		updateTool,
+		mcp.WithDescription("Check that a log stream's destination is accepting events. Sends a test event to the destination, or for S3 with role authentication has the API check the trust policy, and returns the result with the stream's configuration and delivery status (last activity, last error, entries and bytes sent, failed requests) and a verdict: ok, failing, or no_activity_yet. The API does not return the destination's token, so pass it as token to test the credentials too; without it the test only shows whether the destination is reachable. OAuth Scope: logging:read."),
+		mcp.WithString("log_type", mcp.Description("Log type whose stream to check"), mcp.Enum("configuration", "network"), mcp.Required()),
+		mcp.WithString("token", mcp.Description("Token or API key of the destination, to authenticate the test event")),
+		mcp.WithBoolean("test_delivery", mcp.Description("Send a test event to the destination (default: true)"), mcp.DefaultBool(true)),
+	)
+	mcpServer.AddTool(tool, at.ValidateLogstream)
+
+	tool = mcp.NewTool(
+		"tailscale_logging_aws_external_id_get",
+		updateTool,
+		mcp.WithDescription("Get an AWS external ID for streaming logs to S3 with role authentication, creating one for the tailnet when needed. Returns the external ID and the Tailscale AWS account ID to put in the IAM role's trust policy, so that only Tailscale acting for this tailnet can assume the role. OAuth Scope: logging:write."),
+		mcp.WithBoolean("reusable", mcp.Description("Return an existing reusable external ID instead of creating a single-use one (default: false)")),
+	)
+	mcpServer.AddTool(tool, at.GetAWSExternalID)
+
+	tool = mcp.NewTool(
+		"tailscale_logging_aws_trust_policy_validate",
+		readOnlyTool,
+		mcp.WithDescription("Check that Tailscale can assume an IAM role with, and only with, the given external ID. Run this after updating the role's trust policy and before configuring an S3 log stream with tailscale_logging_stream_set. OAuth Scope: logging:write."),
+		mcp.WithString("external_id", mcp.Description("External ID from tailscale_logging_aws_external_id_get"), mcp.Required()),
+		mcp.WithString("role_arn", mcp.Description("ARN of the IAM role to check"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, at.ValidateAWSTrustPolicy)
+
 	// Device posture tools
 	tool = mcp.NewTool(
 		"tailscale_device_posture_integrations_list",
+		readOnlyTool,
+		outputSchema[listOutput[tailscale.PostureIntegration]](),
+		withPagination,
 		mcp.WithDescription("List device posture integrations configured for the tailnet. Returns integrations with device posture data providers like CrowdStrike, Microsoft Intune, and others. Essential for managing device security compliance and conditional access policies. Learn more about device posture at /kb/1288/device-posture. OAuth Scope: posture:read."),
 	)
 	mcpServer.AddTool(tool, at.ListPostureIntegrations)
 
 	tool = mcp.NewTool(
 		"tailscale_device_posture_integration_create",
+		createTool,
 		mcp.WithDescription("Create a new device posture integration with security providers like CrowdStrike, Microsoft Intune, or others. Configure OAuth credentials and provider-specific settings to enable device security data collection. Essential for implementing zero-trust security policies based on device compliance. OAuth Scope: posture:write."),
 		mcp.WithString("provider", mcp.Description("The posture provider (e.g., 'crowdstrike', 'intune')"), mcp.Required()),
 		mcp.WithString("client_id", mcp.Description("OAuth client ID for the integration"), mcp.Required()),
@@ -81,13 +183,28 @@ func (at *AdditionalTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_device_posture_integration_get",
+		readOnlyTool,
+		outputSchema[tailscale.PostureIntegration](),
 		mcp.WithDescription("Get detailed information about a specific device posture integration. Returns integration configuration, connection status, and data collection statistics. Use this to monitor integration health and troubleshoot device posture data issues. OAuth Scope: posture:read."),
 		mcp.WithString("id", mcp.Description("The integration ID"), mcp.Required()),
 	)
 	mcpServer.AddTool(tool, at.GetPostureIntegration)
 
+	tool = mcp.NewTool(
+		"tailscale_device_posture_integration_update",
+		updateTool,
+		mcp.WithDescription("Update a device posture integration in place, for example to rotate its client secret or move it to another tenant. Only the fields given are changed; the client secret is kept unless a new one is supplied. Unlike deleting and recreating the integration, this keeps its ID and does not interrupt posture data collection. OAuth Scope: posture:write."),
+		mcp.WithString("id", mcp.Description("The integration ID"), mcp.Required()),
+		mcp.WithString("client_id", mcp.Description("New OAuth client ID")),
+		mcp.WithString("client_secret", mcp.Description("New OAuth client secret")),
+		mcp.WithString("tenant_id", mcp.Description("New tenant ID")),
+		mcp.WithString("cloud_id", mcp.Description("New cloud ID or region, for providers that use one")),
+	)
+	mcpServer.AddTool(tool, at.UpdatePostureIntegration)
+
 	tool = mcp.NewTool(
 		"tailscale_device_posture_integration_delete",
+		destructiveTool,
 		mcp.WithDescription("Delete a device posture integration permanently. This stops device security data collection from the specified provider. Use this to remove unused or misconfigured integrations. Note that this may affect security policies that depend on posture data. OAuth Scope: posture:write."),
 		mcp.WithString("id", mcp.Description("The integration ID to delete"), mcp.Required()),
 	)
@@ -96,13 +213,16 @@ func (at *AdditionalTools) RegisterTools(mcpServer *server.MCPServer) {
 	// Tailnet settings tools
 	tool = mcp.NewTool(
 		"tailscale_tailnet_settings_get",
+		readOnlyTool,
+		outputSchema[tailscale.TailnetSettings](),
 		mcp.WithDescription("Get tailnet settings and configuration. Returns device approval settings, user permissions, key duration, logging preferences, routing options, and posture collection settings. Essential for understanding and managing tailnet policies and behavior. OAuth Scope: settings:read."),
 	)
 	mcpServer.AddTool(tool, at.GetTailnetSettings)
 
 	tool = mcp.NewTool(
 		"tailscale_tailnet_settings_update",
-		mcp.WithDescription("Update tailnet settings and configuration. Configure device approval requirements, automatic updates, key durations, user permissions, network logging, regional routing, and posture data collection. Changes affect all devices and users in the tailnet. Use with caution as settings impact security and connectivity. OAuth Scope: settings:write."),
+		updateTool,
+		mcp.WithDescription("Update tailnet settings and configuration. Configure device approval requirements, automatic updates, key durations, user permissions, network logging, regional routing, and posture data collection. Changes affect all devices and users in the tailnet. Use with caution as settings impact security and connectivity; use dry_run to show the change for confirmation first. OAuth Scope: settings:write."),
 		mcp.WithBoolean("devices_approval_on", mcp.Description("Whether device approval is required")),
 		mcp.WithBoolean("devices_auto_updates_on", mcp.Description("Whether devices should auto-update")),
 		mcp.WithNumber("devices_key_duration_days", mcp.Description("Default key duration in days")),
@@ -111,23 +231,36 @@ func (at *AdditionalTools) RegisterTools(mcpServer *server.MCPServer) {
 		mcp.WithBoolean("network_flow_logging_on", mcp.Description("Whether network flow logging is enabled")),
 		mcp.WithBoolean("regional_routing_on", mcp.Description("Whether regional routing is enabled")),
 		mcp.WithBoolean("posture_identity_collection_on", mcp.Description("Whether posture identity collection is enabled")),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a before/after diff of the settings being changed without applying it")),
 	)
 	mcpServer.AddTool(tool, at.UpdateTailnetSettings)
+
+	at.registerSettingsTools(mcpServer)
+	at.registerPostureTools(mcpServer)
 }
 
 func (at *AdditionalTools) ListWebhooks(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		pageArgs
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	if args.Cursor != "" {
+		return nextPage[tailscale.Webhook](request.Params.Name, args.pageArgs, "webhooks")
+	}
+
 	client := at.client.GetClient()
 	webhooks, err := client.Webhooks().List(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to list webhooks: %v", err)), nil
 	}
 
-	webhooksJSON, err := json.MarshalIndent(webhooks, "", "  ")
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal webhooks: %v", err)), nil
-	}
-
-	return mcp.NewToolResultText(string(webhooksJSON)), nil
+	return firstPage(request.Params.Name, webhooks, args.pageArgs, "webhooks")
 }
 
 func (at *AdditionalTools) CreateWebhook(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -140,10 +273,9 @@ func (at *AdditionalTools) CreateWebhook(ctx context.Context, request mcp.CallTo
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
 	}
 
-	// Convert string subscriptions to WebhookSubscriptionType
-	subscriptions := make([]tailscale.WebhookSubscriptionType, len(args.Subscriptions))
-	for i, sub := range args.Subscriptions {
-		subscriptions[i] = tailscale.WebhookSubscriptionType(sub)
+	subscriptions, err := webhookSubscriptionTypes(args.Subscriptions)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 
 	createReq := tailscale.CreateWebhookRequest{
@@ -185,9 +317,58 @@ func (at *AdditionalTools) GetWebhook(ctx context.Context, request mcp.CallToolR
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal webhook: %v", err)), nil
 	}
 
+	return mcp.NewToolResultStructured(webhook, string(webhookJSON)), nil
+}
+
+func (at *AdditionalTools) UpdateWebhook(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		EndpointID    string   `json:"endpoint_id"`
+		Subscriptions []string `json:"subscriptions"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if len(args.Subscriptions) == 0 {
+		return mcp.NewToolResultError("subscriptions must not be empty; use tailscale_webhook_delete to remove the endpoint"), nil
+	}
+
+	subscriptions, err := webhookSubscriptionTypes(args.Subscriptions)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := at.client.GetClient()
+	webhook, err := client.Webhooks().Update(ctx, args.EndpointID, subscriptions)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to update webhook: %v", err)), nil
+	}
+
+	webhookJSON, err := json.MarshalIndent(webhook, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal webhook: %v", err)), nil
+	}
+
 	return mcp.NewToolResultText(string(webhookJSON)), nil
 }
 
+func (at *AdditionalTools) TestWebhook(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		EndpointID string `json:"endpoint_id"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	client := at.client.GetClient()
+	if err := client.Webhooks().Test(ctx, args.EndpointID); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to test webhook: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Test event queued for delivery to webhook %s", args.EndpointID)), nil
+}
+
 func (at *AdditionalTools) DeleteWebhook(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
 		EndpointID string `json:"endpoint_id"`
@@ -205,6 +386,69 @@ func (at *AdditionalTools) DeleteWebhook(ctx context.Context, request mcp.CallTo
 	return mcp.NewToolResultText(fmt.Sprintf("Webhook %s deleted successfully", args.EndpointID)), nil
 }
 
+// webhookSubscription describes an event type a webhook can subscribe to.
+type webhookSubscription struct {
+	Type        tailscale.WebhookSubscriptionType `json:"type"`
+	Category    string                            `json:"category"`
+	Description string                            `json:"description"`
+}
+
+// webhookSubscriptions is the catalog of subscription types the API accepts.
+// The API's error for an unknown name does not say which one is wrong, so
+// subscriptions are checked against this list before a request is sent.
+var webhookSubscriptions = []webhookSubscription{
+	{tailscale.WebhookCategoryTailnetManagement, "tailnet_management", "All tailnet management events, including ones added in the future"},
+	{tailscale.WebhookNodeCreated, "tailnet_management", "A device was added to the tailnet"},
+	{tailscale.WebhookNodeNeedsApproval, "tailnet_management", "A device was added and is waiting for approval"},
+	{tailscale.WebhookNodeApproved, "tailnet_management", "A device was approved"},
+	{tailscale.WebhookNodeKeyExpiringInOneDay, "tailnet_management", "A device's node key expires within 24 hours"},
+	{tailscale.WebhookNodeKeyExpired, "tailnet_management", "A device's node key expired"},
+	{tailscale.WebhookNodeDeleted, "tailnet_management", "A device was removed from the tailnet"},
+	{tailscale.WebhookPolicyUpdate, "tailnet_management", "The policy file was updated"},
+	{tailscale.WebhookUserCreated, "tailnet_management", "A user joined the tailnet"},
+	{tailscale.WebhookUserNeedsApproval, "tailnet_management", "A user joined and is waiting for approval"},
+	{tailscale.WebhookUserSuspended, "tailnet_management", "A user was suspended"},
+	{tailscale.WebhookUserRestored, "tailnet_management", "A suspended user was restored"},
+	{tailscale.WebhookUserDeleted, "tailnet_management", "A user was deleted"},
+	{tailscale.WebhookUserApproved, "tailnet_management", "A user was approved"},
+	{tailscale.WebhookUserRoleUpdated, "tailnet_management", "A user's role changed"},
+	{tailscale.WebhookCategoryDeviceMisconfigurations, "device_misconfigurations", "All device misconfiguration events, including ones added in the future"},
+	{tailscale.WebhookSubnetIPForwardingNotEnabled, "device_misconfigurations", "A subnet router advertises routes but does not have IP forwarding enabled"},
+	{tailscale.WebhookExitNodeIPForwardingNotEnabled, "device_misconfigurations", "An exit node does not have IP forwarding enabled"},
+}
+
+func (at *AdditionalTools) ListWebhookSubscriptionTypes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	typesJSON, err := json.MarshalIndent(webhookSubscriptions, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal subscription types: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(typesJSON)), nil
+}
+
+// webhookSubscriptionTypes checks names against the subscription catalog.
+// A name that differs from a known type only in case is reported with the
+// correct spelling.
+func webhookSubscriptionTypes(names []string) ([]tailscale.WebhookSubscriptionType, error) {
+	subscriptions := make([]tailscale.WebhookSubscriptionType, 0, len(names))
+	var unknown []string
+	for _, name := range names {
+		i := slices.IndexFunc(webhookSubscriptions, func(s webhookSubscription) bool { return strings.EqualFold(string(s.Type), name) })
+		switch {
+		case i < 0:
+			unknown = append(unknown, fmt.Sprintf("%q", name))
+		case string(webhookSubscriptions[i].Type) != name:
+			unknown = append(unknown, fmt.Sprintf("%q (did you mean %q?)", name, webhookSubscriptions[i].Type))
+		default:
+			subscriptions = append(subscriptions, webhookSubscriptions[i].Type)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown webhook subscription type(s) %s; use tailscale_webhook_subscription_types to list valid types", strings.Join(unknown, ", "))
+	}
+	return subscriptions, nil
+}
+
 func (at *AdditionalTools) GetConfigurationLogs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	client := at.client.GetClient()
 	logs, err := client.Logging().LogstreamConfiguration(ctx, tailscale.LogTypeConfig)
@@ -235,19 +479,329 @@ func (at *AdditionalTools) GetNetworkLogs(ctx context.Context, request mcp.CallT
 	return mcp.NewToolResultText(string(logsJSON)), nil
 }
 
-func (at *AdditionalTools) ListPostureIntegrations(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (at *AdditionalTools) SetLogstream(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		LogType              string `json:"log_type"`
+		DestinationType      string `json:"destination_type"`
+		URL                  string `json:"url"`
+		Token                string `json:"token"`
+		User                 string `json:"user"`
+		S3Bucket             string `json:"s3_bucket"`
+		S3Region             string `json:"s3_region"`
+		S3KeyPrefix          string `json:"s3_key_prefix"`
+		S3AuthenticationType string `json:"s3_authentication_type"`
+		S3RoleARN            string `json:"s3_role_arn"`
+		S3ExternalID         string `json:"s3_external_id"`
+		S3AccessKeyID        string `json:"s3_access_key_id"`
+		S3SecretAccessKey    string `json:"s3_secret_access_key"`
+		UploadPeriodMinutes  int    `json:"upload_period_minutes"`
+		CompressionFormat    string `json:"compression_format"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	logType, err := parseLogType(args.LogType)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	setReq := tailscale.SetLogstreamConfigurationRequest{
+		DestinationType: tailscale.LogstreamEndpointType(args.DestinationType),
+		URL:             args.URL,
+		User:            args.User,
+		Token:           args.Token,
+	}
+	destination := args.URL
+
+	switch setReq.DestinationType {
+	case tailscale.LogstreamSplunkEndpoint, tailscale.LogstreamElasticEndpoint, tailscale.LogstreamPantherEndpoint,
+		tailscale.LogstreamCriblEndpoint, tailscale.LogstreamDatadogEndpoint, tailscale.LogstreamAxiomEndpoint:
+		if args.URL == "" || args.Token == "" {
+			return mcp.NewToolResultError(fmt.Sprintf("url and token are required for %s destinations", args.DestinationType)), nil
+		}
+	case tailscale.LogstreamS3Endpoint:
+		if args.S3Bucket == "" || args.S3Region == "" {
+			return mcp.NewToolResultError("s3_bucket and s3_region are required for s3 destinations"), nil
+		}
+		if args.S3AuthenticationType == "" {
+			args.S3AuthenticationType = string(tailscale.S3RoleARNAuthentication)
+		}
+		switch tailscale.S3AuthenticationType(args.S3AuthenticationType) {
+		case tailscale.S3RoleARNAuthentication:
+			if args.S3RoleARN == "" || args.S3ExternalID == "" {
+				return mcp.NewToolResultError("s3_role_arn and s3_external_id are required for rolearn authentication; get an external ID with tailscale_logging_aws_external_id_get"), nil
+			}
+		case tailscale.S3AccessKeyAuthentication:
+			if args.S3AccessKeyID == "" || args.S3SecretAccessKey == "" {
+				return mcp.NewToolResultError("s3_access_key_id and s3_secret_access_key are required for accesskey authentication"), nil
+			}
+		default:
+			return mcp.NewToolResultError(fmt.Sprintf("s3_authentication_type must be 'rolearn' or 'accesskey', got %q", args.S3AuthenticationType)), nil
+		}
+
+		setReq.S3Bucket = args.S3Bucket
+		setReq.S3Region = args.S3Region
+		setReq.S3KeyPrefix = args.S3KeyPrefix
+		setReq.S3AuthenticationType = tailscale.S3AuthenticationType(args.S3AuthenticationType)
+		setReq.S3RoleARN = args.S3RoleARN
+		setReq.S3ExternalID = args.S3ExternalID
+		setReq.S3AccessKeyID = args.S3AccessKeyID
+		setReq.S3SecretAccessKey = args.S3SecretAccessKey
+		setReq.UploadPeriodMinutes = args.UploadPeriodMinutes
+		setReq.CompressionFormat = tailscale.CompressionFormat(args.CompressionFormat)
+		destination = "s3://" + args.S3Bucket + "/" + args.S3KeyPrefix
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Unsupported destination_type %q", args.DestinationType)), nil
+	}
+
 	client := at.client.GetClient()
-	integrations, err := client.DevicePosture().ListIntegrations(ctx)
+	if err := client.Logging().SetLogstreamConfiguration(ctx, logType, setReq); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set logstream configuration: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Streaming %s logs to %s at %s", logType, args.DestinationType, destination)), nil
+}
+
+func (at *AdditionalTools) DeleteLogstream(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		LogType string `json:"log_type"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	logType, err := parseLogType(args.LogType)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to list posture integrations: %v", err)), nil
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	client := at.client.GetClient()
+	if err := client.Logging().DeleteLogstreamConfiguration(ctx, logType); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to delete logstream configuration: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Logstream for %s logs deleted successfully", logType)), nil
+}
+
+func (at *AdditionalTools) ValidateLogstream(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := struct {
+		LogType      string `json:"log_type"`
+		Token        string `json:"token"`
+		TestDelivery bool   `json:"test_delivery"`
+	}{TestDelivery: true}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	logType, err := parseLogType(args.LogType)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	dryRun := client.IsDryRun(ctx)
+	config, err := at.client.GetClient().Logging().LogstreamConfiguration(ctx, logType)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get logstream configuration: %v", err)), nil
+	}
+	if config.DestinationType == "" {
+		return mcp.NewToolResultError(fmt.Sprintf("No log stream is configured for %s logs", logType)), nil
 	}
 
-	integrationsJSON, err := json.MarshalIndent(integrations, "", "  ")
+	status, err := at.client.LogstreamStatus(ctx, string(logType))
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal integrations: %v", err)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get logstream status: %v", err)), nil
+	}
+
+	var test *logstreamTest
+	switch {
+	case !args.TestDelivery:
+	case dryRun:
+		test = &logstreamTest{Method: "none", Note: "not sent in a dry run"}
+	default:
+		test = at.testLogstream(ctx, config, args.Token)
 	}
 
-	return mcp.NewToolResultText(string(integrationsJSON)), nil
+	verdict := "no_activity_yet"
+	switch {
+	case test != nil && test.Method != "none" && !test.Passed:
+		verdict = "failing"
+	case test != nil && test.Passed:
+		verdict = "ok"
+	case status.LastError != "":
+		verdict = "failing"
+	case status.NumEntriesSent > 0:
+		verdict = "ok"
+	}
+
+	result := map[string]any{
+		"verdict":       verdict,
+		"configuration": config,
+		"status":        status,
+	}
+	if test != nil {
+		result["test"] = test
+	}
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal logstream status: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// logstreamTest is the result of testing a log stream's destination.
+type logstreamTest struct {
+	// Method is "event" for a test event sent to the destination,
+	// "trust_policy" for the API's check of an S3 role, or "none".
+	Method string `json:"method"`
+	Passed bool   `json:"passed"`
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Note   string `json:"note,omitempty"`
+}
+
+// testLogstream tests the destination of config: S3 roles through the API's
+// trust policy check, other destinations by sending them a test event
+// authenticated as Tailscale would, with token.
+func (at *AdditionalTools) testLogstream(ctx context.Context, config *tailscale.LogstreamConfiguration, token string) *logstreamTest {
+	if config.DestinationType == tailscale.LogstreamS3Endpoint {
+		if config.S3AuthenticationType != tailscale.S3RoleARNAuthentication {
+			return &logstreamTest{Method: "none", Note: "S3 access key destinations cannot be tested, since the API does not return the secret access key"}
+		}
+		test := &logstreamTest{Method: "trust_policy", Passed: true}
+		if err := at.client.GetClient().Logging().ValidateAWSTrustPolicy(ctx, config.S3ExternalID, config.S3RoleARN); err != nil {
+			test.Passed, test.Error = false, err.Error()
+		}
+		return test
+	}
+
+	event := map[string]any{
+		"message":    "Test event from tailscale-mcp-server checking this log stream destination",
+		"@timestamp": time.Now().UTC().Format(time.RFC3339),
+		"source":     "tailscale-mcp-server",
+	}
+	var payload any = []any{event}
+	if config.DestinationType == tailscale.LogstreamSplunkEndpoint {
+		payload = map[string]any{"event": event, "sourcetype": "tailscale-mcp-test"}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return &logstreamTest{Method: "event", Error: err.Error()}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return &logstreamTest{Method: "event", Error: err.Error()}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		switch {
+		case config.DestinationType == tailscale.LogstreamSplunkEndpoint:
+			req.Header.Set("Authorization", "Splunk "+token)
+		case config.DestinationType == tailscale.LogstreamDatadogEndpoint:
+			req.Header.Set("DD-API-KEY", token)
+		case config.User != "":
+			req.SetBasicAuth(config.User, token)
+		default:
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	resp, err := (&http.Client{Timeout: 15 * time.Second}).Do(req)
+	if err != nil {
+		return &logstreamTest{Method: "event", Error: fmt.Sprintf("destination is unreachable: %v", err)}
+	}
+	resp.Body.Close()
+
+	test := &logstreamTest{Method: "event", Status: resp.Status, Passed: resp.StatusCode < http.StatusMultipleChoices}
+	switch {
+	case token == "" && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden):
+		// Reachable, and asking for the credentials the test did not have.
+		test.Passed = true
+		test.Note = "the destination is reachable; pass token to test its credentials too"
+	case token == "":
+		test.Note = "sent without credentials; pass token to test them too"
+	}
+	return test
+}
+
+func (at *AdditionalTools) GetAWSExternalID(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Reusable bool `json:"reusable"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	client := at.client.GetClient()
+	externalID, err := client.Logging().CreateOrGetAwsExternalId(ctx, args.Reusable)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get AWS external ID: %v", err)), nil
+	}
+
+	externalIDJSON, err := json.MarshalIndent(externalID, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal AWS external ID: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(externalIDJSON)), nil
+}
+
+func (at *AdditionalTools) ValidateAWSTrustPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ExternalID string `json:"external_id"`
+		RoleARN    string `json:"role_arn"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	client := at.client.GetClient()
+	if err := client.Logging().ValidateAWSTrustPolicy(ctx, args.ExternalID, args.RoleARN); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("AWS trust policy is not valid: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Tailscale can assume %s with external ID %s", args.RoleARN, args.ExternalID)), nil
+}
+
+// parseLogType checks a log_type argument.
+func parseLogType(logType string) (tailscale.LogType, error) {
+	switch tailscale.LogType(logType) {
+	case tailscale.LogTypeConfig, tailscale.LogTypeNetwork:
+		return tailscale.LogType(logType), nil
+	}
+	return "", fmt.Errorf("log_type must be 'configuration' or 'network', got %q", logType)
+}
+
+func (at *AdditionalTools) ListPostureIntegrations(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		pageArgs
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	if args.Cursor != "" {
+		return nextPage[tailscale.PostureIntegration](request.Params.Name, args.pageArgs, "integrations")
+	}
+
+	client := at.client.GetClient()
+	integrations, err := client.DevicePosture().ListIntegrations(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list posture integrations: %v", err)), nil
+	}
+
+	return firstPage(request.Params.Name, integrations, args.pageArgs, "integrations")
 }
 
 func (at *AdditionalTools) CreatePostureIntegration(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -283,6 +837,43 @@ func (at *AdditionalTools) CreatePostureIntegration(ctx context.Context, request
 	return mcp.NewToolResultText(string(integrationJSON)), nil
 }
 
+func (at *AdditionalTools) UpdatePostureIntegration(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ID           string  `json:"id"`
+		ClientID     string  `json:"client_id"`
+		ClientSecret *string `json:"client_secret"`
+		TenantID     string  `json:"tenant_id"`
+		CloudID      string  `json:"cloud_id"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if args.ClientID == "" && args.ClientSecret == nil && args.TenantID == "" && args.CloudID == "" {
+		return mcp.NewToolResultError("Nothing to update: give at least one of client_id, client_secret, tenant_id, or cloud_id"), nil
+	}
+
+	updateReq := tailscale.UpdatePostureIntegrationRequest{
+		ClientID:     args.ClientID,
+		ClientSecret: args.ClientSecret,
+		TenantID:     args.TenantID,
+		CloudID:      args.CloudID,
+	}
+
+	client := at.client.GetClient()
+	integration, err := client.DevicePosture().UpdateIntegration(ctx, args.ID, updateReq)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to update posture integration: %v", err)), nil
+	}
+
+	integrationJSON, err := json.MarshalIndent(integration, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal integration: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(integrationJSON)), nil
+}
+
 func (at *AdditionalTools) GetPostureIntegration(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
 		ID string `json:"id"`
@@ -303,7 +894,7 @@ func (at *AdditionalTools) GetPostureIntegration(ctx context.Context, request mc
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal integration: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(integrationJSON)), nil
+	return mcp.NewToolResultStructured(integration, string(integrationJSON)), nil
 }
 
 func (at *AdditionalTools) DeletePostureIntegration(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -335,7 +926,7 @@ func (at *AdditionalTools) GetTailnetSettings(ctx context.Context, request mcp.C
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal settings: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(settingsJSON)), nil
+	return mcp.NewToolResultStructured(settings, string(settingsJSON)), nil
 }
 
 func (at *AdditionalTools) UpdateTailnetSettings(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -348,6 +939,7 @@ func (at *AdditionalTools) UpdateTailnetSettings(ctx context.Context, request mc
 		NetworkFlowLoggingOn                   *bool   `json:"network_flow_logging_on"`
 		RegionalRoutingOn                      *bool   `json:"regional_routing_on"`
 		PostureIdentityCollectionOn            *bool   `json:"posture_identity_collection_on"`
+		DryRun                                 bool    `json:"dry_run"`
 	}
 
 	if err := request.BindArguments(&args); err != nil {
@@ -382,6 +974,18 @@ func (at *AdditionalTools) UpdateTailnetSettings(ctx context.Context, request mc
 	}
 
 	client := at.client.GetClient()
+	if args.DryRun {
+		settings, err := client.TailnetSettings().Get(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get tailnet settings: %v", err)), nil
+		}
+		diffs, err := diffTailnetSettings(*settings, updateReq)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to diff tailnet settings: %v", err)), nil
+		}
+		return dryRunResult(diffs...)
+	}
+
 	if err := client.TailnetSettings().Update(ctx, updateReq); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to update tailnet settings: %v", err)), nil
 	}
@@ -399,3 +1003,32 @@ func (at *AdditionalTools) UpdateTailnetSettings(ctx context.Context, request mc
 
 	return mcp.NewToolResultText(string(settingsJSON)), nil
 }
+
+// diffTailnetSettings describes how each setting named in req would change
+// if req were applied to settings. Settings that req leaves alone are not
+// included.
+func diffTailnetSettings(settings tailscale.TailnetSettings, req tailscale.UpdateTailnetSettingsRequest) ([]settingDiff, error) {
+	before, err := jsonObject(settings)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := jsonObject(req)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := []settingDiff{}
+	for _, name := range slices.Sorted(maps.Keys(changes)) {
+		after := changes[name]
+		if after == nil {
+			continue
+		}
+		diffs = append(diffs, settingDiff{
+			Setting: name,
+			Changed: !reflect.DeepEqual(before[name], after),
+			Before:  before[name],
+			After:   after,
+		})
+	}
+	return diffs, nil
+}
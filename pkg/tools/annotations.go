@@ -0,0 +1,31 @@
+package tools
+
+import "github.com/mark3labs/mcp-go/mcp"
+
+// Every tool carries one of these annotations so clients can decide which
+// calls to confirm with the user. Without one, mcp-go marks a tool as
+// destructive and not idempotent.
+var (
+	// readOnlyTool only reads tailnet state.
+	readOnlyTool = toolHints(true, false, true)
+	// createTool adds something new; calling it twice creates two.
+	createTool = toolHints(false, false, false)
+	// updateTool changes state without removing anything, and repeating the
+	// call has no further effect.
+	updateTool = toolHints(false, false, true)
+	// destructiveTool deletes or overwrites state, and repeating the call has
+	// no further effect.
+	destructiveTool = toolHints(false, true, true)
+	// destructiveOnceTool deletes or overwrites state, and repeating the call
+	// does it again.
+	destructiveOnceTool = toolHints(false, true, false)
+)
+
+func toolHints(readOnly, destructive, idempotent bool) mcp.ToolOption {
+	return mcp.WithToolAnnotation(mcp.ToolAnnotation{
+		ReadOnlyHint:    mcp.ToBoolPtr(readOnly),
+		DestructiveHint: mcp.ToBoolPtr(destructive),
+		IdempotentHint:  mcp.ToBoolPtr(idempotent),
+		OpenWorldHint:   mcp.ToBoolPtr(true),
+	})
+}
@@ -0,0 +1,303 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/localapi"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// APITools reports on the Tailscale API itself and, when
+// TAILSCALE_MCP_ENABLE_RAW_API is set, exposes it directly for endpoints that
+// no dedicated tool wraps yet.
+type APITools struct {
+	client *client.TailscaleClient
+	config *config.Config
+}
+
+func NewAPITools(client *client.TailscaleClient, cfg *config.Config) *APITools {
+	return &APITools{client: client, config: cfg}
+}
+
+var rawAPIMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+func (at *APITools) RegisterTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_api_quota_status",
+		readOnlyTool,
+		mcp.WithDescription("Get the Tailscale API rate limit status as last reported by the API: the request limit, how many requests remain and when the quota resets, and how many requests were throttled and when to retry. Check it before bulk operations, and pause until retry_after after being throttled instead of retrying immediately. Tool results also carry a note when a call is throttled or the quota runs low."),
+	)
+	mcpServer.AddTool(tool, at.GetQuotaStatus)
+
+	tool = mcp.NewTool(
+		"tailscale_feature_report",
+		readOnlyTool,
+		mcp.WithDescription("Probe which optional API features this tailnet's plan and credentials give access to (device posture integrations, network flow logs, configuration audit logs, log streaming, webhooks, Tailscale Services, tailnet lock) and whether each is enabled. Run it first to avoid calling tools that cannot succeed here. A feature reported unavailable was refused by the API, which can mean the plan lacks it or the credentials lack its OAuth scope. Makes one or two read-only API calls per feature."),
+	)
+	mcpServer.AddTool(tool, at.GetFeatureReport)
+
+	if !at.config.EnableRawAPI {
+		return
+	}
+
+	tool = mcp.NewTool(
+		"tailscale_api_request",
+		destructiveOnceTool,
+		mcp.WithDescription("Call any Tailscale API v2 endpoint and return the response unchanged, for endpoints that no dedicated tool covers yet. The path is relative to /api/v2, e.g. '/tailnet/{tailnet}/devices', where {tailnet} is replaced with the configured tailnet. Requests are not checked by the guardrails of the dedicated tools, so prefer those where they exist. Enabled by TAILSCALE_MCP_ENABLE_RAW_API. OAuth Scope: depends on the endpoint."),
+		mcp.WithString("method", mcp.Description("HTTP method"), mcp.Enum(rawAPIMethods...), mcp.Required()),
+		mcp.WithString("path", mcp.Description("Endpoint path relative to /api/v2, with an optional query string"), mcp.Required()),
+		mcp.WithString("body", mcp.Description("JSON request body")),
+	)
+	mcpServer.AddTool(tool, at.APIRequest)
+}
+
+func (at *APITools) GetQuotaStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	quota := at.client.Quota()
+	status := map[string]any{"quota": quota}
+	switch {
+	case quota.RetryAfter != nil && time.Now().Before(*quota.RetryAfter):
+		status["advice"] = fmt.Sprintf("Throttled: wait %s before the next request", time.Until(*quota.RetryAfter).Round(time.Second))
+	case quota.Low():
+		status["advice"] = "Less than 10% of the rate limit remains: batch or slow down requests"
+	case quota.Limit < 0:
+		status["advice"] = "The API has not reported a rate limit yet"
+	default:
+		status["advice"] = "OK"
+	}
+
+	statusJSON, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal quota status: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(statusJSON)), nil
+}
+
+// featureStatus is the outcome of probing one feature. Available and Enabled
+// are nil when the probe could not tell.
+type featureStatus struct {
+	Feature   string `json:"feature"`
+	Available *bool  `json:"available"`
+	Enabled   *bool  `json:"enabled"`
+	Detail    string `json:"detail,omitempty"`
+}
+
+// probeError classifies the error of a feature probe: refusals mean the
+// feature is unavailable, anything else leaves it unknown.
+func probeError(feature string, err error) featureStatus {
+	status := featureStatus{Feature: feature, Detail: err.Error()}
+	if code := apiStatus(err); code == http.StatusForbidden || code == http.StatusPaymentRequired {
+		status.Available = new(bool)
+	}
+	return status
+}
+
+// apiStatus returns the HTTP status of an error from the API, or 0 for other
+// errors. client.Do's errors carry it; the SDK keeps it unexported, so it is
+// taken from the SDK error's own "<message> (<status>)" form.
+func apiStatus(err error) int {
+	var doErr *client.APIError
+	if errors.As(err, &doErr) {
+		return doErr.StatusCode
+	}
+	var sdkErr tailscale.APIError
+	if errors.As(err, &sdkErr) {
+		if tailscale.IsNotFound(err) {
+			return http.StatusNotFound
+		}
+		code, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(sdkErr.Error(), sdkErr.Message+" ("), ")"))
+		return code
+	}
+	return 0
+}
+
+// featureTools lists the tools that cannot succeed without each probed
+// feature.
+var featureTools = map[string][]string{
+	"device_posture_integrations": {
+		"tailscale_device_posture_integrations_list", "tailscale_device_posture_integration_create", "tailscale_device_posture_integration_get",
+		"tailscale_device_posture_integration_update", "tailscale_device_posture_integration_delete",
+	},
+	"network_flow_logs":        {"tailscale_network_logs_get", "tailscale_network_top_talkers"},
+	"configuration_audit_logs": {"tailscale_audit_logs_get", "tailscale_audit_logs_export", "tailscale_security_timeline"},
+	"log_streaming_configuration": {
+		"tailscale_logging_configuration_get", "tailscale_logging_stream_set", "tailscale_logging_stream_delete", "tailscale_logging_stream_validate",
+		"tailscale_logging_aws_external_id_get", "tailscale_logging_aws_trust_policy_validate",
+	},
+	"webhooks": {
+		"tailscale_webhooks_list", "tailscale_webhook_create", "tailscale_webhook_get", "tailscale_webhook_update",
+		"tailscale_webhook_test", "tailscale_webhook_delete", "tailscale_webhook_subscription_types",
+	},
+	"services": {"tailscale_services_list", "tailscale_service_get", "tailscale_service_create", "tailscale_service_update", "tailscale_service_delete"},
+}
+
+// UnavailableTools probes the optional features and returns the tools whose
+// feature the API refused. Features it could not tell about count as
+// available.
+func (at *APITools) UnavailableTools(ctx context.Context) []string {
+	var names []string
+	for _, status := range at.probeFeatures(ctx) {
+		if status.Available != nil && !*status.Available {
+			names = append(names, featureTools[status.Feature]...)
+		}
+	}
+	return names
+}
+
+func (at *APITools) GetFeatureReport(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	reportJSON, err := json.MarshalIndent(at.probeFeatures(ctx), "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal feature report: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(reportJSON)), nil
+}
+
+// probeFeatures reports on each optional feature.
+func (at *APITools) probeFeatures(ctx context.Context) []featureStatus {
+	client := at.client.GetClient()
+	yes, no := true, false
+	enabled := func(on bool) *bool {
+		if on {
+			return &yes
+		}
+		return &no
+	}
+
+	var report []featureStatus
+	settings, settingsErr := client.TailnetSettings().Get(ctx)
+
+	if integrations, err := client.DevicePosture().ListIntegrations(ctx); err != nil {
+		report = append(report, probeError("device_posture_integrations", err))
+	} else {
+		report = append(report, featureStatus{Feature: "device_posture_integrations", Available: &yes, Enabled: enabled(len(integrations) > 0), Detail: fmt.Sprintf("%d integrations configured", len(integrations))})
+	}
+
+	if settingsErr == nil {
+		report = append(report, featureStatus{Feature: "posture_identity_collection", Available: &yes, Enabled: enabled(settings.PostureIdentityCollectionOn)})
+	} else {
+		report = append(report, probeError("posture_identity_collection", settingsErr))
+	}
+
+	now := time.Now()
+	if _, err := at.client.NetworkLogs(ctx, now.Add(-time.Minute), now); err != nil {
+		report = append(report, probeError("network_flow_logs", err))
+	} else {
+		status := featureStatus{Feature: "network_flow_logs", Available: &yes}
+		if settingsErr == nil {
+			status.Enabled = enabled(settings.NetworkFlowLoggingOn)
+		}
+		report = append(report, status)
+	}
+
+	if _, err := at.client.AuditLogs(ctx, now.Add(-time.Minute), now); err != nil {
+		report = append(report, probeError("configuration_audit_logs", err))
+	} else {
+		report = append(report, featureStatus{Feature: "configuration_audit_logs", Available: &yes, Enabled: &yes})
+	}
+
+	for _, logType := range []tailscale.LogType{tailscale.LogTypeConfig, tailscale.LogTypeNetwork} {
+		feature := "log_streaming_" + string(logType)
+		stream, err := client.Logging().LogstreamConfiguration(ctx, logType)
+		switch {
+		case err != nil && tailscale.IsNotFound(err):
+			report = append(report, featureStatus{Feature: feature, Available: &yes, Enabled: &no})
+		case err != nil:
+			report = append(report, probeError(feature, err))
+		default:
+			report = append(report, featureStatus{Feature: feature, Available: &yes, Enabled: &yes, Detail: "streaming to " + string(stream.DestinationType)})
+		}
+	}
+
+	if webhooks, err := client.Webhooks().List(ctx); err != nil {
+		report = append(report, probeError("webhooks", err))
+	} else {
+		report = append(report, featureStatus{Feature: "webhooks", Available: &yes, Enabled: enabled(len(webhooks) > 0), Detail: fmt.Sprintf("%d endpoints configured", len(webhooks))})
+	}
+
+	var services struct {
+		VIPServices []json.RawMessage `json:"vipServices"`
+	}
+	if err := at.client.Do(ctx, http.MethodGet, at.client.TailnetPath("vip-services"), nil, &services); err != nil {
+		report = append(report, probeError("services", err))
+	} else {
+		report = append(report, featureStatus{Feature: "services", Available: &yes, Enabled: enabled(len(services.VIPServices) > 0), Detail: fmt.Sprintf("%d services defined", len(services.VIPServices))})
+	}
+
+	lock := featureStatus{Feature: "tailnet_lock", Available: &yes}
+	if at.config.TailscaledSocket != "" {
+		if status, err := localapi.NewClient(at.config.TailscaledSocket).LockStatus(ctx); err != nil {
+			lock.Detail = fmt.Sprintf("failed to read lock status from tailscaled: %v", err)
+		} else {
+			lock.Enabled = enabled(status.Enabled)
+		}
+	} else if devices, err := client.Devices().ListWithAllFields(ctx); err != nil {
+		lock = probeError("tailnet_lock", err)
+	} else {
+		lock.Detail = "set TAILSCALE_MCP_TAILSCALED_SOCKET to read whether lock is enabled"
+		for _, device := range devices {
+			if device.TailnetLockError != "" {
+				lock.Enabled = &yes
+				lock.Detail = "devices report tailnet lock errors, so lock is enabled"
+				break
+			}
+		}
+	}
+	report = append(report, lock)
+	return report
+}
+
+func (at *APITools) APIRequest(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Method string `json:"method"`
+		Path   string `json:"path"`
+		Body   string `json:"body"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	method := strings.ToUpper(args.Method)
+	if !slices.Contains(rawAPIMethods, method) {
+		return mcp.NewToolResultError(fmt.Sprintf("Unsupported method %s; use one of %s", args.Method, strings.Join(rawAPIMethods, ", "))), nil
+	}
+
+	path := strings.ReplaceAll(args.Path, "{tailnet}", url.PathEscape(at.client.GetClient().Tailnet))
+	path = "/" + strings.TrimPrefix(strings.TrimPrefix(path, "/api/v2"), "/")
+	parsed, err := url.Parse(path)
+	if err != nil || parsed.Host != "" || slices.Contains(strings.Split(parsed.Path, "/"), "..") {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid path %s: give an API path such as /tailnet/{tailnet}/devices", args.Path)), nil
+	}
+
+	var body []byte
+	if args.Body != "" {
+		if !json.Valid([]byte(args.Body)) {
+			return mcp.NewToolResultError("Invalid body: not valid JSON"), nil
+		}
+		body = []byte(args.Body)
+	}
+
+	resp, err := at.client.DoRaw(ctx, method, path, body)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to call the Tailscale API: %v", err)), nil
+	}
+
+	result := mcp.NewToolResultText(string(resp.Body))
+	result.IsError = resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices
+	result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf("HTTP %d %s", resp.StatusCode, http.StatusText(resp.StatusCode))))
+	return result, nil
+}
@@ -0,0 +1,395 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/guardrail"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// ChangesetTools applies bundles of changes to several parts of the tailnet
+// as one unit, undoing the applied part of a bundle when a step fails.
+type ChangesetTools struct {
+	client *client.TailscaleClient
+}
+
+func NewChangesetTools(client *client.TailscaleClient) *ChangesetTools {
+	return &ChangesetTools{client: client}
+}
+
+func (ct *ChangesetTools) RegisterTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_apply_changeset",
+		destructiveTool,
+		mcp.WithDescription(`Apply an ordered bundle of changes as one unit. Every step is validated before anything is applied; steps are then applied in order, and if one fails the steps already applied are undone in reverse order, restoring the values read just before each was applied. Returns the outcome of every step. Step types:
+- {"op": "settings", "settings": {"devicesApprovalOn": true, ...}} updates tailnet settings (field names as returned by tailscale_tailnet_settings_get)
+- {"op": "dns_nameservers", "nameservers": ["8.8.8.8"]}
+- {"op": "dns_search_paths", "search_paths": ["corp.example.com"]}
+- {"op": "dns_split_dns", "split_dns": {"corp.example.com": ["10.0.0.53"]}} replaces all split DNS entries
+- {"op": "dns_magic_dns", "magic_dns": true}
+- {"op": "device_routes", "device": "<ID or name>", "routes": ["10.0.0.0/24"]} sets the enabled subnet routes
+- {"op": "device_tags", "device": "<ID or name>", "tags": ["tag:server"]}
+OAuth Scope: settings:write, dns:write, devices:core (as needed by the steps).`),
+		mcp.WithArray("steps", mcp.Description("Ordered list of change steps"), mcp.Required(), mcp.Items(map[string]any{"type": "object"})),
+		mcp.WithBoolean("dry_run", mcp.Description("Validate the steps and return the plan without applying it")),
+	)
+	mcpServer.AddTool(tool, ct.ApplyChangeset)
+}
+
+// ChangesetGuardrailCalls returns, for a call of tailscale_apply_changeset,
+// the call of the dedicated tool that makes the change of each step, such as
+// tailscale_device_routes_set for a device_routes step, so guardrails on
+// those tools cover changesets too.
+func ChangesetGuardrailCalls(ctx context.Context, tool string, args map[string]any) ([]guardrail.Call, error) {
+	if tool != "tailscale_apply_changeset" {
+		return nil, nil
+	}
+	raw, err := json.Marshal(args["steps"])
+	if err != nil {
+		return nil, err
+	}
+	var steps []changeStep
+	if err := json.Unmarshal(raw, &steps); err != nil {
+		return nil, fmt.Errorf("invalid steps: %w", err)
+	}
+
+	var calls []guardrail.Call
+	for i, step := range steps {
+		call := guardrail.Call{}
+		switch step.Op {
+		case "settings":
+			if step.Settings == nil {
+				continue
+			}
+			call = guardrail.Call{Tool: "tailscale_tailnet_settings_update", Args: settingsArgs(*step.Settings)}
+		case "dns_nameservers":
+			call = guardrail.Call{Tool: "tailscale_dns_nameservers_set", Args: map[string]any{"nameservers": step.Nameservers}}
+		case "dns_search_paths":
+			call = guardrail.Call{Tool: "tailscale_dns_searchpaths_set", Args: map[string]any{"search_paths": step.SearchPaths}}
+		case "dns_split_dns":
+			call = guardrail.Call{Tool: "tailscale_dns_split_set", Args: map[string]any{"split_dns": step.SplitDNS}}
+		case "dns_magic_dns":
+			call = guardrail.Call{Tool: "tailscale_dns_preferences_set", Args: map[string]any{"magic_dns": step.MagicDNS}}
+		case "device_routes":
+			call = guardrail.Call{Tool: "tailscale_device_routes_set", Args: map[string]any{"device_id": step.Device, "routes": step.Routes}}
+		case "device_tags":
+			call = guardrail.Call{Tool: "tailscale_device_set_tags", Args: map[string]any{"device_id": step.Device, "tags": step.Tags}}
+		default:
+			return nil, fmt.Errorf("step %d: unknown op %q", i+1, step.Op)
+		}
+		call.Args = callArgs(call.Args)
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+// callArgs returns args as a tool receives them from a client, decoded
+// from JSON, dropping nil values.
+func callArgs(args map[string]any) map[string]any {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return args
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return args
+	}
+	for name, value := range decoded {
+		if value == nil {
+			delete(decoded, name)
+		}
+	}
+	return decoded
+}
+
+// changeStep is one step of a changeset.
+type changeStep struct {
+	Op          string                                  `json:"op"`
+	Settings    *tailscale.UpdateTailnetSettingsRequest `json:"settings,omitempty"`
+	Nameservers []string                                `json:"nameservers,omitempty"`
+	SearchPaths []string                                `json:"search_paths,omitempty"`
+	SplitDNS    map[string][]string                     `json:"split_dns,omitempty"`
+	MagicDNS    *bool                                   `json:"magic_dns,omitempty"`
+	Device      string                                  `json:"device,omitempty"`
+	Routes      []string                                `json:"routes,omitempty"`
+	Tags        []string                                `json:"tags,omitempty"`
+
+	// deviceID is Device resolved during validation.
+	deviceID string
+}
+
+// stepOutcome reports what happened to a step.
+type stepOutcome struct {
+	Step   int    `json:"step"`
+	Op     string `json:"op"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// validate checks the step without changing anything.
+func (s *changeStep) validate(devices []tailscale.Device) error {
+	switch s.Op {
+	case "settings":
+		if s.Settings == nil {
+			return errors.New("settings is required")
+		}
+	case "dns_nameservers":
+		if s.Nameservers == nil {
+			return errors.New("nameservers is required (use [] to clear)")
+		}
+		for _, ns := range s.Nameservers {
+			if _, err := netip.ParseAddr(ns); err != nil {
+				return fmt.Errorf("invalid nameserver %q", ns)
+			}
+		}
+	case "dns_search_paths":
+		if s.SearchPaths == nil {
+			return errors.New("search_paths is required (use [] to clear)")
+		}
+	case "dns_split_dns":
+		if s.SplitDNS == nil {
+			return errors.New("split_dns is required (use {} to clear)")
+		}
+		for domain, nameservers := range s.SplitDNS {
+			if len(nameservers) == 0 {
+				return fmt.Errorf("domain %s has no nameservers; omit it to remove it", domain)
+			}
+			for _, ns := range nameservers {
+				if _, err := netip.ParseAddr(ns); err != nil {
+					return fmt.Errorf("invalid nameserver %q for %s", ns, domain)
+				}
+			}
+		}
+	case "dns_magic_dns":
+		if s.MagicDNS == nil {
+			return errors.New("magic_dns is required")
+		}
+	case "device_routes", "device_tags":
+		if s.Device == "" {
+			return errors.New("device is required")
+		}
+		device, err := resolveDevice(devices, s.Device)
+		if err != nil {
+			return err
+		}
+		s.deviceID = device.NodeID
+		if s.Op == "device_routes" {
+			if s.Routes == nil {
+				return errors.New("routes is required (use [] to disable all)")
+			}
+			for _, route := range s.Routes {
+				if _, err := netip.ParsePrefix(route); err != nil {
+					return fmt.Errorf("invalid route %q", route)
+				}
+			}
+		} else {
+			if s.Tags == nil {
+				return errors.New("tags is required")
+			}
+			for _, tag := range s.Tags {
+				if !strings.HasPrefix(tag, "tag:") {
+					return fmt.Errorf("invalid tag %q: tags start with 'tag:'", tag)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unknown op %q", s.Op)
+	}
+	return nil
+}
+
+// apply makes the step's change and returns a function that undoes it.
+func (s *changeStep) apply(ctx context.Context, client *tailscale.Client) (func(context.Context) error, error) {
+	switch s.Op {
+	case "settings":
+		before, err := client.TailnetSettings().Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.TailnetSettings().Update(ctx, *s.Settings); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error {
+			after, err := client.TailnetSettings().Get(ctx)
+			if err != nil {
+				return err
+			}
+			return client.TailnetSettings().Update(ctx, settingsUpdateFor(*after, *before))
+		}, nil
+	case "dns_nameservers":
+		before, err := client.DNS().Nameservers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.DNS().SetNameservers(ctx, s.Nameservers); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error { return client.DNS().SetNameservers(ctx, before) }, nil
+	case "dns_search_paths":
+		before, err := client.DNS().SearchPaths(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.DNS().SetSearchPaths(ctx, s.SearchPaths); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error { return client.DNS().SetSearchPaths(ctx, before) }, nil
+	case "dns_split_dns":
+		before, err := client.DNS().SplitDNS(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.DNS().SetSplitDNS(ctx, s.SplitDNS); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error {
+			return client.DNS().SetSplitDNS(ctx, tailscale.SplitDNSRequest(before))
+		}, nil
+	case "dns_magic_dns":
+		before, err := client.DNS().Preferences(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.DNS().SetPreferences(ctx, tailscale.DNSPreferences{MagicDNS: *s.MagicDNS}); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error { return client.DNS().SetPreferences(ctx, *before) }, nil
+	case "device_routes":
+		before, err := client.Devices().SubnetRoutes(ctx, s.deviceID)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.Devices().SetSubnetRoutes(ctx, s.deviceID, s.Routes); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error {
+			return client.Devices().SetSubnetRoutes(ctx, s.deviceID, before.Enabled)
+		}, nil
+	case "device_tags":
+		before, err := client.Devices().Get(ctx, s.deviceID)
+		if err != nil {
+			return nil, err
+		}
+		if err := client.Devices().SetTags(ctx, s.deviceID, s.Tags); err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error { return client.Devices().SetTags(ctx, s.deviceID, before.Tags) }, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", s.Op)
+}
+
+func (ct *ChangesetTools) ApplyChangeset(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Steps  []json.RawMessage `json:"steps"`
+		DryRun bool              `json:"dry_run"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if len(args.Steps) == 0 {
+		return mcp.NewToolResultError("At least one step is required"), nil
+	}
+
+	// Undo with a fresh context so a cancelled call still rolls back, and
+	// without the session's API quota so running out of it cannot leave the
+	// changeset half-applied.
+	rollbackCtx := client.Unlimited(context.WithoutCancel(ctx))
+	callsLeft, limited := client.CallsLeft(ctx)
+
+	client := ct.client.GetClient()
+	var devices []tailscale.Device
+	steps := make([]*changeStep, len(args.Steps))
+	var invalid []string
+	for i, raw := range args.Steps {
+		step := &changeStep{}
+		decoder := json.NewDecoder(strings.NewReader(string(raw)))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(step); err != nil {
+			invalid = append(invalid, fmt.Sprintf("step %d: %v", i+1, err))
+			continue
+		}
+		if (step.Op == "device_routes" || step.Op == "device_tags") && devices == nil {
+			var err error
+			if devices, err = client.Devices().List(ctx); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+			}
+		}
+		if err := step.validate(devices); err != nil {
+			invalid = append(invalid, fmt.Sprintf("step %d (%s): %v", i+1, step.Op, err))
+		}
+		steps[i] = step
+	}
+	if len(invalid) > 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("Changeset not applied; invalid steps:\n%s", strings.Join(invalid, "\n"))), nil
+	}
+
+	outcomes := make([]stepOutcome, len(steps))
+	for i, step := range steps {
+		outcomes[i] = stepOutcome{Step: i + 1, Op: step.Op, Status: "pending"}
+	}
+	if args.DryRun {
+		for i := range outcomes {
+			outcomes[i].Status = "valid"
+		}
+		return changesetResult(false, outcomes)
+	}
+
+	// Each step reads the state it changes, then writes it.
+	if needed := 2 * len(steps); limited && callsLeft < needed {
+		return mcp.NewToolResultError(fmt.Sprintf("Changeset not applied: applying its %d steps takes up to %d Tailscale API calls, but this session has %d left of its quota", len(steps), needed, callsLeft)), nil
+	}
+
+	var undos []func(context.Context) error
+	for i, step := range steps {
+		undo, err := step.apply(ctx, client)
+		if err == nil {
+			undos = append(undos, undo)
+			outcomes[i].Status = "applied"
+			continue
+		}
+
+		outcomes[i].Status = "failed"
+		outcomes[i].Error = err.Error()
+		for j := i + 1; j < len(steps); j++ {
+			outcomes[j].Status = "skipped"
+		}
+		for j := len(undos) - 1; j >= 0; j-- {
+			if err := undos[j](rollbackCtx); err != nil {
+				outcomes[j].Status = "rollback_failed"
+				outcomes[j].Error = err.Error()
+			} else {
+				outcomes[j].Status = "rolled_back"
+			}
+		}
+		return changesetResult(false, outcomes)
+	}
+
+	return changesetResult(true, outcomes)
+}
+
+func changesetResult(applied bool, outcomes []stepOutcome) (*mcp.CallToolResult, error) {
+	resultJSON, err := json.MarshalIndent(map[string]any{
+		"applied": applied,
+		"steps":   outcomes,
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal changeset outcome: %v", err)), nil
+	}
+
+	result := mcp.NewToolResultText(string(resultJSON))
+	for _, outcome := range outcomes {
+		if outcome.Status == "failed" || outcome.Status == "rollback_failed" {
+			result.IsError = true
+		}
+	}
+	return result, nil
+}
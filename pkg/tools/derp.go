@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/localapi"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// defaultDERPMapURL serves Tailscale's default DERP map. It does not include
+// regions added or removed by the policy file's derpMap section.
+const defaultDERPMapURL = "https://controlplane.tailscale.com/derpmap/default"
+
+// DERPTools reports on the DERP relay servers. The control API does not
+// expose the DERP map, so it is read from the tailscaled on this host when
+// one is configured, and from Tailscale's published default map otherwise.
+type DERPTools struct {
+	client *client.TailscaleClient
+	// local is nil unless TAILSCALE_MCP_TAILSCALED_SOCKET is set.
+	local *localapi.Client
+}
+
+func NewDERPTools(client *client.TailscaleClient, cfg *config.Config) *DERPTools {
+	dt := &DERPTools{client: client}
+	if cfg.TailscaledSocket != "" {
+		dt.local = localapi.NewClient(cfg.TailscaledSocket)
+	}
+	return dt
+}
+
+func (dt *DERPTools) RegisterTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_derp_map",
+		readOnlyTool,
+		mcp.WithDescription("Get the DERP relay map (regions and their relay servers) and which region each device is homed to, with its measured latency to that region. Devices that relay through a distant home region are a common cause of high latency when direct connections fail. The map comes from the tailscaled on this host when TAILSCALE_MCP_TAILSCALED_SOCKET is set, and so includes custom DERP servers from the policy file; otherwise Tailscale's default map is used. OAuth Scope: devices:read."),
+		mcp.WithString("region", mcp.Description("Only show this region, by ID, code (e.g. 'nyc'), or name")),
+		mcp.WithBoolean("include_devices", mcp.Description("Join with device connectivity to show each region's homed devices (default: true)")),
+	)
+	mcpServer.AddTool(tool, dt.GetDERPMap)
+}
+
+// derpMap returns the DERP map and where it came from.
+func (dt *DERPTools) derpMap(ctx context.Context) (*localapi.DERPMap, string, error) {
+	if dt.local != nil {
+		derpMap, err := dt.local.DERPMap(ctx)
+		return derpMap, "tailscaled", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, defaultDERPMapURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s returned %s", defaultDERPMapURL, resp.Status)
+	}
+
+	var derpMap localapi.DERPMap
+	if err := json.NewDecoder(resp.Body).Decode(&derpMap); err != nil {
+		return nil, "", err
+	}
+	return &derpMap, defaultDERPMapURL, nil
+}
+
+// derpHome returns the name of the region a device is homed to and its
+// latency to it, or "" if the device has not reported one.
+func derpHome(device tailscale.Device, derpMap *localapi.DERPMap) (string, float64) {
+	if device.ClientConnectivity == nil {
+		return "", 0
+	}
+	for name, region := range device.ClientConnectivity.DERPLatency {
+		if region.Preferred {
+			return name, region.LatencyMilliseconds
+		}
+	}
+	// Older clients only report the home region as "derp-<region ID>".
+	if id, err := strconv.Atoi(strings.TrimPrefix(device.ClientConnectivity.DERP, "derp-")); err == nil {
+		if region, ok := derpMap.Regions[id]; ok {
+			return region.RegionName, device.ClientConnectivity.DERPLatency[region.RegionName].LatencyMilliseconds
+		}
+	}
+	return "", 0
+}
+
+func (dt *DERPTools) GetDERPMap(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := struct {
+		Region         string `json:"region"`
+		IncludeDevices bool   `json:"include_devices"`
+	}{IncludeDevices: true}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	derpMap, source, err := dt.derpMap(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get DERP map: %v", err)), nil
+	}
+
+	type homedDevice struct {
+		Name      string  `json:"name"`
+		NodeID    string  `json:"node_id"`
+		LatencyMs float64 `json:"latency_ms,omitempty"`
+	}
+	type relay struct {
+		Name     string `json:"name"`
+		HostName string `json:"hostname"`
+		IPv4     string `json:"ipv4,omitempty"`
+		IPv6     string `json:"ipv6,omitempty"`
+	}
+	type region struct {
+		ID      int           `json:"id"`
+		Code    string        `json:"code"`
+		Name    string        `json:"name"`
+		Avoid   bool          `json:"avoid,omitempty"`
+		Nodes   []relay       `json:"nodes"`
+		Devices []homedDevice `json:"devices,omitempty"`
+	}
+
+	var regions []*region
+	byName := make(map[string]*region)
+	for _, r := range derpMap.Regions {
+		if args.Region != "" && args.Region != strconv.Itoa(r.RegionID) && !strings.EqualFold(args.Region, r.RegionCode) && !strings.EqualFold(args.Region, r.RegionName) {
+			continue
+		}
+		entry := &region{ID: r.RegionID, Code: r.RegionCode, Name: r.RegionName, Avoid: r.Avoid, Nodes: []relay{}}
+		for _, node := range r.Nodes {
+			entry.Nodes = append(entry.Nodes, relay{Name: node.Name, HostName: node.HostName, IPv4: node.IPv4, IPv6: node.IPv6})
+		}
+		regions = append(regions, entry)
+		byName[r.RegionName] = entry
+	}
+	if args.Region != "" && len(regions) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("No DERP region matches %s", args.Region)), nil
+	}
+	slices.SortFunc(regions, func(a, b *region) int { return cmp.Compare(a.ID, b.ID) })
+
+	result := map[string]any{
+		"source":  source,
+		"regions": regions,
+	}
+
+	if args.IncludeDevices {
+		devices, err := dt.client.GetClient().Devices().ListWithAllFields(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+		}
+		var unknown []string
+		for _, device := range devices {
+			home, latency := derpHome(device, derpMap)
+			if home == "" {
+				unknown = append(unknown, device.Name)
+				continue
+			}
+			if r, ok := byName[home]; ok {
+				r.Devices = append(r.Devices, homedDevice{Name: device.Name, NodeID: device.NodeID, LatencyMs: latency})
+			}
+		}
+		for _, r := range regions {
+			slices.SortFunc(r.Devices, func(a, b homedDevice) int { return cmp.Compare(a.Name, b.Name) })
+		}
+		if args.Region == "" && len(unknown) > 0 {
+			slices.Sort(unknown)
+			result["devices_without_home_region"] = unknown
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal DERP map: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -4,6 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -22,6 +27,9 @@ func NewDeviceTools(client *client.TailscaleClient) *DeviceTools {
 func (dt *DeviceTools) RegisterTools(mcpServer *server.MCPServer) {
 	tool := mcp.NewTool(
 		"tailscale_devices_list",
+		readOnlyTool,
+		outputSchema[listOutput[tailscale.Device]](),
+		withPagination,
 		mcp.WithDescription("List all devices in the tailnet. Returns device information including name, IP addresses, machine key, node key, and basic connectivity status. Use 'all' fields to get complete device details including OS version, last seen timestamp, and advanced networking configuration. OAuth Scope: devices:read."),
 		mcp.WithString("fields", mcp.Description("Fields to return. Can be 'all' or 'default'"), mcp.Enum("all", "default"), mcp.DefaultString("default")),
 	)
@@ -29,6 +37,8 @@ func (dt *DeviceTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_device_get",
+		readOnlyTool,
+		outputSchema[tailscale.Device](),
 		mcp.WithDescription("Get detailed information about a specific device in the tailnet. Returns comprehensive device data including hardware specs, network configuration, authentication status, and connectivity details. Use 'all' fields for complete device information including OS version, last seen timestamp, and advanced networking settings. OAuth Scope: devices:read."),
 		mcp.WithString("device_id", mcp.Description("The device ID"), mcp.Required()),
 		mcp.WithString("fields", mcp.Description("Fields to return. Can be 'all' or 'default'"), mcp.Enum("all", "default"), mcp.DefaultString("default")),
@@ -37,6 +47,7 @@ func (dt *DeviceTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_device_delete",
+		destructiveTool,
 		mcp.WithDescription("Remove a device from the tailnet permanently. This action cannot be undone. The device will lose access to the tailnet and must be re-added with a new auth key to rejoin. Use this for devices that are no longer needed or compromised. OAuth Scope: devices:write."),
 		mcp.WithString("device_id", mcp.Description("The device ID to delete"), mcp.Required()),
 	)
@@ -44,6 +55,7 @@ func (dt *DeviceTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_device_authorize",
+		updateTool,
 		mcp.WithDescription("Authorize or deauthorize a device for tailnets requiring device authorization. When authorized=true, grants the device access to the tailnet. When authorized=false, revokes access while keeping the device in the tailnet. Useful for temporarily restricting access without removing the device entirely. OAuth Scope: devices:core."),
 		mcp.WithString("device_id", mcp.Description("The device ID"), mcp.Required()),
 		mcp.WithBoolean("authorized", mcp.Description("Whether to authorize (true) or deauthorize (false) the device"), mcp.Required()),
@@ -52,6 +64,7 @@ func (dt *DeviceTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_device_set_name",
+		updateTool,
 		mcp.WithDescription("Set the Tailscale device name (machine name) for a device. This is the canonical name used throughout the tailnet and affects Magic DNS URLs. Changes propagate immediately, breaking existing Magic DNS URLs with the old name. Provide as FQDN (e.g., 'server.domain.ts.net') or base name (e.g., 'server'). Empty name resets to OS hostname. OAuth Scope: devices:core."),
 		mcp.WithString("device_id", mcp.Description("The device ID"), mcp.Required()),
 		mcp.WithString("name", mcp.Description("The new name for the device"), mcp.Required()),
@@ -60,6 +73,7 @@ func (dt *DeviceTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_device_set_tags",
+		destructiveTool,
 		mcp.WithDescription("Set tags on a device to assign a non-human identity for ACL-based access control. Tags are more flexible than role accounts and allow multiple identities per device. Must be defined in the tailnet policy file with proper ownership. Once tagged, the tag owns the device. Useful for servers, CI/CD systems, and automated services. OAuth Scope: devices:core."),
 		mcp.WithString("device_id", mcp.Description("The device ID"), mcp.Required()),
 		mcp.WithArray("tags", mcp.Description("Array of tags to set on the device"), mcp.WithStringItems(), mcp.Required()),
@@ -68,6 +82,7 @@ func (dt *DeviceTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_device_expire",
+		destructiveTool,
 		mcp.WithDescription("Expire a device's authentication key, forcing it to re-authenticate to maintain tailnet access. This is a security measure to ensure devices periodically refresh their credentials. The device will need to complete the authentication process again. Use this for security compliance or to revoke access temporarily. OAuth Scope: devices:core."),
 		mcp.WithString("device_id", mcp.Description("The device ID to expire"), mcp.Required()),
 	)
@@ -75,6 +90,8 @@ func (dt *DeviceTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_device_routes_list",
+		readOnlyTool,
+		outputSchema[tailscale.DeviceRoutes](),
 		mcp.WithDescription("List subnet routes advertised and enabled for a device. Shows both advertised routes (what the device can route) and enabled routes (what the tailnet allows it to route). Routes must be both advertised and enabled to function as subnet routers or exit nodes. Essential for managing network connectivity and traffic routing. OAuth Scope: devices:routes:read."),
 		mcp.WithString("device_id", mcp.Description("The device ID"), mcp.Required()),
 	)
@@ -82,16 +99,27 @@ func (dt *DeviceTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_device_routes_set",
+		destructiveTool,
 		mcp.WithDescription("Set enabled subnet routes for a device by replacing the existing list. Routes must be both advertised by the device and enabled via this API to function. Cannot set advertised routes (must be done on device). Use for configuring subnet routers and exit nodes. Examples: ['10.0.0.0/16', '192.168.1.0/24']. OAuth Scope: devices:routes."),
 		mcp.WithString("device_id", mcp.Description("The device ID"), mcp.Required()),
 		mcp.WithArray("routes", mcp.Description("Array of routes to set"), mcp.WithStringItems(), mcp.Required()),
 	)
 	mcpServer.AddTool(tool, dt.SetDeviceRoutes)
+
+	tool = mcp.NewTool(
+		"tailscale_devices_name_collisions",
+		readOnlyTool,
+		mcp.WithDescription("Report MagicDNS name collisions. Groups devices that share the same hostname, which MagicDNS disambiguates by auto-suffixing names (e.g., 'server', 'server-1', 'server-2'), and shows which machines are fighting over each name with their owner, OS, and last seen time. Also lists devices that still carry an auto-suffixed name although the conflicting device is gone, which usually means they can be renamed back. Fix collisions with tailscale_device_set_name. OAuth Scope: devices:read."),
+	)
+	mcpServer.AddTool(tool, dt.NameCollisions)
+
+	dt.registerTopologyTools(mcpServer)
 }
 
 func (dt *DeviceTools) ListDevices(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
 		Fields string `json:"fields"`
+		pageArgs
 	}
 
 	if request.Params.Arguments != nil {
@@ -100,6 +128,10 @@ func (dt *DeviceTools) ListDevices(ctx context.Context, request mcp.CallToolRequ
 		}
 	}
 
+	if args.Cursor != "" {
+		return nextPage[tailscale.Device](request.Params.Name, args.pageArgs, "devices")
+	}
+
 	client := dt.client.GetClient()
 	var devices []tailscale.Device
 	var err error
@@ -114,12 +146,7 @@ func (dt *DeviceTools) ListDevices(ctx context.Context, request mcp.CallToolRequ
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
 	}
 
-	devicesJSON, err := json.MarshalIndent(devices, "", "  ")
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal devices: %v", err)), nil
-	}
-
-	return mcp.NewToolResultText(string(devicesJSON)), nil
+	return firstPage(request.Params.Name, devices, args.pageArgs, "devices")
 }
 
 func (dt *DeviceTools) GetDevice(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -151,7 +178,7 @@ func (dt *DeviceTools) GetDevice(ctx context.Context, request mcp.CallToolReques
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal device: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(deviceJSON)), nil
+	return mcp.NewToolResultStructured(device, string(deviceJSON)), nil
 }
 
 func (dt *DeviceTools) DeleteDevice(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -269,7 +296,7 @@ func (dt *DeviceTools) ListDeviceRoutes(ctx context.Context, request mcp.CallToo
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal routes: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(routesJSON)), nil
+	return mcp.NewToolResultStructured(routes, string(routesJSON)), nil
 }
 
 func (dt *DeviceTools) SetDeviceRoutes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -289,3 +316,106 @@ func (dt *DeviceTools) SetDeviceRoutes(ctx context.Context, request mcp.CallTool
 
 	return mcp.NewToolResultText(fmt.Sprintf("Device %s routes set to %v", args.DeviceID, args.Routes)), nil
 }
+
+var magicDNSSuffix = regexp.MustCompile(`^(.+)-[0-9]+$`)
+
+// magicDNSLabel converts a hostname to the label MagicDNS derives from it.
+func magicDNSLabel(hostname string) string {
+	label := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, strings.ToLower(hostname))
+	return strings.Trim(label, "-")
+}
+
+func (dt *DeviceTools) NameCollisions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client := dt.client.GetClient()
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+
+	type namedDevice struct {
+		DeviceID     string    `json:"device_id"`
+		MagicDNSName string    `json:"magic_dns_name"`
+		Hostname     string    `json:"hostname"`
+		User         string    `json:"user"`
+		OS           string    `json:"os"`
+		LastSeen     time.Time `json:"last_seen"`
+		AutoSuffixed bool      `json:"auto_suffixed"`
+	}
+	type collision struct {
+		Name    string        `json:"name"`
+		Devices []namedDevice `json:"devices"`
+	}
+
+	byHostname := make(map[string][]namedDevice)
+	for _, device := range devices {
+		if device.IsExternal {
+			continue
+		}
+		base := magicDNSLabel(device.Hostname)
+		label, _, _ := strings.Cut(device.Name, ".")
+		match := magicDNSSuffix.FindStringSubmatch(label)
+		byHostname[base] = append(byHostname[base], namedDevice{
+			DeviceID:     device.NodeID,
+			MagicDNSName: device.Name,
+			Hostname:     device.Hostname,
+			User:         device.User,
+			OS:           device.OS,
+			LastSeen:     device.LastSeen.Time,
+			AutoSuffixed: match != nil && match[1] == base,
+		})
+	}
+
+	report := struct {
+		Collisions    []collision   `json:"collisions"`
+		StaleSuffixes []namedDevice `json:"stale_suffixes"`
+	}{
+		Collisions:    []collision{},
+		StaleSuffixes: []namedDevice{},
+	}
+	for _, name := range slices.Sorted(maps.Keys(byHostname)) {
+		group := byHostname[name]
+		switch {
+		case len(group) > 1:
+			slices.SortFunc(group, func(a, b namedDevice) int { return strings.Compare(a.MagicDNSName, b.MagicDNSName) })
+			report.Collisions = append(report.Collisions, collision{Name: name, Devices: group})
+		case group[0].AutoSuffixed:
+			report.StaleSuffixes = append(report.StaleSuffixes, group[0])
+		}
+	}
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal name collisions: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(reportJSON)), nil
+}
+
+// resolveDevice finds a device by node ID, legacy ID, MagicDNS name (full or
+// short), or hostname.
+func resolveDevice(devices []tailscale.Device, identifier string) (*tailscale.Device, error) {
+	var matches []tailscale.Device
+	for _, device := range devices {
+		if device.NodeID == identifier || device.ID == identifier {
+			return &device, nil
+		}
+		short, _, _ := strings.Cut(device.Name, ".")
+		if strings.EqualFold(device.Name, identifier) || strings.EqualFold(short, identifier) || strings.EqualFold(device.Hostname, identifier) {
+			matches = append(matches, device)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no device matches %s", identifier)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("%d devices match %s; use the device ID", len(matches), identifier)
+	}
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// settingDiff describes how a single setting would change. Added, Removed,
+// and Modified name the list values, map keys, or object fields involved.
+type settingDiff struct {
+	Setting  string   `json:"setting"`
+	Changed  bool     `json:"changed"`
+	Before   any      `json:"before"`
+	After    any      `json:"after"`
+	Added    []string `json:"added,omitempty"`
+	Removed  []string `json:"removed,omitempty"`
+	Modified []string `json:"modified,omitempty"`
+}
+
+// diffList compares two lists of values. Reordering alone counts as a change.
+func diffList(setting string, before, after []string) settingDiff {
+	diff := settingDiff{Setting: setting, Before: before, After: after}
+	for _, v := range after {
+		if !slices.Contains(before, v) {
+			diff.Added = append(diff.Added, v)
+		}
+	}
+	for _, v := range before {
+		if !slices.Contains(after, v) {
+			diff.Removed = append(diff.Removed, v)
+		}
+	}
+	diff.Changed = !slices.Equal(before, after)
+	return diff
+}
+
+// diffMap compares two maps by key.
+func diffMap[V any](setting string, before, after map[string]V) settingDiff {
+	diff := settingDiff{Setting: setting, Before: before, After: after}
+	for _, k := range slices.Sorted(maps.Keys(after)) {
+		old, ok := before[k]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, k)
+		case !reflect.DeepEqual(old, after[k]):
+			diff.Modified = append(diff.Modified, k)
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(before)) {
+		if _, ok := after[k]; !ok {
+			diff.Removed = append(diff.Removed, k)
+		}
+	}
+	diff.Changed = len(diff.Added)+len(diff.Removed)+len(diff.Modified) > 0
+	return diff
+}
+
+// diffFields compares two values field by field using their JSON encoding.
+func diffFields(setting string, before, after any) (settingDiff, error) {
+	beforeMap, err := jsonObject(before)
+	if err != nil {
+		return settingDiff{}, err
+	}
+	afterMap, err := jsonObject(after)
+	if err != nil {
+		return settingDiff{}, err
+	}
+
+	diff := diffMap(setting, beforeMap, afterMap)
+	diff.Before, diff.After = before, after
+	return diff, nil
+}
+
+// jsonObject returns v's JSON encoding decoded as an object.
+func jsonObject(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// dryRunResult reports the changes a tool would make without applying them.
+func dryRunResult(diffs ...settingDiff) (*mcp.CallToolResult, error) {
+	changed := slices.ContainsFunc(diffs, func(d settingDiff) bool { return d.Changed })
+	resultJSON, err := json.MarshalIndent(map[string]any{
+		"dry_run": true,
+		"changed": changed,
+		"changes": diffs,
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal diff: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -3,81 +3,176 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"gopkg.in/yaml.v3"
 	"tailscale.com/client/tailscale/v2"
 )
 
+// DNSConfigurationPreferences holds the DNS preferences that are part of the
+// tailnet's full DNS configuration.
+type DNSConfigurationPreferences struct {
+	MagicDNS         bool `json:"magicDNS"`
+	OverrideLocalDNS bool `json:"overrideLocalDNS"`
+}
+
 type DNSTools struct {
 	client *client.TailscaleClient
+	config *config.Config
+	// mu serializes read-modify-write updates made through this server.
+	mu sync.Mutex
 }
 
-func NewDNSTools(client *client.TailscaleClient) *DNSTools {
-	return &DNSTools{client: client}
+func NewDNSTools(client *client.TailscaleClient, cfg *config.Config) *DNSTools {
+	return &DNSTools{client: client, config: cfg}
 }
 
 func (dt *DNSTools) RegisterTools(mcpServer *server.MCPServer) {
 	tool := mcp.NewTool(
 		"tailscale_dns_nameservers_get",
+		readOnlyTool,
+		outputSchema[listOutput[string]](),
 		mcp.WithDescription("Get DNS nameservers configured for the tailnet. Returns the list of DNS servers that devices will use for domain resolution. Essential for understanding and troubleshooting DNS configuration. Learn more about DNS in Tailscale at /kb/1054/dns. OAuth Scope: dns:read."),
 	)
 	mcpServer.AddTool(tool, dt.GetNameservers)
 
 	tool = mcp.NewTool(
 		"tailscale_dns_nameservers_set",
-		mcp.WithDescription("Set DNS nameservers for the tailnet. Configure which DNS servers devices will use for domain resolution. Provide IP addresses of DNS servers (e.g., ['8.8.8.8', '1.1.1.1']). Changes apply to all devices in the tailnet. Learn more about DNS in Tailscale at /kb/1054/dns. OAuth Scope: dns:write."),
+		destructiveTool,
+		mcp.WithDescription("Set DNS nameservers for the tailnet. Configure which DNS servers devices will use for domain resolution. Provide IP addresses of DNS servers (e.g., ['8.8.8.8', '1.1.1.1']). Changes apply to all devices in the tailnet. With verify, the change is refused if any nameserver does not answer from the MCP server host; resolvers only reachable inside the tailnet may fail this check. Learn more about DNS in Tailscale at /kb/1054/dns. OAuth Scope: dns:write."),
 		mcp.WithArray("nameservers", mcp.Description("List of DNS nameserver addresses"), mcp.WithStringItems(), mcp.Required()),
+		mcp.WithBoolean("verify", mcp.Description("Check that each nameserver answers DNS queries from the MCP server host before applying the change")),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
 	)
 	mcpServer.AddTool(tool, dt.SetNameservers)
 
+	tool = mcp.NewTool(
+		"tailscale_dns_nameserver_add",
+		updateTool,
+		mcp.WithDescription("Add a single DNS nameserver to the tailnet's global nameservers, keeping the existing ones. The current list is read and updated by the server, so there is no need to fetch and resend the whole list. Adding a nameserver that is already present does nothing. Learn more about DNS in Tailscale at /kb/1054/dns. OAuth Scope: dns:write."),
+		mcp.WithString("nameserver", mcp.Description("IP address of the nameserver to add (e.g., '1.1.1.1')"), mcp.Required()),
+		mcp.WithBoolean("verify", mcp.Description("Check that each nameserver answers DNS queries from the MCP server host before applying the change")),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
+	)
+	mcpServer.AddTool(tool, dt.AddNameserver)
+
+	tool = mcp.NewTool(
+		"tailscale_dns_nameserver_remove",
+		destructiveTool,
+		mcp.WithDescription("Remove a single DNS nameserver from the tailnet's global nameservers, keeping the others. The current list is read and updated by the server. Removing a nameserver that is not present does nothing. Learn more about DNS in Tailscale at /kb/1054/dns. OAuth Scope: dns:write."),
+		mcp.WithString("nameserver", mcp.Description("IP address of the nameserver to remove"), mcp.Required()),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
+	)
+	mcpServer.AddTool(tool, dt.RemoveNameserver)
+
 	tool = mcp.NewTool(
 		"tailscale_dns_preferences_get",
-		mcp.WithDescription("Get DNS preferences for the tailnet. Returns MagicDNS configuration and other DNS settings. MagicDNS enables automatic DNS resolution for device names within the tailnet (e.g., 'device-name.tailnet.ts.net'). Essential for understanding DNS behavior. OAuth Scope: dns:read."),
+		readOnlyTool,
+		outputSchema[DNSConfigurationPreferences](),
+		mcp.WithDescription("Get DNS preferences for the tailnet. Returns MagicDNS and override-local-DNS settings. MagicDNS enables automatic DNS resolution for device names within the tailnet (e.g., 'device-name.tailnet.ts.net'). Override local DNS makes devices use the tailnet's nameservers instead of the DNS settings of the network they are on. Essential for understanding DNS behavior. OAuth Scope: dns:read."),
 	)
 	mcpServer.AddTool(tool, dt.GetPreferences)
 
 	tool = mcp.NewTool(
 		"tailscale_dns_preferences_set",
-		mcp.WithDescription("Set DNS preferences for the tailnet. Enable or disable MagicDNS, which provides automatic DNS resolution for device names within the tailnet. When enabled, devices can reach each other using names like 'device-name.tailnet.ts.net'. Essential for easy device connectivity. OAuth Scope: dns:write."),
-		mcp.WithBoolean("magic_dns", mcp.Description("Enable MagicDNS"), mcp.Required()),
+		updateTool,
+		mcp.WithDescription("Set DNS preferences for the tailnet. Enable or disable MagicDNS, which provides automatic DNS resolution for device names within the tailnet, and override local DNS, which forces devices to use the tailnet's global nameservers instead of the local network's resolver (useful for laptops on untrusted networks). Only the preferences provided are changed; at least one is required. OAuth Scope: dns:write."),
+		mcp.WithBoolean("magic_dns", mcp.Description("Enable MagicDNS")),
+		mcp.WithBoolean("override_local_dns", mcp.Description("Override the local network's DNS settings with the tailnet's global nameservers")),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
 	)
 	mcpServer.AddTool(tool, dt.SetPreferences)
 
 	tool = mcp.NewTool(
 		"tailscale_dns_searchpaths_get",
+		readOnlyTool,
+		outputSchema[listOutput[string]](),
 		mcp.WithDescription("Get DNS search paths for the tailnet. Returns the list of domain suffixes that will be appended to short hostnames during DNS resolution. For example, with search path 'company.com', 'server' resolves to 'server.company.com'. Essential for understanding DNS resolution behavior. OAuth Scope: dns:read."),
 	)
 	mcpServer.AddTool(tool, dt.GetSearchPaths)
 
 	tool = mcp.NewTool(
 		"tailscale_dns_searchpaths_set",
+		destructiveTool,
 		mcp.WithDescription("Set DNS search paths for the tailnet. Configure domain suffixes that will be appended to short hostnames during DNS resolution. For example, with search path 'company.com', typing 'server' will resolve to 'server.company.com'. Improves user experience by enabling short hostname usage. OAuth Scope: dns:write."),
 		mcp.WithArray("search_paths", mcp.Description("List of DNS search paths"), mcp.WithStringItems(), mcp.Required()),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
 	)
 	mcpServer.AddTool(tool, dt.SetSearchPaths)
 
 	tool = mcp.NewTool(
-		"tailscale_policy_get",
-		mcp.WithDescription("Get the current policy file (ACL) for the tailnet. Returns the access control list in HuJSON format that defines who can access what resources. The policy file controls device access, user permissions, and network routing rules. Essential for understanding and managing security policies. Learn more about ACLs at /kb/1018/acls. OAuth Scope: acl:read."),
+		"tailscale_dns_searchpath_add",
+		updateTool,
+		mcp.WithDescription("Append a single DNS search path to the tailnet, keeping the existing ones. The current list is read and updated by the server, so there is no need to fetch and resend the whole list. Adding a search path that is already present does nothing. OAuth Scope: dns:write."),
+		mcp.WithString("search_path", mcp.Description("Domain suffix to add (e.g., 'company.com')"), mcp.Required()),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
+	)
+	mcpServer.AddTool(tool, dt.AddSearchPath)
+
+	tool = mcp.NewTool(
+		"tailscale_dns_searchpath_remove",
+		destructiveTool,
+		mcp.WithDescription("Remove a single DNS search path from the tailnet, keeping the others. The current list is read and updated by the server. Removing a search path that is not present does nothing. OAuth Scope: dns:write."),
+		mcp.WithString("search_path", mcp.Description("Domain suffix to remove"), mcp.Required()),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
+	)
+	mcpServer.AddTool(tool, dt.RemoveSearchPath)
+
+	tool = mcp.NewTool(
+		"tailscale_dns_split_get",
+		readOnlyTool,
+		outputSchema[tailscale.SplitDNSResponse](),
+		mcp.WithDescription("Get the split DNS configuration for the tailnet. Returns a map of domain to nameservers: queries for each domain (e.g., 'corp.example.com') are sent only to its listed nameservers instead of the global ones. Split DNS is configured separately from the global nameservers returned by tailscale_dns_nameservers_get. Learn more at /kb/1054/dns. OAuth Scope: dns:read."),
 	)
-	mcpServer.AddTool(tool, dt.GetPolicy)
+	mcpServer.AddTool(tool, dt.GetSplitDNS)
 
 	tool = mcp.NewTool(
-		"tailscale_policy_set",
-		mcp.WithDescription("Set the policy file (ACL) for the tailnet. Upload a new access control list in HuJSON format to define security policies. Controls device access, user permissions, SSH access, and network routing. Changes apply immediately to all devices. Validate policy first using tailscale_policy_validate. Learn more about ACLs at /kb/1018/acls. OAuth Scope: acl:write."),
-		mcp.WithString("policy", mcp.Description("Policy file content in HuJSON format"), mcp.Required()),
+		"tailscale_dns_split_set",
+		destructiveTool,
+		mcp.WithDescription("Replace the entire split DNS configuration for the tailnet. Provide a map of domain to nameserver addresses (e.g., {\"corp.example.com\": [\"10.0.0.53\"]}). Domains not included are removed, and an empty map clears all split DNS. Use tailscale_dns_split_patch to change individual domains. Learn more at /kb/1054/dns. OAuth Scope: dns:write."),
+		mcp.WithObject("split_dns", mcp.Description("Map of domain to list of nameserver addresses"), mcp.AdditionalProperties(map[string]any{"type": "array", "items": map[string]any{"type": "string"}}), mcp.Required()),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
 	)
-	mcpServer.AddTool(tool, dt.SetPolicy)
+	mcpServer.AddTool(tool, dt.SetSplitDNS)
 
 	tool = mcp.NewTool(
-		"tailscale_policy_validate",
-		mcp.WithDescription("Validate a policy file (ACL) without applying it to the tailnet. Checks the HuJSON syntax and policy rules for errors before deployment. Essential for safe policy management - always validate before setting a new policy. Prevents accidental misconfigurations that could disrupt network access. Learn more about ACLs at /kb/1018/acls. OAuth Scope: acl:read."),
-		mcp.WithString("policy", mcp.Description("Policy file content in HuJSON format to validate"), mcp.Required()),
+		"tailscale_dns_split_patch",
+		destructiveTool,
+		mcp.WithDescription("Update individual split DNS domains without touching the rest of the configuration. Each domain in the map gets the given nameservers; map a domain to null to remove it. Domains not included are left unchanged. Returns the resulting split DNS configuration. Learn more at /kb/1054/dns. OAuth Scope: dns:write."),
+		mcp.WithObject("split_dns", mcp.Description("Map of domain to list of nameserver addresses, or null to remove the domain"), mcp.AdditionalProperties(map[string]any{"type": []string{"array", "null"}, "items": map[string]any{"type": "string"}}), mcp.Required()),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
 	)
-	mcpServer.AddTool(tool, dt.ValidatePolicy)
+	mcpServer.AddTool(tool, dt.PatchSplitDNS)
+
+	tool = mcp.NewTool(
+		"tailscale_dns_export",
+		updateTool,
+		mcp.WithDescription("Export the tailnet's DNS configuration (nameservers, search paths, split DNS, and preferences) as a canonical YAML document. Map keys are sorted so the output is stable and diffs cleanly, making it suitable for keeping DNS settings in a git repository alongside the ACL. Apply it with tailscale_dns_import. OAuth Scope: dns:read."),
+		withExportPath,
+	)
+	mcpServer.AddTool(tool, dt.ExportDNS)
+
+	tool = mcp.NewTool(
+		"tailscale_dns_import",
+		destructiveTool,
+		mcp.WithDescription("Apply a YAML DNS document in the format produced by tailscale_dns_export. The import is idempotent: only sections that differ from the live configuration are written, and sections missing from the document are left unmanaged. Use dry_run to see the diff first. OAuth Scope: dns:write."),
+		mcp.WithString("document", mcp.Description("YAML DNS document with any of: nameservers, search_paths, split_dns, preferences"), mcp.Required()),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
+	)
+	mcpServer.AddTool(tool, dt.ImportDNS)
 }
 
 func (dt *DNSTools) GetNameservers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -92,19 +187,35 @@ func (dt *DNSTools) GetNameservers(ctx context.Context, request mcp.CallToolRequ
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal nameservers: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(nameserversJSON)), nil
+	return mcp.NewToolResultStructured(newListOutput(nameservers), string(nameserversJSON)), nil
 }
 
 func (dt *DNSTools) SetNameservers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
 		Nameservers []string `json:"nameservers"`
+		Verify      bool     `json:"verify"`
+		DryRun      bool     `json:"dry_run"`
 	}
 
 	if err := request.BindArguments(&args); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
 	}
 
+	if args.Verify {
+		if unreachable := checkNameservers(ctx, args.Nameservers); len(unreachable) > 0 {
+			return unreachableNameserversResult(unreachable), nil
+		}
+	}
+
 	client := dt.client.GetClient()
+	if args.DryRun {
+		nameservers, err := client.DNS().Nameservers(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get nameservers: %v", err)), nil
+		}
+		return dryRunResult(diffList("nameservers", nameservers, args.Nameservers))
+	}
+
 	if err := client.DNS().SetNameservers(ctx, args.Nameservers); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to set nameservers: %v", err)), nil
 	}
@@ -112,40 +223,268 @@ func (dt *DNSTools) SetNameservers(ctx context.Context, request mcp.CallToolRequ
 	return mcp.NewToolResultText(fmt.Sprintf("DNS nameservers set to: %v", args.Nameservers)), nil
 }
 
-func (dt *DNSTools) GetPreferences(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (dt *DNSTools) AddNameserver(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return dt.updateNameservers(ctx, request, appendMissing)
+}
+
+func (dt *DNSTools) RemoveNameserver(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return dt.updateNameservers(ctx, request, removeValue)
+}
+
+// appendMissing returns list with value appended, or nil if it is already present.
+func appendMissing(list []string, value string) []string {
+	if slices.ContainsFunc(list, func(v string) bool { return sameNameserver(v, value) }) {
+		return nil
+	}
+	return append(list, value)
+}
+
+// removeValue returns list without value, or nil if it is not present.
+func removeValue(list []string, value string) []string {
+	if !slices.ContainsFunc(list, func(v string) bool { return sameNameserver(v, value) }) {
+		return nil
+	}
+	return slices.DeleteFunc(list, func(v string) bool { return sameNameserver(v, value) })
+}
+
+// sameNameserver reports whether the nameserver entries a and b are the
+// same address, however each is written: the API returns entries as they
+// were set, such as IPv6 addresses without zero compression or in upper
+// case. Entries that are not addresses are compared without case.
+func sameNameserver(a, b string) bool {
+	addrA, errA := netip.ParseAddr(a)
+	addrB, errB := netip.ParseAddr(b)
+	if errA == nil && errB == nil {
+		return addrA == addrB
+	}
+	return strings.EqualFold(a, b)
+}
+
+// updateNameservers applies update to the current nameservers and writes the
+// result back. update returns nil when no change is needed.
+func (dt *DNSTools) updateNameservers(ctx context.Context, request mcp.CallToolRequest, update func(nameservers []string, nameserver string) []string) (*mcp.CallToolResult, error) {
+	var args struct {
+		Nameserver string `json:"nameserver"`
+		Verify     bool   `json:"verify"`
+		DryRun     bool   `json:"dry_run"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	addr, err := netip.ParseAddr(args.Nameserver)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid nameserver address: %v", err)), nil
+	}
+	nameserver := addr.String()
+
+	if args.Verify {
+		if unreachable := checkNameservers(ctx, []string{nameserver}); len(unreachable) > 0 {
+			return unreachableNameserversResult(unreachable), nil
+		}
+	}
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
 	client := dt.client.GetClient()
-	preferences, err := client.DNS().Preferences(ctx)
+	nameservers, err := client.DNS().Nameservers(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get nameservers: %v", err)), nil
+	}
+
+	updated := update(slices.Clone(nameservers), nameserver)
+	if args.DryRun {
+		if updated == nil {
+			updated = nameservers
+		}
+		return dryRunResult(diffList("nameservers", nameservers, updated))
+	}
+	if updated == nil {
+		return mcp.NewToolResultText(fmt.Sprintf("DNS nameservers unchanged: %v", nameservers)), nil
+	}
+
+	if err := client.DNS().SetNameservers(ctx, updated); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set nameservers: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("DNS nameservers set to: %v", updated)), nil
+}
+
+// nameserverCheckTimeout bounds each reachability probe.
+const nameserverCheckTimeout = 3 * time.Second
+
+// checkNameservers sends a DNS query to each nameserver and returns the error
+// for every one that did not answer. Any DNS response, including NXDOMAIN,
+// counts as reachable.
+func checkNameservers(ctx context.Context, nameservers []string) map[string]error {
+	unreachable := make(map[string]error)
+	for _, nameserver := range nameservers {
+		addr, err := netip.ParseAddr(nameserver)
+		if err != nil {
+			unreachable[nameserver] = err
+			continue
+		}
+
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, netip.AddrPortFrom(addr, 53).String())
+			},
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, nameserverCheckTimeout)
+		_, err = resolver.LookupNetIP(probeCtx, "ip4", "tailscale.com")
+		cancel()
+
+		var dnsErr *net.DNSError
+		if err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+			unreachable[nameserver] = err
+		}
+	}
+	return unreachable
+}
+
+func unreachableNameserversResult(unreachable map[string]error) *mcp.CallToolResult {
+	parts := make([]string, 0, len(unreachable))
+	for _, nameserver := range slices.Sorted(maps.Keys(unreachable)) {
+		parts = append(parts, fmt.Sprintf("%s: %v", nameserver, unreachable[nameserver]))
+	}
+	return mcp.NewToolResultError(fmt.Sprintf("Nameservers not changed; unreachable nameservers: %s. Retry without verify to apply anyway.", strings.Join(parts, "; ")))
+}
+
+func (dt *DNSTools) GetPreferences(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	config, err := dt.getDNSConfiguration(ctx)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to get DNS preferences: %v", err)), nil
 	}
 
+	preferences, err := parseDNSPreferences(config)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse DNS preferences: %v", err)), nil
+	}
+
 	preferencesJSON, err := json.MarshalIndent(preferences, "", "  ")
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal preferences: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(preferencesJSON)), nil
+	return mcp.NewToolResultStructured(preferences, string(preferencesJSON)), nil
 }
 
 func (dt *DNSTools) SetPreferences(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
-		MagicDNS bool `json:"magic_dns"`
+		MagicDNS         *bool `json:"magic_dns"`
+		OverrideLocalDNS *bool `json:"override_local_dns"`
+		DryRun           bool  `json:"dry_run"`
 	}
 
 	if err := request.BindArguments(&args); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
 	}
+	if args.MagicDNS == nil && args.OverrideLocalDNS == nil {
+		return mcp.NewToolResultError("At least one of magic_dns or override_local_dns is required"), nil
+	}
 
-	preferences := tailscale.DNSPreferences{
-		MagicDNS: args.MagicDNS,
+	if args.DryRun {
+		config, err := dt.getDNSConfiguration(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get DNS preferences: %v", err)), nil
+		}
+		before, err := parseDNSPreferences(config)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to parse DNS preferences: %v", err)), nil
+		}
+		after := before
+		if args.MagicDNS != nil {
+			after.MagicDNS = *args.MagicDNS
+		}
+		if args.OverrideLocalDNS != nil {
+			after.OverrideLocalDNS = *args.OverrideLocalDNS
+		}
+		diff, err := diffFields("preferences", before, after)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to diff DNS preferences: %v", err)), nil
+		}
+		return dryRunResult(diff)
 	}
 
-	client := dt.client.GetClient()
-	if err := client.DNS().SetPreferences(ctx, preferences); err != nil {
+	// MagicDNS alone is covered by the preferences endpoint; other preferences
+	// are only settable through the full DNS configuration.
+	if args.OverrideLocalDNS == nil {
+		preferences := tailscale.DNSPreferences{
+			MagicDNS: *args.MagicDNS,
+		}
+
+		client := dt.client.GetClient()
+		if err := client.DNS().SetPreferences(ctx, preferences); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to set DNS preferences: %v", err)), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("DNS preferences updated: MagicDNS=%v", *args.MagicDNS)), nil
+	}
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	config, err := dt.getDNSConfiguration(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get DNS configuration: %v", err)), nil
+	}
+
+	preferences, err := parseDNSPreferences(config)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse DNS preferences: %v", err)), nil
+	}
+	if args.MagicDNS != nil {
+		preferences.MagicDNS = *args.MagicDNS
+	}
+	preferences.OverrideLocalDNS = *args.OverrideLocalDNS
+
+	if err := dt.writeDNSPreferences(ctx, config, preferences); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to set DNS preferences: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("DNS preferences updated: MagicDNS=%v", args.MagicDNS)), nil
+	return mcp.NewToolResultText(fmt.Sprintf("DNS preferences updated: MagicDNS=%v, OverrideLocalDNS=%v", preferences.MagicDNS, preferences.OverrideLocalDNS)), nil
+}
+
+// getDNSConfiguration fetches the full DNS configuration. Sections are kept
+// raw so that writing the configuration back preserves anything this server
+// does not model.
+func (dt *DNSTools) getDNSConfiguration(ctx context.Context) (map[string]json.RawMessage, error) {
+	var config map[string]json.RawMessage
+	if err := dt.client.Do(ctx, http.MethodGet, dt.client.TailnetPath("dns", "configuration"), nil, &config); err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = make(map[string]json.RawMessage)
+	}
+	return config, nil
+}
+
+// writeDNSPreferences stores preferences into config and writes the full DNS
+// configuration back.
+func (dt *DNSTools) writeDNSPreferences(ctx context.Context, config map[string]json.RawMessage, preferences DNSConfigurationPreferences) error {
+	raw, err := json.Marshal(preferences)
+	if err != nil {
+		return err
+	}
+	config["preferences"] = raw
+
+	return dt.client.Do(ctx, http.MethodPost, dt.client.TailnetPath("dns", "configuration"), config, nil)
+}
+
+// parseDNSPreferences decodes the preferences section of a DNS configuration.
+func parseDNSPreferences(config map[string]json.RawMessage) (DNSConfigurationPreferences, error) {
+	var preferences DNSConfigurationPreferences
+	if raw, ok := config["preferences"]; ok {
+		if err := json.Unmarshal(raw, &preferences); err != nil {
+			return preferences, err
+		}
+	}
+	return preferences, nil
 }
 
 func (dt *DNSTools) GetSearchPaths(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -160,12 +499,13 @@ func (dt *DNSTools) GetSearchPaths(ctx context.Context, request mcp.CallToolRequ
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal search paths: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(searchPathsJSON)), nil
+	return mcp.NewToolResultStructured(newListOutput(searchPaths), string(searchPathsJSON)), nil
 }
 
 func (dt *DNSTools) SetSearchPaths(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
 		SearchPaths []string `json:"search_paths"`
+		DryRun      bool     `json:"dry_run"`
 	}
 
 	if err := request.BindArguments(&args); err != nil {
@@ -173,6 +513,14 @@ func (dt *DNSTools) SetSearchPaths(ctx context.Context, request mcp.CallToolRequ
 	}
 
 	client := dt.client.GetClient()
+	if args.DryRun {
+		searchPaths, err := client.DNS().SearchPaths(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get search paths: %v", err)), nil
+		}
+		return dryRunResult(diffList("search_paths", searchPaths, args.SearchPaths))
+	}
+
 	if err := client.DNS().SetSearchPaths(ctx, args.SearchPaths); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to set search paths: %v", err)), nil
 	}
@@ -180,46 +528,317 @@ func (dt *DNSTools) SetSearchPaths(ctx context.Context, request mcp.CallToolRequ
 	return mcp.NewToolResultText(fmt.Sprintf("DNS search paths set to: %v", args.SearchPaths)), nil
 }
 
-func (dt *DNSTools) GetPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (dt *DNSTools) AddSearchPath(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return dt.updateSearchPaths(ctx, request, appendMissing)
+}
+
+func (dt *DNSTools) RemoveSearchPath(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return dt.updateSearchPaths(ctx, request, removeValue)
+}
+
+// updateSearchPaths applies update to the current search paths and writes the
+// result back. update returns nil when no change is needed.
+func (dt *DNSTools) updateSearchPaths(ctx context.Context, request mcp.CallToolRequest, update func(searchPaths []string, searchPath string) []string) (*mcp.CallToolResult, error) {
+	var args struct {
+		SearchPath string `json:"search_path"`
+		DryRun     bool   `json:"dry_run"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	searchPath := strings.ToLower(strings.Trim(strings.TrimSpace(args.SearchPath), "."))
+	if searchPath == "" {
+		return mcp.NewToolResultError("search_path must not be empty"), nil
+	}
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	client := dt.client.GetClient()
+	searchPaths, err := client.DNS().SearchPaths(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get search paths: %v", err)), nil
+	}
+
+	updated := update(slices.Clone(searchPaths), searchPath)
+	if args.DryRun {
+		if updated == nil {
+			updated = searchPaths
+		}
+		return dryRunResult(diffList("search_paths", searchPaths, updated))
+	}
+	if updated == nil {
+		return mcp.NewToolResultText(fmt.Sprintf("DNS search paths unchanged: %v", searchPaths)), nil
+	}
+
+	if err := client.DNS().SetSearchPaths(ctx, updated); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set search paths: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("DNS search paths set to: %v", updated)), nil
+}
+
+func (dt *DNSTools) GetSplitDNS(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	client := dt.client.GetClient()
-	policy, err := client.PolicyFile().Raw(ctx)
+	splitDNS, err := client.DNS().SplitDNS(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get split DNS: %v", err)), nil
+	}
+
+	if splitDNS == nil {
+		splitDNS = tailscale.SplitDNSResponse{}
+	}
+
+	splitDNSJSON, err := json.MarshalIndent(splitDNS, "", "  ")
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal split DNS: %v", err)), nil
+	}
+
+	return mcp.NewToolResultStructured(splitDNS, string(splitDNSJSON)), nil
+}
+
+func (dt *DNSTools) SetSplitDNS(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		SplitDNS tailscale.SplitDNSRequest `json:"split_dns"`
+		DryRun   bool                      `json:"dry_run"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	for domain, nameservers := range args.SplitDNS {
+		if len(nameservers) == 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("Domain %s has no nameservers; omit it to remove it", domain)), nil
+		}
+	}
+
+	client := dt.client.GetClient()
+	if args.DryRun {
+		splitDNS, err := client.DNS().SplitDNS(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get split DNS: %v", err)), nil
+		}
+		return dryRunResult(diffMap("split_dns", splitDNS, args.SplitDNS))
 	}
 
-	return mcp.NewToolResultText(policy.HuJSON), nil
+	if err := client.DNS().SetSplitDNS(ctx, args.SplitDNS); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set split DNS: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Split DNS set for %d domain(s)", len(args.SplitDNS))), nil
 }
 
-func (dt *DNSTools) SetPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (dt *DNSTools) PatchSplitDNS(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
-		Policy string `json:"policy"`
+		SplitDNS tailscale.SplitDNSRequest `json:"split_dns"`
+		DryRun   bool                      `json:"dry_run"`
 	}
 
 	if err := request.BindArguments(&args); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
 	}
+	if len(args.SplitDNS) == 0 {
+		return mcp.NewToolResultError("split_dns must contain at least one domain"), nil
+	}
+
+	client := dt.client.GetClient()
+	if args.DryRun {
+		splitDNS, err := client.DNS().SplitDNS(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get split DNS: %v", err)), nil
+		}
+		patched := maps.Clone(splitDNS)
+		for domain, nameservers := range args.SplitDNS {
+			if nameservers == nil {
+				delete(patched, domain)
+			} else {
+				patched[domain] = nameservers
+			}
+		}
+		return dryRunResult(diffMap("split_dns", splitDNS, patched))
+	}
+
+	splitDNS, err := client.DNS().UpdateSplitDNS(ctx, args.SplitDNS)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to update split DNS: %v", err)), nil
+	}
 
+	splitDNSJSON, err := json.MarshalIndent(splitDNS, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal split DNS: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(splitDNSJSON)), nil
+}
+
+// DNSDocument is the YAML form of the tailnet's DNS configuration used for
+// export and import. Sections left out of an imported document are not
+// changed.
+type DNSDocument struct {
+	Nameservers []string            `yaml:"nameservers"`
+	SearchPaths []string            `yaml:"search_paths"`
+	SplitDNS    map[string][]string `yaml:"split_dns"`
+	Preferences *DNSDocumentPrefs   `yaml:"preferences"`
+}
+
+type DNSDocumentPrefs struct {
+	MagicDNS         bool `yaml:"magic_dns"`
+	OverrideLocalDNS bool `yaml:"override_local_dns"`
+}
+
+// currentDNSDocument reads the live DNS configuration.
+func (dt *DNSTools) currentDNSDocument(ctx context.Context) (*DNSDocument, error) {
 	client := dt.client.GetClient()
-	if err := client.PolicyFile().Set(ctx, args.Policy, ""); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to set policy: %v", err)), nil
+	nameservers, err := client.DNS().Nameservers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get nameservers: %w", err)
+	}
+	searchPaths, err := client.DNS().SearchPaths(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search paths: %w", err)
+	}
+	splitDNS, err := client.DNS().SplitDNS(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get split DNS: %w", err)
+	}
+	config, err := dt.getDNSConfiguration(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DNS configuration: %w", err)
+	}
+	preferences, err := parseDNSPreferences(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DNS preferences: %w", err)
+	}
+
+	doc := &DNSDocument{
+		Nameservers: nameservers,
+		SearchPaths: searchPaths,
+		SplitDNS:    splitDNS,
+		Preferences: &DNSDocumentPrefs{MagicDNS: preferences.MagicDNS, OverrideLocalDNS: preferences.OverrideLocalDNS},
+	}
+	if doc.Nameservers == nil {
+		doc.Nameservers = []string{}
+	}
+	if doc.SearchPaths == nil {
+		doc.SearchPaths = []string{}
+	}
+	if doc.SplitDNS == nil {
+		doc.SplitDNS = map[string][]string{}
+	}
+	return doc, nil
+}
+
+func (dt *DNSTools) ExportDNS(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Path string `json:"path"`
 	}
 
-	return mcp.NewToolResultText("Policy file updated successfully"), nil
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	doc, err := dt.currentDNSDocument(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to export DNS configuration: %v", err)), nil
+	}
+
+	docYAML, err := yaml.Marshal(doc)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal DNS configuration: %v", err)), nil
+	}
+
+	return exportResult(ctx, dt.config, args.Path, string(docYAML), "DNS configuration"), nil
 }
 
-func (dt *DNSTools) ValidatePolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (dt *DNSTools) ImportDNS(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
-		Policy string `json:"policy"`
+		Document string `json:"document"`
+		DryRun   bool   `json:"dry_run"`
 	}
 
 	if err := request.BindArguments(&args); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
 	}
 
+	var desired DNSDocument
+	decoder := yaml.NewDecoder(strings.NewReader(args.Document))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&desired); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid DNS document: %v", err)), nil
+	}
+	for domain, nameservers := range desired.SplitDNS {
+		if len(nameservers) == 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("Domain %s has no nameservers; omit it to remove it", domain)), nil
+		}
+	}
+
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	current, err := dt.currentDNSDocument(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read DNS configuration: %v", err)), nil
+	}
+
+	var diffs []settingDiff
+	if desired.Nameservers != nil {
+		diffs = append(diffs, diffList("nameservers", current.Nameservers, desired.Nameservers))
+	}
+	if desired.SearchPaths != nil {
+		diffs = append(diffs, diffList("search_paths", current.SearchPaths, desired.SearchPaths))
+	}
+	if desired.SplitDNS != nil {
+		diffs = append(diffs, diffMap("split_dns", current.SplitDNS, desired.SplitDNS))
+	}
+	if desired.Preferences != nil {
+		diff, err := diffFields("preferences", current.Preferences, desired.Preferences)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to diff DNS preferences: %v", err)), nil
+		}
+		diffs = append(diffs, diff)
+	}
+
+	if args.DryRun {
+		return dryRunResult(diffs...)
+	}
+
 	client := dt.client.GetClient()
-	if err := client.PolicyFile().Validate(ctx, args.Policy); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Policy validation failed: %v", err)), nil
+	var applied []string
+	for _, diff := range diffs {
+		if !diff.Changed {
+			continue
+		}
+		switch diff.Setting {
+		case "nameservers":
+			err = client.DNS().SetNameservers(ctx, desired.Nameservers)
+		case "search_paths":
+			err = client.DNS().SetSearchPaths(ctx, desired.SearchPaths)
+		case "split_dns":
+			err = client.DNS().SetSplitDNS(ctx, desired.SplitDNS)
+		case "preferences":
+			// Re-read the full configuration so that sections applied above
+			// are not overwritten with their old values.
+			var config map[string]json.RawMessage
+			config, err = dt.getDNSConfiguration(ctx)
+			if err == nil {
+				err = dt.writeDNSPreferences(ctx, config, DNSConfigurationPreferences{
+					MagicDNS:         desired.Preferences.MagicDNS,
+					OverrideLocalDNS: desired.Preferences.OverrideLocalDNS,
+				})
+			}
+		}
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to apply %s (already applied: %v): %v", diff.Setting, applied, err)), nil
+		}
+		applied = append(applied, diff.Setting)
 	}
 
-	return mcp.NewToolResultText("Policy validation passed"), nil
+	if len(applied) == 0 {
+		return mcp.NewToolResultText("DNS configuration already matches the document; nothing changed"), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("DNS configuration updated: %s", strings.Join(applied, ", "))), nil
 }
@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUpdateNameserverList(t *testing.T) {
+	current := []string{"8.8.8.8", "2001:DB8:0:0:0:0:0:53", "dns.example.com"}
+	tests := []struct {
+		name       string
+		update     func([]string, string) []string
+		nameserver string
+		want       []string
+	}{
+		{"add new", appendMissing, "1.1.1.1", []string{"8.8.8.8", "2001:DB8:0:0:0:0:0:53", "dns.example.com", "1.1.1.1"}},
+		{"add present", appendMissing, "8.8.8.8", nil},
+		{"add present uncompressed", appendMissing, "2001:db8::53", nil},
+		{"add present in other case", appendMissing, "DNS.example.com", nil},
+		{"remove", removeValue, "8.8.8.8", []string{"2001:DB8:0:0:0:0:0:53", "dns.example.com"}},
+		{"remove uncompressed", removeValue, "2001:db8::53", []string{"8.8.8.8", "dns.example.com"}},
+		{"remove absent", removeValue, "2001:db8::54", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.update(slices.Clone(current), tt.nameserver); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+// rootsTimeout bounds the roots/list request made to find the client's
+// workspace.
+const rootsTimeout = 30 * time.Second
+
+var errNoExportRoot = errors.New("writing exports to files requires TAILSCALE_MCP_EXPORT_DIR or a client that shares its workspace roots; omit path to get the export in the result")
+
+// withExportPath adds the path argument of export tools.
+func withExportPath(tool *mcp.Tool) {
+	mcp.WithString("path", mcp.Description("File to write the export to, relative to TAILSCALE_MCP_EXPORT_DIR or, without it, to the client's first workspace root; the result then holds the file's path instead of the export"))(tool)
+}
+
+// exportRoot returns the directory exports are written under: the
+// configured export directory, or else the client's first filesystem root.
+// Roots are only used over stdio, where the client and the server share a
+// filesystem; over HTTP a client's paths mean nothing on the server.
+func exportRoot(ctx context.Context, cfg *config.Config) (string, error) {
+	if cfg.ExportDir != "" {
+		return cfg.ExportDir, nil
+	}
+
+	mcpServer := server.ServerFromContext(ctx)
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+	if cfg.Transport != "stdio" || mcpServer == nil || !ok || session.GetClientCapabilities().Roots == nil {
+		return "", errNoExportRoot
+	}
+
+	rootsCtx, cancel := context.WithTimeout(ctx, rootsTimeout)
+	defer cancel()
+	result, err := mcpServer.RequestRoots(rootsCtx, mcp.ListRootsRequest{})
+	if err != nil {
+		return "", fmt.Errorf("failed to list the client's roots: %w", err)
+	}
+	for _, root := range result.Roots {
+		u, err := url.Parse(root.URI)
+		if err != nil || u.Scheme != "file" || !filepath.IsAbs(u.Path) {
+			continue
+		}
+		if info, err := os.Stat(u.Path); err == nil && info.IsDir() {
+			return u.Path, nil
+		}
+	}
+	return "", errNoExportRoot
+}
+
+// exportFile returns the file path, relative to the export root, refers to.
+func exportFile(ctx context.Context, cfg *config.Config, path string) (string, error) {
+	if !filepath.IsLocal(path) {
+		return "", fmt.Errorf("path must be relative and stay inside the export directory, got %q", path)
+	}
+	root, err := exportRoot(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, path), nil
+}
+
+// writeExport writes data to file, creating its directory.
+func writeExport(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return fmt.Errorf("failed to create export directory: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write export: %w", err)
+	}
+	return nil
+}
+
+// exportResult returns text as the result of an export tool, or writes it to
+// path under the export root and returns where it went. what names the
+// export in the result.
+func exportResult(ctx context.Context, cfg *config.Config, path, text, what string) *mcp.CallToolResult {
+	if path == "" {
+		return mcp.NewToolResultText(text)
+	}
+	file, err := exportFile(ctx, cfg, path)
+	if err == nil {
+		err = writeExport(file, []byte(text))
+	}
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to export %s: %v", what, err))
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Wrote %s to %s (%d bytes)", what, file, len(text)))
+}
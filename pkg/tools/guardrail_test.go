@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/guardrail"
+	"github.com/pnocera/tailscale-mcp-server/internal/rbac"
+)
+
+// exitRoutes denies routes that make a device an exit node.
+const exitRoutes = `[{"name": "no-exit", "tools": ["tailscale_device_routes_set"], "deny": "args.routes.exists(r, r in ['0.0.0.0/0', '::/0'])"}]`
+
+// deniedBy returns the names of the rules that deny any of calls.
+func deniedBy(t *testing.T, rules string, calls []guardrail.Call) []string {
+	t.Helper()
+	parsed, err := guardrail.Parse(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, call := range calls {
+		for _, rule := range parsed {
+			if !rule.Applies(call.Tool) {
+				continue
+			}
+			denied, err := rule.Denies(call.Tool, call.Args)
+			if err != nil {
+				t.Fatalf("rule %s failed on %s: %v", rule.Name, call.Tool, err)
+			}
+			if denied {
+				names = append(names, rule.Name)
+			}
+		}
+	}
+	return names
+}
+
+func TestChangesetGuardrailCalls(t *testing.T) {
+	args := map[string]any{"steps": []any{
+		map[string]any{"op": "dns_magic_dns", "magic_dns": true},
+		map[string]any{"op": "device_routes", "device": "d1", "routes": []any{"10.0.0.0/24", "0.0.0.0/0"}},
+		map[string]any{"op": "settings", "settings": map[string]any{"devicesApprovalOn": false}},
+	}}
+	calls, err := ChangesetGuardrailCalls(context.Background(), "tailscale_apply_changeset", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"tailscale_dns_preferences_set", "tailscale_device_routes_set", "tailscale_tailnet_settings_update"}
+	if len(calls) != len(want) {
+		t.Fatalf("got %d calls, want %d: %+v", len(calls), len(want), calls)
+	}
+	for i, call := range calls {
+		if call.Tool != want[i] {
+			t.Errorf("call %d is %s, want %s", i, call.Tool, want[i])
+		}
+	}
+	if device := calls[1].Args["device_id"]; device != "d1" {
+		t.Errorf("routes call has device_id %v, want d1", device)
+	}
+	if denied := deniedBy(t, exitRoutes, calls); len(denied) != 1 {
+		t.Errorf("got denials %v, want the exit route step denied by no-exit", denied)
+	}
+
+	safe := map[string]any{"steps": []any{
+		map[string]any{"op": "device_routes", "device": "d1", "routes": []any{"10.0.0.0/24"}},
+	}}
+	calls, err = ChangesetGuardrailCalls(context.Background(), "tailscale_apply_changeset", safe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if denied := deniedBy(t, exitRoutes, calls); len(denied) != 0 {
+		t.Errorf("got denials %v for a subnet route, want none", denied)
+	}
+}
+
+func TestChangesetGuardrailCallsErrors(t *testing.T) {
+	if calls, err := ChangesetGuardrailCalls(context.Background(), "tailscale_device_routes_set", map[string]any{"routes": []any{"0.0.0.0/0"}}); err != nil || calls != nil {
+		t.Errorf("got %+v, %v for another tool, want nothing", calls, err)
+	}
+	if _, err := ChangesetGuardrailCalls(context.Background(), "tailscale_apply_changeset", map[string]any{"steps": []any{map[string]any{"op": "device_delete"}}}); err == nil {
+		t.Errorf("an unknown op was accepted")
+	}
+	if _, err := ChangesetGuardrailCalls(context.Background(), "tailscale_apply_changeset", map[string]any{"steps": "not a list"}); err == nil {
+		t.Errorf("invalid steps were accepted")
+	}
+}
+
+func TestUndoGuardrailCalls(t *testing.T) {
+	ut := NewUndoTools(nil)
+	alice := rbac.WithPrincipal(context.Background(), config.Principal{Name: "alice", Role: rbac.Admin})
+	bob := rbac.WithPrincipal(context.Background(), config.Principal{Name: "bob", Role: rbac.Admin})
+
+	// Alice removed the exit routes; undoing it would put them back.
+	ut.record(alice, "tailscale_device_routes_set", ut.deviceTarget("d1", "routes"), `["0.0.0.0/0","::/0"]`+"\n", `[]`+"\n")
+	ut.record(alice, "tailscale_device_set_name", ut.deviceTarget("d2", "name"), `"old.example.ts.net"`+"\n", `"new.example.ts.net"`+"\n")
+
+	calls, err := ut.GuardrailCalls(alice, "tailscale_undo_last", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0].Tool != "tailscale_device_set_name" || calls[0].Args["name"] != "old" {
+		t.Errorf("got %+v, want the rename back to old", calls)
+	}
+
+	ut.markUndone(ut.last(alice))
+	calls, err = ut.GuardrailCalls(alice, "tailscale_undo_last", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0].Tool != "tailscale_device_routes_set" || calls[0].Args["device_id"] != "d1" {
+		t.Fatalf("got %+v, want the routes of d1 put back", calls)
+	}
+	if denied := deniedBy(t, exitRoutes, calls); len(denied) != 1 {
+		t.Errorf("got denials %v, want undoing to the exit routes denied by no-exit", denied)
+	}
+
+	if calls, err := ut.GuardrailCalls(bob, "tailscale_undo_last", nil); err != nil || calls != nil {
+		t.Errorf("got %+v, %v for another principal, want nothing to undo", calls, err)
+	}
+	if calls, err := ut.GuardrailCalls(alice, "tailscale_changes_list", nil); err != nil || calls != nil {
+		t.Errorf("got %+v, %v for another tool, want nothing", calls, err)
+	}
+}
@@ -1,33 +1,64 @@
 package tools
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
 	"tailscale.com/client/tailscale/v2"
 )
 
+// ciKeyExpirySeconds is the default lifetime of keys minted by
+// tailscale_key_create_ephemeral_ci.
+const ciKeyExpirySeconds = 3600
+
 type KeyTools struct {
 	client *client.TailscaleClient
+	config *config.Config
 }
 
-func NewKeyTools(client *client.TailscaleClient) *KeyTools {
-	return &KeyTools{client: client}
+func NewKeyTools(client *client.TailscaleClient, cfg *config.Config) *KeyTools {
+	return &KeyTools{client: client, config: cfg}
 }
 
 func (kt *KeyTools) RegisterTools(mcpServer *server.MCPServer) {
 	tool := mcp.NewTool(
 		"tailscale_keys_list",
-		mcp.WithDescription("List all authentication keys for the tailnet. Returns all auth keys including reusable keys, ephemeral keys, and tagged keys. Shows key status, expiration times, usage counts, and associated capabilities. Essential for managing device onboarding and access control. OAuth Scope: keys:read."),
+		readOnlyTool,
+		outputSchema[listOutput[tailscale.Key]](),
+		withPagination,
+		mcp.WithDescription("List all authentication keys for the tailnet. Returns all auth keys including reusable keys, ephemeral keys, and tagged keys. Shows key status, expiration times, usage counts, and associated capabilities. Supply filters or a sort order to fetch full key details and narrow the result on tailnets with many keys. Essential for managing device onboarding and access control. OAuth Scope: keys:read."),
+		mcp.WithString("description", mcp.Description("Only keys whose description contains this substring (case-insensitive)")),
+		mcp.WithString("tag", mcp.Description("Only keys that apply this tag to new devices")),
+		mcp.WithBoolean("reusable", mcp.Description("Only reusable (true) or single-use (false) keys")),
+		mcp.WithBoolean("ephemeral", mcp.Description("Only ephemeral (true) or non-ephemeral (false) keys")),
+		mcp.WithString("created_after", mcp.Description("Only keys created at or after this RFC 3339 timestamp")),
+		mcp.WithString("created_before", mcp.Description("Only keys created before this RFC 3339 timestamp")),
+		mcp.WithString("expires_after", mcp.Description("Only keys expiring at or after this RFC 3339 timestamp")),
+		mcp.WithString("expires_before", mcp.Description("Only keys expiring before this RFC 3339 timestamp")),
+		mcp.WithString("sort_by", mcp.Description("Field to sort by"), mcp.Enum("created", "expires", "description", "id")),
+		mcp.WithString("sort_order", mcp.Description("Sort direction"), mcp.Enum("asc", "desc"), mcp.DefaultString("asc")),
 	)
 	mcpServer.AddTool(tool, kt.ListKeys)
 
 	tool = mcp.NewTool(
 		"tailscale_key_get",
+		readOnlyTool,
+		outputSchema[tailscale.Key](),
 		mcp.WithDescription("Get detailed information about a specific authentication key. Returns key capabilities, creation time, expiration status, usage count, and associated tags. Use this to verify key permissions and monitor key usage for security auditing. OAuth Scope: keys:read."),
 		mcp.WithString("key_id", mcp.Description("The key ID"), mcp.Required()),
 	)
@@ -35,6 +66,7 @@ func (kt *KeyTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_key_create",
+		createTool,
 		mcp.WithDescription("Create a new authentication key for device onboarding. Configure key as reusable (multiple devices), ephemeral (temporary devices), or preauthorized (automatic approval). Set expiration time and assign tags for ACL-based access control. Essential for automated device deployment and CI/CD integration. OAuth Scope: keys:write."),
 		mcp.WithBoolean("reusable", mcp.Description("Whether the key can be reused"), mcp.DefaultBool(false)),
 		mcp.WithBoolean("ephemeral", mcp.Description("Whether devices using this key will be ephemeral"), mcp.DefaultBool(false)),
@@ -42,30 +74,155 @@ func (kt *KeyTools) RegisterTools(mcpServer *server.MCPServer) {
 		mcp.WithString("description", mcp.Description("Description of the key")),
 		mcp.WithArray("tags", mcp.Description("Tags to apply to devices using this key"), mcp.WithStringItems()),
 		mcp.WithNumber("expiry_seconds", mcp.Description("Expiry time in seconds from now")),
+		mcp.WithString("template", mcp.Description("Name of a configured key template to create the key from. Cannot be combined with reusable, ephemeral, preauthorized, tags, or expiry_seconds")),
+		mcp.WithString("secret_output", mcp.Description("Where to return the key secret: 'inline' in the result, or 'file' to write it under TAILSCALE_MCP_SECRETS_DIR and return only the file path"), mcp.Enum("inline", "file"), mcp.DefaultString("inline")),
+		withRevealSecrets,
 	)
 	mcpServer.AddTool(tool, kt.CreateKey)
 
+	tool = mcp.NewTool(
+		"tailscale_key_templates_list",
+		readOnlyTool,
+		mcp.WithDescription("List the auth key templates configured on this server. Templates are operator-approved key shapes (reusable, ephemeral, preauthorized, tags, expiry) that can be passed by name to tailscale_key_create. When the server requires templates, keys can only be created from this list."),
+	)
+	mcpServer.AddTool(tool, kt.ListKeyTemplates)
+
+	tool = mcp.NewTool(
+		"tailscale_key_create_ephemeral_ci",
+		createTool,
+		mcp.WithDescription("Create a single-use, preauthorized, ephemeral, tagged authentication key for a CI job in one call. The key expires after one hour unless expiry_seconds is given, and ephemeral devices are removed automatically once they go offline. Returns the key together with ready-to-paste 'tailscale up' instructions. Tags must be defined in the policy file's tagOwners. OAuth Scope: keys:write."),
+		mcp.WithArray("tags", mcp.Description("Tags to apply to the CI device (e.g., ['tag:ci'])"), mcp.WithStringItems(), mcp.Required()),
+		mcp.WithString("description", mcp.Description("Description of the key"), mcp.DefaultString("ephemeral CI key")),
+		mcp.WithNumber("expiry_seconds", mcp.Description("Expiry time in seconds from now"), mcp.DefaultNumber(ciKeyExpirySeconds)),
+		mcp.WithString("hostname", mcp.Description("Hostname to suggest in the join instructions")),
+		mcp.WithString("secret_output", mcp.Description("Where to return the key secret: 'inline' in the result, or 'file' to write it under TAILSCALE_MCP_SECRETS_DIR and return only the file path"), mcp.Enum("inline", "file"), mcp.DefaultString("inline")),
+		withRevealSecrets,
+	)
+	mcpServer.AddTool(tool, kt.CreateEphemeralCIKey)
+
 	tool = mcp.NewTool(
 		"tailscale_key_delete",
+		destructiveTool,
 		mcp.WithDescription("Delete an authentication key to revoke its ability to add new devices. This does not affect devices already authenticated with this key. Use this to clean up unused keys or revoke compromised keys. Essential for maintaining security hygiene and key lifecycle management. OAuth Scope: keys:write."),
 		mcp.WithString("key_id", mcp.Description("The key ID to delete"), mcp.Required()),
 	)
 	mcpServer.AddTool(tool, kt.DeleteKey)
+
+	tool = mcp.NewTool(
+		"tailscale_key_rotate",
+		destructiveOnceTool,
+		mcp.WithDescription("Rotate an authentication key by creating a replacement with identical capabilities, tags, description, and lifetime. Returns the new key secret. Optionally deletes the old key immediately or after a grace period so automation can switch over before the old key is revoked. OAuth Scope: keys:write."),
+		mcp.WithString("key_id", mcp.Description("The key ID to rotate"), mcp.Required()),
+		mcp.WithBoolean("delete_old", mcp.Description("Whether to delete the old key after creating the replacement"), mcp.DefaultBool(false)),
+		mcp.WithNumber("delete_after_seconds", mcp.Description("Grace period in seconds before the old key is deleted (requires delete_old). The deletion is scheduled in memory and does not happen if the server stops first")),
+		mcp.WithString("secret_output", mcp.Description("Where to return the key secret: 'inline' in the result, or 'file' to write it under TAILSCALE_MCP_SECRETS_DIR and return only the file path"), mcp.Enum("inline", "file"), mcp.DefaultString("inline")),
+		withRevealSecrets,
+	)
+	mcpServer.AddTool(tool, kt.RotateKey)
+
+	tool = mcp.NewTool(
+		"tailscale_keys_audit",
+		readOnlyTool,
+		mcp.WithDescription("Audit authentication keys against the devices they onboarded. The API does not record which key created a device, so devices are attributed to keys whose tags match the device tags and whose validity window covers the device creation time. Reports devices attributed only to expired or revoked keys, tagged devices with no matching key (the key was likely deleted), and keys with no attributed devices. OAuth Scope: keys:read, devices:read."),
+	)
+	mcpServer.AddTool(tool, kt.AuditKeys)
+
+	tool = mcp.NewTool(
+		"tailscale_keys_delete_bulk",
+		destructiveTool,
+		mcp.WithDescription("Delete every authentication key matching the given filters. Filters are combined: a key must match all of them to be selected, and at least one filter is required. Runs as a dry run by default, listing exactly which keys would be removed; set dry_run=false to delete them. Devices already authenticated are not affected. OAuth Scope: keys:write."),
+		mcp.WithBoolean("expired", mcp.Description("Select keys that have expired")),
+		mcp.WithBoolean("revoked", mcp.Description("Select keys that have been revoked")),
+		mcp.WithString("description_pattern", mcp.Description("Regular expression matched against the key description")),
+		mcp.WithString("tag", mcp.Description("Select keys that apply this tag to new devices (e.g., 'tag:ci')")),
+		mcp.WithBoolean("dry_run", mcp.Description("Only list the keys that would be deleted"), mcp.DefaultBool(true)),
+	)
+	mcpServer.AddTool(tool, kt.DeleteKeysBulk)
+
+	tool = mcp.NewTool(
+		"tailscale_keys_export",
+		updateTool,
+		mcp.WithDescription("Export the authentication key inventory as CSV or a Markdown table for access reviews. Includes key ID, description, capabilities, tags, creation, expiry, revocation, and status. Key secrets are never included. OAuth Scope: keys:read."),
+		mcp.WithString("format", mcp.Description("Export format"), mcp.Enum("csv", "markdown"), mcp.DefaultString("csv")),
+		withExportPath,
+	)
+	mcpServer.AddTool(tool, kt.ExportKeys)
 }
 
 func (kt *KeyTools) ListKeys(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	client := kt.client.GetClient()
-	keys, err := client.Keys().List(ctx, false)
+	var args struct {
+		Description   string `json:"description"`
+		Tag           string `json:"tag"`
+		Reusable      *bool  `json:"reusable"`
+		Ephemeral     *bool  `json:"ephemeral"`
+		CreatedAfter  string `json:"created_after"`
+		CreatedBefore string `json:"created_before"`
+		ExpiresAfter  string `json:"expires_after"`
+		ExpiresBefore string `json:"expires_before"`
+		SortBy        string `json:"sort_by"`
+		SortOrder     string `json:"sort_order"`
+		pageArgs
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	if args.Cursor != "" {
+		return nextPage[tailscale.Key](request.Params.Name, args.pageArgs, "keys")
+	}
+
+	var bounds [4]time.Time
+	for i, raw := range []string{args.CreatedAfter, args.CreatedBefore, args.ExpiresAfter, args.ExpiresBefore} {
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid timestamp %q: %v", raw, err)), nil
+		}
+		bounds[i] = t
+	}
+	createdAfter, createdBefore, expiresAfter, expiresBefore := bounds[0], bounds[1], bounds[2], bounds[3]
+
+	filtered := args.Description != "" || args.Tag != "" || args.Reusable != nil || args.Ephemeral != nil ||
+		args.CreatedAfter != "" || args.CreatedBefore != "" || args.ExpiresAfter != "" || args.ExpiresBefore != "" || args.SortBy != ""
+
+	var keys []tailscale.Key
+	var err error
+	if filtered {
+		keys, err = kt.client.ListKeysWithDetails(ctx)
+	} else {
+		keys, err = kt.client.GetClient().Keys().List(ctx, false)
+	}
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to list keys: %v", err)), nil
 	}
 
-	keysJSON, err := json.MarshalIndent(keys, "", "  ")
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal keys: %v", err)), nil
+	if filtered {
+		description := strings.ToLower(args.Description)
+		keys = slices.DeleteFunc(keys, func(key tailscale.Key) bool {
+			create := key.Capabilities.Devices.Create
+			switch {
+			case description != "" && !strings.Contains(strings.ToLower(key.Description), description):
+			case args.Tag != "" && !slices.Contains(create.Tags, args.Tag):
+			case args.Reusable != nil && create.Reusable != *args.Reusable:
+			case args.Ephemeral != nil && create.Ephemeral != *args.Ephemeral:
+			case !createdAfter.IsZero() && key.Created.Before(createdAfter):
+			case !createdBefore.IsZero() && !key.Created.Before(createdBefore):
+			case !expiresAfter.IsZero() && key.Expires.Before(expiresAfter):
+			case !expiresBefore.IsZero() && !key.Expires.Before(expiresBefore):
+			default:
+				return false
+			}
+			return true
+		})
+		sortKeys(keys, args.SortBy, args.SortOrder == "desc")
 	}
 
-	return mcp.NewToolResultText(string(keysJSON)), nil
+	return firstPage(request.Params.Name, keys, args.pageArgs, "keys")
 }
 
 func (kt *KeyTools) GetKey(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -88,7 +245,7 @@ func (kt *KeyTools) GetKey(ctx context.Context, request mcp.CallToolRequest) (*m
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal key: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(keyJSON)), nil
+	return mcp.NewToolResultStructured(key, string(keyJSON)), nil
 }
 
 func (kt *KeyTools) CreateKey(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -99,6 +256,8 @@ func (kt *KeyTools) CreateKey(ctx context.Context, request mcp.CallToolRequest)
 		Description   string   `json:"description"`
 		Tags          []string `json:"tags"`
 		ExpirySeconds int      `json:"expiry_seconds"`
+		SecretOutput  string   `json:"secret_output"`
+		Template      string   `json:"template"`
 	}
 
 	if request.Params.Arguments != nil {
@@ -138,18 +297,247 @@ func (kt *KeyTools) CreateKey(ctx context.Context, request mcp.CallToolRequest)
 		createReq.ExpirySeconds = expiry
 	}
 
+	switch {
+	case args.Template != "":
+		template, ok := kt.config.KeyTemplates[args.Template]
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Unknown key template %q. Available templates: %s", args.Template, strings.Join(slices.Sorted(maps.Keys(kt.config.KeyTemplates)), ", "))), nil
+		}
+		for _, name := range []string{"reusable", "ephemeral", "preauthorized", "tags", "expiry_seconds"} {
+			if _, ok := request.GetArguments()[name]; ok {
+				return mcp.NewToolResultError(fmt.Sprintf("Argument %s cannot be combined with a key template", name)), nil
+			}
+		}
+		createReq = template.CreateKeyRequest()
+		if args.Description != "" {
+			createReq.Description = args.Description
+		}
+	case kt.config.RequireKeyTemplate:
+		return mcp.NewToolResultError(fmt.Sprintf("This server only creates keys from approved templates. Available templates: %s", strings.Join(slices.Sorted(maps.Keys(kt.config.KeyTemplates)), ", "))), nil
+	}
+
+	policyNote, err := kt.config.EnforceKeyPolicy(&createReq)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Key request rejected: %v", err)), nil
+	}
+
 	client := kt.client.GetClient()
+	if err := validateTagsAgainstPolicy(ctx, client, createReq.Capabilities.Devices.Create.Tags); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid tags: %v", err)), nil
+	}
+
+	if err := kt.checkSecretOutput(args.SecretOutput); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Cannot store the key secret as a file: %v", err)), nil
+	}
+
 	key, err := client.Keys().Create(ctx, createReq)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to create key: %v", err)), nil
 	}
 
+	if err := kt.storeSecret(key, args.SecretOutput); err != nil {
+		return mcp.NewToolResultError("The key was created but " + discardKey(ctx, client, key, err)), nil
+	}
+
 	keyJSON, err := json.MarshalIndent(key, "", "  ")
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal key: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(keyJSON)), nil
+	result := mcp.NewToolResultText(string(keyJSON))
+	if policyNote != "" {
+		result.Content = append(result.Content, mcp.NewTextContent("Note: "+policyNote))
+	}
+	return result, nil
+}
+
+func (kt *KeyTools) CreateEphemeralCIKey(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := struct {
+		Tags          []string `json:"tags"`
+		Description   string   `json:"description"`
+		ExpirySeconds int64    `json:"expiry_seconds"`
+		Hostname      string   `json:"hostname"`
+		SecretOutput  string   `json:"secret_output"`
+	}{
+		Description:   "ephemeral CI key",
+		ExpirySeconds: ciKeyExpirySeconds,
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	if len(args.Tags) == 0 {
+		return mcp.NewToolResultError("At least one tag is required for a CI key"), nil
+	}
+
+	template := config.KeyTemplate{
+		Description:   args.Description,
+		Ephemeral:     true,
+		Preauthorized: true,
+		Tags:          args.Tags,
+		ExpirySeconds: args.ExpirySeconds,
+	}
+	createReq := template.CreateKeyRequest()
+
+	policyNote, err := kt.config.EnforceKeyPolicy(&createReq)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Key request rejected: %v", err)), nil
+	}
+
+	client := kt.client.GetClient()
+	if err := validateTagsAgainstPolicy(ctx, client, args.Tags); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid tags: %v", err)), nil
+	}
+
+	if err := kt.checkSecretOutput(args.SecretOutput); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Cannot store the key secret as a file: %v", err)), nil
+	}
+
+	key, err := client.Keys().Create(ctx, createReq)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to create key: %v", err)), nil
+	}
+
+	if err := kt.storeSecret(key, args.SecretOutput); err != nil {
+		return mcp.NewToolResultError("The key was created but " + discardKey(ctx, client, key, err)), nil
+	}
+
+	// tailscale up reads the key from disk when given "file:<path>".
+	authKey := key.Key
+	if path, ok := strings.CutPrefix(key.Key, "file://"); ok {
+		authKey = "file:" + path
+	}
+
+	command := fmt.Sprintf("tailscale up --auth-key=%s --advertise-tags=%s", authKey, strings.Join(args.Tags, ","))
+	if args.Hostname != "" {
+		command += " --hostname=" + args.Hostname
+	}
+
+	result := struct {
+		Key          *tailscale.Key `json:"key"`
+		Instructions []string       `json:"instructions"`
+		PolicyNote   string         `json:"policy_note,omitempty"`
+	}{
+		Key: key,
+		Instructions: []string{
+			"Install Tailscale on the CI runner (e.g., curl -fsSL https://tailscale.com/install.sh | sh).",
+			"Join the tailnet: " + command,
+			fmt.Sprintf("The key works once and expires at %s; the device is removed automatically after it goes offline.", key.Expires.UTC().Format(time.RFC3339)),
+		},
+		PolicyNote: policyNote,
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal key: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+func (kt *KeyTools) ListKeyTemplates(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	result := struct {
+		Required  bool                          `json:"required"`
+		Templates map[string]config.KeyTemplate `json:"templates"`
+	}{
+		Required:  kt.config.RequireKeyTemplate,
+		Templates: kt.config.KeyTemplates,
+	}
+
+	templatesJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal key templates: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(templatesJSON)), nil
+}
+
+// withRevealSecrets adds the reveal_secrets argument of tools whose results
+// carry a new secret, which is otherwise masked in every result.
+func withRevealSecrets(tool *mcp.Tool) {
+	mcp.WithBoolean("reveal_secrets", mcp.Description("Return the new secret unmasked in the result; by default it is shown as [REDACTED]. Prefer secret_output 'file' where available"))(tool)
+}
+
+// checkSecretOutput checks, before a key is created, that its secret can be
+// stored as the secret_output option asks: in "file" mode the secrets
+// directory must be configured and writable.
+func (kt *KeyTools) checkSecretOutput(output string) error {
+	if output != "file" {
+		return nil
+	}
+	if kt.config.SecretsDir == "" {
+		return fmt.Errorf("TAILSCALE_MCP_SECRETS_DIR is not configured")
+	}
+
+	if err := os.MkdirAll(kt.config.SecretsDir, 0o700); err != nil {
+		return err
+	}
+	probe, err := os.CreateTemp(kt.config.SecretsDir, ".probe-*")
+	if err != nil {
+		return fmt.Errorf("secrets directory is not writable: %w", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
+
+// storeSecret handles the secret_output option for newly created keys. In
+// "file" mode the secret is written to the configured secrets directory and
+// replaced in the key by a file reference, so it never reaches the transcript.
+func (kt *KeyTools) storeSecret(key *tailscale.Key, output string) error {
+	if output != "file" || key.Key == "" {
+		return nil
+	}
+	if err := kt.checkSecretOutput(output); err != nil {
+		return err
+	}
+
+	path := filepath.Join(kt.config.SecretsDir, key.ID+".key")
+	if err := os.WriteFile(path, []byte(key.Key+"\n"), 0o600); err != nil {
+		return err
+	}
+
+	key.Key = "file://" + path
+	return nil
+}
+
+// discardKey deletes a key whose secret could not be stored, so it does not
+// linger unusable, and describes what happened.
+func discardKey(ctx context.Context, client *tailscale.Client, key *tailscale.Key, storeErr error) string {
+	if err := client.Keys().Delete(ctx, key.ID); err != nil {
+		return fmt.Sprintf("its secret could not be stored (%v) and deleting it failed, so delete key %s by hand: %v", storeErr, key.ID, err)
+	}
+	return fmt.Sprintf("its secret could not be stored, so key %s was deleted again: %v", key.ID, storeErr)
+}
+
+// validateTagsAgainstPolicy checks that every requested tag has an entry in
+// the policy file's tagOwners. If the policy cannot be read (for example the
+// credentials lack acl:read) validation is skipped and the API has the final say.
+func validateTagsAgainstPolicy(ctx context.Context, client *tailscale.Client, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	policy, err := client.PolicyFile().Get(ctx)
+	if err != nil {
+		return nil
+	}
+
+	var unknown []string
+	for _, tag := range tags {
+		if _, ok := policy.TagOwners[tag]; !ok {
+			unknown = append(unknown, tag)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	valid := slices.Sorted(maps.Keys(policy.TagOwners))
+	if len(valid) == 0 {
+		return fmt.Errorf("%s not defined in tagOwners (the policy file defines no tags)", strings.Join(unknown, ", "))
+	}
+	return fmt.Errorf("%s not defined in tagOwners (valid tags: %s)", strings.Join(unknown, ", "), strings.Join(valid, ", "))
 }
 
 func (kt *KeyTools) DeleteKey(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -168,3 +556,372 @@ func (kt *KeyTools) DeleteKey(ctx context.Context, request mcp.CallToolRequest)
 
 	return mcp.NewToolResultText(fmt.Sprintf("Key %s deleted successfully", args.KeyID)), nil
 }
+
+func (kt *KeyTools) RotateKey(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		KeyID              string `json:"key_id"`
+		DeleteOld          bool   `json:"delete_old"`
+		DeleteAfterSeconds int    `json:"delete_after_seconds"`
+		SecretOutput       string `json:"secret_output"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	if args.DeleteAfterSeconds < 0 {
+		return mcp.NewToolResultError("delete_after_seconds cannot be negative"), nil
+	}
+	if args.DeleteAfterSeconds > 0 && !args.DeleteOld {
+		return mcp.NewToolResultError("delete_after_seconds requires delete_old"), nil
+	}
+
+	dryRun := client.IsDryRun(ctx)
+	client := kt.client.GetClient()
+	oldKey, err := client.Keys().Get(ctx, args.KeyID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get key: %v", err)), nil
+	}
+
+	createReq := tailscale.CreateKeyRequest{
+		Capabilities: oldKey.Capabilities,
+		Description:  oldKey.Description,
+	}
+	if !oldKey.Expires.IsZero() && oldKey.Expires.After(oldKey.Created) {
+		createReq.ExpirySeconds = int64(oldKey.Expires.Sub(oldKey.Created).Seconds())
+	}
+
+	policyNote, err := kt.config.EnforceKeyPolicy(&createReq)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Replacement key rejected: %v", err)), nil
+	}
+
+	if err := kt.checkSecretOutput(args.SecretOutput); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Cannot store the replacement key secret as a file: %v", err)), nil
+	}
+
+	newKey, err := client.Keys().Create(ctx, createReq)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to create replacement key: %v", err)), nil
+	}
+
+	if err := kt.storeSecret(newKey, args.SecretOutput); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Old key %s was left untouched. The replacement key was created but %s", oldKey.ID, discardKey(ctx, client, newKey, err))), nil
+	}
+
+	result := struct {
+		NewKey       *tailscale.Key `json:"new_key"`
+		OldKeyID     string         `json:"old_key_id"`
+		OldKeyStatus string         `json:"old_key_status"`
+		PolicyNote   string         `json:"policy_note,omitempty"`
+	}{
+		NewKey:       newKey,
+		OldKeyID:     oldKey.ID,
+		OldKeyStatus: "retained",
+		PolicyNote:   policyNote,
+	}
+
+	switch {
+	case args.DeleteOld && args.DeleteAfterSeconds > 0 && dryRun:
+		// A later delete would run without the dry run's hold-back; record
+		// it now instead, so the dry run shows it.
+		client.Keys().Delete(ctx, oldKey.ID)
+		result.OldKeyStatus = fmt.Sprintf("would be scheduled for deletion in %ds", args.DeleteAfterSeconds)
+	case args.DeleteOld && args.DeleteAfterSeconds > 0:
+		delay := time.Duration(args.DeleteAfterSeconds) * time.Second
+		oldKeyID := oldKey.ID
+		time.AfterFunc(delay, func() {
+			if err := client.Keys().Delete(context.Background(), oldKeyID); err != nil {
+				log.Printf("Failed to delete rotated key %s: %v", oldKeyID, err)
+			}
+		})
+		result.OldKeyStatus = fmt.Sprintf("scheduled for deletion at %s; the deletion is held in memory, so if the server stops before then, delete key %s with tailscale_key_delete", time.Now().Add(delay).UTC().Format(time.RFC3339), oldKeyID)
+	case args.DeleteOld:
+		if err := client.Keys().Delete(ctx, oldKey.ID); err != nil {
+			result.OldKeyStatus = fmt.Sprintf("deletion failed: %v", err)
+		} else {
+			result.OldKeyStatus = "deleted"
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal key rotation result: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+func sortKeys(keys []tailscale.Key, by string, desc bool) {
+	var cmp func(a, b tailscale.Key) int
+	switch by {
+	case "created":
+		cmp = func(a, b tailscale.Key) int { return a.Created.Compare(b.Created) }
+	case "expires":
+		cmp = func(a, b tailscale.Key) int { return a.Expires.Compare(b.Expires) }
+	case "description":
+		cmp = func(a, b tailscale.Key) int { return strings.Compare(a.Description, b.Description) }
+	case "id":
+		cmp = func(a, b tailscale.Key) int { return strings.Compare(a.ID, b.ID) }
+	default:
+		return
+	}
+
+	slices.SortStableFunc(keys, cmp)
+	if desc {
+		slices.Reverse(keys)
+	}
+}
+
+func keyStatus(key tailscale.Key, now time.Time) string {
+	switch {
+	case !key.Revoked.IsZero():
+		return "revoked"
+	case !key.Expires.IsZero() && key.Expires.Before(now):
+		return "expired"
+	case key.Invalid:
+		return "invalid"
+	default:
+		return "active"
+	}
+}
+
+// keyCouldHaveCreated reports whether a device is consistent with having been
+// onboarded by key: identical tags, created while the key was still usable.
+func keyCouldHaveCreated(key tailscale.Key, device tailscale.Device) bool {
+	keyTags := slices.Sorted(slices.Values(key.Capabilities.Devices.Create.Tags))
+	deviceTags := slices.Sorted(slices.Values(device.Tags))
+	if !slices.Equal(keyTags, deviceTags) {
+		return false
+	}
+
+	created := device.Created.Time
+	if created.IsZero() || created.Before(key.Created) {
+		return false
+	}
+	if !key.Revoked.IsZero() && created.After(key.Revoked) {
+		return false
+	}
+	if !key.Expires.IsZero() && created.After(key.Expires) {
+		return false
+	}
+	return true
+}
+
+func (kt *KeyTools) AuditKeys(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	keys, err := kt.client.ListKeysWithDetails(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list keys: %v", err)), nil
+	}
+
+	client := kt.client.GetClient()
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+
+	type keyRef struct {
+		ID          string `json:"id"`
+		Description string `json:"description"`
+		Status      string `json:"status"`
+	}
+	type deviceAttribution struct {
+		DeviceID   string   `json:"device_id"`
+		Name       string   `json:"name"`
+		Tags       []string `json:"tags"`
+		Created    string   `json:"created"`
+		Candidates []keyRef `json:"candidate_keys,omitempty"`
+	}
+	var report struct {
+		Attributed            []deviceAttribution `json:"attributed_devices"`
+		CreatedByInactiveKeys []deviceAttribution `json:"devices_created_by_expired_or_revoked_keys"`
+		UnattributedTagged    []deviceAttribution `json:"tagged_devices_without_matching_key"`
+		KeysWithoutDevices    []keyRef            `json:"keys_without_attributed_devices"`
+	}
+
+	now := time.Now()
+	used := make(map[string]bool)
+	for _, device := range devices {
+		entry := deviceAttribution{
+			DeviceID: device.NodeID,
+			Name:     device.Name,
+			Tags:     device.Tags,
+			Created:  device.Created.UTC().Format(time.RFC3339),
+		}
+
+		active := false
+		for _, key := range keys {
+			if !keyCouldHaveCreated(key, device) {
+				continue
+			}
+			status := keyStatus(key, now)
+			entry.Candidates = append(entry.Candidates, keyRef{ID: key.ID, Description: key.Description, Status: status})
+			used[key.ID] = true
+			if status == "active" {
+				active = true
+			}
+		}
+
+		switch {
+		case len(entry.Candidates) == 0 && len(device.Tags) > 0:
+			report.UnattributedTagged = append(report.UnattributedTagged, entry)
+		case len(entry.Candidates) == 0:
+			// Untagged devices without a matching key were most likely added
+			// through interactive login rather than an auth key.
+		case active:
+			report.Attributed = append(report.Attributed, entry)
+		default:
+			report.CreatedByInactiveKeys = append(report.CreatedByInactiveKeys, entry)
+		}
+	}
+
+	for _, key := range keys {
+		if !used[key.ID] {
+			report.KeysWithoutDevices = append(report.KeysWithoutDevices, keyRef{ID: key.ID, Description: key.Description, Status: keyStatus(key, now)})
+		}
+	}
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal key audit: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(reportJSON)), nil
+}
+
+func (kt *KeyTools) DeleteKeysBulk(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := struct {
+		Expired            bool   `json:"expired"`
+		Revoked            bool   `json:"revoked"`
+		DescriptionPattern string `json:"description_pattern"`
+		Tag                string `json:"tag"`
+		DryRun             bool   `json:"dry_run"`
+	}{DryRun: true}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	if !args.Expired && !args.Revoked && args.DescriptionPattern == "" && args.Tag == "" {
+		return mcp.NewToolResultError("At least one filter (expired, revoked, description_pattern, tag) is required"), nil
+	}
+
+	var pattern *regexp.Regexp
+	if args.DescriptionPattern != "" {
+		var err error
+		if pattern, err = regexp.Compile(args.DescriptionPattern); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid description_pattern: %v", err)), nil
+		}
+	}
+
+	keys, err := kt.client.ListKeysWithDetails(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list keys: %v", err)), nil
+	}
+
+	type keyResult struct {
+		ID          string   `json:"id"`
+		Description string   `json:"description"`
+		Tags        []string `json:"tags"`
+		Status      string   `json:"status"`
+		Result      string   `json:"result"`
+	}
+	results := []keyResult{}
+
+	client := kt.client.GetClient()
+	now := time.Now()
+	for _, key := range keys {
+		status := keyStatus(key, now)
+		if args.Expired && status != "expired" {
+			continue
+		}
+		if args.Revoked && status != "revoked" {
+			continue
+		}
+		if pattern != nil && !pattern.MatchString(key.Description) {
+			continue
+		}
+		if args.Tag != "" && !slices.Contains(key.Capabilities.Devices.Create.Tags, args.Tag) {
+			continue
+		}
+
+		entry := keyResult{
+			ID:          key.ID,
+			Description: key.Description,
+			Tags:        key.Capabilities.Devices.Create.Tags,
+			Status:      status,
+			Result:      "would be deleted",
+		}
+		if !args.DryRun {
+			if err := client.Keys().Delete(ctx, key.ID); err != nil {
+				entry.Result = fmt.Sprintf("deletion failed: %v", err)
+			} else {
+				entry.Result = "deleted"
+			}
+		}
+		results = append(results, entry)
+	}
+
+	resultsJSON, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal bulk deletion results: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultsJSON)), nil
+}
+
+func (kt *KeyTools) ExportKeys(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := struct {
+		Format string `json:"format"`
+		Path   string `json:"path"`
+	}{Format: "csv"}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	keys, err := kt.client.ListKeysWithDetails(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list keys: %v", err)), nil
+	}
+
+	header := []string{"id", "description", "type", "reusable", "ephemeral", "preauthorized", "tags", "created", "expires", "revoked", "status"}
+	rows := make([][]string, 0, len(keys))
+	now := time.Now()
+	for _, key := range keys {
+		create := key.Capabilities.Devices.Create
+		rows = append(rows, []string{
+			key.ID,
+			key.Description,
+			key.KeyType,
+			fmt.Sprint(create.Reusable),
+			fmt.Sprint(create.Ephemeral),
+			fmt.Sprint(create.Preauthorized),
+			strings.Join(create.Tags, " "),
+			formatTime(key.Created),
+			formatTime(key.Expires),
+			formatTime(key.Revoked),
+			keyStatus(key, now),
+		})
+	}
+
+	switch args.Format {
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(header)
+		w.WriteAll(rows)
+		if err := w.Error(); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to write CSV: %v", err)), nil
+		}
+		return exportResult(ctx, kt.config, args.Path, buf.String(), "key inventory"), nil
+	case "markdown":
+		return exportResult(ctx, kt.config, args.Path, markdownTable(header, rows), "key inventory"), nil
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid format: %s", args.Format)), nil
+	}
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+func TestRotateKeyDryRunDoesNotDeleteOldKey(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "k1", "created": "2026-01-01T00:00:00Z", "expires": "2026-04-01T00:00:00Z"}`))
+	}))
+	defer api.Close()
+
+	cfg := &config.Config{TailscaleAPIKey: "test", TailscaleTailnet: "-"}
+	tc, err := client.NewTailscaleClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.GetClient().BaseURL, err = url.Parse(api.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, dryRun := client.WithDryRun(context.Background())
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "tailscale_key_rotate"
+	request.Params.Arguments = map[string]any{"key_id": "k1", "delete_old": true, "delete_after_seconds": 1}
+	result, err := NewKeyTools(tc, cfg).RotateKey(ctx, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.IsError {
+		t.Fatalf("RotateKey returned an error: %v", result.Content)
+	}
+
+	held := false
+	for _, call := range dryRun.Calls() {
+		held = held || call.Method == http.MethodDelete
+	}
+	if !held {
+		t.Errorf("dry run did not record the deletion of the old key, got %+v", dryRun.Calls())
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, method := range methods {
+		if !strings.HasPrefix(method, "GET ") {
+			t.Errorf("dry run sent %s", method)
+		}
+	}
+}
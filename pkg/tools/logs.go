@@ -0,0 +1,803 @@
+package tools
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/webhookrecv"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// LogTools read configuration audit logs and network flow logs. The tools in
+// AdditionalTools only manage where logs are streamed to.
+type LogTools struct {
+	client *client.TailscaleClient
+	config *config.Config
+	events *webhookrecv.Log
+}
+
+func NewLogTools(client *client.TailscaleClient, cfg *config.Config, events *webhookrecv.Log) *LogTools {
+	return &LogTools{client: client, config: cfg, events: events}
+}
+
+func (lt *LogTools) RegisterTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_audit_logs_get",
+		readOnlyTool,
+		mcp.WithDescription("Get configuration audit log entries: who changed what in the tailnet and when, including policy file edits, device approvals, key creation, and setting changes. Entries are returned newest first and can be filtered by actor, action, and target, e.g. to answer 'who changed the ACL yesterday?' (action UPDATE, target ACL). Learn more at /kb/1203/audit-logging. OAuth Scope: logging:read."),
+		mcp.WithString("start", mcp.Description("Start of the time range, RFC 3339 (default: 24 hours before end)")),
+		mcp.WithString("end", mcp.Description("End of the time range, RFC 3339 (default: now)")),
+		mcp.WithString("actor", mcp.Description("Only entries whose actor's login name, display name, or ID contains this (case-insensitive)")),
+		mcp.WithString("action", mcp.Description("Only entries with this action, e.g. CREATE, UPDATE, DELETE (case-insensitive)")),
+		mcp.WithString("target", mcp.Description("Only entries whose target's name, type, or ID contains this (case-insensitive), e.g. 'ACL' or a device name")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of entries to return (default: 100)")),
+	)
+	mcpServer.AddTool(tool, lt.GetAuditLogs)
+
+	tool = mcp.NewTool(
+		"tailscale_audit_logs_export",
+		updateTool,
+		mcp.WithDescription("Export configuration audit log entries for a time range as NDJSON or CSV for SIEM ingestion. Fields follow the Elastic Common Schema where one applies (@timestamp, event.action, event.outcome, user.name, ...), with Tailscale-specific fields under tailscale.*. With path set the export is written to that file under TAILSCALE_MCP_EXPORT_DIR or the client's workspace root; otherwise it is returned as an embedded resource. Takes the same filters as tailscale_audit_logs_get. OAuth Scope: logging:read."),
+		mcp.WithString("start", mcp.Description("Start of the time range, RFC 3339 (default: 24 hours before end)")),
+		mcp.WithString("end", mcp.Description("End of the time range, RFC 3339 (default: now)")),
+		mcp.WithString("actor", mcp.Description("Only entries whose actor's login name, display name, or ID contains this (case-insensitive)")),
+		mcp.WithString("action", mcp.Description("Only entries with this action, e.g. CREATE, UPDATE, DELETE (case-insensitive)")),
+		mcp.WithString("target", mcp.Description("Only entries whose target's name, type, or ID contains this (case-insensitive)")),
+		mcp.WithString("format", mcp.Description("Export format (default: ndjson)"), mcp.Enum("ndjson", "csv")),
+		mcp.WithString("path", mcp.Description("File to write, relative to TAILSCALE_MCP_EXPORT_DIR or, without it, to the client's first workspace root, e.g. 'audit/2024-06-01.ndjson'")),
+	)
+	mcpServer.AddTool(tool, lt.ExportAuditLogs)
+
+	tool = mcp.NewTool(
+		"tailscale_network_logs_get",
+		readOnlyTool,
+		mcp.WithDescription("Get network flow log entries: per node and time window, the connections it made or received with packet and byte counts, split into virtual (tailnet), subnet, exit node, and physical traffic. Filter by node and by a CIDR that the source or destination must fall in, to investigate connectivity from the MCP client. Flow logging must be enabled with tailscale_tailnet_settings_update (network_flow_logging_on). Learn more at /kb/1219/network-flow-logs. OAuth Scope: logging:read."),
+		mcp.WithString("start", mcp.Description("Start of the time range, RFC 3339 (default: 1 hour before end)")),
+		mcp.WithString("end", mcp.Description("End of the time range, RFC 3339 (default: now)")),
+		mcp.WithString("node", mcp.Description("Only logs from this device (node ID, device ID, MagicDNS name, or hostname)")),
+		mcp.WithString("cidr", mcp.Description("Only connections whose source or destination address is in this CIDR, e.g. '100.64.0.0/10' or '10.0.0.5/32'")),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of log entries to return (default: 50)")),
+	)
+	mcpServer.AddTool(tool, lt.GetNetworkLogs)
+
+	tool = mcp.NewTool(
+		"tailscale_network_top_talkers",
+		readOnlyTool,
+		mcp.WithDescription("Summarize network flow logs over a time window instead of returning them raw: the top source/destination pairs and destination ports by bytes, with packet and connection counts, and overall totals. Addresses of tailnet devices are shown with the device name. Takes the same node and CIDR filters as tailscale_network_logs_get. Both ends of a tailnet connection may log it, so per-pair counts can include the same traffic twice. OAuth Scope: logging:read, devices:read."),
+		mcp.WithString("start", mcp.Description("Start of the time range, RFC 3339 (default: 1 hour before end)")),
+		mcp.WithString("end", mcp.Description("End of the time range, RFC 3339 (default: now)")),
+		mcp.WithString("node", mcp.Description("Only logs from this device (node ID, device ID, MagicDNS name, or hostname)")),
+		mcp.WithString("cidr", mcp.Description("Only connections whose source or destination address is in this CIDR")),
+		mcp.WithString("traffic", mcp.Description("Which traffic to count (default: all but physical)"), mcp.Enum("virtual", "subnet", "exit", "physical", "all")),
+		mcp.WithNumber("limit", mcp.Description("Number of rows in each table (default: 20)")),
+	)
+	mcpServer.AddTool(tool, lt.GetTopTalkers)
+
+	tool = mcp.NewTool(
+		"tailscale_security_timeline",
+		readOnlyTool,
+		mcp.WithDescription("Correlate configuration audit log entries, webhook events received by this server, and device lifecycle changes (devices added, node keys expiring) over a time window into one chronological timeline per actor or per device. Use it to reconstruct what happened around an incident without stitching the sources together by hand. Webhook events are only included when the webhook receiver is enabled (TAILSCALE_MCP_WEBHOOK_SECRET), and only those received since the server started. OAuth Scope: logging:read, devices:read."),
+		mcp.WithString("start", mcp.Description("Start of the time range, RFC 3339 (default: 24 hours before end)")),
+		mcp.WithString("end", mcp.Description("End of the time range, RFC 3339 (default: now)")),
+		mcp.WithString("group_by", mcp.Description("Build one timeline per actor or per device (default: actor)"), mcp.Enum("actor", "device")),
+		mcp.WithString("actor", mcp.Description("Only events by actors whose login name contains this (case-insensitive)")),
+		mcp.WithString("device", mcp.Description("Only events about devices whose name or node ID contains this (case-insensitive)")),
+	)
+	mcpServer.AddTool(tool, lt.GetSecurityTimeline)
+}
+
+func (lt *LogTools) GetAuditLogs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Start  string `json:"start"`
+		End    string `json:"end"`
+		Actor  string `json:"actor"`
+		Action string `json:"action"`
+		Target string `json:"target"`
+		Limit  int    `json:"limit"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+	if args.Limit <= 0 {
+		args.Limit = 100
+	}
+
+	start, end, err := logTimeRange(args.Start, args.End, 24*time.Hour)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	entries, err := lt.client.AuditLogs(ctx, start, end)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get audit logs: %v", err)), nil
+	}
+
+	logs := filterAuditLogs(entries, args.Actor, args.Action, args.Target)
+
+	total := len(logs)
+	logs = logs[:min(total, args.Limit)]
+
+	resultJSON, err := json.MarshalIndent(map[string]any{
+		"start":     start.UTC(),
+		"end":       end.UTC(),
+		"total":     total,
+		"truncated": total > len(logs),
+		"logs":      logs,
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal audit logs: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+func (lt *LogTools) GetNetworkLogs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+		Node  string `json:"node"`
+		CIDR  string `json:"cidr"`
+		Limit int    `json:"limit"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+	if args.Limit <= 0 {
+		args.Limit = 50
+	}
+
+	flows, err := lt.queryNetworkLogs(ctx, args.Start, args.End, args.Node, args.CIDR)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	logs, devices := flows.logs, flows.devices
+	slices.SortStableFunc(logs, func(a, b client.NetworkLogEntry) int { return b.Start.Compare(a.Start) })
+
+	total := len(logs)
+	logs = logs[:min(total, args.Limit)]
+
+	// Name the nodes that appear, since logs only carry node IDs.
+	nodes := make(map[string]string)
+	for _, entry := range logs {
+		if i := slices.IndexFunc(devices, func(d tailscale.Device) bool { return d.NodeID == entry.NodeID }); i >= 0 {
+			nodes[entry.NodeID] = devices[i].Name
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(map[string]any{
+		"start":     flows.start.UTC(),
+		"end":       flows.end.UTC(),
+		"total":     total,
+		"truncated": total > len(logs),
+		"nodes":     nodes,
+		"logs":      logs,
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal network logs: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// talker is a row of a top-talkers table.
+type talker struct {
+	Src         string `json:"src,omitempty"`
+	Dst         string `json:"dst,omitempty"`
+	Port        string `json:"port,omitempty"`
+	Bytes       uint64 `json:"bytes"`
+	TxBytes     uint64 `json:"tx_bytes"`
+	RxBytes     uint64 `json:"rx_bytes"`
+	Packets     uint64 `json:"packets"`
+	Connections int    `json:"connections"`
+}
+
+func (t *talker) add(stats client.TrafficStats) {
+	t.TxBytes += stats.TxBytes
+	t.RxBytes += stats.RxBytes
+	t.Bytes += stats.TxBytes + stats.RxBytes
+	t.Packets += stats.TxPkts + stats.RxPkts
+	t.Connections++
+}
+
+func (lt *LogTools) GetTopTalkers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Start   string `json:"start"`
+		End     string `json:"end"`
+		Node    string `json:"node"`
+		CIDR    string `json:"cidr"`
+		Traffic string `json:"traffic"`
+		Limit   int    `json:"limit"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+	if args.Limit <= 0 {
+		args.Limit = 20
+	}
+	switch args.Traffic {
+	case "", "virtual", "subnet", "exit", "physical", "all":
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("traffic must be one of virtual, subnet, exit, physical, or all, got %q", args.Traffic)), nil
+	}
+
+	flows, err := lt.queryNetworkLogs(ctx, args.Start, args.End, args.Node, args.CIDR)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	names := make(map[netip.Addr]string)
+	for _, device := range flows.devices {
+		for _, address := range device.Addresses {
+			if addr, err := netip.ParseAddr(address); err == nil {
+				names[addr] = device.Name
+			}
+		}
+	}
+	label := func(addr netip.Addr) string {
+		if name, ok := names[addr]; ok {
+			return name + " (" + addr.String() + ")"
+		}
+		return addr.String()
+	}
+
+	pairs := make(map[string]*talker)
+	ports := make(map[string]*talker)
+	var total talker
+	nodes := make(map[string]bool)
+	for _, entry := range flows.logs {
+		var traffic []client.TrafficStats
+		switch args.Traffic {
+		case "virtual":
+			traffic = entry.VirtualTraffic
+		case "subnet":
+			traffic = entry.SubnetTraffic
+		case "exit":
+			traffic = entry.ExitTraffic
+		case "physical":
+			traffic = entry.PhysicalTraffic
+		case "all":
+			traffic = slices.Concat(entry.VirtualTraffic, entry.SubnetTraffic, entry.ExitTraffic, entry.PhysicalTraffic)
+		case "":
+			traffic = slices.Concat(entry.VirtualTraffic, entry.SubnetTraffic, entry.ExitTraffic)
+		}
+
+		for _, stats := range traffic {
+			src, srcErr := netip.ParseAddrPort(stats.Src)
+			dst, dstErr := netip.ParseAddrPort(stats.Dst)
+			if srcErr != nil || dstErr != nil {
+				continue
+			}
+			nodes[entry.NodeID] = true
+			total.add(stats)
+
+			pairKey := src.Addr().String() + " " + dst.Addr().String()
+			if pairs[pairKey] == nil {
+				pairs[pairKey] = &talker{Src: label(src.Addr()), Dst: label(dst.Addr())}
+			}
+			pairs[pairKey].add(stats)
+
+			port := fmt.Sprintf("%d/%s", dst.Port(), protocolName(stats.Proto))
+			if ports[port] == nil {
+				ports[port] = &talker{Port: port}
+			}
+			ports[port].add(stats)
+		}
+	}
+
+	top := func(rows map[string]*talker) []*talker {
+		sorted := slices.SortedFunc(maps.Values(rows), func(a, b *talker) int {
+			if a.Bytes != b.Bytes {
+				return cmp.Compare(b.Bytes, a.Bytes)
+			}
+			return cmp.Compare(a.Src+a.Dst+a.Port, b.Src+b.Dst+b.Port)
+		})
+		if sorted == nil {
+			return []*talker{}
+		}
+		return sorted[:min(len(sorted), args.Limit)]
+	}
+
+	resultJSON, err := json.MarshalIndent(map[string]any{
+		"start": flows.start.UTC(),
+		"end":   flows.end.UTC(),
+		"totals": map[string]any{
+			"bytes":       total.Bytes,
+			"packets":     total.Packets,
+			"connections": total.Connections,
+			"pairs":       len(pairs),
+			"nodes":       len(nodes),
+		},
+		"top_pairs": top(pairs),
+		"top_ports": top(ports),
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal top talkers: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// protocolName names an IP protocol number from a flow log.
+func protocolName(proto int) string {
+	switch proto {
+	case 1:
+		return "icmp"
+	case 6:
+		return "tcp"
+	case 17:
+		return "udp"
+	case 58:
+		return "icmpv6"
+	}
+	return fmt.Sprintf("proto-%d", proto)
+}
+
+// networkLogs is the result of a flow log query.
+type networkLogs struct {
+	start, end time.Time
+	logs       []client.NetworkLogEntry
+	devices    []tailscale.Device
+}
+
+// queryNetworkLogs fetches the flow logs in a time range, keeping those of
+// node, if set, and only the connections with an address in cidr, if set.
+// The tailnet's devices are returned too, to name the nodes in the logs.
+// Its errors are worded to be returned to the caller as they are.
+func (lt *LogTools) queryNetworkLogs(ctx context.Context, rawStart, rawEnd, node, cidr string) (*networkLogs, error) {
+	start, end, err := logTimeRange(rawStart, rawEnd, time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	var prefix netip.Prefix
+	if cidr != "" {
+		if prefix, err = netip.ParsePrefix(cidr); err != nil {
+			return nil, fmt.Errorf("Invalid cidr %q: %v", cidr, err)
+		}
+	}
+
+	devices, err := lt.client.GetClient().Devices().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list devices: %w", err)
+	}
+	var nodeID string
+	if node != "" {
+		device, err := resolveDevice(devices, node)
+		if err != nil {
+			return nil, err
+		}
+		nodeID = device.NodeID
+	}
+
+	entries, err := lt.client.NetworkLogs(ctx, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get network logs: %w", err)
+	}
+
+	logs := []client.NetworkLogEntry{}
+	for _, entry := range entries {
+		if nodeID != "" && entry.NodeID != nodeID {
+			continue
+		}
+		if prefix.IsValid() {
+			entry.VirtualTraffic = trafficInPrefix(entry.VirtualTraffic, prefix)
+			entry.SubnetTraffic = trafficInPrefix(entry.SubnetTraffic, prefix)
+			entry.ExitTraffic = trafficInPrefix(entry.ExitTraffic, prefix)
+			entry.PhysicalTraffic = trafficInPrefix(entry.PhysicalTraffic, prefix)
+			if len(entry.VirtualTraffic)+len(entry.SubnetTraffic)+len(entry.ExitTraffic)+len(entry.PhysicalTraffic) == 0 {
+				continue
+			}
+		}
+		logs = append(logs, entry)
+	}
+	return &networkLogs{start: start, end: end, logs: logs, devices: devices}, nil
+}
+
+// trafficInPrefix keeps the connections whose source or destination address
+// is in prefix.
+func trafficInPrefix(traffic []client.TrafficStats, prefix netip.Prefix) []client.TrafficStats {
+	var kept []client.TrafficStats
+	for _, t := range traffic {
+		if prefixContainsEndpoint(prefix, t.Src) || prefixContainsEndpoint(prefix, t.Dst) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// prefixContainsEndpoint reports whether an "ip:port" or bare IP flow log
+// address is in prefix.
+func prefixContainsEndpoint(prefix netip.Prefix, endpoint string) bool {
+	if addrPort, err := netip.ParseAddrPort(endpoint); err == nil {
+		return prefix.Contains(addrPort.Addr())
+	}
+	addr, err := netip.ParseAddr(endpoint)
+	return err == nil && prefix.Contains(addr)
+}
+
+// timelineEvent is one event of a correlated security timeline.
+type timelineEvent struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Type    string    `json:"type"`
+	Actor   string    `json:"actor,omitempty"`
+	Device  string    `json:"device,omitempty"`
+	Summary string    `json:"summary"`
+}
+
+func (lt *LogTools) GetSecurityTimeline(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := struct {
+		Start   string `json:"start"`
+		End     string `json:"end"`
+		GroupBy string `json:"group_by"`
+		Actor   string `json:"actor"`
+		Device  string `json:"device"`
+	}{GroupBy: "actor"}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+	if args.GroupBy != "actor" && args.GroupBy != "device" {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid group_by: %s", args.GroupBy)), nil
+	}
+
+	start, end, err := logTimeRange(args.Start, args.End, 24*time.Hour)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	entries, err := lt.client.AuditLogs(ctx, start, end)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get audit logs: %v", err)), nil
+	}
+	devices, err := lt.client.GetClient().Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+	deviceNames := make(map[string]string, len(devices))
+	for _, device := range devices {
+		deviceNames[device.NodeID] = device.Name
+		deviceNames[device.ID] = device.Name
+	}
+
+	var events []timelineEvent
+	for _, entry := range entries {
+		event := timelineEvent{
+			Time:    entry.EventTime,
+			Source:  "audit_log",
+			Type:    entry.Type + " " + entry.Target.Type,
+			Actor:   entry.Actor.LoginName,
+			Summary: strings.TrimSpace(fmt.Sprintf("%s %s %s %s", entry.Type, entry.Target.Type, entry.Target.Name, entry.Target.Property)),
+		}
+		if strings.EqualFold(entry.Target.Type, "NODE") {
+			event.Device = cmp.Or(deviceNames[entry.Target.ID], entry.Target.Name, entry.Target.ID)
+		}
+		if entry.Error != "" {
+			event.Summary += " (failed: " + entry.Error + ")"
+		}
+		events = append(events, event)
+	}
+
+	for _, webhook := range lt.events.Between(start, end) {
+		var data struct {
+			Actor      string `json:"actor"`
+			NodeID     string `json:"nodeID"`
+			DeviceName string `json:"deviceName"`
+		}
+		summary := webhook.Message
+		if len(webhook.Data) > 0 {
+			if err := json.Unmarshal(webhook.Data, &data); err != nil {
+				// Keep the event, without the actor and device its data
+				// would have named, rather than lose the whole timeline.
+				log.Printf("warning: Failed to parse %s webhook event data: %v", webhook.Type, err)
+				data.Actor, data.NodeID, data.DeviceName = "", "", ""
+				summary = strings.TrimSpace(summary + " (event data could not be parsed: " + err.Error() + ")")
+			}
+		}
+		events = append(events, timelineEvent{
+			Time:    webhook.Timestamp,
+			Source:  "webhook",
+			Type:    webhook.Type,
+			Actor:   data.Actor,
+			Device:  cmp.Or(data.DeviceName, deviceNames[data.NodeID], data.NodeID),
+			Summary: summary,
+		})
+	}
+
+	inWindow := func(t time.Time) bool { return !t.Before(start) && t.Before(end) }
+	for _, device := range devices {
+		if inWindow(device.Created.Time) {
+			events = append(events, timelineEvent{
+				Time:    device.Created.Time,
+				Source:  "device",
+				Type:    "device_added",
+				Actor:   device.User,
+				Device:  device.Name,
+				Summary: fmt.Sprintf("%s (%s) added by %s", device.Name, device.OS, device.User),
+			})
+		}
+		if !device.KeyExpiryDisabled && inWindow(device.Expires.Time) {
+			events = append(events, timelineEvent{
+				Time:    device.Expires.Time,
+				Source:  "device",
+				Type:    "key_expiry",
+				Device:  device.Name,
+				Summary: fmt.Sprintf("node key of %s expires", device.Name),
+			})
+		}
+	}
+
+	containsFold := func(s, substr string) bool { return strings.Contains(strings.ToLower(s), strings.ToLower(substr)) }
+	timelines := make(map[string][]timelineEvent)
+	for _, event := range events {
+		if args.Actor != "" && !containsFold(event.Actor, args.Actor) {
+			continue
+		}
+		if args.Device != "" && !containsFold(event.Device, args.Device) {
+			continue
+		}
+		key := event.Actor
+		if args.GroupBy == "device" {
+			key = event.Device
+		}
+		if key == "" {
+			key = "(none)"
+		}
+		timelines[key] = append(timelines[key], event)
+	}
+	for _, timeline := range timelines {
+		slices.SortStableFunc(timeline, func(a, b timelineEvent) int { return a.Time.Compare(b.Time) })
+	}
+
+	result := map[string]any{
+		"start":     start.UTC(),
+		"end":       end.UTC(),
+		"group_by":  args.GroupBy,
+		"timelines": timelines,
+	}
+	if len(lt.config.WebhookSecrets) == 0 {
+		result["note"] = "webhook receiver is disabled, so no webhook events are included"
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal timeline: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// siemAuditRecord is an audit log entry with Elastic Common Schema field names,
+// which most SIEMs map without configuration.
+type siemAuditRecord struct {
+	Timestamp time.Time `json:"@timestamp"`
+	Event     struct {
+		Kind     string   `json:"kind"`
+		Category []string `json:"category"`
+		Type     []string `json:"type"`
+		Action   string   `json:"action"`
+		Outcome  string   `json:"outcome"`
+		ID       string   `json:"id"`
+		Provider string   `json:"provider"`
+	} `json:"event"`
+	User struct {
+		ID       string `json:"id,omitempty"`
+		Name     string `json:"name,omitempty"`
+		FullName string `json:"full_name,omitempty"`
+	} `json:"user"`
+	Tailscale struct {
+		Origin        string                `json:"origin,omitempty"`
+		ActorType     string                `json:"actor_type,omitempty"`
+		Target        client.AuditLogTarget `json:"target"`
+		Old           json.RawMessage       `json:"old,omitempty"`
+		New           json.RawMessage       `json:"new,omitempty"`
+		ActionDetails string                `json:"action_details,omitempty"`
+		Error         string                `json:"error,omitempty"`
+	} `json:"tailscale"`
+}
+
+func newSIEMAuditRecord(entry client.AuditLogEntry) siemAuditRecord {
+	var r siemAuditRecord
+	r.Timestamp = entry.EventTime.UTC()
+	r.Event.Kind = "event"
+	r.Event.Category = []string{"configuration"}
+	switch strings.ToUpper(entry.Type) {
+	case "CREATE":
+		r.Event.Type = []string{"creation"}
+	case "DELETE":
+		r.Event.Type = []string{"deletion"}
+	default:
+		r.Event.Type = []string{"change"}
+	}
+	r.Event.Action = strings.ToLower(entry.Type)
+	r.Event.Outcome = "success"
+	if entry.Error != "" {
+		r.Event.Outcome = "failure"
+	}
+	r.Event.ID = entry.EventGroupID
+	r.Event.Provider = "tailscale"
+	r.User.ID = entry.Actor.ID
+	r.User.Name = entry.Actor.LoginName
+	r.User.FullName = entry.Actor.DisplayName
+	r.Tailscale.Origin = entry.Origin
+	r.Tailscale.ActorType = entry.Actor.Type
+	r.Tailscale.Target = entry.Target
+	r.Tailscale.Old = entry.Old
+	r.Tailscale.New = entry.New
+	r.Tailscale.ActionDetails = entry.ActionDetails
+	r.Tailscale.Error = entry.Error
+	return r
+}
+
+// siemAuditColumns are the CSV columns of an audit log export, named after
+// the corresponding NDJSON fields.
+var siemAuditColumns = []string{
+	"@timestamp", "event.action", "event.outcome", "event.id", "user.id", "user.name", "user.full_name",
+	"tailscale.actor_type", "tailscale.origin", "tailscale.target.type", "tailscale.target.id",
+	"tailscale.target.name", "tailscale.target.property", "tailscale.old", "tailscale.new",
+	"tailscale.action_details", "tailscale.error",
+}
+
+func (r siemAuditRecord) csvRow() []string {
+	return []string{
+		r.Timestamp.Format(time.RFC3339), r.Event.Action, r.Event.Outcome, r.Event.ID, r.User.ID, r.User.Name, r.User.FullName,
+		r.Tailscale.ActorType, r.Tailscale.Origin, r.Tailscale.Target.Type, r.Tailscale.Target.ID,
+		r.Tailscale.Target.Name, r.Tailscale.Target.Property, string(r.Tailscale.Old), string(r.Tailscale.New),
+		r.Tailscale.ActionDetails, r.Tailscale.Error,
+	}
+}
+
+func (lt *LogTools) ExportAuditLogs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := struct {
+		Start  string `json:"start"`
+		End    string `json:"end"`
+		Actor  string `json:"actor"`
+		Action string `json:"action"`
+		Target string `json:"target"`
+		Format string `json:"format"`
+		Path   string `json:"path"`
+	}{Format: "ndjson"}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+	if args.Format != "ndjson" && args.Format != "csv" {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid format: %s", args.Format)), nil
+	}
+
+	var path string
+	if args.Path != "" {
+		var err error
+		if path, err = exportFile(ctx, lt.config, args.Path); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to export audit logs: %v", err)), nil
+		}
+	}
+
+	start, end, err := logTimeRange(args.Start, args.End, 24*time.Hour)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	entries, err := lt.client.AuditLogs(ctx, start, end)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get audit logs: %v", err)), nil
+	}
+	logs := filterAuditLogs(entries, args.Actor, args.Action, args.Target)
+	// SIEMs expect events in the order they happened.
+	slices.Reverse(logs)
+
+	var buf bytes.Buffer
+	mimeType := "application/x-ndjson"
+	if args.Format == "csv" {
+		mimeType = "text/csv"
+		w := csv.NewWriter(&buf)
+		w.Write(siemAuditColumns)
+		for _, entry := range logs {
+			w.Write(newSIEMAuditRecord(entry).csvRow())
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to write CSV: %v", err)), nil
+		}
+	} else {
+		enc := json.NewEncoder(&buf)
+		for _, entry := range logs {
+			if err := enc.Encode(newSIEMAuditRecord(entry)); err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal audit log entry: %v", err)), nil
+			}
+		}
+	}
+
+	summary := fmt.Sprintf("Exported %d audit log entries from %s to %s", len(logs), start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+	if path == "" {
+		result := mcp.NewToolResultText(summary)
+		result.Content = append(result.Content, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+			URI:      fmt.Sprintf("tailscale://exports/audit-logs-%s.%s", start.UTC().Format("20060102T150405Z"), args.Format),
+			MIMEType: mimeType,
+			Text:     buf.String(),
+		}))
+		return result, nil
+	}
+
+	if err := writeExport(path, buf.Bytes()); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to export audit logs: %v", err)), nil
+	}
+	return mcp.NewToolResultText(summary + " in " + path), nil
+}
+
+// filterAuditLogs keeps the entries matching the actor, action, and target
+// filters, sorted newest first. Empty filters match everything.
+func filterAuditLogs(entries []client.AuditLogEntry, actor, action, target string) []client.AuditLogEntry {
+	matches := func(filter string, values ...string) bool {
+		if filter == "" {
+			return true
+		}
+		return slices.ContainsFunc(values, func(v string) bool {
+			return strings.Contains(strings.ToLower(v), strings.ToLower(filter))
+		})
+	}
+
+	logs := []client.AuditLogEntry{}
+	for _, entry := range entries {
+		if !matches(actor, entry.Actor.LoginName, entry.Actor.DisplayName, entry.Actor.ID) ||
+			!matches(target, entry.Target.Name, entry.Target.Type, entry.Target.ID) ||
+			(action != "" && !strings.EqualFold(entry.Type, action)) {
+			continue
+		}
+		logs = append(logs, entry)
+	}
+	slices.SortStableFunc(logs, func(a, b client.AuditLogEntry) int { return b.EventTime.Compare(a.EventTime) })
+	return logs
+}
+
+// logTimeRange parses RFC 3339 start and end arguments. end defaults to now
+// and start to window before end.
+func logTimeRange(rawStart, rawEnd string, window time.Duration) (time.Time, time.Time, error) {
+	end := time.Now()
+	if rawEnd != "" {
+		t, err := time.Parse(time.RFC3339, rawEnd)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end %q: %v", rawEnd, err)
+		}
+		end = t
+	}
+	start := end.Add(-window)
+	if rawStart != "" {
+		t, err := time.Parse(time.RFC3339, rawStart)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start %q: %v", rawStart, err)
+		}
+		start = t
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start must be before end")
+	}
+	return start, end, nil
+}
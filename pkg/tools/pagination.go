@@ -0,0 +1,155 @@
+package tools
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+	// listingTTL is how long the full result behind a cursor is kept, so
+	// later pages are served without listing again.
+	listingTTL = 10 * time.Minute
+)
+
+// pageArgs are the pagination arguments of list tools. Handlers embed them
+// in their arguments, and the tools are declared withPagination.
+type pageArgs struct {
+	Cursor   string `json:"cursor"`
+	PageSize int    `json:"page_size"`
+}
+
+func (p pageArgs) size() int {
+	if p.PageSize <= 0 {
+		return defaultPageSize
+	}
+	return min(p.PageSize, maxPageSize)
+}
+
+// withPagination adds the cursor and page_size arguments to a list tool.
+func withPagination(tool *mcp.Tool) {
+	mcp.WithString("cursor", mcp.Description("next_cursor of the previous page, to get the next one. The page comes from the listing made for the first page, so other filter arguments are ignored"))(tool)
+	mcp.WithNumber("page_size", mcp.Description(fmt.Sprintf("Items per page (default %d, max %d)", defaultPageSize, maxPageSize)))(tool)
+}
+
+type cachedListing struct {
+	tool    string
+	items   any
+	expires time.Time
+}
+
+// listingCache holds full list results between the pages of a listing.
+type listingCache struct {
+	mu       sync.Mutex
+	listings map[string]cachedListing
+}
+
+var listings = &listingCache{listings: make(map[string]cachedListing)}
+
+func (c *listingCache) store(tool string, items any) (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for key, listing := range c.listings {
+		if now.After(listing.expires) {
+			delete(c.listings, key)
+		}
+	}
+	key := hex.EncodeToString(id)
+	c.listings[key] = cachedListing{tool: tool, items: items, expires: now.Add(listingTTL)}
+	return key, nil
+}
+
+func (c *listingCache) load(id, tool string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	listing, ok := c.listings[id]
+	if !ok || listing.tool != tool || time.Now().After(listing.expires) {
+		return nil, false
+	}
+	return listing.items, true
+}
+
+// Cursors are opaque to clients; they encode the listing and the offset of
+// the next page.
+func encodeCursor(id string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id + ":" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (string, int, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, false
+	}
+	id, rawOffset, ok := strings.Cut(string(raw), ":")
+	offset, err := strconv.Atoi(rawOffset)
+	if !ok || err != nil || offset < 0 {
+		return "", 0, false
+	}
+	return id, offset, true
+}
+
+// firstPage returns the first page of items as tool's result, keeping the
+// rest for the cursor it returns. noun names the items in messages.
+func firstPage[T any](tool string, items []T, args pageArgs, noun string) (*mcp.CallToolResult, error) {
+	var id string
+	if len(items) > args.size() {
+		var err error
+		if id, err = listings.store(tool, items); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to generate cursor: %v", err)), nil
+		}
+	}
+	return listPage(tool, items, 0, args.size(), id, noun)
+}
+
+// nextPage returns the page args.Cursor points to in a listing made by
+// firstPage.
+func nextPage[T any](tool string, args pageArgs, noun string) (*mcp.CallToolResult, error) {
+	id, offset, ok := decodeCursor(args.Cursor)
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid cursor: %s", args.Cursor)), nil
+	}
+	cached, ok := listings.load(id, tool)
+	items, isList := cached.([]T)
+	if !ok || !isList {
+		return mcp.NewToolResultError(fmt.Sprintf("Cursor has expired or is not from %s; list again without a cursor", tool)), nil
+	}
+	return listPage(tool, items, offset, args.size(), id, noun)
+}
+
+func listPage[T any](tool string, items []T, offset, size int, id, noun string) (*mcp.CallToolResult, error) {
+	start := min(offset, len(items))
+	end := min(start+size, len(items))
+	page := items[start:end]
+
+	pageJSON, err := json.MarshalIndent(page, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal %s: %v", noun, err)), nil
+	}
+
+	output := newListOutput(page)
+	output.Total = len(items)
+	if end < len(items) {
+		output.NextCursor = encodeCursor(id, end)
+	}
+	result := mcp.NewToolResultStructured(output, string(pageJSON))
+	if output.NextCursor != "" {
+		result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf("Showing %s %d-%d of %d. Call %s with cursor %q for the next page.", noun, start+1, end, len(items), tool, output.NextCursor)))
+	}
+	return result, nil
+}
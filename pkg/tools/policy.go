@@ -0,0 +1,318 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/policybackup"
+	"github.com/pnocera/tailscale-mcp-server/internal/policysync"
+	"github.com/tailscale/hujson"
+)
+
+type PolicyTools struct {
+	client *client.TailscaleClient
+	config *config.Config
+	// sync is nil unless a policy git repository is configured.
+	sync *policysync.Syncer
+
+	mu sync.Mutex
+	// backups is created on first use; see backupStore.
+	backups policybackup.Store
+	// staged holds proposed policies by change ID until they are applied.
+	staged map[string]*stagedPolicy
+}
+
+func NewPolicyTools(client *client.TailscaleClient, cfg *config.Config, sync *policysync.Syncer) *PolicyTools {
+	return &PolicyTools{client: client, config: cfg, sync: sync, staged: make(map[string]*stagedPolicy)}
+}
+
+func (pt *PolicyTools) RegisterTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_policy_get",
+		readOnlyTool,
+		mcp.WithDescription("Get the current policy file (ACL) for the tailnet. Returns the access control list in HuJSON format that defines who can access what resources, followed by the policy's ETag. Pass the ETag as if_match to tailscale_policy_set so an edit fails instead of overwriting changes made in the meantime. The policy file controls device access, user permissions, and network routing rules. Essential for understanding and managing security policies. Learn more about ACLs at /kb/1018/acls. OAuth Scope: acl:read."),
+	)
+	mcpServer.AddTool(tool, pt.GetPolicy)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_set",
+		destructiveTool,
+		mcp.WithDescription("Set the policy file (ACL) for the tailnet. Upload a new access control list in HuJSON format to define security policies. Controls device access, user permissions, SSH access, and network routing. Changes apply immediately to all devices. Validate policy first using tailscale_policy_validate. Provide if_match with the ETag from tailscale_policy_get to fail with a conflict if the policy has changed since it was read. Learn more about ACLs at /kb/1018/acls. OAuth Scope: acl:write."),
+		mcp.WithString("policy", mcp.Description("Policy file content in HuJSON format"), mcp.Required()),
+		mcp.WithString("if_match", mcp.Description("ETag of the policy this edit is based on, as returned by tailscale_policy_get")),
+	)
+	mcpServer.AddTool(tool, pt.SetPolicy)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_validate",
+		readOnlyTool,
+		mcp.WithDescription("Validate a policy file (ACL) without applying it to the tailnet. Checks the HuJSON syntax and policy rules for errors before deployment. Essential for safe policy management - always validate before setting a new policy. Prevents accidental misconfigurations that could disrupt network access. Learn more about ACLs at /kb/1018/acls. OAuth Scope: acl:read."),
+		mcp.WithString("policy", mcp.Description("Policy file content in HuJSON format to validate"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, pt.ValidatePolicy)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_sections_get",
+		readOnlyTool,
+		mcp.WithDescription("Get the current policy file (ACL) parsed into structured JSON, keyed by section (e.g., acls, grants, groups, tagOwners, hosts, ssh, tests, autoApprovers, nodeAttrs). Comments and trailing commas are stripped. Request only the sections you need to reason about specific parts of the policy without parsing HuJSON by hand. The policy's ETag follows the sections. Learn more about ACLs at /kb/1018/acls. OAuth Scope: acl:read."),
+		mcp.WithArray("sections", mcp.Description("Sections to return (default: all sections present in the policy)"), mcp.WithStringItems()),
+	)
+	mcpServer.AddTool(tool, pt.GetPolicySections)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_test",
+		readOnlyTool,
+		mcp.WithDescription("Run the ACL tests in a policy file and report structured results. Validates the policy (the current policy when none is given) through the API, which evaluates every entry in its 'tests' and 'sshTests' sections, and returns pass/fail with the failure messages for each test, plus any errors not tied to a test. Use this for CI-style checks before applying a policy. Learn more about ACL tests at /kb/1337/acl-syntax#tests. OAuth Scope: acl:read."),
+		mcp.WithString("policy", mcp.Description("Policy file content in HuJSON format to test (default: the current policy)")),
+	)
+	mcpServer.AddTool(tool, pt.TestPolicy)
+
+	pt.registerEditTools(mcpServer)
+	pt.registerSSHTools(mcpServer)
+	pt.registerBackupTools(mcpServer)
+	pt.registerSyncTools(mcpServer)
+	pt.registerLintTools(mcpServer)
+	pt.registerAccessTools(mcpServer)
+	pt.registerStageTools(mcpServer)
+	pt.registerTestGenTools(mcpServer)
+	pt.registerSuggestTools(mcpServer)
+}
+
+func (pt *PolicyTools) GetPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client := pt.client.GetClient()
+	policy, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+
+	result := mcp.NewToolResultText(policy.HuJSON)
+	result.Content = append(result.Content, mcp.NewTextContent("ETag: "+normalizeETag(policy.ETag)))
+	return result, nil
+}
+
+func (pt *PolicyTools) SetPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Policy  string `json:"policy"`
+		IfMatch string `json:"if_match"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	client := pt.client.GetClient()
+	if err := client.PolicyFile().Set(ctx, args.Policy, normalizeETag(args.IfMatch)); err != nil {
+		if isPreconditionFailed(err) {
+			return mcp.NewToolResultError("Policy not updated: it has changed since ETag " + args.IfMatch + " was read. Fetch it again with tailscale_policy_get, reapply your edit, and retry with the new ETag."), nil
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set policy: %v", err)), nil
+	}
+
+	result := mcp.NewToolResultText("Policy file updated successfully")
+	if updated, err := client.PolicyFile().Raw(ctx); err == nil {
+		result.Content = append(result.Content, mcp.NewTextContent("ETag: "+normalizeETag(updated.ETag)))
+	}
+	return result, nil
+}
+
+// normalizeETag is client.NormalizeETag, under a name that the handlers'
+// client variables do not shadow.
+func normalizeETag(etag string) string {
+	return client.NormalizeETag(etag)
+}
+
+// isPreconditionFailed reports whether err is the API's response to a stale
+// If-Match header.
+func isPreconditionFailed(err error) bool {
+	return apiStatus(err) == http.StatusPreconditionFailed
+}
+
+func (pt *PolicyTools) ValidatePolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Policy string `json:"policy"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	client := pt.client.GetClient()
+	if err := client.PolicyFile().Validate(ctx, args.Policy); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Policy validation failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText("Policy validation passed"), nil
+}
+
+// parsePolicySections parses a HuJSON policy into its top-level sections,
+// keeping sections the SDK's ACL type does not model, such as grants.
+func parsePolicySections(policy string) (map[string]json.RawMessage, error) {
+	standard, err := hujson.Standardize([]byte(policy))
+	if err != nil {
+		return nil, err
+	}
+
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(standard, &sections); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+func (pt *PolicyTools) GetPolicySections(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Sections []string `json:"sections"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	client := pt.client.GetClient()
+	policy, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+
+	sections, err := parsePolicySections(policy.HuJSON)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+
+	if len(args.Sections) > 0 {
+		for name := range sections {
+			if !slices.Contains(args.Sections, name) {
+				delete(sections, name)
+			}
+		}
+	}
+
+	sectionsJSON, err := json.MarshalIndent(sections, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal policy sections: %v", err)), nil
+	}
+
+	result := mcp.NewToolResultText(string(sectionsJSON))
+	result.Content = append(result.Content, mcp.NewTextContent("ETag: "+normalizeETag(policy.ETag)))
+	return result, nil
+}
+
+// policyValidation is the body the validate endpoint returns. An empty
+// message means the policy, including its tests, is valid.
+type policyValidation struct {
+	Message string `json:"message"`
+	Data    []struct {
+		User   string   `json:"user"`
+		Errors []string `json:"errors"`
+	} `json:"data"`
+}
+
+func (pt *PolicyTools) TestPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Policy string `json:"policy"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	if args.Policy == "" {
+		policy, err := pt.client.GetClient().PolicyFile().Raw(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+		}
+		args.Policy = policy.HuJSON
+	}
+
+	standard, err := hujson.Standardize([]byte(args.Policy))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+	var sections struct {
+		Tests    []map[string]any `json:"tests"`
+		SSHTests []map[string]any `json:"sshTests"`
+	}
+	if err := json.Unmarshal(standard, &sections); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+
+	var validation policyValidation
+	if err := pt.client.Do(ctx, http.MethodPost, pt.client.TailnetPath("acl", "validate"), json.RawMessage(standard), &validation); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to validate policy: %v", err)), nil
+	}
+
+	// The API reports test failures grouped by the test's source.
+	failures := make(map[string][]string)
+	for _, d := range validation.Data {
+		failures[d.User] = append(failures[d.User], d.Errors...)
+	}
+
+	type testResult struct {
+		Section string         `json:"section"`
+		Index   int            `json:"index"`
+		Src     string         `json:"src"`
+		Passed  bool           `json:"passed"`
+		Errors  []string       `json:"errors,omitempty"`
+		Test    map[string]any `json:"test"`
+	}
+	result := struct {
+		Passed      bool         `json:"passed"`
+		Message     string       `json:"message,omitempty"`
+		Tests       []testResult `json:"tests"`
+		OtherErrors []string     `json:"other_errors,omitempty"`
+	}{
+		Passed:  validation.Message == "",
+		Message: validation.Message,
+		Tests:   []testResult{},
+	}
+
+	matched := make(map[string]bool)
+	addResults := func(section string, tests []map[string]any) {
+		for i, test := range tests {
+			src, _ := test["src"].(string)
+			errs := failures[src]
+			matched[src] = true
+			result.Tests = append(result.Tests, testResult{
+				Section: section,
+				Index:   i,
+				Src:     src,
+				Passed:  len(errs) == 0,
+				Errors:  errs,
+				Test:    test,
+			})
+		}
+	}
+	addResults("tests", sections.Tests)
+	addResults("sshTests", sections.SSHTests)
+
+	for _, user := range slices.Sorted(maps.Keys(failures)) {
+		if !matched[user] {
+			for _, e := range failures[user] {
+				if user != "" {
+					e = user + ": " + e
+				}
+				result.OtherErrors = append(result.OtherErrors, e)
+			}
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal test results: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,475 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/tailscale/hujson"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// policyGrant is an entry of the policy's grants section, which the SDK's
+// ACL type does not model.
+type policyGrant struct {
+	Src []string `json:"src"`
+	Dst []string `json:"dst"`
+	IP  []string `json:"ip"`
+}
+
+// accessRule is an ACL rule or grant normalized for evaluation.
+type accessRule struct {
+	Section string      `json:"section"`
+	Index   int         `json:"index"`
+	Src     []string    `json:"src"`
+	Dst     []accessDst `json:"dst"`
+}
+
+// accessDst is one destination of an accessRule. An empty Proto matches any
+// protocol.
+type accessDst struct {
+	Host  string `json:"host"`
+	Ports string `json:"ports"`
+	Proto string `json:"proto,omitempty"`
+}
+
+// parsePolicy decodes a HuJSON policy into the SDK's ACL type plus grants.
+func parsePolicy(policy string) (*tailscale.ACL, []policyGrant, error) {
+	standard, err := hujson.Standardize([]byte(policy))
+	if err != nil {
+		return nil, nil, err
+	}
+	var acl tailscale.ACL
+	if err := json.Unmarshal(standard, &acl); err != nil {
+		return nil, nil, err
+	}
+	var extra struct {
+		Grants []policyGrant `json:"grants"`
+	}
+	if err := json.Unmarshal(standard, &extra); err != nil {
+		return nil, nil, err
+	}
+	return &acl, extra.Grants, nil
+}
+
+// accessRules flattens the acls and grants sections into accessRules.
+func accessRules(acl *tailscale.ACL, grants []policyGrant) []accessRule {
+	var rules []accessRule
+	for i, entry := range acl.ACLs {
+		if entry.Action != "accept" {
+			continue
+		}
+		rule := accessRule{Section: "acls", Index: i, Src: slices.Concat(entry.Source, entry.Users)}
+		for _, dst := range entry.Destination {
+			host, ports := destinationHost(dst), "*"
+			if i := strings.LastIndex(dst, ":"); i >= 0 {
+				ports = dst[i+1:]
+			}
+			rule.Dst = append(rule.Dst, accessDst{Host: host, Ports: ports, Proto: entry.Protocol})
+		}
+		rules = append(rules, rule)
+	}
+	for i, grant := range grants {
+		rule := accessRule{Section: "grants", Index: i, Src: grant.Src}
+		for _, host := range grant.Dst {
+			for _, ip := range grant.IP {
+				proto, ports, ok := strings.Cut(ip, ":")
+				if !ok {
+					proto, ports = "", ip
+				}
+				rule.Dst = append(rule.Dst, accessDst{Host: host, Ports: ports, Proto: proto})
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// matchDestination reports whether any of the rule's destinations selects
+// target, and whether one that does also allows port and proto.
+func (r accessRule) matchDestination(acl *tailscale.ACL, target accessTarget, srcUser *tailscale.User, port int, proto string) (hostOK, portOK bool) {
+	for _, dst := range r.Dst {
+		if !target.matchesHost(acl, dst.Host, srcUser) {
+			continue
+		}
+		hostOK = true
+		if portsMatch(dst.Ports, port) && protoMatches(dst.Proto, proto) {
+			return true, true
+		}
+	}
+	return hostOK, false
+}
+
+// accessTarget is the destination an access question is asked about: a
+// device, a bare address, or both.
+type accessTarget struct {
+	device *tailscale.Device
+	owner  *tailscale.User
+	addrs  []netip.Addr
+}
+
+// matchesHost reports whether a rule destination host selects the target for
+// traffic from srcUser, which is nil for tag sources.
+func (t accessTarget) matchesHost(acl *tailscale.ACL, host string, srcUser *tailscale.User) bool {
+	if host == "autogroup:self" {
+		return t.device != nil && len(t.device.Tags) == 0 && t.owner != nil && srcUser != nil &&
+			strings.EqualFold(t.owner.LoginName, srcUser.LoginName)
+	}
+	if alias, ok := acl.Hosts[host]; ok {
+		host = alias
+	}
+	if prefix, err := netip.ParsePrefix(host); err == nil {
+		for _, addr := range t.addrs {
+			if prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		for _, a := range t.addrs {
+			if a == addr {
+				return true
+			}
+		}
+		return false
+	}
+	if host == "*" {
+		return true
+	}
+	return t.device != nil && deviceMatchesTarget(acl, *t.device, t.owner, host)
+}
+
+// portsMatch reports whether a port list such as "*", "22", "80,443", or
+// "8000-8100" includes port.
+func portsMatch(spec string, port int) bool {
+	for _, part := range strings.Split(spec, ",") {
+		if part == "*" {
+			return true
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		if !isRange {
+			hi = lo
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 == nil && err2 == nil && port >= from && port <= to {
+			return true
+		}
+	}
+	return false
+}
+
+// protoMatches reports whether a rule protocol, by name or IANA number,
+// allows proto. An empty rule protocol allows any.
+func protoMatches(ruleProto, proto string) bool {
+	numbers := map[string]string{"1": "icmp", "6": "tcp", "17": "udp", "58": "ipv6-icmp", "132": "sctp"}
+	if name, ok := numbers[ruleProto]; ok {
+		ruleProto = name
+	}
+	return ruleProto == "" || ruleProto == "*" || strings.EqualFold(ruleProto, proto)
+}
+
+// sourceMatches reports whether any rule source names one of the principals.
+func sourceMatches(src, principals []string) bool {
+	for _, s := range src {
+		for _, p := range principals {
+			if s == "*" || strings.EqualFold(s, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// deviceOwner returns the user owning an untagged device, or nil.
+func deviceOwner(device tailscale.Device, users []tailscale.User) *tailscale.User {
+	if len(device.Tags) > 0 {
+		return nil
+	}
+	for i := range users {
+		if strings.EqualFold(users[i].LoginName, device.User) {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
+func deviceAddrs(device tailscale.Device) []netip.Addr {
+	var addrs []netip.Addr
+	for _, a := range device.Addresses {
+		if addr, err := netip.ParseAddr(a); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+func (pt *PolicyTools) registerAccessTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_access_check",
+		readOnlyTool,
+		mcp.WithDescription("Answer 'can X reach Y on port Z?' by evaluating the live policy's acls and grants against the device and user lists. The source is a user login name, tag, or group; the destination is a device (ID, name, or hostname) or a tailnet IP address. Returns whether access is allowed, the rules that allow it, and otherwise the rules that come closest and why they do not apply. Device posture conditions and IP-based sources are not evaluated. OAuth Scope: acl:read, devices:read, users:read."),
+		mcp.WithString("source", mcp.Description("User login name, tag, or group the connection comes from (e.g., 'alice@example.com', 'tag:ci')"), mcp.Required()),
+		mcp.WithString("destination", mcp.Description("Device ID, name, or hostname, or a tailnet IP address"), mcp.Required()),
+		mcp.WithNumber("port", mcp.Description("Destination port"), mcp.Required()),
+		mcp.WithString("proto", mcp.Description("IP protocol (default: tcp)")),
+	)
+	mcpServer.AddTool(tool, pt.CheckAccess)
+
+	tool = mcp.NewTool(
+		"tailscale_device_access_report",
+		readOnlyTool,
+		mcp.WithDescription("Report who can reach a device according to the current policy: every acls rule and grant whose destination covers the device, and for each source (user, group, tag, or autogroup) the ports and protocols it can reach, with groups and autogroups expanded to their users. Intended for security reviews of sensitive hosts. Device posture conditions are not evaluated. OAuth Scope: acl:read, devices:read, users:read."),
+		mcp.WithString("device", mcp.Description("Device ID, name, or hostname"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, pt.DeviceAccessReport)
+}
+
+func (pt *PolicyTools) CheckAccess(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Port        int    `json:"port"`
+		Proto       string `json:"proto"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if args.Proto == "" {
+		args.Proto = "tcp"
+	}
+	if args.Port < 0 || args.Port > 65535 {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid port: %d", args.Port)), nil
+	}
+
+	client := pt.client.GetClient()
+	raw, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+	acl, grants, err := parsePolicy(raw.HuJSON)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+	users, err := client.Users().List(ctx, tailscale.PointerTo(tailscale.UserType("all")), nil)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list users: %v", err)), nil
+	}
+
+	principals := []string{args.Source}
+	var srcUser *tailscale.User
+	for i := range users {
+		if strings.EqualFold(users[i].LoginName, args.Source) {
+			srcUser = &users[i]
+			principals = userPrincipals(acl, users[i])
+			break
+		}
+	}
+	if srcUser == nil && strings.Contains(args.Source, "@") {
+		return mcp.NewToolResultError(fmt.Sprintf("Unknown user: %s", args.Source)), nil
+	}
+
+	var target accessTarget
+	if addr, err := netip.ParseAddr(args.Destination); err == nil {
+		target.addrs = []netip.Addr{addr}
+		for i := range devices {
+			if slices.Contains(deviceAddrs(devices[i]), addr) {
+				target.device = &devices[i]
+				break
+			}
+		}
+	} else {
+		device, err := resolveDevice(devices, args.Destination)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to find device: %v", err)), nil
+		}
+		target.device = device
+		target.addrs = deviceAddrs(*device)
+	}
+	if target.device != nil {
+		target.owner = deviceOwner(*target.device, users)
+	}
+
+	type ruleMatch struct {
+		accessRule
+		Reason string `json:"reason,omitempty"`
+	}
+	result := struct {
+		Allowed          bool        `json:"allowed"`
+		Source           string      `json:"source"`
+		SourcePrincipals []string    `json:"source_principals"`
+		Device           string      `json:"device,omitempty"`
+		Addresses        []string    `json:"addresses"`
+		Port             int         `json:"port"`
+		Proto            string      `json:"proto"`
+		MatchingRules    []ruleMatch `json:"matching_rules"`
+		NearMisses       []ruleMatch `json:"near_misses,omitempty"`
+		Reason           string      `json:"reason"`
+	}{
+		Source:           args.Source,
+		SourcePrincipals: principals,
+		Port:             args.Port,
+		Proto:            args.Proto,
+		MatchingRules:    []ruleMatch{},
+	}
+	for _, addr := range target.addrs {
+		result.Addresses = append(result.Addresses, addr.String())
+	}
+	if target.device != nil {
+		result.Device = target.device.Name
+	}
+
+	for _, rule := range accessRules(acl, grants) {
+		srcOK := sourceMatches(rule.Src, principals)
+		hostOK, portOK := rule.matchDestination(acl, target, srcUser, args.Port, args.Proto)
+
+		switch {
+		case srcOK && hostOK && portOK:
+			result.MatchingRules = append(result.MatchingRules, ruleMatch{accessRule: rule})
+		case srcOK && hostOK:
+			result.NearMisses = append(result.NearMisses, ruleMatch{rule, fmt.Sprintf("allows the destination, but not %s port %d", args.Proto, args.Port)})
+		case hostOK && portOK:
+			result.NearMisses = append(result.NearMisses, ruleMatch{rule, fmt.Sprintf("allows %s port %d on the destination, but not from %s", args.Proto, args.Port, args.Source)})
+		}
+	}
+
+	result.Allowed = len(result.MatchingRules) > 0
+	switch {
+	case result.Allowed:
+		result.Reason = fmt.Sprintf("%d rule(s) allow %s to reach the destination on %s port %d", len(result.MatchingRules), args.Source, args.Proto, args.Port)
+	case len(result.NearMisses) > 0:
+		result.Reason = "no rule allows this connection; the near misses show rules that cover the source or port but not both"
+	default:
+		result.Reason = "no rule allows this connection, and no rule covers the destination on this port"
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal access check: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+func (pt *PolicyTools) DeviceAccessReport(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Device string `json:"device"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	client := pt.client.GetClient()
+	raw, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+	acl, grants, err := parsePolicy(raw.HuJSON)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+	device, err := resolveDevice(devices, args.Device)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to find device: %v", err)), nil
+	}
+	users, err := client.Users().List(ctx, tailscale.PointerTo(tailscale.UserType("all")), nil)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list users: %v", err)), nil
+	}
+
+	target := accessTarget{device: device, owner: deviceOwner(*device, users), addrs: deviceAddrs(*device)}
+
+	type ruleAccess struct {
+		Section string      `json:"section"`
+		Index   int         `json:"index"`
+		Src     []string    `json:"src"`
+		Ports   []accessDst `json:"ports"`
+	}
+	type sourceAccess struct {
+		Source string   `json:"source"`
+		Users  []string `json:"users,omitempty"`
+		Ports  []string `json:"ports"`
+	}
+
+	rules := []ruleAccess{}
+	portsBySource := make(map[string][]string)
+	for _, rule := range accessRules(acl, grants) {
+		matched := ruleAccess{Section: rule.Section, Index: rule.Index, Src: rule.Src}
+		for _, dst := range rule.Dst {
+			sources := rule.Src
+			if dst.Host == "autogroup:self" {
+				// Only the owner reaches their own device through autogroup:self.
+				if target.owner == nil || len(device.Tags) > 0 || !sourceMatches(rule.Src, userPrincipals(acl, *target.owner)) {
+					continue
+				}
+				sources = []string{target.owner.LoginName}
+			} else if !target.matchesHost(acl, dst.Host, nil) {
+				continue
+			}
+
+			matched.Ports = append(matched.Ports, dst)
+			proto := dst.Proto
+			if proto == "" {
+				proto = "*"
+			}
+			for _, src := range sources {
+				portsBySource[src] = append(portsBySource[src], proto+":"+dst.Ports)
+			}
+		}
+		if len(matched.Ports) > 0 {
+			rules = append(rules, matched)
+		}
+	}
+
+	exposure := []sourceAccess{}
+	for _, src := range slices.Sorted(maps.Keys(portsBySource)) {
+		access := sourceAccess{Source: src, Ports: slices.Compact(slices.Sorted(slices.Values(portsBySource[src])))}
+		if src == "*" || strings.HasPrefix(src, "group:") || strings.HasPrefix(src, "autogroup:") {
+			access.Users = principalUsers(acl, users, src)
+		}
+		exposure = append(exposure, access)
+	}
+
+	result := struct {
+		Device    string         `json:"device"`
+		Addresses []string       `json:"addresses"`
+		Tags      []string       `json:"tags,omitempty"`
+		Owner     string         `json:"owner,omitempty"`
+		Rules     []ruleAccess   `json:"rules"`
+		Exposure  []sourceAccess `json:"exposure"`
+	}{
+		Device:    device.Name,
+		Addresses: device.Addresses,
+		Tags:      device.Tags,
+		Rules:     rules,
+		Exposure:  exposure,
+	}
+	if target.owner != nil {
+		result.Owner = target.owner.LoginName
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal access report: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
@@ -0,0 +1,234 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/policybackup"
+	"github.com/pnocera/tailscale-mcp-server/internal/textdiff"
+)
+
+func (pt *PolicyTools) registerBackupTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_policy_backup",
+		createTool,
+		mcp.WithDescription("Store a timestamped copy of the current policy file in the backup location configured with TAILSCALE_MCP_POLICY_BACKUP_DIR (a local directory or an s3://bucket/prefix URL), or else in a tailscale-policy-backups directory under TAILSCALE_MCP_EXPORT_DIR or the client's workspace root. Backups give a change history that is independent of the admin console. If the policy is identical to the most recent backup no new copy is written unless force is set. OAuth Scope: acl:read."),
+		mcp.WithBoolean("force", mcp.Description("Write a backup even if the policy has not changed since the last one (default: false)")),
+	)
+	mcpServer.AddTool(tool, pt.BackupPolicy)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_backups_list",
+		readOnlyTool,
+		mcp.WithDescription("List the policy backups stored in the configured backup location, newest first, with each backup's name, time, and size."),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of backups to return (default: all)")),
+	)
+	mcpServer.AddTool(tool, pt.ListPolicyBackups)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_rollback",
+		destructiveTool,
+		mcp.WithDescription("Restore the policy file from a backup listed by tailscale_policy_backups_list. Without confirm, returns a diff from the current policy to the backup and the current ETag, and changes nothing. With confirm set, the current policy is first backed up so the rollback itself can be undone, then the backup is applied. Pass the ETag from the preview as if_match to make sure the policy being replaced is the one that was reviewed. OAuth Scope: acl:write."),
+		mcp.WithString("backup", mcp.Description("Name of the backup to restore (e.g., 'policy-20250101T120000.000Z.hujson')"), mcp.Required()),
+		mcp.WithBoolean("confirm", mcp.Description("Apply the rollback instead of previewing it (default: false)")),
+		mcp.WithString("if_match", mcp.Description("Only roll back if the current policy still has this ETag")),
+	)
+	mcpServer.AddTool(tool, pt.RollbackPolicy)
+}
+
+// backupStore returns the configured backup store, creating it on first use.
+// Without one, backups go to the policybackup.DefaultDir directory of the export
+// root, such as the client's workspace, which is looked up on every call
+// since it can differ between clients.
+func (pt *PolicyTools) backupStore(ctx context.Context) (policybackup.Store, error) {
+	if pt.config.PolicyBackupLocation == "" {
+		root, err := exportRoot(ctx, pt.config)
+		if errors.Is(err, errNoExportRoot) {
+			return nil, errors.New("TAILSCALE_MCP_POLICY_BACKUP_DIR is not configured, and there is no export directory or client workspace root to back up to")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return policybackup.New(ctx, filepath.Join(root, policybackup.DefaultDir))
+	}
+
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if pt.backups != nil {
+		return pt.backups, nil
+	}
+	store, err := policybackup.New(ctx, pt.config.PolicyBackupLocation)
+	if err != nil {
+		return nil, err
+	}
+	pt.backups = store
+	return store, nil
+}
+
+func (pt *PolicyTools) BackupPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Force bool `json:"force"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	store, err := pt.backupStore(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to open backup store: %v", err)), nil
+	}
+
+	client := pt.client.GetClient()
+	policy, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+	content := []byte(policy.HuJSON)
+
+	if !args.Force {
+		backups, err := store.List(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to list backups: %v", err)), nil
+		}
+		if len(backups) > 0 {
+			latest, err := store.Load(ctx, backups[0].Name)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Failed to read backup %s: %v", backups[0].Name, err)), nil
+			}
+			if bytes.Equal(latest, content) {
+				return mcp.NewToolResultText(fmt.Sprintf("Policy unchanged since backup %s; no new backup written", backups[0].Name)), nil
+			}
+		}
+	}
+
+	backup, err := store.Save(ctx, content, time.Now())
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to write backup: %v", err)), nil
+	}
+
+	resultJSON, err := json.MarshalIndent(struct {
+		policybackup.Backup
+		Location string `json:"location"`
+		ETag     string `json:"etag"`
+	}{backup, store.Location(), normalizeETag(policy.ETag)}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal backup: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+func (pt *PolicyTools) ListPolicyBackups(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Limit int `json:"limit"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	store, err := pt.backupStore(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to open backup store: %v", err)), nil
+	}
+
+	backups, err := store.List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list backups: %v", err)), nil
+	}
+	total := len(backups)
+	if args.Limit > 0 && len(backups) > args.Limit {
+		backups = backups[:args.Limit]
+	}
+	if backups == nil {
+		backups = []policybackup.Backup{}
+	}
+
+	resultJSON, err := json.MarshalIndent(map[string]any{
+		"location": store.Location(),
+		"total":    total,
+		"backups":  backups,
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal backups: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+func (pt *PolicyTools) RollbackPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Backup  string `json:"backup"`
+		Confirm bool   `json:"confirm"`
+		IfMatch string `json:"if_match"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	store, err := pt.backupStore(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to open backup store: %v", err)), nil
+	}
+	restored, err := store.Load(ctx, args.Backup)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read backup %s: %v", args.Backup, err)), nil
+	}
+
+	client := pt.client.GetClient()
+	policy, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+
+	etag := normalizeETag(policy.ETag)
+	if args.IfMatch != "" && normalizeETag(args.IfMatch) != etag {
+		return mcp.NewToolResultError(fmt.Sprintf("Policy not rolled back: it has changed since ETag %s was read (current ETag %s). Preview the rollback again.", args.IfMatch, etag)), nil
+	}
+
+	diff := textdiff.Unified("current", args.Backup, policy.HuJSON, string(restored))
+	if diff == "" {
+		return mcp.NewToolResultText(fmt.Sprintf("Policy already matches backup %s; nothing to roll back", args.Backup)), nil
+	}
+
+	if !args.Confirm {
+		result := mcp.NewToolResultText(diff)
+		result.Content = append(result.Content,
+			mcp.NewTextContent("ETag: "+etag),
+			mcp.NewTextContent("Preview only, policy not changed. Call again with confirm set and if_match set to this ETag to roll back."),
+		)
+		return result, nil
+	}
+
+	saved, err := store.Save(ctx, []byte(policy.HuJSON), time.Now())
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Policy not rolled back: failed to back up the current policy: %v", err)), nil
+	}
+
+	if err := client.PolicyFile().Set(ctx, string(restored), etag); err != nil {
+		if isPreconditionFailed(err) {
+			return mcp.NewToolResultError("Policy not rolled back: it was changed by someone else in the meantime. Preview the rollback again."), nil
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set policy: %v", err)), nil
+	}
+
+	result := mcp.NewToolResultText(fmt.Sprintf("Policy rolled back to %s. The replaced policy was backed up as %s.", args.Backup, saved.Name))
+	if current, err := client.PolicyFile().Raw(ctx); err == nil {
+		result.Content = append(result.Content, mcp.NewTextContent("ETag: "+normalizeETag(current.ETag)))
+	}
+	return result, nil
+}
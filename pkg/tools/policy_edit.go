@@ -0,0 +1,612 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/tailscale/hujson"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// patchOp is a single RFC 6902 JSON Patch operation. hujson applies these to
+// the policy in place, so comments and formatting outside the edited values
+// survive the edit.
+type patchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func addOp(path string, value any) patchOp {
+	raw, _ := json.Marshal(value)
+	return patchOp{Op: "add", Path: path, Value: raw}
+}
+
+func removeOp(path string) patchOp {
+	return patchOp{Op: "remove", Path: path}
+}
+
+// jsonPointer builds an RFC 6901 pointer from unescaped path segments.
+func jsonPointer(elem ...string) string {
+	var b strings.Builder
+	for _, e := range elem {
+		b.WriteString("/")
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(e))
+	}
+	return b.String()
+}
+
+// sectionKey returns the key under which a top-level policy section is
+// stored. Section names are matched case-insensitively, as the policy parser
+// does, and default to name when the section is absent.
+func sectionKey(sections map[string]json.RawMessage, name string) string {
+	for key := range sections {
+		if strings.EqualFold(key, name) {
+			return key
+		}
+	}
+	return name
+}
+
+// decodeSection unmarshals a policy section into v, leaving v unchanged when
+// the section is absent.
+func decodeSection(sections map[string]json.RawMessage, name string, v any) error {
+	raw, ok := sections[sectionKey(sections, name)]
+	if !ok {
+		return nil
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid %s section: %w", name, err)
+	}
+	return nil
+}
+
+// applyPolicyPatch applies ops to a HuJSON policy and reformats it.
+func applyPolicyPatch(policy string, ops []patchOp) (string, error) {
+	value, err := hujson.Parse([]byte(policy))
+	if err != nil {
+		return "", err
+	}
+
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return "", err
+	}
+	if err := value.Patch(patch); err != nil {
+		return "", err
+	}
+
+	value.Format()
+	return value.String(), nil
+}
+
+// policyEditFunc computes the patch for an edit from the parsed policy
+// sections. A nil patch means the policy already has the requested state.
+type policyEditFunc func(sections map[string]json.RawMessage) (ops []patchOp, summary string, err error)
+
+// editPolicy reads the policy, applies edit, and writes the result back with
+// If-Match so that concurrent changes are never overwritten. ifMatch, when
+// set, additionally requires the policy to still be the version the caller
+// last read.
+func (pt *PolicyTools) editPolicy(ctx context.Context, ifMatch string, dryRun bool, edit policyEditFunc) (*mcp.CallToolResult, error) {
+	client := pt.client.GetClient()
+	policy, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+
+	etag := normalizeETag(policy.ETag)
+	if ifMatch != "" && normalizeETag(ifMatch) != etag {
+		return mcp.NewToolResultError(fmt.Sprintf("Policy not updated: it has changed since ETag %s was read (current ETag %s). Re-read the policy and retry.", ifMatch, etag)), nil
+	}
+
+	sections, err := parsePolicySections(policy.HuJSON)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+
+	ops, summary, err := edit(sections)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid edit: %v", err)), nil
+	}
+	if len(ops) == 0 {
+		return mcp.NewToolResultText("Policy unchanged: " + summary), nil
+	}
+
+	updated, err := applyPolicyPatch(policy.HuJSON, ops)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to edit policy: %v", err)), nil
+	}
+
+	if dryRun {
+		result := mcp.NewToolResultText(updated)
+		result.Content = append(result.Content, mcp.NewTextContent("Dry run, policy not changed: "+summary))
+		return result, nil
+	}
+
+	if err := client.PolicyFile().Set(ctx, updated, etag); err != nil {
+		if isPreconditionFailed(err) {
+			return mcp.NewToolResultError("Policy not updated: it was changed by someone else during the edit. Retry."), nil
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set policy: %v", err)), nil
+	}
+
+	result := mcp.NewToolResultText("Policy updated: " + summary)
+	if current, err := client.PolicyFile().Raw(ctx); err == nil {
+		result.Content = append(result.Content, mcp.NewTextContent("ETag: "+normalizeETag(current.ETag)))
+	}
+	return result, nil
+}
+
+// aclRule is an entry of the policy's acls section.
+type aclRule struct {
+	Action string   `json:"action"`
+	Src    []string `json:"src"`
+	Dst    []string `json:"dst"`
+	Proto  string   `json:"proto,omitempty"`
+}
+
+func (pt *PolicyTools) registerEditTools(mcpServer *server.MCPServer) {
+	ifMatch := mcp.WithString("if_match", mcp.Description("Only apply the edit if the policy still has this ETag"))
+	dryRun := mcp.WithBoolean("dry_run", mcp.Description("Return the edited policy without applying it"))
+
+	tool := mcp.NewTool(
+		"tailscale_policy_acl_rule_add",
+		createTool,
+		mcp.WithDescription("Add a single ACL rule to the policy file from structured arguments. The server edits the HuJSON in place, preserving comments and formatting, and writes it back only if nobody changed the policy in the meantime. Rules are evaluated in any order, but position is kept for readability. Learn more about ACLs at /kb/1018/acls. OAuth Scope: acl:write."),
+		mcp.WithArray("src", mcp.Description("Sources: users, groups, tags, autogroups, hosts, or IPs (e.g., ['group:eng'])"), mcp.WithStringItems(), mcp.Required()),
+		mcp.WithArray("dst", mcp.Description("Destinations as host:ports (e.g., ['tag:db:5432'])"), mcp.WithStringItems(), mcp.Required()),
+		mcp.WithString("proto", mcp.Description("IP protocol to restrict the rule to (e.g., 'tcp', 'udp', 'icmp')")),
+		mcp.WithNumber("index", mcp.Description("Position to insert the rule at (default: end of the acls section)")),
+		ifMatch,
+		dryRun,
+	)
+	mcpServer.AddTool(tool, pt.AddACLRule)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_acl_rule_update",
+		destructiveTool,
+		mcp.WithDescription("Modify fields of a single ACL rule, identified by its zero-based index in the acls section (see tailscale_policy_sections_get). Only the fields provided are replaced; comments elsewhere are preserved. Use if_match with the ETag the index was read from so a concurrent edit cannot shift the rule. OAuth Scope: acl:write."),
+		mcp.WithNumber("index", mcp.Description("Zero-based index of the rule in the acls section"), mcp.Required()),
+		mcp.WithArray("src", mcp.Description("New sources"), mcp.WithStringItems()),
+		mcp.WithArray("dst", mcp.Description("New destinations"), mcp.WithStringItems()),
+		mcp.WithString("proto", mcp.Description("New IP protocol")),
+		ifMatch,
+		dryRun,
+	)
+	mcpServer.AddTool(tool, pt.UpdateACLRule)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_acl_rule_remove",
+		destructiveTool,
+		mcp.WithDescription("Remove a single ACL rule, identified by its zero-based index in the acls section (see tailscale_policy_sections_get). Use if_match with the ETag the index was read from so a concurrent edit cannot shift the rule. OAuth Scope: acl:write."),
+		mcp.WithNumber("index", mcp.Description("Zero-based index of the rule in the acls section"), mcp.Required()),
+		ifMatch,
+		dryRun,
+	)
+	mcpServer.AddTool(tool, pt.RemoveACLRule)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_group_member_add",
+		updateTool,
+		mcp.WithDescription("Add a member to a policy group, creating the group if it does not exist. Comments and formatting in the policy are preserved. Adding an existing member does nothing. OAuth Scope: acl:write."),
+		mcp.WithString("group", mcp.Description("Group name (e.g., 'group:eng')"), mcp.Required()),
+		mcp.WithString("member", mcp.Description("User login name or nested group to add"), mcp.Required()),
+		ifMatch,
+		dryRun,
+	)
+	mcpServer.AddTool(tool, pt.AddGroupMember)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_group_member_remove",
+		destructiveTool,
+		mcp.WithDescription("Remove a member from a policy group. Comments and formatting in the policy are preserved. Removing a member that is not in the group does nothing. OAuth Scope: acl:write."),
+		mcp.WithString("group", mcp.Description("Group name (e.g., 'group:eng')"), mcp.Required()),
+		mcp.WithString("member", mcp.Description("User login name or nested group to remove"), mcp.Required()),
+		ifMatch,
+		dryRun,
+	)
+	mcpServer.AddTool(tool, pt.RemoveGroupMember)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_tag_owner_add",
+		updateTool,
+		mcp.WithDescription("Add an owner to a tag in the tagOwners section, defining the tag if it does not exist. Owners may apply the tag to devices and auth keys. Comments and formatting in the policy are preserved. OAuth Scope: acl:write."),
+		mcp.WithString("tag", mcp.Description("Tag name (e.g., 'tag:server')"), mcp.Required()),
+		mcp.WithString("owner", mcp.Description("User, group, autogroup, or tag that owns the tag"), mcp.Required()),
+		ifMatch,
+		dryRun,
+	)
+	mcpServer.AddTool(tool, pt.AddTagOwner)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_tag_owner_remove",
+		destructiveTool,
+		mcp.WithDescription("Remove an owner from a tag in the tagOwners section. The tag stays defined even if it has no owners left. Comments and formatting in the policy are preserved. OAuth Scope: acl:write."),
+		mcp.WithString("tag", mcp.Description("Tag name (e.g., 'tag:server')"), mcp.Required()),
+		mcp.WithString("owner", mcp.Description("Owner to remove"), mcp.Required()),
+		ifMatch,
+		dryRun,
+	)
+	mcpServer.AddTool(tool, pt.RemoveTagOwner)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_auto_approvers_get",
+		readOnlyTool,
+		mcp.WithDescription("Get the policy file's autoApprovers section: for each subnet route, the users, groups, or tags whose advertised routes are approved automatically, and who may advertise exit nodes without manual approval. Learn more at /kb/1337/acl-syntax#autoapprovers. OAuth Scope: acl:read."),
+	)
+	mcpServer.AddTool(tool, pt.GetAutoApprovers)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_auto_approver_add",
+		updateTool,
+		mcp.WithDescription("Add an auto-approver so that subnet routes or exit nodes advertised by the given user, group, or tag are approved without manual review. For routes, an approver of a prefix also covers routes inside it. Comments and formatting in the policy are preserved. OAuth Scope: acl:write."),
+		mcp.WithString("kind", mcp.Description("What is auto-approved"), mcp.Enum("route", "exit_node"), mcp.Required()),
+		mcp.WithString("route", mcp.Description("Subnet route CIDR, required for kind 'route' (e.g., '10.0.0.0/16')")),
+		mcp.WithString("approver", mcp.Description("User, group, or tag whose advertisements are approved (e.g., 'tag:router')"), mcp.Required()),
+		ifMatch,
+		dryRun,
+	)
+	mcpServer.AddTool(tool, pt.AddAutoApprover)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_auto_approver_remove",
+		destructiveTool,
+		mcp.WithDescription("Remove an auto-approver for a subnet route or for exit nodes. Routes already approved stay approved; only future advertisements need manual approval again. Comments and formatting in the policy are preserved. OAuth Scope: acl:write."),
+		mcp.WithString("kind", mcp.Description("What is auto-approved"), mcp.Enum("route", "exit_node"), mcp.Required()),
+		mcp.WithString("route", mcp.Description("Subnet route CIDR, required for kind 'route'")),
+		mcp.WithString("approver", mcp.Description("Approver to remove"), mcp.Required()),
+		ifMatch,
+		dryRun,
+	)
+	mcpServer.AddTool(tool, pt.RemoveAutoApprover)
+}
+
+func (pt *PolicyTools) AddACLRule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Src     []string `json:"src"`
+		Dst     []string `json:"dst"`
+		Proto   string   `json:"proto"`
+		Index   *int     `json:"index"`
+		IfMatch string   `json:"if_match"`
+		DryRun  bool     `json:"dry_run"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if len(args.Src) == 0 || len(args.Dst) == 0 {
+		return mcp.NewToolResultError("src and dst must not be empty"), nil
+	}
+
+	rule := aclRule{Action: "accept", Src: args.Src, Dst: args.Dst, Proto: args.Proto}
+	return pt.editPolicy(ctx, args.IfMatch, args.DryRun, func(sections map[string]json.RawMessage) ([]patchOp, string, error) {
+		var rules []json.RawMessage
+		if err := decodeSection(sections, "acls", &rules); err != nil {
+			return nil, "", err
+		}
+
+		key := sectionKey(sections, "acls")
+		summary := fmt.Sprintf("added rule %v -> %v", rule.Src, rule.Dst)
+		if _, ok := sections[key]; !ok {
+			return []patchOp{addOp(jsonPointer(key), []aclRule{rule})}, summary, nil
+		}
+
+		position := "-"
+		if args.Index != nil {
+			if *args.Index < 0 || *args.Index > len(rules) {
+				return nil, "", fmt.Errorf("index %d out of range; the policy has %d rules", *args.Index, len(rules))
+			}
+			position = strconv.Itoa(*args.Index)
+		}
+		return []patchOp{addOp(jsonPointer(key, position), rule)}, summary, nil
+	})
+}
+
+func (pt *PolicyTools) UpdateACLRule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Index   int      `json:"index"`
+		Src     []string `json:"src"`
+		Dst     []string `json:"dst"`
+		Proto   string   `json:"proto"`
+		IfMatch string   `json:"if_match"`
+		DryRun  bool     `json:"dry_run"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if args.Src == nil && args.Dst == nil && args.Proto == "" {
+		return mcp.NewToolResultError("At least one of src, dst, or proto is required"), nil
+	}
+
+	return pt.editPolicy(ctx, args.IfMatch, args.DryRun, func(sections map[string]json.RawMessage) ([]patchOp, string, error) {
+		var rules []aclRule
+		if err := decodeSection(sections, "acls", &rules); err != nil {
+			return nil, "", err
+		}
+		if args.Index < 0 || args.Index >= len(rules) {
+			return nil, "", fmt.Errorf("index %d out of range; the policy has %d rules", args.Index, len(rules))
+		}
+
+		key := sectionKey(sections, "acls")
+		index := strconv.Itoa(args.Index)
+		rule := rules[args.Index]
+		var ops []patchOp
+		if args.Src != nil {
+			if len(args.Src) == 0 {
+				return nil, "", errors.New("src must not be empty")
+			}
+			if !slices.Equal(rule.Src, args.Src) {
+				ops = append(ops, addOp(jsonPointer(key, index, "src"), args.Src))
+			}
+		}
+		if args.Dst != nil {
+			if len(args.Dst) == 0 {
+				return nil, "", errors.New("dst must not be empty")
+			}
+			if !slices.Equal(rule.Dst, args.Dst) {
+				ops = append(ops, addOp(jsonPointer(key, index, "dst"), args.Dst))
+			}
+		}
+		if args.Proto != "" && args.Proto != rule.Proto {
+			ops = append(ops, addOp(jsonPointer(key, index, "proto"), args.Proto))
+		}
+		return ops, fmt.Sprintf("rule %d", args.Index), nil
+	})
+}
+
+func (pt *PolicyTools) RemoveACLRule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Index   int    `json:"index"`
+		IfMatch string `json:"if_match"`
+		DryRun  bool   `json:"dry_run"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	return pt.editPolicy(ctx, args.IfMatch, args.DryRun, func(sections map[string]json.RawMessage) ([]patchOp, string, error) {
+		var rules []aclRule
+		if err := decodeSection(sections, "acls", &rules); err != nil {
+			return nil, "", err
+		}
+		if args.Index < 0 || args.Index >= len(rules) {
+			return nil, "", fmt.Errorf("index %d out of range; the policy has %d rules", args.Index, len(rules))
+		}
+
+		rule := rules[args.Index]
+		summary := fmt.Sprintf("removed rule %d (%v -> %v)", args.Index, rule.Src, rule.Dst)
+		return []patchOp{removeOp(jsonPointer(sectionKey(sections, "acls"), strconv.Itoa(args.Index)))}, summary, nil
+	})
+}
+
+// addListMember returns the patch that adds value to the list stored under
+// name in a section of string lists, such as groups or tagOwners.
+func addListMember(sections map[string]json.RawMessage, section, name, value string) ([]patchOp, string, error) {
+	var lists map[string][]string
+	if err := decodeSection(sections, section, &lists); err != nil {
+		return nil, "", err
+	}
+
+	key := sectionKey(sections, section)
+	summary := fmt.Sprintf("added %s to %s", value, name)
+	switch list, ok := lists[name]; {
+	case slices.Contains(list, value):
+		return nil, fmt.Sprintf("%s already has %s", name, value), nil
+	case lists == nil:
+		if _, exists := sections[key]; exists {
+			return []patchOp{addOp(jsonPointer(key, name), []string{value})}, summary, nil
+		}
+		return []patchOp{addOp(jsonPointer(key), map[string][]string{name: {value}})}, summary, nil
+	case !ok:
+		return []patchOp{addOp(jsonPointer(key, name), []string{value})}, summary, nil
+	default:
+		return []patchOp{addOp(jsonPointer(key, name, "-"), value)}, summary, nil
+	}
+}
+
+// removeListMember returns the patch that removes value from the list stored
+// under name in a section of string lists.
+func removeListMember(sections map[string]json.RawMessage, section, name, value string) ([]patchOp, string, error) {
+	var lists map[string][]string
+	if err := decodeSection(sections, section, &lists); err != nil {
+		return nil, "", err
+	}
+
+	list, ok := lists[name]
+	if !ok {
+		return nil, "", fmt.Errorf("%s is not defined in %s", name, section)
+	}
+	i := slices.Index(list, value)
+	if i < 0 {
+		return nil, fmt.Sprintf("%s does not have %s", name, value), nil
+	}
+	return []patchOp{removeOp(jsonPointer(sectionKey(sections, section), name, strconv.Itoa(i)))}, fmt.Sprintf("removed %s from %s", value, name), nil
+}
+
+func (pt *PolicyTools) AddGroupMember(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return pt.editListMember(ctx, request, "groups", "group", "member", "group:", addListMember)
+}
+
+func (pt *PolicyTools) RemoveGroupMember(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return pt.editListMember(ctx, request, "groups", "group", "member", "group:", removeListMember)
+}
+
+func (pt *PolicyTools) AddTagOwner(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return pt.editListMember(ctx, request, "tagOwners", "tag", "owner", "tag:", addListMember)
+}
+
+func (pt *PolicyTools) RemoveTagOwner(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return pt.editListMember(ctx, request, "tagOwners", "tag", "owner", "tag:", removeListMember)
+}
+
+// editListMember binds the name and value arguments of a group or tag owner
+// edit and applies it with update.
+func (pt *PolicyTools) editListMember(ctx context.Context, request mcp.CallToolRequest, section, nameArg, valueArg, prefix string, update func(sections map[string]json.RawMessage, section, name, value string) ([]patchOp, string, error)) (*mcp.CallToolResult, error) {
+	name := request.GetString(nameArg, "")
+	value := request.GetString(valueArg, "")
+	if !strings.HasPrefix(name, prefix) {
+		return mcp.NewToolResultError(fmt.Sprintf("%s must start with '%s'", nameArg, prefix)), nil
+	}
+	if value == "" {
+		return mcp.NewToolResultError(fmt.Sprintf("%s must not be empty", valueArg)), nil
+	}
+
+	return pt.editPolicy(ctx, request.GetString("if_match", ""), request.GetBool("dry_run", false), func(sections map[string]json.RawMessage) ([]patchOp, string, error) {
+		return update(sections, section, name, value)
+	})
+}
+
+func (pt *PolicyTools) GetAutoApprovers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client := pt.client.GetClient()
+	policy, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+
+	sections, err := parsePolicySections(policy.HuJSON)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+	var autoApprovers tailscale.ACLAutoApprovers
+	if err := decodeSection(sections, "autoApprovers", &autoApprovers); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+	if autoApprovers.Routes == nil {
+		autoApprovers.Routes = map[string][]string{}
+	}
+	if autoApprovers.ExitNode == nil {
+		autoApprovers.ExitNode = []string{}
+	}
+
+	// Marshal explicitly so empty sections are shown rather than omitted.
+	autoApproversJSON, err := json.MarshalIndent(map[string]any{
+		"routes":   autoApprovers.Routes,
+		"exitNode": autoApprovers.ExitNode,
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal auto approvers: %v", err)), nil
+	}
+
+	result := mcp.NewToolResultText(string(autoApproversJSON))
+	result.Content = append(result.Content, mcp.NewTextContent("ETag: "+normalizeETag(policy.ETag)))
+	return result, nil
+}
+
+func (pt *PolicyTools) AddAutoApprover(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return pt.editAutoApprover(ctx, request, true)
+}
+
+func (pt *PolicyTools) RemoveAutoApprover(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return pt.editAutoApprover(ctx, request, false)
+}
+
+func (pt *PolicyTools) editAutoApprover(ctx context.Context, request mcp.CallToolRequest, add bool) (*mcp.CallToolResult, error) {
+	var args struct {
+		Kind     string `json:"kind"`
+		Route    string `json:"route"`
+		Approver string `json:"approver"`
+		IfMatch  string `json:"if_match"`
+		DryRun   bool   `json:"dry_run"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if args.Approver == "" {
+		return mcp.NewToolResultError("approver must not be empty"), nil
+	}
+
+	switch args.Kind {
+	case "route":
+		prefix, err := netip.ParsePrefix(args.Route)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid route: %v", err)), nil
+		}
+		args.Route = prefix.Masked().String()
+	case "exit_node":
+		if args.Route != "" {
+			return mcp.NewToolResultError("route does not apply to kind 'exit_node'"), nil
+		}
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid kind: %s", args.Kind)), nil
+	}
+
+	return pt.editPolicy(ctx, args.IfMatch, args.DryRun, func(sections map[string]json.RawMessage) ([]patchOp, string, error) {
+		key := sectionKey(sections, "autoApprovers")
+		var autoApprovers map[string]json.RawMessage
+		if err := decodeSection(sections, "autoApprovers", &autoApprovers); err != nil {
+			return nil, "", err
+		}
+
+		if autoApprovers == nil {
+			if !add {
+				return nil, "", errors.New("the policy has no autoApprovers section")
+			}
+			section := map[string]any{"exitNode": []string{args.Approver}}
+			summary := fmt.Sprintf("added %s as exit node auto-approver", args.Approver)
+			if args.Kind == "route" {
+				section = map[string]any{"routes": map[string][]string{args.Route: {args.Approver}}}
+				summary = fmt.Sprintf("added %s as auto-approver for %s", args.Approver, args.Route)
+			}
+			return []patchOp{addOp(jsonPointer(key), section)}, summary, nil
+		}
+
+		var ops []patchOp
+		var summary string
+		var err error
+		if args.Kind == "route" {
+			if add {
+				ops, summary, err = addListMember(autoApprovers, "routes", args.Route, args.Approver)
+			} else {
+				ops, summary, err = removeListMember(autoApprovers, "routes", args.Route, args.Approver)
+			}
+		} else {
+			ops, summary, err = editExitNodeApprovers(autoApprovers, args.Approver, add)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+
+		// The helpers address paths inside the section; make them absolute.
+		for i := range ops {
+			ops[i].Path = jsonPointer(key) + ops[i].Path
+		}
+		return ops, summary, nil
+	})
+}
+
+// editExitNodeApprovers returns the patch, relative to the autoApprovers
+// section, that adds or removes an exit node approver.
+func editExitNodeApprovers(autoApprovers map[string]json.RawMessage, approver string, add bool) ([]patchOp, string, error) {
+	key := sectionKey(autoApprovers, "exitNode")
+	var approvers []string
+	if err := decodeSection(autoApprovers, "exitNode", &approvers); err != nil {
+		return nil, "", err
+	}
+	_, exists := autoApprovers[key]
+	i := slices.Index(approvers, approver)
+
+	switch {
+	case add && i >= 0:
+		return nil, fmt.Sprintf("%s is already an exit node auto-approver", approver), nil
+	case add && !exists:
+		return []patchOp{addOp(jsonPointer(key), []string{approver})}, fmt.Sprintf("added %s as exit node auto-approver", approver), nil
+	case add:
+		return []patchOp{addOp(jsonPointer(key, "-"), approver)}, fmt.Sprintf("added %s as exit node auto-approver", approver), nil
+	case i < 0:
+		return nil, fmt.Sprintf("%s is not an exit node auto-approver", approver), nil
+	default:
+		return []patchOp{removeOp(jsonPointer(key, strconv.Itoa(i)))}, fmt.Sprintf("removed %s as exit node auto-approver", approver), nil
+	}
+}
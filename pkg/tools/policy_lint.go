@@ -0,0 +1,267 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// lintFinding is a single problem reported by tailscale_policy_lint.
+type lintFinding struct {
+	Check    string `json:"check"`
+	Severity string `json:"severity"`
+	Location string `json:"location,omitempty"`
+	Message  string `json:"message"`
+}
+
+func (pt *PolicyTools) registerLintTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_policy_lint",
+		readOnlyTool,
+		mcp.WithDescription("Lint the policy file for problems the API's validation does not catch: groups and tags that nothing uses, ACL rules made redundant by an earlier accept-all rule, references to users who are no longer in the tailnet, overly broad '*:*' destinations, and ACL rules that no entry in the tests section exercises. Lints the current policy, or the given HuJSON before it is uploaded. OAuth Scope: acl:read, users:read, devices:read."),
+		mcp.WithString("policy", mcp.Description("Policy file content in HuJSON format to lint instead of the tailnet's current policy")),
+	)
+	mcpServer.AddTool(tool, pt.LintPolicy)
+}
+
+func (pt *PolicyTools) LintPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Policy string `json:"policy"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	client := pt.client.GetClient()
+	if args.Policy == "" {
+		policy, err := client.PolicyFile().Raw(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+		}
+		args.Policy = policy.HuJSON
+	}
+
+	policy, grants, err := parsePolicy(args.Policy)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+
+	users, err := client.Users().List(ctx, tailscale.PointerTo(tailscale.UserType("all")), nil)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list users: %v", err)), nil
+	}
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+
+	// Every principal the policy refers to, with where it is referenced.
+	// ACL and test destinations carry a port suffix, which is stripped.
+	refs := make(map[string][]string)
+	addRefs := func(location string, values []string, hasPort bool) {
+		for _, v := range values {
+			if hasPort {
+				v = destinationHost(v)
+			}
+			refs[v] = append(refs[v], location)
+		}
+	}
+	for i, rule := range policy.ACLs {
+		location := fmt.Sprintf("acls[%d]", i)
+		addRefs(location, rule.Source, false)
+		addRefs(location, rule.Users, false)
+		addRefs(location, rule.Destination, true)
+	}
+	for i, grant := range grants {
+		location := fmt.Sprintf("grants[%d]", i)
+		addRefs(location, grant.Src, false)
+		addRefs(location, grant.Dst, false)
+	}
+	for i, rule := range policy.SSH {
+		location := fmt.Sprintf("ssh[%d]", i)
+		addRefs(location, rule.Source, false)
+		addRefs(location, rule.Destination, false)
+	}
+	for _, group := range slices.Sorted(maps.Keys(policy.Groups)) {
+		addRefs("groups."+group, policy.Groups[group], false)
+	}
+	for _, tag := range slices.Sorted(maps.Keys(policy.TagOwners)) {
+		addRefs("tagOwners."+tag, policy.TagOwners[tag], false)
+	}
+	if policy.AutoApprovers != nil {
+		for _, route := range slices.Sorted(maps.Keys(policy.AutoApprovers.Routes)) {
+			addRefs("autoApprovers.routes."+route, policy.AutoApprovers.Routes[route], false)
+		}
+		addRefs("autoApprovers.exitNode", policy.AutoApprovers.ExitNode, false)
+	}
+	for i, attr := range policy.NodeAttrs {
+		addRefs(fmt.Sprintf("nodeAttrs[%d]", i), attr.Target, false)
+	}
+	// Tests do not grant anything, so they count toward known-user checks
+	// but not toward a group or tag being used.
+	testRefs := make(map[string][]string)
+	for i, test := range policy.Tests {
+		location := fmt.Sprintf("tests[%d]", i)
+		testRefs[test.Source] = append(testRefs[test.Source], location)
+	}
+
+	findings := []lintFinding{}
+
+	for _, group := range slices.Sorted(maps.Keys(policy.Groups)) {
+		used := slices.ContainsFunc(refs[group], func(location string) bool { return location != "groups."+group })
+		if !used {
+			findings = append(findings, lintFinding{
+				Check:    "unused_group",
+				Severity: "info",
+				Location: "groups." + group,
+				Message:  fmt.Sprintf("%s is not referenced by any rule, group, tag owner, or auto-approver", group),
+			})
+		}
+	}
+
+	for _, tag := range slices.Sorted(maps.Keys(policy.TagOwners)) {
+		assigned := slices.ContainsFunc(devices, func(device tailscale.Device) bool { return slices.Contains(device.Tags, tag) })
+		used := slices.ContainsFunc(refs[tag], func(location string) bool { return location != "tagOwners."+tag })
+		if !assigned && !used {
+			findings = append(findings, lintFinding{
+				Check:    "unused_tag",
+				Severity: "info",
+				Location: "tagOwners." + tag,
+				Message:  fmt.Sprintf("%s is not assigned to any device and not referenced by any rule", tag),
+			})
+		}
+	}
+
+	for i, rule := range policy.ACLs {
+		if !isAllowAll(rule) {
+			continue
+		}
+		for j := i + 1; j < len(policy.ACLs); j++ {
+			findings = append(findings, lintFinding{
+				Check:    "shadowed_rule",
+				Severity: "warning",
+				Location: fmt.Sprintf("acls[%d]", j),
+				Message:  fmt.Sprintf("rule is redundant: acls[%d] already accepts all traffic from '*' to '*:*'", i),
+			})
+		}
+		break
+	}
+
+	logins := make(map[string]bool, len(users))
+	for _, user := range users {
+		logins[strings.ToLower(user.LoginName)] = true
+	}
+	allRefs := maps.Clone(refs)
+	for principal, locations := range testRefs {
+		allRefs[principal] = append(allRefs[principal], locations...)
+	}
+	for _, principal := range slices.Sorted(maps.Keys(allRefs)) {
+		if !strings.Contains(principal, "@") || strings.Contains(principal, ":") || logins[strings.ToLower(principal)] {
+			continue
+		}
+		locations := slices.Compact(slices.Sorted(slices.Values(allRefs[principal])))
+		findings = append(findings, lintFinding{
+			Check:    "unknown_user",
+			Severity: "warning",
+			Location: strings.Join(locations, ", "),
+			Message:  fmt.Sprintf("%s is not a user in this tailnet", principal),
+		})
+	}
+
+	for i, rule := range policy.ACLs {
+		if slices.Contains(rule.Destination, "*:*") {
+			findings = append(findings, lintFinding{
+				Check:    "broad_destination",
+				Severity: "warning",
+				Location: fmt.Sprintf("acls[%d]", i),
+				Message:  fmt.Sprintf("rule gives %s access to every port on every device; consider narrowing dst", strings.Join(rule.Source, ", ")),
+			})
+		}
+	}
+
+	if len(policy.ACLs) > 0 && len(policy.Tests) == 0 {
+		findings = append(findings, lintFinding{
+			Check:    "missing_tests",
+			Severity: "info",
+			Location: "tests",
+			Message:  "policy has ACL rules but no tests; add tests so policy changes that break access are rejected",
+		})
+	} else {
+		for i, rule := range policy.ACLs {
+			if !slices.ContainsFunc(policy.Tests, func(test tailscale.ACLTest) bool { return testCoversRule(policy, users, test, rule) }) {
+				findings = append(findings, lintFinding{
+					Check:    "missing_tests",
+					Severity: "info",
+					Location: fmt.Sprintf("acls[%d]", i),
+					Message:  fmt.Sprintf("no test checks access from %s to %s", strings.Join(rule.Source, ", "), strings.Join(rule.Destination, ", ")),
+				})
+			}
+		}
+	}
+
+	counts := map[string]int{}
+	for _, finding := range findings {
+		counts[finding.Severity]++
+	}
+
+	resultJSON, err := json.MarshalIndent(map[string]any{
+		"findings": findings,
+		"counts":   counts,
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal lint findings: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+// destinationHost strips the port list from an ACL destination such as
+// "tag:db:5432" or "*:*".
+func destinationHost(dst string) string {
+	if i := strings.LastIndex(dst, ":"); i >= 0 {
+		return dst[:i]
+	}
+	return dst
+}
+
+// isAllowAll reports whether an ACL rule accepts all traffic between all
+// devices, making every other rule redundant.
+func isAllowAll(rule tailscale.ACLEntry) bool {
+	return rule.Action == "accept" && rule.Protocol == "" && len(rule.SourcePosture) == 0 &&
+		slices.Contains(rule.Source, "*") && slices.Contains(rule.Destination, "*:*")
+}
+
+// testCoversRule reports whether test exercises rule: the test's source is
+// one of the rule's sources, and it checks a destination host the rule names.
+func testCoversRule(policy *tailscale.ACL, users []tailscale.User, test tailscale.ACLTest, rule tailscale.ACLEntry) bool {
+	principals := []string{test.Source}
+	for _, user := range users {
+		if strings.EqualFold(user.LoginName, test.Source) {
+			principals = userPrincipals(policy, user)
+			break
+		}
+	}
+	if !slices.Contains(rule.Source, "*") && !slices.ContainsFunc(rule.Source, func(src string) bool {
+		return slices.ContainsFunc(principals, func(p string) bool { return strings.EqualFold(p, src) })
+	}) {
+		return false
+	}
+
+	hosts := make([]string, 0, len(rule.Destination))
+	for _, dst := range rule.Destination {
+		hosts = append(hosts, destinationHost(dst))
+	}
+	return slices.Contains(hosts, "*") || slices.ContainsFunc(slices.Concat(test.Accept, test.Deny), func(dst string) bool {
+		return slices.Contains(hosts, destinationHost(dst))
+	})
+}
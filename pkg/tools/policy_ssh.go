@@ -0,0 +1,304 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// sshRule is an entry of the policy's ssh section.
+type sshRule struct {
+	Action      string   `json:"action"`
+	Src         []string `json:"src"`
+	Dst         []string `json:"dst"`
+	Users       []string `json:"users"`
+	CheckPeriod string   `json:"checkPeriod,omitempty"`
+}
+
+func (pt *PolicyTools) registerSSHTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_policy_ssh_rules_list",
+		readOnlyTool,
+		mcp.WithDescription("List the Tailscale SSH rules in the policy file's ssh section with their zero-based index, action ('accept' or 'check'), sources, destinations, allowed local users, and check period. Use the index with tailscale_policy_ssh_rule_remove. Learn more at /kb/1193/tailscale-ssh. OAuth Scope: acl:read."),
+	)
+	mcpServer.AddTool(tool, pt.ListSSHRules)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_ssh_rule_add",
+		createTool,
+		mcp.WithDescription("Add a Tailscale SSH rule to the policy file's ssh section from structured arguments. 'accept' lets sources connect directly; 'check' additionally requires them to have re-authenticated within check_period. The HuJSON is edited in place, preserving comments and formatting. Learn more at /kb/1193/tailscale-ssh. OAuth Scope: acl:write."),
+		mcp.WithString("action", mcp.Description("Rule action (default: accept)"), mcp.Enum("accept", "check")),
+		mcp.WithArray("src", mcp.Description("Users, groups, or autogroups allowed to connect (e.g., ['group:sre'])"), mcp.WithStringItems(), mcp.Required()),
+		mcp.WithArray("dst", mcp.Description("Devices that accept the connection: tags, users, or autogroup:self (e.g., ['tag:prod'])"), mcp.WithStringItems(), mcp.Required()),
+		mcp.WithArray("users", mcp.Description("Local users that may be logged in as (e.g., ['ubuntu', 'autogroup:nonroot'])"), mcp.WithStringItems(), mcp.Required()),
+		mcp.WithString("check_period", mcp.Description("How recently users must have re-authenticated for 'check' rules (e.g., '12h', or 'always')")),
+		mcp.WithString("if_match", mcp.Description("Only apply the edit if the policy still has this ETag")),
+		mcp.WithBoolean("dry_run", mcp.Description("Return the edited policy without applying it")),
+	)
+	mcpServer.AddTool(tool, pt.AddSSHRule)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_ssh_rule_remove",
+		destructiveTool,
+		mcp.WithDescription("Remove a Tailscale SSH rule, identified by its zero-based index from tailscale_policy_ssh_rules_list. Use if_match with the ETag the index was read from so a concurrent edit cannot shift the rule. OAuth Scope: acl:write."),
+		mcp.WithNumber("index", mcp.Description("Zero-based index of the rule in the ssh section"), mcp.Required()),
+		mcp.WithString("if_match", mcp.Description("Only apply the edit if the policy still has this ETag")),
+		mcp.WithBoolean("dry_run", mcp.Description("Return the edited policy without applying it")),
+	)
+	mcpServer.AddTool(tool, pt.RemoveSSHRule)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_ssh_access",
+		readOnlyTool,
+		mcp.WithDescription("Answer 'who can SSH to device X as local user Y?' from the policy file's ssh section. Returns every matching rule with its action and the users it admits, expanding groups and autogroups, plus the combined list of users. Tag sources are reported as-is, since they identify devices rather than people. Does not evaluate network ACLs, which must also allow port 22. OAuth Scope: acl:read, devices:read, users:read."),
+		mcp.WithString("device", mcp.Description("Target device ID, MagicDNS name, or hostname"), mcp.Required()),
+		mcp.WithString("user", mcp.Description("Local user to log in as (e.g., 'root', 'ubuntu')"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, pt.SSHAccess)
+}
+
+func (pt *PolicyTools) ListSSHRules(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client := pt.client.GetClient()
+	policy, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+
+	sections, err := parsePolicySections(policy.HuJSON)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+	var rules []sshRule
+	if err := decodeSection(sections, "ssh", &rules); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+
+	type indexedRule struct {
+		Index int `json:"index"`
+		sshRule
+	}
+	indexed := make([]indexedRule, 0, len(rules))
+	for i, rule := range rules {
+		indexed = append(indexed, indexedRule{Index: i, sshRule: rule})
+	}
+
+	rulesJSON, err := json.MarshalIndent(indexed, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal SSH rules: %v", err)), nil
+	}
+
+	result := mcp.NewToolResultText(string(rulesJSON))
+	result.Content = append(result.Content, mcp.NewTextContent("ETag: "+normalizeETag(policy.ETag)))
+	return result, nil
+}
+
+func (pt *PolicyTools) AddSSHRule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := struct {
+		Action      string   `json:"action"`
+		Src         []string `json:"src"`
+		Dst         []string `json:"dst"`
+		Users       []string `json:"users"`
+		CheckPeriod string   `json:"check_period"`
+		IfMatch     string   `json:"if_match"`
+		DryRun      bool     `json:"dry_run"`
+	}{Action: "accept"}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if args.Action != "accept" && args.Action != "check" {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid action: %s", args.Action)), nil
+	}
+	if len(args.Src) == 0 || len(args.Dst) == 0 || len(args.Users) == 0 {
+		return mcp.NewToolResultError("src, dst, and users must not be empty"), nil
+	}
+	if args.CheckPeriod != "" {
+		if args.Action != "check" {
+			return mcp.NewToolResultError("check_period only applies to 'check' rules"), nil
+		}
+		if _, err := time.ParseDuration(args.CheckPeriod); err != nil && args.CheckPeriod != "always" {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid check_period: %v", err)), nil
+		}
+	}
+
+	rule := sshRule{Action: args.Action, Src: args.Src, Dst: args.Dst, Users: args.Users, CheckPeriod: args.CheckPeriod}
+	return pt.editPolicy(ctx, args.IfMatch, args.DryRun, func(sections map[string]json.RawMessage) ([]patchOp, string, error) {
+		key := sectionKey(sections, "ssh")
+		summary := fmt.Sprintf("added SSH %s rule %v -> %v as %v", rule.Action, rule.Src, rule.Dst, rule.Users)
+		if _, ok := sections[key]; !ok {
+			return []patchOp{addOp(jsonPointer(key), []sshRule{rule})}, summary, nil
+		}
+		return []patchOp{addOp(jsonPointer(key, "-"), rule)}, summary, nil
+	})
+}
+
+func (pt *PolicyTools) RemoveSSHRule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Index   int    `json:"index"`
+		IfMatch string `json:"if_match"`
+		DryRun  bool   `json:"dry_run"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	return pt.editPolicy(ctx, args.IfMatch, args.DryRun, func(sections map[string]json.RawMessage) ([]patchOp, string, error) {
+		var rules []sshRule
+		if err := decodeSection(sections, "ssh", &rules); err != nil {
+			return nil, "", err
+		}
+		if args.Index < 0 || args.Index >= len(rules) {
+			return nil, "", fmt.Errorf("index %d out of range; the policy has %d SSH rules", args.Index, len(rules))
+		}
+
+		rule := rules[args.Index]
+		summary := fmt.Sprintf("removed SSH rule %d (%v -> %v as %v)", args.Index, rule.Src, rule.Dst, rule.Users)
+		return []patchOp{removeOp(jsonPointer(sectionKey(sections, "ssh"), strconv.Itoa(args.Index)))}, summary, nil
+	})
+}
+
+// sshUserAllowed reports whether an SSH rule's users list admits logging in
+// as localUser for the connecting user login.
+func sshUserAllowed(ruleUsers []string, localUser, login string) bool {
+	for _, u := range ruleUsers {
+		switch {
+		case u == localUser:
+			return true
+		case u == "autogroup:nonroot" && localUser != "root":
+			return true
+		case strings.HasPrefix(u, "localpart:"):
+			// localpart:*@example.com maps user@example.com to local user "user".
+			domain := strings.TrimPrefix(u, "localpart:*@")
+			local, loginDomain, ok := strings.Cut(login, "@")
+			if ok && local == localUser && strings.EqualFold(loginDomain, domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (pt *PolicyTools) SSHAccess(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Device string `json:"device"`
+		User   string `json:"user"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	client := pt.client.GetClient()
+	policy, err := client.PolicyFile().Get(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy file: %v", err)), nil
+	}
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+	device, err := resolveDevice(devices, args.Device)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to find device: %v", err)), nil
+	}
+	users, err := client.Users().List(ctx, tailscale.PointerTo(tailscale.UserType("all")), nil)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list users: %v", err)), nil
+	}
+
+	var owner *tailscale.User
+	if len(device.Tags) == 0 {
+		for _, user := range users {
+			if strings.EqualFold(user.LoginName, device.User) {
+				owner = &user
+				break
+			}
+		}
+	}
+
+	type ruleMatch struct {
+		Index       int      `json:"index"`
+		Action      string   `json:"action"`
+		CheckPeriod string   `json:"check_period,omitempty"`
+		Users       []string `json:"users"`
+		TagSources  []string `json:"tag_sources,omitempty"`
+	}
+	result := struct {
+		Device    string      `json:"device"`
+		LocalUser string      `json:"local_user"`
+		Rules     []ruleMatch `json:"rules"`
+		Users     []string    `json:"users"`
+	}{
+		Device:    device.Name,
+		LocalUser: args.User,
+		Rules:     []ruleMatch{},
+		Users:     []string{},
+	}
+
+	for i, rule := range policy.SSH {
+		self := false
+		matched := false
+		for _, dst := range rule.Destination {
+			if dst == "autogroup:self" {
+				self = self || owner != nil
+				continue
+			}
+			matched = matched || deviceMatchesTarget(policy, *device, owner, dst)
+		}
+		if !matched && !self {
+			continue
+		}
+
+		match := ruleMatch{Index: i, Action: rule.Action, Users: []string{}}
+		if rule.CheckPeriod != 0 {
+			if text, err := rule.CheckPeriod.MarshalText(); err == nil {
+				match.CheckPeriod = string(text)
+			}
+		}
+		for _, src := range rule.Source {
+			if strings.HasPrefix(src, "tag:") {
+				if matched && sshUserAllowed(rule.Users, args.User, "") {
+					match.TagSources = append(match.TagSources, src)
+				}
+				continue
+			}
+			for _, login := range principalUsers(policy, users, src) {
+				// autogroup:self only admits the device's owner.
+				if !matched && !strings.EqualFold(login, owner.LoginName) {
+					continue
+				}
+				if sshUserAllowed(rule.Users, args.User, login) && !slices.Contains(match.Users, login) {
+					match.Users = append(match.Users, login)
+				}
+			}
+		}
+		if len(match.Users) == 0 && len(match.TagSources) == 0 {
+			continue
+		}
+
+		slices.Sort(match.Users)
+		result.Rules = append(result.Rules, match)
+		for _, login := range match.Users {
+			if !slices.Contains(result.Users, login) {
+				result.Users = append(result.Users, login)
+			}
+		}
+	}
+	slices.Sort(result.Users)
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal SSH access: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
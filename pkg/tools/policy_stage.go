@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/textdiff"
+)
+
+// stagedPolicy is a proposed policy waiting to be applied.
+type stagedPolicy struct {
+	ID       string    `json:"change_id"`
+	Note     string    `json:"note,omitempty"`
+	BaseETag string    `json:"base_etag"`
+	Staged   time.Time `json:"staged"`
+	Diff     string    `json:"diff"`
+	policy   string
+}
+
+func (pt *PolicyTools) registerStageTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_policy_stage",
+		createTool,
+		mcp.WithDescription("Propose a new policy file without applying it. The policy is validated, then stored on this server with a change ID, and the diff from the current policy is returned for review. Apply it with tailscale_policy_apply_staged. Staged changes are kept in memory and lost when the server restarts. OAuth Scope: acl:read."),
+		mcp.WithString("policy", mcp.Description("Proposed policy file content in HuJSON format"), mcp.Required()),
+		mcp.WithString("note", mcp.Description("Why the change is proposed, shown to the reviewer")),
+	)
+	mcpServer.AddTool(tool, pt.StagePolicy)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_staged_list",
+		readOnlyTool,
+		mcp.WithDescription("List the staged policy changes waiting to be applied, with their change IDs, notes, and diffs."),
+	)
+	mcpServer.AddTool(tool, pt.ListStagedPolicies)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_apply_staged",
+		destructiveTool,
+		mcp.WithDescription("Apply a policy change staged with tailscale_policy_stage. The change is only applied if the policy has not been modified since it was staged; otherwise stage it again against the current policy. When the server is configured with TAILSCALE_MCP_POLICY_APPROVAL_TOKEN, the token must be supplied, so that a change proposed by an assistant is approved by a person who holds it. OAuth Scope: acl:write."),
+		mcp.WithString("change_id", mcp.Description("Change ID returned by tailscale_policy_stage"), mcp.Required()),
+		mcp.WithString("approval_token", mcp.Description("Approval token, required when the server is configured with one")),
+	)
+	mcpServer.AddTool(tool, pt.ApplyStagedPolicy)
+}
+
+func (pt *PolicyTools) StagePolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Policy string `json:"policy"`
+		Note   string `json:"note"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	client := pt.client.GetClient()
+	if err := client.PolicyFile().Validate(ctx, args.Policy); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Policy not staged: %v", err)), nil
+	}
+
+	current, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+	diff := textdiff.Unified("current", "staged", current.HuJSON, args.Policy)
+	if diff == "" {
+		return mcp.NewToolResultText("Policy not staged: it is identical to the current policy"), nil
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to generate change ID: %v", err)), nil
+	}
+
+	staged := &stagedPolicy{
+		ID:       hex.EncodeToString(id),
+		Note:     args.Note,
+		BaseETag: normalizeETag(current.ETag),
+		Staged:   time.Now().UTC(),
+		Diff:     diff,
+		policy:   args.Policy,
+	}
+	pt.mu.Lock()
+	pt.staged[staged.ID] = staged
+	pt.mu.Unlock()
+
+	stagedJSON, err := json.MarshalIndent(staged, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal staged change: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(stagedJSON)), nil
+}
+
+func (pt *PolicyTools) ListStagedPolicies(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	pt.mu.Lock()
+	staged := slices.SortedFunc(maps.Values(pt.staged), func(a, b *stagedPolicy) int { return a.Staged.Compare(b.Staged) })
+	pt.mu.Unlock()
+	if staged == nil {
+		staged = []*stagedPolicy{}
+	}
+
+	stagedJSON, err := json.MarshalIndent(staged, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal staged changes: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(stagedJSON)), nil
+}
+
+func (pt *PolicyTools) ApplyStagedPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ChangeID      string `json:"change_id"`
+		ApprovalToken string `json:"approval_token"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	if token := pt.config.PolicyApprovalToken; token != "" && subtle.ConstantTimeCompare([]byte(args.ApprovalToken), []byte(token)) != 1 {
+		return mcp.NewToolResultError("Staged policy not applied: a valid approval_token is required"), nil
+	}
+
+	pt.mu.Lock()
+	staged, ok := pt.staged[args.ChangeID]
+	pt.mu.Unlock()
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Unknown change ID: %s", args.ChangeID)), nil
+	}
+
+	client := pt.client.GetClient()
+	if err := client.PolicyFile().Set(ctx, staged.policy, staged.BaseETag); err != nil {
+		if isPreconditionFailed(err) {
+			return mcp.NewToolResultError("Staged policy not applied: the policy has changed since the change was staged. Stage it again against the current policy."), nil
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set policy: %v", err)), nil
+	}
+
+	pt.mu.Lock()
+	delete(pt.staged, args.ChangeID)
+	pt.mu.Unlock()
+
+	result := mcp.NewToolResultText(fmt.Sprintf("Staged change %s applied", args.ChangeID))
+	if current, err := client.PolicyFile().Raw(ctx); err == nil {
+		result.Content = append(result.Content, mcp.NewTextContent("ETag: "+normalizeETag(current.ETag)))
+	}
+	return result, nil
+}
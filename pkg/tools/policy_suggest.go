@@ -0,0 +1,167 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// suggestTimeout bounds a sampling request, which the client may hold
+	// while the user reviews it.
+	suggestTimeout = 5 * time.Minute
+	// suggestMaxTokens leaves room for the model to return a whole policy.
+	suggestMaxTokens = 16000
+)
+
+const suggestSystemPrompt = `You edit Tailscale policy files. You are given the current policy file in HuJSON, the groups, tag owners, and hosts it defines, the tags devices carry, and a change to make. Reply with the complete new policy file in HuJSON and nothing else: no explanation and no code fences.
+
+Make the smallest edit that makes the change. Keep every existing rule, test, comment, and the existing formatting unless the change requires otherwise. Reuse existing groups, tags, and hosts. A new tag needs a tagOwners entry. Add entries to the tests section that check the new access is allowed and that nearby access which should stay denied is still denied.`
+
+func (pt *PolicyTools) registerSuggestTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_policy_suggest",
+		createTool,
+		mcp.WithDescription("Draft a policy change from a plain-language intent, such as 'let the data team reach the staging databases on 5432'. The draft is written by the client's model through MCP sampling, from the current policy and the tailnet's groups, tags, and hosts, and is never applied: it is linted, its tests are run, and it is staged with tailscale_policy_stage, so the result holds the draft, lint findings, test results, and the staged change ID and diff. Review the diff and apply it with tailscale_policy_apply_staged. Requires a client that supports sampling. OAuth Scope: acl:read, users:read, devices:read."),
+		mcp.WithString("intent", mcp.Description("The change to make, in plain language"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, pt.SuggestPolicy)
+}
+
+func (pt *PolicyTools) SuggestPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Intent string `json:"intent"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if strings.TrimSpace(args.Intent) == "" {
+		return mcp.NewToolResultError("intent is required"), nil
+	}
+
+	mcpServer := server.ServerFromContext(ctx)
+	session, ok := server.ClientSessionFromContext(ctx).(server.SessionWithClientInfo)
+	if mcpServer == nil || !ok || session.GetClientCapabilities().Sampling == nil {
+		return mcp.NewToolResultError("Policy not drafted: this client does not support sampling. Use the tailscale_policy_change prompt instead."), nil
+	}
+
+	client := pt.client.GetClient()
+	current, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+	sections, err := parsePolicySections(current.HuJSON)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+	inventory, err := json.MarshalIndent(map[string]any{
+		"names":       policyNames(sections),
+		"device_tags": deviceTagCounts(devices),
+	}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal inventory: %v", err)), nil
+	}
+
+	sampleCtx, cancel := context.WithTimeout(ctx, suggestTimeout)
+	defer cancel()
+	sample, err := mcpServer.RequestSampling(sampleCtx, mcp.CreateMessageRequest{
+		CreateMessageParams: mcp.CreateMessageParams{
+			SystemPrompt: suggestSystemPrompt,
+			Messages: []mcp.SamplingMessage{{
+				Role:    mcp.RoleUser,
+				Content: mcp.NewTextContent(fmt.Sprintf("Change to make: %s\n\nCurrent policy file:\n%s\n\nNames and device tags:\n%s", args.Intent, current.HuJSON, inventory)),
+			}},
+			MaxTokens: suggestMaxTokens,
+		},
+	})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to draft policy: %v", err)), nil
+	}
+	draft := stripCodeFence(samplingText(sample.Content))
+	if draft == "" {
+		return mcp.NewToolResultError("Failed to draft policy: the model returned no text"), nil
+	}
+
+	// The draft goes through the same checks a person's edit would, and is
+	// only staged, never applied.
+	suggestion := map[string]any{
+		"intent": args.Intent,
+		"model":  sample.Model,
+		"draft":  draft,
+	}
+	for _, step := range []struct {
+		name    string
+		handler server.ToolHandlerFunc
+		args    map[string]any
+	}{
+		{"lint", pt.LintPolicy, map[string]any{"policy": draft}},
+		{"tests", pt.TestPolicy, map[string]any{"policy": draft}},
+		{"staged", pt.StagePolicy, map[string]any{"policy": draft, "note": "Suggested for: " + args.Intent}},
+	} {
+		var stepRequest mcp.CallToolRequest
+		stepRequest.Params.Arguments = step.args
+		result, err := step.handler(ctx, stepRequest)
+		if err != nil {
+			return nil, err
+		}
+		suggestion[step.name] = resultValue(result)
+	}
+
+	suggestionJSON, err := json.MarshalIndent(suggestion, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal suggestion: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(suggestionJSON)), nil
+}
+
+// samplingText returns the text of a sampled message, whose content is a
+// TextContent when sampled in process and a decoded JSON object otherwise.
+func samplingText(content any) string {
+	switch c := content.(type) {
+	case mcp.TextContent:
+		return c.Text
+	case map[string]any:
+		if text, ok := c["text"].(string); ok && c["type"] == "text" {
+			return text
+		}
+	}
+	return ""
+}
+
+// stripCodeFence removes a Markdown code fence around text, which models add
+// despite being asked not to.
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	_, text, _ = strings.Cut(text, "\n")
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), "```"))
+}
+
+// resultValue returns the JSON a tool result holds, or its text when it is
+// not JSON, such as an error message.
+func resultValue(result *mcp.CallToolResult) any {
+	var texts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	text := strings.Join(texts, "\n")
+	if !result.IsError && json.Valid([]byte(text)) {
+		return json.RawMessage(text)
+	}
+	return text
+}
@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func (pt *PolicyTools) registerSyncTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_policy_sync",
+		destructiveTool,
+		mcp.WithDescription("Sync the policy file from the git repository configured with TAILSCALE_MCP_POLICY_GIT_REPO, TAILSCALE_MCP_POLICY_GIT_BRANCH, and TAILSCALE_MCP_POLICY_GIT_PATH. Pulls the latest commit, validates the policy, and returns a diff from the tailnet's current policy to the git copy. With apply set, the git copy is then written to the tailnet. When TAILSCALE_MCP_POLICY_SYNC_INTERVAL is set the server also syncs and applies periodically. OAuth Scope: acl:write."),
+		mcp.WithBoolean("apply", mcp.Description("Apply the policy from git if it differs from the tailnet's (default: false, diff only)")),
+	)
+	mcpServer.AddTool(tool, pt.SyncPolicy)
+
+	tool = mcp.NewTool(
+		"tailscale_policy_sync_status",
+		readOnlyTool,
+		mcp.WithDescription("Get the status of git policy sync: the configured repository, branch, path, and interval, the most recent sync and the most recent one that changed the policy, and the last error."),
+	)
+	mcpServer.AddTool(tool, pt.GetPolicySyncStatus)
+}
+
+func (pt *PolicyTools) SyncPolicy(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Apply bool `json:"apply"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	if pt.sync == nil {
+		return mcp.NewToolResultError("Policy sync is not configured: set TAILSCALE_MCP_POLICY_GIT_REPO"), nil
+	}
+
+	result, err := pt.sync.Sync(ctx, args.Apply)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to sync policy: %v", err)), nil
+	}
+
+	var summary string
+	switch {
+	case !result.Changed:
+		summary = fmt.Sprintf("Policy already matches commit %s", result.Commit)
+	case result.Applied:
+		summary = fmt.Sprintf("Policy updated to commit %s", result.Commit)
+	default:
+		summary = fmt.Sprintf("Policy differs from commit %s; call again with apply set to apply it", result.Commit)
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal sync result: %v", err)), nil
+	}
+
+	toolResult := mcp.NewToolResultText(summary)
+	toolResult.Content = append(toolResult.Content, mcp.NewTextContent(string(resultJSON)))
+	return toolResult, nil
+}
+
+func (pt *PolicyTools) GetPolicySyncStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if pt.sync == nil {
+		return mcp.NewToolResultError("Policy sync is not configured: set TAILSCALE_MCP_POLICY_GIT_REPO"), nil
+	}
+
+	statusJSON, err := json.MarshalIndent(pt.sync.Status(), "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal sync status: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(statusJSON)), nil
+}
@@ -0,0 +1,223 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// denyProbePort is the port generated deny tests check tags on.
+const denyProbePort = 22
+
+func (pt *PolicyTools) registerTestGenTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_policy_tests_generate",
+		updateTool,
+		mcp.WithDescription("Generate entries for the policy's tests section from the flows the current acls and grants allow, as a starting regression suite. For every rule, a representative user of each source (the first member of a group, for example) gets an accept check on each tagged, host alias, or IP destination, using the lowest port the rule allows. Optionally, deny checks are added for tags the source cannot reach on port 22. Flows already covered by existing tests are skipped. Returns the generated tests; with add set they are appended to the policy, which the API rejects if any of them fails. OAuth Scope: acl:read, users:read (acl:write with add)."),
+		mcp.WithBoolean("include_deny", mcp.Description("Also generate deny checks for tags each source cannot reach (default: true)")),
+		mcp.WithBoolean("add", mcp.Description("Append the generated tests to the policy's tests section (default: false)")),
+		mcp.WithString("if_match", mcp.Description("Only add the tests if the policy still has this ETag")),
+	)
+	mcpServer.AddTool(tool, pt.GenerateTests)
+}
+
+func (pt *PolicyTools) GenerateTests(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		IncludeDeny *bool  `json:"include_deny"`
+		Add         bool   `json:"add"`
+		IfMatch     string `json:"if_match"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+	includeDeny := args.IncludeDeny == nil || *args.IncludeDeny
+
+	client := pt.client.GetClient()
+	raw, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+	}
+	acl, grants, err := parsePolicy(raw.HuJSON)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+	}
+	users, err := client.Users().List(ctx, tailscale.PointerTo(tailscale.UserType("all")), nil)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list users: %v", err)), nil
+	}
+	slices.SortFunc(users, func(a, b tailscale.User) int { return strings.Compare(a.LoginName, b.LoginName) })
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+
+	existing := make(map[string]bool)
+	for _, test := range acl.Tests {
+		for _, dst := range slices.Concat(test.Accept, test.Allow) {
+			existing[test.Source+" accept "+dst] = true
+		}
+		for _, dst := range test.Deny {
+			existing[test.Source+" deny "+dst] = true
+		}
+	}
+
+	var order []string
+	tests := make(map[string]*tailscale.ACLTest)
+	skipped := make(map[string]string)
+	record := func(src, verdict, dst string) {
+		if existing[src+" "+verdict+" "+dst] {
+			return
+		}
+		test, ok := tests[src]
+		if !ok {
+			test = &tailscale.ACLTest{Source: src}
+			tests[src] = test
+			order = append(order, src)
+		}
+		if verdict == "accept" && !slices.Contains(test.Accept, dst) {
+			test.Accept = append(test.Accept, dst)
+		}
+		if verdict == "deny" && !slices.Contains(test.Deny, dst) {
+			test.Deny = append(test.Deny, dst)
+		}
+	}
+
+	rules := accessRules(acl, grants)
+	for _, rule := range rules {
+		for _, src := range rule.Src {
+			testSrc, ok := representativeSource(acl, users, src)
+			if !ok {
+				skipped[src] = "no user or tag to test as"
+				continue
+			}
+			for _, dst := range rule.Dst {
+				if dst.Proto != "" && !protoMatches(dst.Proto, "tcp") {
+					skipped[dst.Host] = "tests only cover tcp and udp"
+					continue
+				}
+				if !testableHost(acl, dst.Host) {
+					skipped[dst.Host] = "tests need a tag, host alias, or IP destination"
+					continue
+				}
+				record(testSrc, "accept", fmt.Sprintf("%s:%d", dst.Host, lowestPort(dst.Ports)))
+			}
+		}
+	}
+
+	if includeDeny {
+		for _, src := range slices.Clone(order) {
+			var principals []string
+			var srcUser *tailscale.User
+			for i := range users {
+				if users[i].LoginName == src {
+					srcUser = &users[i]
+					principals = userPrincipals(acl, users[i])
+				}
+			}
+			if srcUser == nil {
+				principals = []string{src}
+			}
+
+			for _, tag := range slices.Sorted(maps.Keys(acl.TagOwners)) {
+				// Probe a real device with the tag so address-based rules
+				// apply; tags on no device are probed by tag alone.
+				target := accessTarget{device: &tailscale.Device{Tags: []string{tag}}}
+				if i := slices.IndexFunc(devices, func(d tailscale.Device) bool { return slices.Contains(d.Tags, tag) }); i >= 0 {
+					target = accessTarget{device: &devices[i], addrs: deviceAddrs(devices[i])}
+				}
+				allowed := slices.ContainsFunc(rules, func(rule accessRule) bool {
+					_, portOK := rule.matchDestination(acl, target, srcUser, denyProbePort, "tcp")
+					return portOK && sourceMatches(rule.Src, principals)
+				})
+				if !allowed && tag != src {
+					record(src, "deny", fmt.Sprintf("%s:%d", tag, denyProbePort))
+				}
+			}
+		}
+	}
+
+	generated := []tailscale.ACLTest{}
+	for _, src := range order {
+		if test := tests[src]; len(test.Accept)+len(test.Deny) > 0 {
+			generated = append(generated, *test)
+		}
+	}
+
+	if !args.Add || len(generated) == 0 {
+		resultJSON, err := json.MarshalIndent(map[string]any{
+			"tests":   generated,
+			"skipped": skipped,
+		}, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal tests: %v", err)), nil
+		}
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	}
+
+	return pt.editPolicy(ctx, args.IfMatch, false, func(sections map[string]json.RawMessage) ([]patchOp, string, error) {
+		key := sectionKey(sections, "tests")
+		summary := fmt.Sprintf("added %d generated tests", len(generated))
+		if _, ok := sections[key]; !ok {
+			return []patchOp{addOp(jsonPointer(key), generated)}, summary, nil
+		}
+		var ops []patchOp
+		for _, test := range generated {
+			ops = append(ops, addOp(jsonPointer(key, "-"), test))
+		}
+		return ops, summary, nil
+	})
+}
+
+// representativeSource picks the identity a generated test runs as for a
+// rule source: tags stand for themselves, and groups, autogroups, and '*'
+// are represented by their first user.
+func representativeSource(acl *tailscale.ACL, users []tailscale.User, src string) (string, bool) {
+	switch {
+	case strings.HasPrefix(src, "tag:"):
+		return src, true
+	case strings.Contains(src, "@"):
+		return src, slices.ContainsFunc(users, func(u tailscale.User) bool { return strings.EqualFold(u.LoginName, src) })
+	}
+	for _, login := range principalUsers(acl, users, src) {
+		if slices.ContainsFunc(users, func(u tailscale.User) bool { return strings.EqualFold(u.LoginName, login) }) {
+			return login, true
+		}
+	}
+	return "", false
+}
+
+// testableHost reports whether a destination host can appear in a test.
+func testableHost(acl *tailscale.ACL, host string) bool {
+	if _, ok := acl.Hosts[host]; ok {
+		return true
+	}
+	_, err := netip.ParseAddr(host)
+	return strings.HasPrefix(host, "tag:") || err == nil
+}
+
+// lowestPort returns the lowest port in a port list, or 443 for '*'.
+func lowestPort(spec string) int {
+	lowest := 0
+	for _, part := range strings.Split(spec, ",") {
+		lo, _, _ := strings.Cut(part, "-")
+		var port int
+		if _, err := fmt.Sscanf(lo, "%d", &port); err == nil && (lowest == 0 || port < lowest) {
+			lowest = port
+		}
+	}
+	if lowest == 0 {
+		return 443
+	}
+	return lowest
+}
@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// postureCondition is one condition of a device posture, in the syntax of
+// the policy file's postures section, e.g. "node:os IN ['macos', 'linux']".
+type postureCondition struct {
+	Source    string
+	Attribute string
+	Op        string
+	Values    []string
+}
+
+var postureConditionRe = regexp.MustCompile(`^\s*(\S+)\s+(==|!=|<=|>=|<|>|NOT IN|IN|NOT IS SET|IS SET)\s*(.*?)\s*$`)
+
+// leadingVersion matches the numeric part of versions such as "1.60.1-t1234".
+var leadingVersion = regexp.MustCompile(`^\d+(\.\d+)*`)
+
+func parsePostureCondition(condition string) (postureCondition, error) {
+	m := postureConditionRe.FindStringSubmatch(condition)
+	if m == nil {
+		return postureCondition{}, fmt.Errorf("cannot parse posture condition %q", condition)
+	}
+	c := postureCondition{Source: condition, Attribute: m[1], Op: m[2]}
+
+	switch c.Op {
+	case "IS SET", "NOT IS SET":
+		if m[3] != "" {
+			return postureCondition{}, fmt.Errorf("posture condition %q: %s takes no value", condition, c.Op)
+		}
+	case "IN", "NOT IN":
+		list, ok := strings.CutPrefix(m[3], "[")
+		list, ok2 := strings.CutSuffix(list, "]")
+		if !ok || !ok2 {
+			return postureCondition{}, fmt.Errorf("posture condition %q: %s needs a list such as ['a', 'b']", condition, c.Op)
+		}
+		for _, v := range strings.Split(list, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				c.Values = append(c.Values, unquotePostureValue(v))
+			}
+		}
+	default:
+		if m[3] == "" {
+			return postureCondition{}, fmt.Errorf("posture condition %q: %s needs a value", condition, c.Op)
+		}
+		c.Values = []string{unquotePostureValue(m[3])}
+	}
+	return c, nil
+}
+
+func unquotePostureValue(v string) string {
+	if len(v) >= 2 && (v[0] == '\'' || v[0] == '"') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// matches reports whether attrs satisfy the condition.
+func (c postureCondition) matches(attrs map[string]any) bool {
+	actual, set := attrs[c.Attribute]
+	switch c.Op {
+	case "IS SET":
+		return set
+	case "NOT IS SET":
+		return !set
+	}
+	if !set {
+		return false
+	}
+
+	switch c.Op {
+	case "IN":
+		return slices.ContainsFunc(c.Values, func(v string) bool { return comparePostureValue(actual, v) == 0 })
+	case "NOT IN":
+		return !slices.ContainsFunc(c.Values, func(v string) bool { return comparePostureValue(actual, v) == 0 })
+	}
+
+	result := comparePostureValue(actual, c.Values[0])
+	switch c.Op {
+	case "==":
+		return result == 0
+	case "!=":
+		return result != 0
+	case "<":
+		return result < 0
+	case "<=":
+		return result <= 0
+	case ">":
+		return result > 0
+	case ">=":
+		return result >= 0
+	}
+	return false
+}
+
+// comparePostureValue compares an attribute value with a condition value:
+// numerically for numbers, component by component for versions, and as
+// strings otherwise.
+func comparePostureValue(actual any, want string) int {
+	if n, ok := actual.(float64); ok {
+		if w, err := strconv.ParseFloat(want, 64); err == nil {
+			return cmp.Compare(n, w)
+		}
+	}
+
+	s := fmt.Sprint(actual)
+	a, w := leadingVersion.FindString(s), leadingVersion.FindString(want)
+	if a != "" && w != "" {
+		as, ws := strings.Split(a, "."), strings.Split(w, ".")
+		for i := range max(len(as), len(ws)) {
+			var x, y int
+			if i < len(as) {
+				x, _ = strconv.Atoi(as[i])
+			}
+			if i < len(ws) {
+				y, _ = strconv.Atoi(ws[i])
+			}
+			if x != y {
+				return cmp.Compare(x, y)
+			}
+		}
+		return 0
+	}
+	return strings.Compare(s, want)
+}
+
+func (at *AdditionalTools) registerPostureTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_posture_compliance_report",
+		readOnlyTool,
+		mcp.WithDescription("Check every device's posture attributes against a set of conditions and report the devices that fail, with the attribute values that caused each failure. Conditions use the policy file's posture syntax, e.g. \"node:os IN ['macos', 'windows']\", \"node:tsVersion >= '1.60'\", \"falcon:ztaScore >= 50\", or \"intune:complianceState IS SET\"; alternatively name a posture from the policy's postures section. Use it for audits such as finding devices without EDR or below a minimum OS version. Requires one API call per device. OAuth Scope: devices:posture_attributes:read, devices:read (acl:read with posture)."),
+		mcp.WithArray("conditions", mcp.Description("Posture conditions every device should satisfy"), mcp.WithStringItems()),
+		mcp.WithString("posture", mcp.Description("Name of a posture in the policy file to check instead, e.g. 'posture:latestMac'")),
+		mcp.WithString("tag", mcp.Description("Only check devices with this tag")),
+		mcp.WithBoolean("include_compliant", mcp.Description("Also list the devices that satisfy every condition (default: false)")),
+	)
+	mcpServer.AddTool(tool, at.PostureComplianceReport)
+}
+
+func (at *AdditionalTools) PostureComplianceReport(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Conditions       []string `json:"conditions"`
+		Posture          string   `json:"posture"`
+		Tag              string   `json:"tag"`
+		IncludeCompliant bool     `json:"include_compliant"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	client := at.client.GetClient()
+	if args.Posture != "" {
+		raw, err := client.PolicyFile().Raw(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy: %v", err)), nil
+		}
+		acl, _, err := parsePolicy(raw.HuJSON)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to parse policy: %v", err)), nil
+		}
+		conditions, ok := acl.Postures[args.Posture]
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("The policy defines no posture named %s", args.Posture)), nil
+		}
+		args.Conditions = append(args.Conditions, conditions...)
+	}
+	if len(args.Conditions) == 0 {
+		return mcp.NewToolResultError("Give conditions or a posture to check"), nil
+	}
+
+	var conditions []postureCondition
+	for _, raw := range args.Conditions {
+		c, err := parsePostureCondition(raw)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		conditions = append(conditions, c)
+	}
+
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+
+	type failure struct {
+		Condition string `json:"condition"`
+		Actual    any    `json:"actual"`
+	}
+	type deviceResult struct {
+		Name     string    `json:"name"`
+		NodeID   string    `json:"node_id"`
+		User     string    `json:"user"`
+		OS       string    `json:"os"`
+		Failures []failure `json:"failures,omitempty"`
+	}
+	report := struct {
+		Conditions     []string          `json:"conditions"`
+		Checked        int               `json:"checked"`
+		CompliantCount int               `json:"compliant_count"`
+		NonCompliant   []deviceResult    `json:"non_compliant"`
+		Compliant      []deviceResult    `json:"compliant,omitempty"`
+		Errors         map[string]string `json:"errors,omitempty"`
+	}{Conditions: args.Conditions, NonCompliant: []deviceResult{}, Errors: map[string]string{}}
+
+	for _, device := range devices {
+		if args.Tag != "" && !slices.Contains(device.Tags, args.Tag) {
+			continue
+		}
+		posture, err := client.Devices().GetPostureAttributes(ctx, device.NodeID)
+		if err != nil {
+			report.Errors[device.Name] = err.Error()
+			continue
+		}
+		report.Checked++
+
+		result := deviceResult{Name: device.Name, NodeID: device.NodeID, User: device.User, OS: device.OS}
+		for _, c := range conditions {
+			if !c.matches(posture.Attributes) {
+				result.Failures = append(result.Failures, failure{Condition: c.Source, Actual: posture.Attributes[c.Attribute]})
+			}
+		}
+		if len(result.Failures) > 0 {
+			report.NonCompliant = append(report.NonCompliant, result)
+			continue
+		}
+		report.CompliantCount++
+		if args.IncludeCompliant {
+			report.Compliant = append(report.Compliant, result)
+		}
+	}
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal compliance report: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(reportJSON)), nil
+}
@@ -0,0 +1,360 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// Prompts are guided workflows that gather the tailnet state a task needs
+// and tell the model which tools to use, in which order.
+type Prompts struct {
+	client *client.TailscaleClient
+	config *config.Config
+}
+
+func NewPrompts(client *client.TailscaleClient, cfg *config.Config) *Prompts {
+	return &Prompts{client: client, config: cfg}
+}
+
+func (p *Prompts) RegisterPrompts(mcpServer *server.MCPServer) {
+	prompt := mcp.NewPrompt(
+		"tailscale_security_review",
+		mcp.WithPromptDescription("Review the tailnet's security: loads devices, keys, tailnet settings, and the policy file, and walks through unauthorized and stale devices, key hygiene, risky settings, and overly broad access rules."),
+		mcp.WithArgument("stale_days", mcp.ArgumentDescription("Devices not seen for this many days count as stale (default: 30)")),
+	)
+	mcpServer.AddPrompt(prompt, p.SecurityReview)
+
+	prompt = mcp.NewPrompt(
+		"tailscale_device_onboarding",
+		mcp.WithPromptDescription("Onboard a new device following the standard runbook: create a suitable auth key, tell the user how to join, wait for the device to appear, then tag it and enable its subnet routes."),
+		mcp.WithArgument("hostname", mcp.ArgumentDescription("Hostname the new device will join with"), mcp.RequiredArgument()),
+		mcp.WithArgument("tags", mcp.ArgumentDescription("Comma-separated tags for the device, e.g. 'tag:server,tag:prod'")),
+		mcp.WithArgument("routes", mcp.ArgumentDescription("Comma-separated subnet routes the device will advertise, e.g. '10.0.0.0/24'")),
+		mcp.WithArgument("os", mcp.ArgumentDescription("Operating system of the device: linux, macos, windows, ios, or android (default: linux)")),
+	)
+	mcpServer.AddPrompt(prompt, p.DeviceOnboarding)
+
+	prompt = mcp.NewPrompt(
+		"tailscale_policy_change",
+		mcp.WithPromptDescription("Draft and stage a policy file change: loads the current policy with its groups, tags, and hosts, and walks through drafting, linting, testing, and reviewing the diff before staging it for approval."),
+		mcp.WithArgument("change", mcp.ArgumentDescription("The access change wanted, in plain words, e.g. 'let group:eng reach tag:db on 5432'"), mcp.RequiredArgument()),
+	)
+	mcpServer.AddPrompt(prompt, p.PolicyChange)
+}
+
+// jsonMessage embeds v as a JSON resource in a user message.
+func jsonMessage(uri string, v any) (mcp.PromptMessage, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return mcp.PromptMessage{}, err
+	}
+	return mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+		URI:      uri,
+		MIMEType: "application/json",
+		Text:     string(data),
+	})), nil
+}
+
+const securityReviewInstructions = `Perform a security review of this Tailscale tailnet using the attached devices, keys, tailnet settings, and policy file. Work through each area below, and for every finding give its severity (high, medium, low), the affected devices, keys, or rules, and the tool call that would fix it.
+
+1. Devices
+   - Devices that are not authorized, or that are waiting for approval.
+   - Devices not seen for more than %d days: candidates for removal with tailscale_device_delete.
+   - Personal (untagged) devices with key expiry disabled, and devices whose keys have already expired.
+   - Devices with an update available or an old client version.
+2. Keys
+   - Reusable or preauthorized auth keys, keys without tags, and keys that never or only distantly expire.
+   - Keys that have expired or were revoked but are still listed, and API tokens older than 90 days.
+3. Tailnet settings
+   - Device approval or user approval turned off.
+   - A long device key duration, and network flow logging turned off.
+4. Policy
+   - Rules granting access to "*" or "*:*", or letting autogroup:member reach everything.
+   - SSH rules with action "accept" to root or to tagged servers from broad groups, and missing checkPeriod.
+   - tagOwners that let broad groups assign server tags, autoApprovers for exit nodes or large routes, and missing tests.
+
+Summarize the most important findings first. Do not change anything: propose the fixes and wait for confirmation before calling any tool that modifies the tailnet.`
+
+func (p *Prompts) SecurityReview(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	staleDays := 30
+	if raw := request.Params.Arguments["stale_days"]; raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days <= 0 {
+			return nil, fmt.Errorf("invalid stale_days %q", raw)
+		}
+		staleDays = days
+	}
+
+	client := p.client.GetClient()
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	keys, err := p.client.ListKeysWithDetails(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list keys: %w", err)
+	}
+	for i := range keys {
+		keys[i].Key = ""
+	}
+	settings, err := client.TailnetSettings().Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tailnet settings: %w", err)
+	}
+	policy, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+
+	type deviceSummary struct {
+		Name              string    `json:"name"`
+		NodeID            string    `json:"node_id"`
+		User              string    `json:"user"`
+		OS                string    `json:"os"`
+		ClientVersion     string    `json:"client_version"`
+		UpdateAvailable   bool      `json:"update_available"`
+		Authorized        bool      `json:"authorized"`
+		Tags              []string  `json:"tags,omitempty"`
+		KeyExpiryDisabled bool      `json:"key_expiry_disabled"`
+		Expires           time.Time `json:"expires"`
+		LastSeen          time.Time `json:"last_seen"`
+	}
+	summaries := make([]deviceSummary, 0, len(devices))
+	for _, device := range devices {
+		summaries = append(summaries, deviceSummary{
+			Name:              device.Name,
+			NodeID:            device.NodeID,
+			User:              device.User,
+			OS:                device.OS,
+			ClientVersion:     device.ClientVersion,
+			UpdateAvailable:   device.UpdateAvailable,
+			Authorized:        device.Authorized,
+			Tags:              device.Tags,
+			KeyExpiryDisabled: device.KeyExpiryDisabled,
+			Expires:           device.Expires.Time,
+			LastSeen:          device.LastSeen.Time,
+		})
+	}
+
+	messages := []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(fmt.Sprintf(securityReviewInstructions, staleDays))),
+	}
+	for _, attachment := range []struct {
+		uri   string
+		value any
+	}{
+		{"tailscale://review/devices", summaries},
+		{"tailscale://review/keys", keys},
+		{"tailscale://review/settings", settings},
+	} {
+		message, err := jsonMessage(attachment.uri, attachment.value)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	messages = append(messages, mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+		URI:      "tailscale://review/policy.hujson",
+		MIMEType: "application/hujson",
+		Text:     policy.HuJSON,
+	})))
+
+	return mcp.NewGetPromptResult(fmt.Sprintf("Security review of %d devices and %d keys", len(devices), len(keys)), messages), nil
+}
+
+const deviceOnboardingInstructions = `Onboard a new %[2]s device named %[1]q into this tailnet, following these steps in order. Report progress after each step and stop if one fails.
+
+1. Create an auth key with tailscale_key_create. %[3]s Use a one-off (not reusable) key that expires within a day, with description "onboarding %[1]s".%[4]s
+2. Tell the user how to join: %[5]s
+   Ask them to say when the command has finished.
+3. Wait for the device: call tailscale_devices_list and look for hostname %[1]q. If it is not there yet, ask the user to check the command's output and try again; do not create another key unless the first one has expired.
+4. If the device shows authorized: false, authorize it with tailscale_device_authorize after confirming with the user.
+5. Check its tags with tailscale_device_get.%[6]s
+6. %[7]s
+7. Finish with a summary: device name, node ID, Tailscale IPs, tags, and enabled routes. Remind the user that the auth key has been used and will expire on its own.
+
+The attached policy excerpt shows which tags can be assigned and which routes are approved automatically; attached key templates, if any, are the approved key shapes.`
+
+// onboardingJoinCommands tells the user how to join from each OS.
+var onboardingJoinCommands = map[string]string{
+	"linux":   "install Tailscale with 'curl -fsSL https://tailscale.com/install.sh | sh', then run 'sudo tailscale up --authkey=<key> --hostname=%s%s'.",
+	"macos":   "install Tailscale from the Mac App Store or pkgs.tailscale.com, then run 'tailscale up --authkey=<key> --hostname=%s%s' from the CLI.",
+	"windows": "install Tailscale from https://tailscale.com/download/windows, then run 'tailscale up --authkey=<key> --hostname=%s%s' from an administrator prompt.",
+	"ios":     "install Tailscale from the App Store and sign in; auth keys cannot be used on iOS, so approve or tag it afterwards (hostname %s%s).",
+	"android": "install Tailscale from Google Play and sign in; auth keys cannot be used on Android, so approve or tag it afterwards (hostname %s%s).",
+}
+
+// splitList splits a comma-separated prompt argument.
+func splitList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (p *Prompts) DeviceOnboarding(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	hostname := request.Params.Arguments["hostname"]
+	if hostname == "" {
+		return nil, fmt.Errorf("hostname is required")
+	}
+	tags := splitList(request.Params.Arguments["tags"])
+	routes := splitList(request.Params.Arguments["routes"])
+	os := request.Params.Arguments["os"]
+	if os == "" {
+		os = "linux"
+	}
+	joinCommand, ok := onboardingJoinCommands[os]
+	if !ok {
+		return nil, fmt.Errorf("unsupported os %q", os)
+	}
+
+	keyStep := "Give it no tags, since the device will be tied to the user who created the key."
+	tagStep := " It needs no tags."
+	if len(tags) > 0 {
+		keyStep = fmt.Sprintf("Give it the tags %s, so the device is owned by the tags rather than the key's creator.", strings.Join(tags, ", "))
+		tagStep = fmt.Sprintf(" If they are not exactly %s, set them with tailscale_device_set_tags.", strings.Join(tags, ", "))
+	}
+	templateNote := ""
+	if len(p.config.KeyTemplates) > 0 {
+		templateNote = " Prefer a matching key template from the attached list, passed as template."
+	}
+	if p.config.RequireKeyTemplate {
+		templateNote = " This server only creates keys from key templates: pick the matching template from the attached list."
+	}
+
+	upFlags := ""
+	routeStep := "The device advertises no subnet routes; skip this step."
+	if len(routes) > 0 {
+		upFlags = " --advertise-routes=" + strings.Join(routes, ",")
+		routeStep = fmt.Sprintf("List the device's routes with tailscale_device_routes_list. Routes %s should be advertised; enable the ones not already approved with tailscale_device_routes_set, after confirming with the user.", strings.Join(routes, ", "))
+	}
+
+	instructions := fmt.Sprintf(deviceOnboardingInstructions,
+		hostname, os, keyStep, templateNote, fmt.Sprintf(joinCommand, hostname, upFlags), tagStep, routeStep)
+	messages := []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(instructions)),
+	}
+
+	raw, err := p.client.GetClient().PolicyFile().Raw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+	acl, _, err := parsePolicy(raw.HuJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	message, err := jsonMessage("tailscale://onboarding/policy", map[string]any{
+		"tagOwners":     acl.TagOwners,
+		"autoApprovers": acl.AutoApprovers,
+	})
+	if err != nil {
+		return nil, err
+	}
+	messages = append(messages, message)
+
+	if len(p.config.KeyTemplates) > 0 {
+		message, err := jsonMessage("tailscale://onboarding/key-templates", p.config.KeyTemplates)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return mcp.NewGetPromptResult(fmt.Sprintf("Onboarding %s", hostname), messages), nil
+}
+
+const policyChangeInstructions = `Make this change to the tailnet policy file: %q
+
+The current policy, its groups, tag owners, and hosts, and the tags that devices carry are attached. Follow these steps in order and do not skip any:
+
+1. Draft: work out the smallest edit that makes the change, reusing existing groups, tags, and hosts where possible. If the change needs a new tag, it also needs a tagOwners entry. Ask the user about anything ambiguous before going further. Write the full new policy as HuJSON, keeping the existing comments and formatting.
+2. Add tests: add entries to the tests section that check the new access is allowed and that nearby access which should stay denied is still denied. tailscale_policy_tests_generate can suggest tests for the existing rules.
+3. Lint: run tailscale_policy_lint with the draft and fix any findings the change introduced.
+4. Test: run tailscale_policy_test with the draft. Fix the draft until every test passes; never remove an existing test to make it pass without asking the user.
+5. Stage: call tailscale_policy_stage with the draft and a note describing the change, and show the user the returned diff and change ID.
+6. Apply: only when the user approves the diff, call tailscale_policy_apply_staged with the change ID (and the approval token, if the server requires one). If it reports that the policy changed since staging, start again from step 1 with tailscale_policy_get.
+
+Do not use tailscale_policy_set or the single-rule edit tools for this change: they apply immediately and skip the review.`
+
+// policyNames returns the sections of a policy that define names rules
+// refer to: groups, tagOwners, and hosts.
+func policyNames(sections map[string]json.RawMessage) map[string]json.RawMessage {
+	names := map[string]json.RawMessage{}
+	for _, section := range []string{"groups", "tagOwners", "hosts"} {
+		if raw, ok := sections[section]; ok {
+			names[section] = raw
+		}
+	}
+	return names
+}
+
+// deviceTagCounts returns how many devices carry each tag.
+func deviceTagCounts(devices []tailscale.Device) map[string]int {
+	counts := make(map[string]int)
+	for _, device := range devices {
+		for _, tag := range device.Tags {
+			counts[tag]++
+		}
+	}
+	return counts
+}
+
+func (p *Prompts) PolicyChange(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	change := request.Params.Arguments["change"]
+	if change == "" {
+		return nil, fmt.Errorf("change is required")
+	}
+
+	client := p.client.GetClient()
+	policy, err := client.PolicyFile().Raw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get policy: %w", err)
+	}
+	sections, err := parsePolicySections(policy.HuJSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+
+	names, deviceTags := policyNames(sections), deviceTagCounts(devices)
+
+	messages := []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(fmt.Sprintf(policyChangeInstructions, change))),
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+			URI:      "tailscale://policy-change/policy.hujson",
+			MIMEType: "application/hujson",
+			Text:     policy.HuJSON,
+		})),
+	}
+	for _, attachment := range []struct {
+		uri   string
+		value any
+	}{
+		{"tailscale://policy-change/names", names},
+		{"tailscale://policy-change/device-tags", deviceTags},
+	} {
+		message, err := jsonMessage(attachment.uri, attachment.value)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+
+	return mcp.NewGetPromptResult(fmt.Sprintf("Policy change: %s", change), messages), nil
+}
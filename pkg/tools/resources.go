@@ -0,0 +1,200 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+)
+
+// URIs of the resources that are not templates. Refresh notifies clients
+// when their content changes.
+const (
+	settingsURI = "tailscale://settings"
+	dnsURI      = "tailscale://dns"
+)
+
+// Resources exposes tailnet objects as MCP resources, so clients can pull
+// them into context by URI without spending a tool call.
+type Resources struct {
+	client *client.TailscaleClient
+
+	mu sync.Mutex
+	// contents holds the last content read of each static resource.
+	contents map[string]string
+}
+
+func NewResources(client *client.TailscaleClient) *Resources {
+	return &Resources{client: client, contents: make(map[string]string)}
+}
+
+func (r *Resources) RegisterResources(mcpServer *server.MCPServer) {
+	resource := mcp.NewResource(
+		settingsURI,
+		"Tailnet settings",
+		mcp.WithResourceDescription("The tailnet's settings: device and user approval, key duration, auto-updates, network flow logging, regional routing, and posture identity collection. Clients are sent notifications/resources/updated when they change."),
+		mcp.WithMIMEType("application/json"),
+	)
+	mcpServer.AddResource(resource, r.readStatic)
+
+	resource = mcp.NewResource(
+		dnsURI,
+		"Tailnet DNS configuration",
+		mcp.WithResourceDescription("The tailnet's DNS configuration: global nameservers, split DNS, search paths, and MagicDNS and override preferences. Clients are sent notifications/resources/updated when it changes."),
+		mcp.WithMIMEType("application/json"),
+	)
+	mcpServer.AddResource(resource, r.readStatic)
+
+	template := mcp.NewResourceTemplate(
+		"tailscale://users/{id}",
+		"Tailscale user",
+		mcp.WithTemplateDescription("A tailnet user: login and display name, role, status, device count, and when they were created and last seen."),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	mcpServer.AddResourceTemplate(template, r.ReadUser)
+
+	template = mcp.NewResourceTemplate(
+		"tailscale://keys/{id}",
+		"Tailscale key",
+		mcp.WithTemplateDescription("An auth key, API access token, or OAuth client: its capabilities, tags, and creation, expiry, and revocation times. The secret itself is never included."),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	mcpServer.AddResourceTemplate(template, r.ReadKey)
+
+	template = mcp.NewResourceTemplate(
+		"tailscale://webhooks/{id}",
+		"Tailscale webhook",
+		mcp.WithTemplateDescription("A webhook endpoint: its URL, provider type, subscribed event types, and creator."),
+		mcp.WithTemplateMIMEType("application/json"),
+	)
+	mcpServer.AddResourceTemplate(template, r.ReadWebhook)
+}
+
+// templateArg returns the value a URI template variable matched.
+func templateArg(request mcp.ReadResourceRequest, name string) (string, error) {
+	switch v := request.Params.Arguments[name].(type) {
+	case string:
+		if v != "" {
+			return v, nil
+		}
+	case []string:
+		if len(v) == 1 && v[0] != "" {
+			return v[0], nil
+		}
+	}
+	return "", fmt.Errorf("resource URI %s has no %s", request.Params.URI, name)
+}
+
+// jsonResource returns v as the JSON contents of the resource at uri.
+func jsonResource(uri string, v any) ([]mcp.ResourceContents, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: uri, MIMEType: "application/json", Text: string(data)},
+	}, nil
+}
+
+// staticContent returns the current content of the static resource at uri.
+func (r *Resources) staticContent(ctx context.Context, uri string) (string, error) {
+	var v any
+	switch uri {
+	case settingsURI:
+		settings, err := r.client.GetClient().TailnetSettings().Get(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to get tailnet settings: %w", err)
+		}
+		v = settings
+	case dnsURI:
+		var config map[string]json.RawMessage
+		if err := r.client.Do(ctx, http.MethodGet, r.client.TailnetPath("dns", "configuration"), nil, &config); err != nil {
+			return "", fmt.Errorf("failed to get DNS configuration: %w", err)
+		}
+		v = config
+	default:
+		return "", fmt.Errorf("unknown resource %s", uri)
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (r *Resources) readStatic(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	content, err := r.staticContent(ctx, request.Params.URI)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.contents[request.Params.URI] = content
+	r.mu.Unlock()
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: request.Params.URI, MIMEType: "application/json", Text: content},
+	}, nil
+}
+
+// Refresh reads the static resources again and sends
+// notifications/resources/updated for each whose content changed since it
+// was last read.
+func (r *Resources) Refresh(ctx context.Context, mcpServer *server.MCPServer) error {
+	for _, uri := range []string{settingsURI, dnsURI} {
+		content, err := r.staticContent(ctx, uri)
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		previous, seen := r.contents[uri]
+		r.contents[uri] = content
+		r.mu.Unlock()
+		if seen && previous != content {
+			mcpServer.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+		}
+	}
+	return nil
+}
+
+func (r *Resources) ReadUser(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id, err := templateArg(request, "id")
+	if err != nil {
+		return nil, err
+	}
+	user, err := r.client.GetClient().Users().Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return jsonResource(request.Params.URI, user)
+}
+
+func (r *Resources) ReadKey(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id, err := templateArg(request, "id")
+	if err != nil {
+		return nil, err
+	}
+	key, err := r.client.GetClient().Keys().Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key: %w", err)
+	}
+	// Only a newly created key carries its secret, but never expose one.
+	key.Key = ""
+	return jsonResource(request.Params.URI, key)
+}
+
+func (r *Resources) ReadWebhook(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id, err := templateArg(request, "id")
+	if err != nil {
+		return nil, err
+	}
+	webhook, err := r.client.GetClient().Webhooks().Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	webhook.Secret = nil
+	return jsonResource(request.Params.URI, webhook)
+}
@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// listOutput is the structured content of list tools; MCP requires
+// structured content to be an object, so the list is wrapped. Paginated
+// tools return a page of Total items, and NextCursor while more remain.
+type listOutput[T any] struct {
+	Items      []T    `json:"items"`
+	Count      int    `json:"count"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+func newListOutput[T any](items []T) listOutput[T] {
+	if items == nil {
+		items = []T{}
+	}
+	return listOutput[T]{Items: items, Count: len(items), Total: len(items)}
+}
+
+// outputSchema declares that a tool returns structured content encoded from
+// T. The schema is derived from T's JSON encoding rather than with mcp-go's
+// generator, which describes tailscale.Time as an object and does not allow
+// the nulls that nil slices, maps, and pointers encode to.
+func outputSchema[T any]() mcp.ToolOption {
+	schema := jsonSchema(reflect.TypeFor[T](), map[reflect.Type]bool{})
+	// The top level is always an object, never null.
+	schema["type"] = "object"
+	data, err := json.Marshal(schema)
+	if err != nil {
+		panic(err)
+	}
+	return mcp.WithRawOutputSchema(data)
+}
+
+var (
+	timeType        = reflect.TypeFor[time.Time]()
+	tailscaleTime   = reflect.TypeFor[tailscale.Time]()
+	rawMessageType  = reflect.TypeFor[json.RawMessage]()
+	marshalerType   = reflect.TypeFor[json.Marshaler]()
+	textMarshalType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// jsonSchema returns the JSON schema of t's encoding. seen guards against
+// recursive types, which are described as any value.
+func jsonSchema(t reflect.Type, seen map[reflect.Type]bool) map[string]any {
+	switch {
+	case t == timeType || t == tailscaleTime:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		return map[string]any{}
+	case t.Implements(textMarshalType) || reflect.PointerTo(t).Implements(textMarshalType):
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return nullable(jsonSchema(t.Elem(), seen))
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": []string{"string", "null"}}
+		}
+		return map[string]any{"type": []string{"array", "null"}, "items": jsonSchema(t.Elem(), seen)}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": jsonSchema(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			return map[string]any{}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := map[string]any{}
+		var required []string
+		addFields(t, seen, properties, &required)
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	return map[string]any{}
+}
+
+// addFields adds the JSON properties of struct t, including those of
+// embedded structs, which encoding/json inlines.
+func addFields(t reflect.Type, seen map[reflect.Type]bool, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded, embeddedRequired := field.Type, required
+			if embedded.Kind() == reflect.Pointer {
+				// A nil embedded pointer leaves all of its fields out.
+				embedded, embeddedRequired = embedded.Elem(), new([]string)
+			}
+			if embedded.Kind() == reflect.Struct {
+				addFields(embedded, seen, properties, embeddedRequired)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := jsonSchema(field.Type, seen)
+		omitted := false
+		for _, option := range strings.Split(options, ",") {
+			switch option {
+			case "string":
+				schema = map[string]any{"type": "string"}
+			case "omitempty", "omitzero":
+				omitted = true
+			}
+		}
+		properties[name] = schema
+		if !omitted {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// nullable allows null in addition to what schema allows.
+func nullable(schema map[string]any) map[string]any {
+	if kind, ok := schema["type"].(string); ok {
+		schema["type"] = []string{kind, "null"}
+	}
+	return schema
+}
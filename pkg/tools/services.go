@@ -0,0 +1,280 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+)
+
+// VIPService is a Tailscale Service: a named virtual IP address, with a
+// stable MagicDNS name, that is served by the tagged devices advertising it.
+type VIPService struct {
+	Name        string            `json:"name"`
+	Addrs       []string          `json:"addrs,omitempty"`
+	Comment     string            `json:"comment,omitempty"`
+	Ports       []string          `json:"ports,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ServiceTools struct {
+	client *client.TailscaleClient
+}
+
+func NewServiceTools(client *client.TailscaleClient) *ServiceTools {
+	return &ServiceTools{client: client}
+}
+
+func (st *ServiceTools) RegisterTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_services_list",
+		readOnlyTool,
+		outputSchema[listOutput[VIPService]](),
+		withPagination,
+		mcp.WithDescription("List the tailnet's Tailscale Services (VIP services). Each service has a name such as 'svc:web', the virtual IP addresses it is reachable on, the ports it serves, and the tags of the devices allowed to host it. OAuth Scope: services:read."),
+	)
+	mcpServer.AddTool(tool, st.ListServices)
+
+	tool = mcp.NewTool(
+		"tailscale_service_get",
+		readOnlyTool,
+		outputSchema[VIPService](),
+		mcp.WithDescription("Get a Tailscale Service (VIP service) by name, including its addresses, ports, host tags, and annotations. OAuth Scope: services:read."),
+		mcp.WithString("name", mcp.Description("Service name, e.g. 'svc:web' (the 'svc:' prefix may be omitted)"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, st.GetService)
+
+	tool = mcp.NewTool(
+		"tailscale_service_create",
+		createTool,
+		mcp.WithDescription("Create a Tailscale Service (VIP service). Tailscale allocates its virtual IP addresses unless addrs is given. Devices with one of the service's tags can then advertise it with 'tailscale serve --service'. Fails if a service with the name already exists. OAuth Scope: services:write."),
+		mcp.WithString("name", mcp.Description("Service name, e.g. 'svc:web' (the 'svc:' prefix may be omitted)"), mcp.Required()),
+		mcp.WithArray("ports", mcp.Description("Ports the service serves, e.g. ['tcp:443', 'tcp:80']"), mcp.WithStringItems(), mcp.Required()),
+		mcp.WithArray("tags", mcp.Description("Tags of the devices allowed to host the service, e.g. ['tag:web']"), mcp.WithStringItems()),
+		mcp.WithArray("addrs", mcp.Description("Virtual IPv4 and IPv6 addresses (default: allocated by Tailscale)"), mcp.WithStringItems()),
+		mcp.WithString("comment", mcp.Description("Description of the service")),
+		mcp.WithObject("annotations", mcp.Description("Key/value annotations to attach to the service")),
+	)
+	mcpServer.AddTool(tool, st.CreateService)
+
+	tool = mcp.NewTool(
+		"tailscale_service_update",
+		updateTool,
+		mcp.WithDescription("Update a Tailscale Service (VIP service). Only the fields given are changed; lists and annotations given replace the existing ones. Changing tags changes which devices may host the service. OAuth Scope: services:write."),
+		mcp.WithString("name", mcp.Description("Service name, e.g. 'svc:web' (the 'svc:' prefix may be omitted)"), mcp.Required()),
+		mcp.WithArray("ports", mcp.Description("Ports the service serves, e.g. ['tcp:443']"), mcp.WithStringItems()),
+		mcp.WithArray("tags", mcp.Description("Tags of the devices allowed to host the service"), mcp.WithStringItems()),
+		mcp.WithArray("addrs", mcp.Description("Virtual IPv4 and IPv6 addresses"), mcp.WithStringItems()),
+		mcp.WithString("comment", mcp.Description("Description of the service")),
+		mcp.WithObject("annotations", mcp.Description("Key/value annotations to attach to the service")),
+	)
+	mcpServer.AddTool(tool, st.UpdateService)
+
+	tool = mcp.NewTool(
+		"tailscale_service_delete",
+		destructiveTool,
+		mcp.WithDescription("Delete a Tailscale Service (VIP service). Clients can no longer reach it at its virtual IP addresses or MagicDNS name, and the addresses may be reallocated. OAuth Scope: services:write."),
+		mcp.WithString("name", mcp.Description("Service name, e.g. 'svc:web' (the 'svc:' prefix may be omitted)"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, st.DeleteService)
+}
+
+// serviceName adds the "svc:" prefix to a bare service name.
+func serviceName(name string) string {
+	if strings.HasPrefix(name, "svc:") {
+		return name
+	}
+	return "svc:" + name
+}
+
+func (st *ServiceTools) getService(ctx context.Context, name string) (*VIPService, error) {
+	var service VIPService
+	if err := st.client.Do(ctx, http.MethodGet, st.client.TailnetPath("vip-services", name), nil, &service); err != nil {
+		return nil, err
+	}
+	return &service, nil
+}
+
+func (st *ServiceTools) ListServices(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		pageArgs
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	if args.Cursor != "" {
+		return nextPage[VIPService](request.Params.Name, args.pageArgs, "services")
+	}
+
+	var resp struct {
+		VIPServices []VIPService `json:"vipServices"`
+	}
+	if err := st.client.Do(ctx, http.MethodGet, st.client.TailnetPath("vip-services"), nil, &resp); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list services: %v", err)), nil
+	}
+	if resp.VIPServices == nil {
+		resp.VIPServices = []VIPService{}
+	}
+
+	return firstPage(request.Params.Name, resp.VIPServices, args.pageArgs, "services")
+}
+
+func (st *ServiceTools) GetService(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Name string `json:"name"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	service, err := st.getService(ctx, serviceName(args.Name))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get service: %v", err)), nil
+	}
+
+	serviceJSON, err := json.MarshalIndent(service, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal service: %v", err)), nil
+	}
+
+	return mcp.NewToolResultStructured(service, string(serviceJSON)), nil
+}
+
+func (st *ServiceTools) CreateService(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Name        string            `json:"name"`
+		Ports       []string          `json:"ports"`
+		Tags        []string          `json:"tags"`
+		Addrs       []string          `json:"addrs"`
+		Comment     string            `json:"comment"`
+		Annotations map[string]string `json:"annotations"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if len(args.Ports) == 0 {
+		return mcp.NewToolResultError("At least one port is required, e.g. 'tcp:443'"), nil
+	}
+
+	// The API creates and updates services with the same PUT, so check first
+	// to avoid silently overwriting an existing service.
+	name := serviceName(args.Name)
+	_, err := st.getService(ctx, name)
+	var apiErr *client.APIError
+	switch {
+	case err == nil:
+		return mcp.NewToolResultError(fmt.Sprintf("Service %s already exists; use tailscale_service_update to change it", name)), nil
+	case !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound:
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to check for an existing service: %v", err)), nil
+	}
+
+	service := VIPService{
+		Name:        name,
+		Addrs:       args.Addrs,
+		Comment:     args.Comment,
+		Ports:       args.Ports,
+		Tags:        args.Tags,
+		Annotations: args.Annotations,
+	}
+	var created VIPService
+	if err := st.client.Do(ctx, http.MethodPut, st.client.TailnetPath("vip-services", name), service, &created); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to create service: %v", err)), nil
+	}
+	if created.Name == "" {
+		created = service
+	}
+
+	serviceJSON, err := json.MarshalIndent(created, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal service: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(serviceJSON)), nil
+}
+
+func (st *ServiceTools) UpdateService(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Name        string            `json:"name"`
+		Ports       []string          `json:"ports"`
+		Tags        []string          `json:"tags"`
+		Addrs       []string          `json:"addrs"`
+		Comment     *string           `json:"comment"`
+		Annotations map[string]string `json:"annotations"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if args.Ports == nil && args.Tags == nil && args.Addrs == nil && args.Comment == nil && args.Annotations == nil {
+		return mcp.NewToolResultError("At least one of ports, tags, addrs, comment, or annotations is required"), nil
+	}
+
+	name := serviceName(args.Name)
+	service, err := st.getService(ctx, name)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get service: %v", err)), nil
+	}
+	if args.Ports != nil {
+		if len(args.Ports) == 0 {
+			return mcp.NewToolResultError("A service needs at least one port"), nil
+		}
+		service.Ports = args.Ports
+	}
+	if args.Tags != nil {
+		service.Tags = args.Tags
+	}
+	if args.Addrs != nil {
+		service.Addrs = args.Addrs
+	}
+	if args.Comment != nil {
+		service.Comment = *args.Comment
+	}
+	if args.Annotations != nil {
+		service.Annotations = args.Annotations
+	}
+
+	var updated VIPService
+	if err := st.client.Do(ctx, http.MethodPut, st.client.TailnetPath("vip-services", name), service, &updated); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to update service: %v", err)), nil
+	}
+	if updated.Name == "" {
+		updated = *service
+	}
+
+	serviceJSON, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal service: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(serviceJSON)), nil
+}
+
+func (st *ServiceTools) DeleteService(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Name string `json:"name"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	name := serviceName(args.Name)
+	if err := st.client.Do(ctx, http.MethodDelete, st.client.TailnetPath("vip-services", name), nil, nil); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to delete service: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Service %s deleted successfully", name)), nil
+}
@@ -0,0 +1,320 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// settingsSnapshot is a saved copy of the tailnet settings.
+type settingsSnapshot struct {
+	TakenAt  time.Time                 `json:"taken_at"`
+	Settings tailscale.TailnetSettings `json:"settings"`
+}
+
+func (at *AdditionalTools) registerSettingsTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_tailnet_settings_snapshot",
+		readOnlyTool,
+		mcp.WithDescription("Export the full tailnet settings as a JSON snapshot. Keep the snapshot before experimenting with risky settings, such as turning on device approval, and re-apply it with tailscale_tailnet_settings_restore to revert. OAuth Scope: settings:read."),
+	)
+	mcpServer.AddTool(tool, at.SnapshotTailnetSettings)
+
+	tool = mcp.NewTool(
+		"tailscale_tailnet_settings_restore",
+		destructiveTool,
+		mcp.WithDescription("Re-apply a snapshot produced by tailscale_tailnet_settings_snapshot. Only settings that differ from the live tailnet are written, so restoring an unchanged snapshot is a no-op. Use dry_run to see the before/after diff first. OAuth Scope: settings:write."),
+		mcp.WithString("snapshot", mcp.Description("JSON snapshot from tailscale_tailnet_settings_snapshot"), mcp.Required()),
+		mcp.WithBoolean("dry_run", mcp.Description("Return a diff of what would change without applying it")),
+	)
+	mcpServer.AddTool(tool, at.RestoreTailnetSettings)
+
+	tool = mcp.NewTool(
+		"tailscale_setting_device_approval_set",
+		updateTool,
+		mcp.WithDescription("Turn device approval on or off. With it on, devices added to the tailnet cannot communicate until an admin approves them. Without confirm, reports the change and the devices currently awaiting approval, and changes nothing. OAuth Scope: settings:write, devices:read."),
+		mcp.WithBoolean("enabled", mcp.Description("Whether new devices need approval"), mcp.Required()),
+		mcp.WithBoolean("confirm", mcp.Description("Apply the change instead of previewing it (default: false)")),
+	)
+	mcpServer.AddTool(tool, at.SetDeviceApproval)
+
+	tool = mcp.NewTool(
+		"tailscale_setting_user_approval_set",
+		updateTool,
+		mcp.WithDescription("Turn user approval on or off. With it on, users joining the tailnet cannot use it until an admin approves them. Without confirm, reports the change and the users currently awaiting approval, and changes nothing. OAuth Scope: settings:write, users:read."),
+		mcp.WithBoolean("enabled", mcp.Description("Whether new users need approval"), mcp.Required()),
+		mcp.WithBoolean("confirm", mcp.Description("Apply the change instead of previewing it (default: false)")),
+	)
+	mcpServer.AddTool(tool, at.SetUserApproval)
+
+	tool = mcp.NewTool(
+		"tailscale_setting_key_duration_set",
+		updateTool,
+		mcp.WithDescription("Set how many days device node keys are valid before the device must re-authenticate. The new duration applies the next time each device authenticates. Without confirm, reports the change and how many devices have key expiry enabled, and changes nothing. OAuth Scope: settings:write, devices:read."),
+		mcp.WithNumber("days", mcp.Description("Key duration in days (1 to 180)"), mcp.Required()),
+		mcp.WithBoolean("confirm", mcp.Description("Apply the change instead of previewing it (default: false)")),
+	)
+	mcpServer.AddTool(tool, at.SetKeyDuration)
+}
+
+func (at *AdditionalTools) SnapshotTailnetSettings(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client := at.client.GetClient()
+	settings, err := client.TailnetSettings().Get(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get tailnet settings: %v", err)), nil
+	}
+
+	snapshotJSON, err := json.MarshalIndent(settingsSnapshot{TakenAt: time.Now().UTC(), Settings: *settings}, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal snapshot: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(snapshotJSON)), nil
+}
+
+func (at *AdditionalTools) RestoreTailnetSettings(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Snapshot string `json:"snapshot"`
+		DryRun   bool   `json:"dry_run"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	var snapshot settingsSnapshot
+	decoder := json.NewDecoder(strings.NewReader(args.Snapshot))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&snapshot); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid snapshot: %v", err)), nil
+	}
+	if snapshot.TakenAt.IsZero() {
+		return mcp.NewToolResultError("Invalid snapshot: missing taken_at; pass the output of tailscale_tailnet_settings_snapshot"), nil
+	}
+
+	client := at.client.GetClient()
+	current, err := client.TailnetSettings().Get(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get tailnet settings: %v", err)), nil
+	}
+
+	updateReq := settingsUpdateFor(*current, snapshot.Settings)
+	diffs, err := diffTailnetSettings(*current, updateReq)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to diff tailnet settings: %v", err)), nil
+	}
+
+	if args.DryRun {
+		return dryRunResult(diffs...)
+	}
+	if len(diffs) == 0 {
+		return mcp.NewToolResultText("Tailnet settings already match the snapshot; nothing changed"), nil
+	}
+
+	if err := client.TailnetSettings().Update(ctx, updateReq); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to restore tailnet settings: %v", err)), nil
+	}
+
+	var restored []string
+	for _, diff := range diffs {
+		restored = append(restored, diff.Setting)
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Tailnet settings restored from snapshot taken at %s: %s", snapshot.TakenAt.Format(time.RFC3339), strings.Join(restored, ", "))), nil
+}
+
+// settingsUpdateFor returns the update that turns current into desired,
+// naming only the settings that differ.
+func settingsUpdateFor(current, desired tailscale.TailnetSettings) tailscale.UpdateTailnetSettingsRequest {
+	var req tailscale.UpdateTailnetSettingsRequest
+	if current.ACLsExternallyManagedOn != desired.ACLsExternallyManagedOn {
+		req.ACLsExternallyManagedOn = &desired.ACLsExternallyManagedOn
+	}
+	if current.ACLsExternalLink != desired.ACLsExternalLink {
+		req.ACLsExternalLink = &desired.ACLsExternalLink
+	}
+	if current.DevicesApprovalOn != desired.DevicesApprovalOn {
+		req.DevicesApprovalOn = &desired.DevicesApprovalOn
+	}
+	if current.DevicesAutoUpdatesOn != desired.DevicesAutoUpdatesOn {
+		req.DevicesAutoUpdatesOn = &desired.DevicesAutoUpdatesOn
+	}
+	if current.DevicesKeyDurationDays != desired.DevicesKeyDurationDays {
+		req.DevicesKeyDurationDays = &desired.DevicesKeyDurationDays
+	}
+	if current.UsersApprovalOn != desired.UsersApprovalOn {
+		req.UsersApprovalOn = &desired.UsersApprovalOn
+	}
+	if current.UsersRoleAllowedToJoinExternalTailnets != desired.UsersRoleAllowedToJoinExternalTailnets {
+		req.UsersRoleAllowedToJoinExternalTailnets = &desired.UsersRoleAllowedToJoinExternalTailnets
+	}
+	if current.NetworkFlowLoggingOn != desired.NetworkFlowLoggingOn {
+		req.NetworkFlowLoggingOn = &desired.NetworkFlowLoggingOn
+	}
+	if current.RegionalRoutingOn != desired.RegionalRoutingOn {
+		req.RegionalRoutingOn = &desired.RegionalRoutingOn
+	}
+	if current.PostureIdentityCollectionOn != desired.PostureIdentityCollectionOn {
+		req.PostureIdentityCollectionOn = &desired.PostureIdentityCollectionOn
+	}
+	return req
+}
+
+// settingsArgs returns the arguments of tailscale_tailnet_settings_update
+// that make the change req does; fields req leaves alone are nil and
+// dropped.
+func settingsArgs(req tailscale.UpdateTailnetSettingsRequest) map[string]any {
+	return callArgs(map[string]any{
+		"devices_approval_on":                          req.DevicesApprovalOn,
+		"devices_auto_updates_on":                      req.DevicesAutoUpdatesOn,
+		"devices_key_duration_days":                    req.DevicesKeyDurationDays,
+		"users_approval_on":                            req.UsersApprovalOn,
+		"users_role_allowed_to_join_external_tailnets": req.UsersRoleAllowedToJoinExternalTailnets,
+		"network_flow_logging_on":                      req.NetworkFlowLoggingOn,
+		"regional_routing_on":                          req.RegionalRoutingOn,
+		"posture_identity_collection_on":               req.PostureIdentityCollectionOn,
+	})
+}
+
+// sampleSize caps the names listed in a setting change's impact report.
+const sampleSize = 20
+
+// setGuardedSetting previews req, with whatever impact reports, and applies
+// it only when confirm is set.
+func (at *AdditionalTools) setGuardedSetting(ctx context.Context, req tailscale.UpdateTailnetSettingsRequest, confirm bool, impact func(context.Context) (map[string]any, error)) (*mcp.CallToolResult, error) {
+	client := at.client.GetClient()
+	current, err := client.TailnetSettings().Get(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get tailnet settings: %v", err)), nil
+	}
+	diffs, err := diffTailnetSettings(*current, req)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to diff tailnet settings: %v", err)), nil
+	}
+	affected, err := impact(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to assess the change: %v", err)), nil
+	}
+
+	report := map[string]any{
+		"change": diffs[0],
+		"impact": affected,
+	}
+	if !diffs[0].Changed {
+		report["applied"] = false
+		report["note"] = "The setting already has this value; nothing to change"
+	} else if !confirm {
+		report["applied"] = false
+		report["note"] = "Preview only, setting not changed. Call again with confirm set to apply it."
+	} else {
+		if err := client.TailnetSettings().Update(ctx, req); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to update tailnet settings: %v", err)), nil
+		}
+		report["applied"] = true
+	}
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal setting change: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(reportJSON)), nil
+}
+
+func (at *AdditionalTools) SetDeviceApproval(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Enabled bool `json:"enabled"`
+		Confirm bool `json:"confirm"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	req := tailscale.UpdateTailnetSettingsRequest{DevicesApprovalOn: &args.Enabled}
+	return at.setGuardedSetting(ctx, req, args.Confirm, func(ctx context.Context) (map[string]any, error) {
+		devices, err := at.client.GetClient().Devices().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var pending []string
+		for _, device := range devices {
+			if !device.Authorized {
+				pending = append(pending, device.Name)
+			}
+		}
+		return map[string]any{
+			"devices_total":            len(devices),
+			"devices_pending_approval": len(pending),
+			"pending_sample":           pending[:min(len(pending), sampleSize)],
+		}, nil
+	})
+}
+
+func (at *AdditionalTools) SetUserApproval(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Enabled bool `json:"enabled"`
+		Confirm bool `json:"confirm"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	req := tailscale.UpdateTailnetSettingsRequest{UsersApprovalOn: &args.Enabled}
+	return at.setGuardedSetting(ctx, req, args.Confirm, func(ctx context.Context) (map[string]any, error) {
+		users, err := at.client.GetClient().Users().List(ctx, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var pending []string
+		for _, user := range users {
+			if user.Status == tailscale.UserStatusNeedsApproval {
+				pending = append(pending, user.LoginName)
+			}
+		}
+		return map[string]any{
+			"users_total":            len(users),
+			"users_pending_approval": len(pending),
+			"pending_sample":         pending[:min(len(pending), sampleSize)],
+		}, nil
+	})
+}
+
+func (at *AdditionalTools) SetKeyDuration(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Days    int  `json:"days"`
+		Confirm bool `json:"confirm"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if args.Days < 1 || args.Days > 180 {
+		return mcp.NewToolResultError(fmt.Sprintf("Key duration must be between 1 and 180 days, got %d", args.Days)), nil
+	}
+
+	req := tailscale.UpdateTailnetSettingsRequest{DevicesKeyDurationDays: &args.Days}
+	return at.setGuardedSetting(ctx, req, args.Confirm, func(ctx context.Context) (map[string]any, error) {
+		devices, err := at.client.GetClient().Devices().List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var expiring int
+		for _, device := range devices {
+			if !device.KeyExpiryDisabled {
+				expiring++
+			}
+		}
+		return map[string]any{
+			"devices_total":               len(devices),
+			"devices_with_key_expiry":     expiring,
+			"devices_key_expiry_disabled": len(devices) - expiring,
+		}, nil
+	})
+}
@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+	"github.com/pnocera/tailscale-mcp-server/internal/localapi"
+)
+
+// TailnetLockTools reports on tailnet lock. The control API only exposes each
+// device's lock key and error; the lock state and log come from the LocalAPI
+// of a tailscaled on this host, when one is configured.
+type TailnetLockTools struct {
+	client *client.TailscaleClient
+	// local is nil unless TAILSCALE_MCP_TAILSCALED_SOCKET is set.
+	local *localapi.Client
+}
+
+func NewTailnetLockTools(client *client.TailscaleClient, cfg *config.Config) *TailnetLockTools {
+	lt := &TailnetLockTools{client: client}
+	if cfg.TailscaledSocket != "" {
+		lt.local = localapi.NewClient(cfg.TailscaledSocket)
+	}
+	return lt
+}
+
+const noTailscaledSocket = "Tailnet lock state is only available from a local tailscaled: set TAILSCALE_MCP_TAILSCALED_SOCKET (e.g., /var/run/tailscale/tailscaled.sock)"
+
+func (lt *TailnetLockTools) RegisterTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_tailnet_lock_status",
+		readOnlyTool,
+		mcp.WithDescription("Get the tailnet lock status as seen by the tailscaled on this host: whether lock is enabled, the current authority head, the trusted signing keys with their votes, and peers filtered out because their node key is not signed. Requires TAILSCALE_MCP_TAILSCALED_SOCKET. Learn more at /kb/1226/tailnet-lock."),
+	)
+	mcpServer.AddTool(tool, lt.GetLockStatus)
+
+	tool = mcp.NewTool(
+		"tailscale_tailnet_lock_pending",
+		readOnlyTool,
+		mcp.WithDescription("List devices waiting for a tailnet lock signature: devices the control plane reports a tailnet lock error for, with their lock keys and node IDs, plus peers the local tailscaled filters out when TAILSCALE_MCP_TAILSCALED_SOCKET is set. Sign them from a signing node with 'tailscale lock sign'. OAuth Scope: devices:read."),
+	)
+	mcpServer.AddTool(tool, lt.ListPendingSignatures)
+
+	tool = mcp.NewTool(
+		"tailscale_tailnet_lock_log",
+		readOnlyTool,
+		mcp.WithDescription("Get the most recent entries of the tailnet lock log (key additions and removals, signature changes) from the tailscaled on this host. Requires TAILSCALE_MCP_TAILSCALED_SOCKET."),
+		mcp.WithNumber("limit", mcp.Description("Maximum number of entries (default: 50)")),
+	)
+	mcpServer.AddTool(tool, lt.GetLockLog)
+}
+
+func (lt *TailnetLockTools) GetLockStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if lt.local == nil {
+		return mcp.NewToolResultError(noTailscaledSocket), nil
+	}
+
+	status, err := lt.local.LockStatus(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get tailnet lock status: %v", err)), nil
+	}
+
+	statusJSON, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal tailnet lock status: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(statusJSON)), nil
+}
+
+func (lt *TailnetLockTools) ListPendingSignatures(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	devices, err := lt.client.GetClient().Devices().ListWithAllFields(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+
+	type pendingDevice struct {
+		ID             string `json:"id"`
+		NodeID         string `json:"node_id"`
+		Name           string `json:"name"`
+		User           string `json:"user"`
+		NodeKey        string `json:"node_key"`
+		TailnetLockKey string `json:"tailnet_lock_key"`
+		Error          string `json:"error"`
+	}
+	result := struct {
+		Devices       []pendingDevice     `json:"devices"`
+		FilteredPeers []localapi.LockPeer `json:"filtered_peers,omitempty"`
+		Note          string              `json:"note,omitempty"`
+	}{Devices: []pendingDevice{}}
+
+	for _, device := range devices {
+		if device.TailnetLockError == "" {
+			continue
+		}
+		result.Devices = append(result.Devices, pendingDevice{
+			ID:             device.ID,
+			NodeID:         device.NodeID,
+			Name:           device.Name,
+			User:           device.User,
+			NodeKey:        device.NodeKey,
+			TailnetLockKey: device.TailnetLockKey,
+			Error:          device.TailnetLockError,
+		})
+	}
+
+	if lt.local != nil {
+		status, err := lt.local.LockStatus(ctx)
+		if err != nil {
+			result.Note = fmt.Sprintf("Failed to get filtered peers from tailscaled: %v", err)
+		} else {
+			result.FilteredPeers = status.FilteredPeers
+		}
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal pending signatures: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
+
+func (lt *TailnetLockTools) GetLockLog(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Limit int `json:"limit"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+	if args.Limit <= 0 {
+		args.Limit = 50
+	}
+
+	if lt.local == nil {
+		return mcp.NewToolResultError(noTailscaledSocket), nil
+	}
+
+	updates, err := lt.local.LockLog(ctx, args.Limit)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get tailnet lock log: %v", err)), nil
+	}
+	if updates == nil {
+		updates = []localapi.LockUpdate{}
+	}
+
+	updatesJSON, err := json.MarshalIndent(updates, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal tailnet lock log: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(updatesJSON)), nil
+}
@@ -0,0 +1,207 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// topology is the tailnet as a graph: users and tags own devices, and
+// devices route subnets and act as exit nodes.
+type topology struct {
+	nodes []topologyNode
+	edges []topologyEdge
+}
+
+type topologyNode struct {
+	id    string
+	label string
+	kind  string // "user", "tag", "device", "route", or "internet"
+}
+
+type topologyEdge struct {
+	from, to string
+	// pending marks a route or exit node that is advertised but not enabled.
+	pending bool
+}
+
+func isExitRoute(route string) bool {
+	return route == "0.0.0.0/0" || route == "::/0"
+}
+
+func buildTopology(devices []tailscale.Device) *topology {
+	t := &topology{}
+	ids := make(map[string]string)
+	node := func(kind, label string) string {
+		key := kind + " " + label
+		if id, ok := ids[key]; ok {
+			return id
+		}
+		id := fmt.Sprintf("n%d", len(ids))
+		ids[key] = id
+		t.nodes = append(t.nodes, topologyNode{id: id, label: label, kind: kind})
+		return id
+	}
+
+	slices.SortFunc(devices, func(a, b tailscale.Device) int { return strings.Compare(a.Name, b.Name) })
+	for _, device := range devices {
+		deviceID := node("device", strings.Split(device.Name, ".")[0])
+		if len(device.Tags) == 0 {
+			t.edges = append(t.edges, topologyEdge{from: node("user", device.User), to: deviceID})
+		}
+		for _, tag := range device.Tags {
+			t.edges = append(t.edges, topologyEdge{from: node("tag", tag), to: deviceID})
+		}
+
+		enabled := make(map[string]bool)
+		for _, route := range device.EnabledRoutes {
+			enabled[route] = true
+		}
+		routes := slices.Sorted(maps.Keys(enabled))
+		for _, route := range device.AdvertisedRoutes {
+			if !enabled[route] {
+				routes = append(routes, route)
+			}
+		}
+		exitNode := false
+		for _, route := range routes {
+			if isExitRoute(route) {
+				// Exit nodes advertise both default routes; draw one edge.
+				if !exitNode {
+					t.edges = append(t.edges, topologyEdge{from: deviceID, to: node("internet", "Internet (exit node)"), pending: !enabled[route]})
+					exitNode = true
+				}
+				continue
+			}
+			t.edges = append(t.edges, topologyEdge{from: deviceID, to: node("route", route), pending: !enabled[route]})
+		}
+	}
+	return t
+}
+
+// mermaid renders t as a Mermaid flowchart.
+func (t *topology) mermaid() string {
+	shapes := map[string]string{
+		"user":     "([%s])",
+		"tag":      "{{%s}}",
+		"device":   "[%s]",
+		"route":    "[/%s/]",
+		"internet": "((%s))",
+	}
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for _, n := range t.nodes {
+		fmt.Fprintf(&b, "  %s"+shapes[n.kind]+"\n", n.id, `"`+strings.ReplaceAll(n.label, `"`, "#quot;")+`"`)
+	}
+	for _, e := range t.edges {
+		arrow := "-->"
+		if e.pending {
+			arrow = "-.->|not approved|"
+		}
+		fmt.Fprintf(&b, "  %s %s %s\n", e.from, arrow, e.to)
+	}
+	return b.String()
+}
+
+// dot renders t as Graphviz DOT source.
+func (t *topology) dot() string {
+	shapes := map[string]string{
+		"user":     "ellipse",
+		"tag":      "hexagon",
+		"device":   "box",
+		"route":    "parallelogram",
+		"internet": "doublecircle",
+	}
+	var b strings.Builder
+	b.WriteString("digraph tailnet {\n  rankdir=LR;\n")
+	for _, n := range t.nodes {
+		fmt.Fprintf(&b, "  %s [label=%q, shape=%s];\n", n.id, n.label, shapes[n.kind])
+	}
+	for _, e := range t.edges {
+		if e.pending {
+			fmt.Fprintf(&b, "  %s -> %s [style=dashed, label=\"not approved\"];\n", e.from, e.to)
+		} else {
+			fmt.Fprintf(&b, "  %s -> %s;\n", e.from, e.to)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func (dt *DeviceTools) registerTopologyTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_topology_graph",
+		readOnlyTool,
+		mcp.WithDescription("Draw the tailnet as a graph: which users and tags own which devices, the subnet routes devices serve, and the exit nodes. Routes and exit nodes that are advertised but not approved are drawn dashed. Returns Mermaid flowchart or Graphviz DOT source; with render, the graph is also rendered to SVG by the Graphviz 'dot' program on the server and attached as an image/svg+xml resource. OAuth Scope: devices:read."),
+		mcp.WithString("format", mcp.Description("Source format: 'mermaid' (default) or 'dot'"), mcp.Enum("mermaid", "dot")),
+		mcp.WithBoolean("render", mcp.Description("Also render the graph to SVG; requires Graphviz on the server")),
+	)
+	mcpServer.AddTool(tool, dt.TopologyGraph)
+}
+
+func (dt *DeviceTools) TopologyGraph(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Format string `json:"format"`
+		Render bool   `json:"render"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	devices, err := dt.client.GetClient().Devices().ListWithAllFields(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+	graph := buildTopology(devices)
+
+	var source string
+	switch args.Format {
+	case "", "mermaid":
+		source = graph.mermaid()
+	case "dot":
+		source = graph.dot()
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid format %q: must be 'mermaid' or 'dot'", args.Format)), nil
+	}
+	result := mcp.NewToolResultText(source)
+
+	if args.Render {
+		svg, err := renderSVG(ctx, graph.dot())
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to render graph: %v", err)), nil
+		}
+		result.Content = append(result.Content, mcp.NewEmbeddedResource(mcp.TextResourceContents{
+			URI:      "tailscale://topology/graph.svg",
+			MIMEType: "image/svg+xml",
+			Text:     svg,
+		}))
+	}
+	return result, nil
+}
+
+// renderSVG renders DOT source with the Graphviz dot program.
+func renderSVG(ctx context.Context, source string) (string, error) {
+	cmd := exec.CommandContext(ctx, "dot", "-Tsvg")
+	cmd.Stdin = strings.NewReader(source)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("dot: %s", msg)
+		}
+		return "", fmt.Errorf("dot: %w", err)
+	}
+	return stdout.String(), nil
+}
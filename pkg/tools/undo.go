@@ -0,0 +1,527 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/guardrail"
+	"github.com/pnocera/tailscale-mcp-server/internal/rbac"
+	"github.com/pnocera/tailscale-mcp-server/internal/textdiff"
+	"tailscale.com/client/tailscale/v2"
+)
+
+// maxChanges is how many recorded changes are kept; older ones can no
+// longer be undone.
+const maxChanges = 100
+
+// change is a tool call that changed the tailnet, with the state of what it
+// changed before and after.
+type change struct {
+	ID     int       `json:"id"`
+	Time   time.Time `json:"time"`
+	Tool   string    `json:"tool"`
+	By     string    `json:"by,omitempty"`
+	Target string    `json:"target"`
+	Diff   string    `json:"diff"`
+	Undone bool      `json:"undone"`
+	before string
+	after  string
+	undo   undoTarget
+}
+
+// undoTarget is something a tool changes whose state can be read and put
+// back: read returns the current state as text, restore makes it before,
+// and inverse returns the calls of dedicated tools that would change it
+// from after back to before.
+type undoTarget struct {
+	describe string
+	read     func(ctx context.Context) (string, error)
+	restore  func(ctx context.Context, before string) error
+	inverse  func(before, after string) ([]guardrail.Call, error)
+}
+
+// UndoTools records the prior state of what reversible tools change, such
+// as device tags, routes, and names, DNS, tailnet settings, and the policy,
+// so that the most recent change can be reverted.
+type UndoTools struct {
+	client *client.TailscaleClient
+	dns    *DNSTools
+
+	mu      sync.Mutex
+	changes []*change
+	nextID  int
+}
+
+func NewUndoTools(client *client.TailscaleClient) *UndoTools {
+	return &UndoTools{client: client, dns: &DNSTools{client: client}, nextID: 1}
+}
+
+func (ut *UndoTools) RegisterTools(mcpServer *server.MCPServer) {
+	tool := mcp.NewTool(
+		"tailscale_changes_list",
+		readOnlyTool,
+		mcp.WithDescription("List the reversible changes this session's principal made through this server, newest first, with what each changed and a diff from the state before to the state after. Device tags, routes, and names, DNS settings, tailnet settings, and the policy file are recorded; other changes, such as deletions, cannot be undone. The list is kept in memory and lost when the server restarts."),
+	)
+	mcpServer.AddTool(tool, ut.ListChanges)
+
+	tool = mcp.NewTool(
+		"tailscale_undo_last",
+		destructiveTool,
+		mcp.WithDescription("Revert the most recent change listed by tailscale_changes_list that has not been undone, which is always one made by this session's principal, by putting back the state from before it. The change is only reverted if what it changed still holds the state it left, so later changes are not silently overwritten; pass force to revert anyway."),
+		mcp.WithBoolean("force", mcp.Description("Revert even if the state was changed again since (default: false)")),
+	)
+	mcpServer.AddTool(tool, ut.UndoLast)
+}
+
+// Middleware records the calls of reversible tools that change what they
+// target. The target is read before and after the call, so previews and
+// calls that fail without changing anything are not recorded, and partial
+// changes are. Register it after the dry-run and confirmation middleware.
+func (ut *UndoTools) Middleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target, ok := ut.targetFor(ctx, request)
+		if !ok || client.IsDryRun(ctx) {
+			return next(ctx, request)
+		}
+
+		before, err := target.read(ctx)
+		if err != nil {
+			log.Printf("warning: Cannot record %s for undo: %v", request.Params.Name, err)
+			return next(ctx, request)
+		}
+		result, err := next(ctx, request)
+		after, readErr := target.read(ctx)
+		if readErr != nil {
+			log.Printf("warning: Cannot record %s for undo: %v", request.Params.Name, readErr)
+			return result, err
+		}
+		if after != before {
+			ut.record(ctx, request.Params.Name, target, before, after)
+		}
+		return result, err
+	}
+}
+
+// targetFor returns what a call of a reversible tool changes.
+func (ut *UndoTools) targetFor(ctx context.Context, request mcp.CallToolRequest) (undoTarget, bool) {
+	name := request.Params.Name
+	deviceID, _ := request.GetArguments()["device_id"].(string)
+	switch {
+	case name == "tailscale_device_set_tags" && deviceID != "":
+		return ut.deviceTarget(deviceID, "tags"), true
+	case name == "tailscale_device_set_name" && deviceID != "":
+		return ut.deviceTarget(deviceID, "name"), true
+	case name == "tailscale_device_routes_set" && deviceID != "":
+		return ut.deviceTarget(deviceID, "routes"), true
+	case name == "tailscale_tailnet_settings_update" || name == "tailscale_tailnet_settings_restore" || strings.HasPrefix(name, "tailscale_setting_"):
+		return ut.settingsTarget(), true
+	}
+
+	// The DNS and policy tools are recognized by prefix; read-only ones
+	// never change anything and are skipped.
+	var target undoTarget
+	switch {
+	case strings.HasPrefix(name, "tailscale_dns_"):
+		target = ut.dnsTarget()
+	case strings.HasPrefix(name, "tailscale_policy_"):
+		target = ut.policyTarget()
+	default:
+		return undoTarget{}, false
+	}
+	if mcpServer := server.ServerFromContext(ctx); mcpServer != nil {
+		if tool := mcpServer.GetTool(name); tool != nil {
+			if readOnly := tool.Tool.Annotations.ReadOnlyHint; readOnly != nil && *readOnly {
+				return undoTarget{}, false
+			}
+		}
+	}
+	return target, true
+}
+
+// changedBy returns who ctx acts for: the principal of an authenticated
+// HTTP session, or "" for the server's own configured principal.
+func changedBy(ctx context.Context) string {
+	principal, _ := rbac.FromContext(ctx)
+	return principal.Name
+}
+
+func (ut *UndoTools) record(ctx context.Context, tool string, target undoTarget, before, after string) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+
+	ut.changes = append(ut.changes, &change{
+		ID:     ut.nextID,
+		Time:   time.Now().UTC(),
+		Tool:   tool,
+		By:     changedBy(ctx),
+		Target: target.describe,
+		Diff:   textdiff.Unified("before", "after", before, after),
+		before: before,
+		after:  after,
+		undo:   target,
+	})
+	ut.nextID++
+	if len(ut.changes) > maxChanges {
+		ut.changes = slices.Delete(ut.changes, 0, len(ut.changes)-maxChanges)
+	}
+}
+
+func (ut *UndoTools) ListChanges(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	by := changedBy(ctx)
+	ut.mu.Lock()
+	changes := make([]change, 0, len(ut.changes))
+	for i := len(ut.changes) - 1; i >= 0; i-- {
+		if ut.changes[i].By == by {
+			changes = append(changes, *ut.changes[i])
+		}
+	}
+	ut.mu.Unlock()
+
+	changesJSON, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal changes: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(changesJSON)), nil
+}
+
+func (ut *UndoTools) UndoLast(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Force bool `json:"force"`
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	last := ut.last(ctx)
+	if last == nil {
+		return mcp.NewToolResultError("There is no recorded change to undo"), nil
+	}
+
+	current, err := last.undo.read(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read %s: %v", last.Target, err)), nil
+	}
+	if current == last.before {
+		ut.markUndone(last)
+		return mcp.NewToolResultText(fmt.Sprintf("Change %d (%s on %s) is already reverted; nothing changed", last.ID, last.Tool, last.Target)), nil
+	}
+	if current != last.after && !args.Force {
+		return mcp.NewToolResultError(fmt.Sprintf("Change %d (%s on %s) was not undone: %s changed again since. Review it with tailscale_changes_list and pass force to revert anyway.\n%s",
+			last.ID, last.Tool, last.Target, last.Target, textdiff.Unified("after change", "current", last.after, current))), nil
+	}
+
+	if err := last.undo.restore(ctx, last.before); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to undo change %d: %v", last.ID, err)), nil
+	}
+	if !client.IsDryRun(ctx) {
+		ut.markUndone(last)
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Undid change %d (%s on %s):\n%s", last.ID, last.Tool, last.Target, textdiff.Unified("current", "restored", current, last.before))), nil
+}
+
+// last returns the most recent change made for ctx's principal that has not
+// been undone, or nil. Principals cannot undo each other's changes.
+func (ut *UndoTools) last(ctx context.Context) *change {
+	by := changedBy(ctx)
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	for i := len(ut.changes) - 1; i >= 0; i-- {
+		if !ut.changes[i].Undone && ut.changes[i].By == by {
+			return ut.changes[i]
+		}
+	}
+	return nil
+}
+
+// GuardrailCalls returns, for a call of tailscale_undo_last, the calls of
+// dedicated tools that would make the change undoing does, so guardrails on
+// those tools cover undo too.
+func (ut *UndoTools) GuardrailCalls(ctx context.Context, tool string, args map[string]any) ([]guardrail.Call, error) {
+	if tool != "tailscale_undo_last" {
+		return nil, nil
+	}
+	last := ut.last(ctx)
+	if last == nil {
+		return nil, nil
+	}
+	return last.undo.inverse(last.before, last.after)
+}
+
+func (ut *UndoTools) markUndone(c *change) {
+	ut.mu.Lock()
+	defer ut.mu.Unlock()
+	c.Undone = true
+}
+
+// undoJSON renders a state for comparison and diffs.
+func undoJSON(v any) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b) + "\n", nil
+}
+
+// deviceTarget is the tags, name, or enabled routes of a device.
+func (ut *UndoTools) deviceTarget(deviceID, field string) undoTarget {
+	return undoTarget{
+		describe: fmt.Sprintf("device %s %s", deviceID, field),
+		read: func(ctx context.Context) (string, error) {
+			devices := ut.client.GetClient().Devices()
+			if field == "routes" {
+				routes, err := devices.SubnetRoutes(ctx, deviceID)
+				if err != nil {
+					return "", err
+				}
+				return undoJSON(nonNil(routes.Enabled))
+			}
+			device, err := devices.Get(ctx, deviceID)
+			if err != nil {
+				return "", err
+			}
+			if field == "name" {
+				return undoJSON(device.Name)
+			}
+			return undoJSON(nonNil(device.Tags))
+		},
+		restore: func(ctx context.Context, before string) error {
+			devices := ut.client.GetClient().Devices()
+			if field == "name" {
+				var name string
+				if err := json.Unmarshal([]byte(before), &name); err != nil {
+					return err
+				}
+				// The API returns the MagicDNS name but takes the host label.
+				host, _, _ := strings.Cut(name, ".")
+				return devices.SetName(ctx, deviceID, host)
+			}
+			var values []string
+			if err := json.Unmarshal([]byte(before), &values); err != nil {
+				return err
+			}
+			if field == "routes" {
+				return devices.SetSubnetRoutes(ctx, deviceID, values)
+			}
+			return devices.SetTags(ctx, deviceID, values)
+		},
+		inverse: func(before, after string) ([]guardrail.Call, error) {
+			var value any
+			if err := json.Unmarshal([]byte(before), &value); err != nil {
+				return nil, err
+			}
+			tool := "tailscale_device_set_tags"
+			switch field {
+			case "name":
+				tool = "tailscale_device_set_name"
+				if name, ok := value.(string); ok {
+					value, _, _ = strings.Cut(name, ".")
+				}
+			case "routes":
+				tool = "tailscale_device_routes_set"
+			}
+			return []guardrail.Call{{Tool: tool, Args: map[string]any{"device_id": deviceID, field: value}}}, nil
+		},
+	}
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// dnsState is every DNS setting of the tailnet.
+type dnsState struct {
+	Nameservers      []string                   `json:"nameservers"`
+	SearchPaths      []string                   `json:"search_paths"`
+	SplitDNS         tailscale.SplitDNSResponse `json:"split_dns"`
+	MagicDNS         bool                       `json:"magic_dns"`
+	OverrideLocalDNS bool                       `json:"override_local_dns"`
+}
+
+func (ut *UndoTools) readDNS(ctx context.Context) (dnsState, error) {
+	dns := ut.client.GetClient().DNS()
+	var state dnsState
+	var err error
+	if state.Nameservers, err = dns.Nameservers(ctx); err != nil {
+		return state, err
+	}
+	if state.SearchPaths, err = dns.SearchPaths(ctx); err != nil {
+		return state, err
+	}
+	if state.SplitDNS, err = dns.SplitDNS(ctx); err != nil {
+		return state, err
+	}
+	// OverrideLocalDNS is only part of the full DNS configuration.
+	config, err := ut.dns.getDNSConfiguration(ctx)
+	if err != nil {
+		return state, err
+	}
+	preferences, err := parseDNSPreferences(config)
+	if err != nil {
+		return state, err
+	}
+	state.MagicDNS, state.OverrideLocalDNS = preferences.MagicDNS, preferences.OverrideLocalDNS
+	state.Nameservers, state.SearchPaths = nonNil(state.Nameservers), nonNil(state.SearchPaths)
+	if state.SplitDNS == nil {
+		state.SplitDNS = tailscale.SplitDNSResponse{}
+	}
+	return state, nil
+}
+
+// dnsTarget is the tailnet's DNS settings; restoring them sets only the
+// parts that differ.
+func (ut *UndoTools) dnsTarget() undoTarget {
+	return undoTarget{
+		describe: "DNS settings",
+		read: func(ctx context.Context) (string, error) {
+			state, err := ut.readDNS(ctx)
+			if err != nil {
+				return "", err
+			}
+			return undoJSON(state)
+		},
+		restore: func(ctx context.Context, before string) error {
+			var desired dnsState
+			if err := json.Unmarshal([]byte(before), &desired); err != nil {
+				return err
+			}
+			current, err := ut.readDNS(ctx)
+			if err != nil {
+				return err
+			}
+
+			dns := ut.client.GetClient().DNS()
+			if !slices.Equal(current.Nameservers, desired.Nameservers) {
+				if err := dns.SetNameservers(ctx, desired.Nameservers); err != nil {
+					return fmt.Errorf("nameservers: %w", err)
+				}
+			}
+			if !slices.Equal(current.SearchPaths, desired.SearchPaths) {
+				if err := dns.SetSearchPaths(ctx, desired.SearchPaths); err != nil {
+					return fmt.Errorf("search paths: %w", err)
+				}
+			}
+			currentSplit, _ := json.Marshal(current.SplitDNS)
+			desiredSplit, _ := json.Marshal(desired.SplitDNS)
+			if string(currentSplit) != string(desiredSplit) {
+				if err := dns.SetSplitDNS(ctx, tailscale.SplitDNSRequest(desired.SplitDNS)); err != nil {
+					return fmt.Errorf("split DNS: %w", err)
+				}
+			}
+			if current.MagicDNS != desired.MagicDNS || current.OverrideLocalDNS != desired.OverrideLocalDNS {
+				config, err := ut.dns.getDNSConfiguration(ctx)
+				if err != nil {
+					return fmt.Errorf("preferences: %w", err)
+				}
+				preferences := DNSConfigurationPreferences{MagicDNS: desired.MagicDNS, OverrideLocalDNS: desired.OverrideLocalDNS}
+				if err := ut.dns.writeDNSPreferences(ctx, config, preferences); err != nil {
+					return fmt.Errorf("preferences: %w", err)
+				}
+			}
+			return nil
+		},
+		inverse: func(before, after string) ([]guardrail.Call, error) {
+			var desired, changed dnsState
+			if err := json.Unmarshal([]byte(before), &desired); err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(after), &changed); err != nil {
+				return nil, err
+			}
+			var calls []guardrail.Call
+			if !slices.Equal(changed.Nameservers, desired.Nameservers) {
+				calls = append(calls, guardrail.Call{Tool: "tailscale_dns_nameservers_set", Args: callArgs(map[string]any{"nameservers": desired.Nameservers})})
+			}
+			if !slices.Equal(changed.SearchPaths, desired.SearchPaths) {
+				calls = append(calls, guardrail.Call{Tool: "tailscale_dns_searchpaths_set", Args: callArgs(map[string]any{"search_paths": desired.SearchPaths})})
+			}
+			changedSplit, _ := json.Marshal(changed.SplitDNS)
+			desiredSplit, _ := json.Marshal(desired.SplitDNS)
+			if string(changedSplit) != string(desiredSplit) {
+				calls = append(calls, guardrail.Call{Tool: "tailscale_dns_split_set", Args: callArgs(map[string]any{"split_dns": desired.SplitDNS})})
+			}
+			if changed.MagicDNS != desired.MagicDNS || changed.OverrideLocalDNS != desired.OverrideLocalDNS {
+				calls = append(calls, guardrail.Call{Tool: "tailscale_dns_preferences_set", Args: map[string]any{"magic_dns": desired.MagicDNS, "override_local_dns": desired.OverrideLocalDNS}})
+			}
+			return calls, nil
+		},
+	}
+}
+
+// settingsTarget is the tailnet settings.
+func (ut *UndoTools) settingsTarget() undoTarget {
+	return undoTarget{
+		describe: "tailnet settings",
+		read: func(ctx context.Context) (string, error) {
+			settings, err := ut.client.GetClient().TailnetSettings().Get(ctx)
+			if err != nil {
+				return "", err
+			}
+			return undoJSON(settings)
+		},
+		restore: func(ctx context.Context, before string) error {
+			var desired tailscale.TailnetSettings
+			if err := json.Unmarshal([]byte(before), &desired); err != nil {
+				return err
+			}
+			settings := ut.client.GetClient().TailnetSettings()
+			current, err := settings.Get(ctx)
+			if err != nil {
+				return err
+			}
+			return settings.Update(ctx, settingsUpdateFor(*current, desired))
+		},
+		inverse: func(before, after string) ([]guardrail.Call, error) {
+			var desired, changed tailscale.TailnetSettings
+			if err := json.Unmarshal([]byte(before), &desired); err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(after), &changed); err != nil {
+				return nil, err
+			}
+			return []guardrail.Call{{Tool: "tailscale_tailnet_settings_update", Args: settingsArgs(settingsUpdateFor(changed, desired))}}, nil
+		},
+	}
+}
+
+// policyTarget is the policy file. It is restored against the ETag of the
+// policy just read, so a change made in between fails the restore.
+func (ut *UndoTools) policyTarget() undoTarget {
+	return undoTarget{
+		describe: "policy file",
+		read: func(ctx context.Context) (string, error) {
+			policy, err := ut.client.GetClient().PolicyFile().Raw(ctx)
+			if err != nil {
+				return "", err
+			}
+			return policy.HuJSON, nil
+		},
+		restore: func(ctx context.Context, before string) error {
+			policyFile := ut.client.GetClient().PolicyFile()
+			current, err := policyFile.Raw(ctx)
+			if err != nil {
+				return err
+			}
+			return policyFile.Set(ctx, before, current.ETag)
+		},
+		inverse: func(before, after string) ([]guardrail.Call, error) {
+			return []guardrail.Call{{Tool: "tailscale_policy_set", Args: map[string]any{"policy": before}}}, nil
+		},
+	}
+}
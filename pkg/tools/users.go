@@ -1,33 +1,86 @@
 package tools
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
 	"tailscale.com/client/tailscale/v2"
 )
 
+// UserInvite describes a pending invitation for a user to join the tailnet.
+// InviterLoginName is not part of the API's invite: it is looked up from
+// InviterID, and is empty if the inviter is no longer a user of the tailnet.
+type UserInvite struct {
+	ID               string    `json:"id"`
+	Role             string    `json:"role"`
+	TailnetID        int64     `json:"tailnetId"`
+	InviterID        int64     `json:"inviterId"`
+	InviterLoginName string    `json:"inviterLoginName,omitempty"`
+	Email            string    `json:"email,omitempty"`
+	LastEmailSentAt  time.Time `json:"lastEmailSentAt"`
+	InviteURL        string    `json:"inviteUrl,omitempty"`
+}
+
+// DeviceInvite describes an invitation to share a device with another user.
+type DeviceInvite struct {
+	ID            string    `json:"id"`
+	Created       time.Time `json:"created"`
+	TailnetID     int64     `json:"tailnetId"`
+	DeviceID      string    `json:"deviceId"`
+	SharerID      string    `json:"sharerId"`
+	MultiUse      bool      `json:"multiUse"`
+	AllowExitNode bool      `json:"allowExitNode"`
+	Email         string    `json:"email,omitempty"`
+	LastEmailSent time.Time `json:"lastEmailSentAt"`
+	InviteURL     string    `json:"inviteUrl,omitempty"`
+	Accepted      bool      `json:"accepted"`
+	AcceptedBy    *struct {
+		ID        int64  `json:"id"`
+		LoginName string `json:"loginName"`
+	} `json:"acceptedBy,omitempty"`
+}
+
 type UserTools struct {
 	client *client.TailscaleClient
+	config *config.Config
 }
 
-func NewUserTools(client *client.TailscaleClient) *UserTools {
-	return &UserTools{client: client}
+func NewUserTools(client *client.TailscaleClient, cfg *config.Config) *UserTools {
+	return &UserTools{client: client, config: cfg}
 }
 
 func (ut *UserTools) RegisterTools(mcpServer *server.MCPServer) {
 	tool := mcp.NewTool(
 		"tailscale_users_list",
-		mcp.WithDescription("List all users in the tailnet. Returns user information including display name, login name, profile picture, role, status, and last seen timestamp. Essential for user management and access auditing. OAuth Scope: users:read."),
+		readOnlyTool,
+		outputSchema[listOutput[tailscale.User]](),
+		withPagination,
+		mcp.WithDescription("List all users in the tailnet. Returns user information including display name, login name, profile picture, role, status, and last seen timestamp. Filter by user type, role, or a name/email substring to narrow large tailnets. Essential for user management and access auditing. OAuth Scope: users:read."),
+		mcp.WithString("type", mcp.Description("User type: 'member' for tailnet members, 'shared' for users with shared devices, or 'all'"), mcp.Enum("member", "shared", "all")),
+		mcp.WithString("role", mcp.Description("Only users with this role"), mcp.Enum("owner", "member", "admin", "it-admin", "network-admin", "billing-admin", "auditor")),
+		mcp.WithString("query", mcp.Description("Only users whose display name or login name contains this substring (case-insensitive)")),
 	)
 	mcpServer.AddTool(tool, ut.ListUsers)
 
 	tool = mcp.NewTool(
 		"tailscale_user_get",
+		readOnlyTool,
+		outputSchema[tailscale.User](),
 		mcp.WithDescription("Get detailed information about a specific user in the tailnet. Returns comprehensive user data including account details, role assignments, device count, and authentication status. Use this for user profile management and access verification. OAuth Scope: users:read."),
 		mcp.WithString("user_id", mcp.Description("The user ID"), mcp.Required()),
 	)
@@ -35,60 +88,182 @@ func (ut *UserTools) RegisterTools(mcpServer *server.MCPServer) {
 
 	tool = mcp.NewTool(
 		"tailscale_user_approve",
-		mcp.WithDescription("Approve a user for tailnet access. This grants the user permission to join the tailnet and access resources according to their role and ACL policies. Use this for tailnets requiring user approval for new members. Note: This functionality may not be available in all API versions. OAuth Scope: users:write."),
+		updateTool,
+		mcp.WithDescription("Approve a user for tailnet access. This grants the user permission to join the tailnet and access resources according to their role and ACL policies. Use this for tailnets requiring user approval for new members. OAuth Scope: users:write."),
 		mcp.WithString("user_id", mcp.Description("The user ID to approve"), mcp.Required()),
 	)
 	mcpServer.AddTool(tool, ut.ApproveUser)
 
 	tool = mcp.NewTool(
 		"tailscale_user_suspend",
-		mcp.WithDescription("Suspend a user to temporarily revoke their tailnet access. Suspended users cannot access tailnet resources but remain in the user list for future restoration. Use this for temporary access control without removing the user permanently. Note: This functionality may not be available in all API versions. OAuth Scope: users:write."),
+		destructiveTool,
+		mcp.WithDescription("Suspend a user to temporarily revoke their tailnet access. Suspended users cannot access tailnet resources but remain in the user list for future restoration. Use this for temporary access control without removing the user permanently. OAuth Scope: users:write."),
 		mcp.WithString("user_id", mcp.Description("The user ID to suspend"), mcp.Required()),
 	)
 	mcpServer.AddTool(tool, ut.SuspendUser)
 
 	tool = mcp.NewTool(
 		"tailscale_user_restore",
-		mcp.WithDescription("Restore a previously suspended user to active status. This re-enables their access to tailnet resources according to their role and ACL policies. Use this to reinstate users after temporary suspension. Note: This functionality may not be available in all API versions. OAuth Scope: users:write."),
+		updateTool,
+		mcp.WithDescription("Restore a previously suspended user to active status. This re-enables their access to tailnet resources according to their role and ACL policies. Use this to reinstate users after temporary suspension. OAuth Scope: users:write."),
 		mcp.WithString("user_id", mcp.Description("The user ID to restore"), mcp.Required()),
 	)
 	mcpServer.AddTool(tool, ut.RestoreUser)
 
 	tool = mcp.NewTool(
 		"tailscale_user_delete",
-		mcp.WithDescription("Delete a user from the tailnet permanently. This removes the user and their access to all tailnet resources. Use this for user offboarding or when users no longer need access. Note: This functionality may not be available in all API versions. OAuth Scope: users:write."),
+		destructiveTool,
+		mcp.WithDescription("Delete a user from the tailnet permanently. This removes the user and their access to all tailnet resources. Use this for user offboarding or when users no longer need access. OAuth Scope: users:write."),
 		mcp.WithString("user_id", mcp.Description("The user ID to delete"), mcp.Required()),
 	)
 	mcpServer.AddTool(tool, ut.DeleteUser)
 
 	tool = mcp.NewTool(
 		"tailscale_contacts_get",
+		readOnlyTool,
+		outputSchema[tailscale.Contacts](),
 		mcp.WithDescription("Get contact preferences for the tailnet. Returns configured contact information for account notifications, support requests, and security alerts. Essential for maintaining proper communication channels and compliance requirements. OAuth Scope: users:read."),
 	)
 	mcpServer.AddTool(tool, ut.GetContacts)
 
 	tool = mcp.NewTool(
 		"tailscale_contact_update",
+		updateTool,
 		mcp.WithDescription("Update contact preferences for the tailnet. Configure email addresses for different contact types: 'account' for billing/administrative, 'support' for technical issues, and 'security' for security-related notifications. Essential for maintaining proper communication channels and compliance. OAuth Scope: users:write."),
 		mcp.WithString("contact_type", mcp.Description("Type of contact (account, support, security)"), mcp.Enum("account", "support", "security"), mcp.Required()),
 		mcp.WithString("email", mcp.Description("Email address for the contact"), mcp.Required()),
 	)
 	mcpServer.AddTool(tool, ut.UpdateContact)
+
+	tool = mcp.NewTool(
+		"tailscale_contact_resend_verification",
+		createTool,
+		mcp.WithDescription("Resend the verification email for a tailnet contact. Contacts whose email has not been verified (needsVerification=true in tailscale_contacts_get) do not receive notifications, so use this to fix unverified account, support, or security contacts. OAuth Scope: users:write."),
+		mcp.WithString("contact_type", mcp.Description("Type of contact (account, support, security)"), mcp.Enum("account", "support", "security"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, ut.ResendContactVerification)
+
+	tool = mcp.NewTool(
+		"tailscale_user_invites_list",
+		readOnlyTool,
+		outputSchema[listOutput[UserInvite]](),
+		withPagination,
+		mcp.WithDescription("List pending user invites for the tailnet. Returns each invite's ID, invited email, role, inviter (ID and login name), when the invite email was last sent, and the invite URL. The API does not report when an invite expires, so none is returned. Use this to review the queue of people who have been invited but not yet joined. OAuth Scope: users:read."),
+	)
+	mcpServer.AddTool(tool, ut.ListUserInvites)
+
+	tool = mcp.NewTool(
+		"tailscale_user_invite_get",
+		readOnlyTool,
+		outputSchema[UserInvite](),
+		mcp.WithDescription("Get detailed information about a specific pending user invite, including the invited email, role, inviter (ID and login name), when the invite email was last sent, and the invite URL. The API does not report when an invite expires, so none is returned. OAuth Scope: users:read."),
+		mcp.WithString("invite_id", mcp.Description("The user invite ID"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, ut.GetUserInvite)
+
+	tool = mcp.NewTool(
+		"tailscale_user_invite_delete",
+		destructiveTool,
+		mcp.WithDescription("Cancel a pending user invite. The invite URL stops working and the invited person can no longer join the tailnet with it. Use this to withdraw invites sent by mistake or that are no longer needed. OAuth Scope: users:write."),
+		mcp.WithString("invite_id", mcp.Description("The user invite ID to delete"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, ut.DeleteUserInvite)
+
+	tool = mcp.NewTool(
+		"tailscale_user_invite_resend",
+		createTool,
+		mcp.WithDescription("Resend the invitation email for a pending user invite. Only invites created with an email address can be resent. Use this when the original email was lost or filtered. OAuth Scope: users:write."),
+		mcp.WithString("invite_id", mcp.Description("The user invite ID to resend"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, ut.ResendUserInvite)
+
+	tool = mcp.NewTool(
+		"tailscale_user_devices_list",
+		readOnlyTool,
+		outputSchema[listOutput[tailscale.Device]](),
+		withPagination,
+		mcp.WithDescription("List all devices owned by a user, identified by user ID or login name (email). Returns each device with its authorization state, last seen timestamp, addresses, OS, and tags. Essential for offboarding, support requests, and per-user access reviews. OAuth Scope: users:read, devices:read."),
+		mcp.WithString("user", mcp.Description("The user ID or login name (e.g., 'alice@example.com')"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, ut.ListUserDevices)
+
+	tool = mcp.NewTool(
+		"tailscale_users_shared_report",
+		readOnlyTool,
+		mcp.WithDescription("Report external users who can reach this tailnet through device sharing. Lists shared users and, for every device, the share invites that were accepted (with the external accepter) or are still pending. Also lists devices shared into this tailnet from elsewhere. Use this to audit what outside parties can reach. Requires one API call per device. OAuth Scope: users:read, devices:read."),
+	)
+	mcpServer.AddTool(tool, ut.SharedUsersReport)
+
+	tool = mcp.NewTool(
+		"tailscale_users_ownership_map",
+		readOnlyTool,
+		mcp.WithDescription("Answer 'what can this user administer?' from the policy file. For each user, reports the policy groups (including nested groups) and autogroups they belong to, the tags they own through tagOwners, the devices they own, and the tagged devices they can manage through those tags. Also lists each policy group with its expanded members and owned tags. OAuth Scope: acl:read, users:read, devices:read."),
+		mcp.WithString("user", mcp.Description("Limit the report to this user ID or login name (e.g., 'alice@example.com')")),
+	)
+	mcpServer.AddTool(tool, ut.OwnershipMap)
+
+	tool = mcp.NewTool(
+		"tailscale_user_export",
+		updateTool,
+		mcp.WithDescription("Export everything the tailnet holds about one user for GDPR subject-access or access-review requests: profile, owned devices, auth keys created by the user (metadata only, no secrets), and configuration audit log entries where the user is the actor or target. Returns a single JSON bundle or a flat CSV with one row per record. OAuth Scope: users:read, devices:read, keys:read, logging:read."),
+		mcp.WithString("user", mcp.Description("The user ID or login name (e.g., 'alice@example.com')"), mcp.Required()),
+		mcp.WithString("format", mcp.Description("Output format (default: json)"), mcp.Enum("json", "csv")),
+		mcp.WithNumber("audit_days", mcp.Description("How many days of audit log to include (default: 30)"), mcp.DefaultNumber(30)),
+		withExportPath,
+	)
+	mcpServer.AddTool(tool, ut.ExportUser)
+
+	tool = mcp.NewTool(
+		"tailscale_group_members",
+		readOnlyTool,
+		mcp.WithDescription("Resolve the effective membership of a policy group or autogroup. For 'group:' names, nested groups are followed and the users they contain are listed, with members that are not known tailnet users flagged. For role and type autogroups such as 'autogroup:admin', 'autogroup:member', or 'autogroup:shared', the matching users are listed; 'autogroup:tagged' lists tagged devices. Use this to verify ACL membership without reading the policy by eye. OAuth Scope: acl:read, users:read."),
+		mcp.WithString("group", mcp.Description("The group to resolve (e.g., 'group:eng', 'autogroup:admin')"), mcp.Required()),
+	)
+	mcpServer.AddTool(tool, ut.GroupMembers)
 }
 
 func (ut *UserTools) ListUsers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Type  string `json:"type"`
+		Role  string `json:"role"`
+		Query string `json:"query"`
+		pageArgs
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	if args.Cursor != "" {
+		return nextPage[tailscale.User](request.Params.Name, args.pageArgs, "users")
+	}
+
+	var userType *tailscale.UserType
+	if args.Type != "" {
+		userType = tailscale.PointerTo(tailscale.UserType(args.Type))
+	}
+	var role *tailscale.UserRole
+	if args.Role != "" {
+		role = tailscale.PointerTo(tailscale.UserRole(args.Role))
+	}
+
 	client := ut.client.GetClient()
-	users, err := client.Users().List(ctx, nil, nil)
+	users, err := client.Users().List(ctx, userType, role)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to list users: %v", err)), nil
 	}
 
-	usersJSON, err := json.MarshalIndent(users, "", "  ")
-	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal users: %v", err)), nil
+	if args.Query != "" {
+		query := strings.ToLower(args.Query)
+		users = slices.DeleteFunc(users, func(user tailscale.User) bool {
+			return !strings.Contains(strings.ToLower(user.DisplayName), query) &&
+				!strings.Contains(strings.ToLower(user.LoginName), query)
+		})
 	}
 
-	return mcp.NewToolResultText(string(usersJSON)), nil
+	return firstPage(request.Params.Name, users, args.pageArgs, "users")
 }
 
 func (ut *UserTools) GetUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -111,7 +286,7 @@ func (ut *UserTools) GetUser(ctx context.Context, request mcp.CallToolRequest) (
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal user: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(userJSON)), nil
+	return mcp.NewToolResultStructured(user, string(userJSON)), nil
 }
 
 func (ut *UserTools) ApproveUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -123,8 +298,11 @@ func (ut *UserTools) ApproveUser(ctx context.Context, request mcp.CallToolReques
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
 	}
 
-	// User approval is not available in the current API
-	return mcp.NewToolResultError("User approval functionality is not available in the current API"), nil
+	if err := ut.client.Do(ctx, http.MethodPost, "/users/"+url.PathEscape(args.UserID)+"/approve", nil, nil); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to approve user: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("User %s approved successfully", args.UserID)), nil
 }
 
 func (ut *UserTools) SuspendUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -136,8 +314,11 @@ func (ut *UserTools) SuspendUser(ctx context.Context, request mcp.CallToolReques
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
 	}
 
-	// User suspension is not available in the current API
-	return mcp.NewToolResultError("User suspension functionality is not available in the current API"), nil
+	if err := ut.client.Do(ctx, http.MethodPost, "/users/"+url.PathEscape(args.UserID)+"/suspend", nil, nil); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to suspend user: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("User %s suspended successfully", args.UserID)), nil
 }
 
 func (ut *UserTools) RestoreUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -149,8 +330,11 @@ func (ut *UserTools) RestoreUser(ctx context.Context, request mcp.CallToolReques
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
 	}
 
-	// User restoration is not available in the current API
-	return mcp.NewToolResultError("User restoration functionality is not available in the current API"), nil
+	if err := ut.client.Do(ctx, http.MethodPost, "/users/"+url.PathEscape(args.UserID)+"/restore", nil, nil); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to restore user: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("User %s restored successfully", args.UserID)), nil
 }
 
 func (ut *UserTools) DeleteUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -162,8 +346,11 @@ func (ut *UserTools) DeleteUser(ctx context.Context, request mcp.CallToolRequest
 		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
 	}
 
-	// User deletion is not available in the current API
-	return mcp.NewToolResultError("User deletion functionality is not available in the current API"), nil
+	if err := ut.client.Do(ctx, http.MethodPost, "/users/"+url.PathEscape(args.UserID)+"/delete", nil, nil); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to delete user: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("User %s deleted successfully", args.UserID)), nil
 }
 
 func (ut *UserTools) GetContacts(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -178,7 +365,7 @@ func (ut *UserTools) GetContacts(ctx context.Context, request mcp.CallToolReques
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal contacts: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText(string(contactsJSON)), nil
+	return mcp.NewToolResultStructured(contacts, string(contactsJSON)), nil
 }
 
 func (ut *UserTools) UpdateContact(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -214,3 +401,527 @@ func (ut *UserTools) UpdateContact(ctx context.Context, request mcp.CallToolRequ
 
 	return mcp.NewToolResultText(fmt.Sprintf("Contact %s updated to %s", args.ContactType, args.Email)), nil
 }
+
+func (ut *UserTools) ListUserInvites(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		pageArgs
+	}
+
+	if request.Params.Arguments != nil {
+		if err := request.BindArguments(&args); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+		}
+	}
+
+	if args.Cursor != "" {
+		return nextPage[UserInvite](request.Params.Name, args.pageArgs, "user invites")
+	}
+
+	var invites []UserInvite
+	if err := ut.client.Do(ctx, http.MethodGet, ut.client.TailnetPath("user-invites"), nil, &invites); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list user invites: %v", err)), nil
+	}
+	ut.resolveInviters(ctx, invites)
+
+	return firstPage(request.Params.Name, invites, args.pageArgs, "user invites")
+}
+
+func (ut *UserTools) GetUserInvite(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		InviteID string `json:"invite_id"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	var invite UserInvite
+	if err := ut.client.Do(ctx, http.MethodGet, "/user-invites/"+url.PathEscape(args.InviteID), nil, &invite); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get user invite: %v", err)), nil
+	}
+	invites := []UserInvite{invite}
+	ut.resolveInviters(ctx, invites)
+	invite = invites[0]
+
+	inviteJSON, err := json.MarshalIndent(invite, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal user invite: %v", err)), nil
+	}
+
+	return mcp.NewToolResultStructured(invite, string(inviteJSON)), nil
+}
+
+// resolveInviters fills in the login names of the users who sent invites.
+// The invites are still useful without them, so a failure to list the users
+// is only logged.
+func (ut *UserTools) resolveInviters(ctx context.Context, invites []UserInvite) {
+	if len(invites) == 0 {
+		return
+	}
+	users, err := ut.client.GetClient().Users().List(ctx, tailscale.PointerTo(tailscale.UserType("all")), nil)
+	if err != nil {
+		log.Printf("warning: Failed to list users to name invite senders: %v", err)
+		return
+	}
+	loginNames := make(map[string]string, len(users))
+	for _, user := range users {
+		loginNames[user.ID] = user.LoginName
+	}
+	for i := range invites {
+		invites[i].InviterLoginName = loginNames[strconv.FormatInt(invites[i].InviterID, 10)]
+	}
+}
+
+func (ut *UserTools) DeleteUserInvite(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		InviteID string `json:"invite_id"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	if err := ut.client.Do(ctx, http.MethodDelete, "/user-invites/"+url.PathEscape(args.InviteID), nil, nil); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to delete user invite: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("User invite %s deleted successfully", args.InviteID)), nil
+}
+
+func (ut *UserTools) ResendUserInvite(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		InviteID string `json:"invite_id"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	if err := ut.client.Do(ctx, http.MethodPost, "/user-invites/"+url.PathEscape(args.InviteID)+"/resend", nil, nil); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to resend user invite: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("User invite %s resent successfully", args.InviteID)), nil
+}
+
+// resolveUser looks up a user by ID, or by login name when the identifier
+// contains an '@'.
+func resolveUser(ctx context.Context, client *tailscale.Client, identifier string) (*tailscale.User, error) {
+	if !strings.Contains(identifier, "@") {
+		return client.Users().Get(ctx, identifier)
+	}
+
+	users, err := client.Users().List(ctx, tailscale.PointerTo(tailscale.UserType("all")), nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if strings.EqualFold(user.LoginName, identifier) {
+			return &user, nil
+		}
+	}
+	return nil, fmt.Errorf("no user with login name %s", identifier)
+}
+
+func (ut *UserTools) ListUserDevices(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		User string `json:"user"`
+		pageArgs
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	if args.Cursor != "" {
+		return nextPage[tailscale.Device](request.Params.Name, args.pageArgs, "devices")
+	}
+
+	client := ut.client.GetClient()
+	user, err := resolveUser(ctx, client, args.User)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get user: %v", err)), nil
+	}
+
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+
+	owned := []tailscale.Device{}
+	for _, device := range devices {
+		if strings.EqualFold(device.User, user.LoginName) {
+			owned = append(owned, device)
+		}
+	}
+
+	return firstPage(request.Params.Name, owned, args.pageArgs, "devices")
+}
+
+func (ut *UserTools) ResendContactVerification(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ContactType string `json:"contact_type"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	switch args.ContactType {
+	case "account", "support", "security":
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid contact type: %s", args.ContactType)), nil
+	}
+
+	path := ut.client.TailnetPath("contacts", args.ContactType, "resend-verification-email")
+	if err := ut.client.Do(ctx, http.MethodPost, path, nil, nil); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to resend verification email: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Verification email resent for %s contact", args.ContactType)), nil
+}
+
+func (ut *UserTools) SharedUsersReport(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	client := ut.client.GetClient()
+	sharedUsers, err := client.Users().List(ctx, tailscale.PointerTo(tailscale.UserTypeShared), nil)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list shared users: %v", err)), nil
+	}
+
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+
+	type sharedDevice struct {
+		DeviceID string         `json:"device_id"`
+		Name     string         `json:"name"`
+		Owner    string         `json:"owner"`
+		Invites  []DeviceInvite `json:"invites"`
+	}
+	type externalDevice struct {
+		DeviceID string `json:"device_id"`
+		Name     string `json:"name"`
+		Owner    string `json:"owner"`
+	}
+	report := struct {
+		SharedUsers     []tailscale.User `json:"shared_users"`
+		SharedDevices   []sharedDevice   `json:"devices_shared_out"`
+		ExternalDevices []externalDevice `json:"devices_shared_in"`
+	}{
+		SharedUsers:     sharedUsers,
+		SharedDevices:   []sharedDevice{},
+		ExternalDevices: []externalDevice{},
+	}
+
+	for _, device := range devices {
+		if device.IsExternal {
+			report.ExternalDevices = append(report.ExternalDevices, externalDevice{DeviceID: device.NodeID, Name: device.Name, Owner: device.User})
+			continue
+		}
+
+		var invites []DeviceInvite
+		if err := ut.client.Do(ctx, http.MethodGet, "/device/"+url.PathEscape(device.ID)+"/device-invites", nil, &invites); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to list share invites for device %s: %v", device.Name, err)), nil
+		}
+		if len(invites) > 0 {
+			report.SharedDevices = append(report.SharedDevices, sharedDevice{DeviceID: device.NodeID, Name: device.Name, Owner: device.User, Invites: invites})
+		}
+	}
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal shared user report: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(reportJSON)), nil
+}
+
+func (ut *UserTools) OwnershipMap(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		User string `json:"user"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	client := ut.client.GetClient()
+	policy, err := client.PolicyFile().Get(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy file: %v", err)), nil
+	}
+
+	var users []tailscale.User
+	if args.User != "" {
+		user, err := resolveUser(ctx, client, args.User)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get user: %v", err)), nil
+		}
+		users = []tailscale.User{*user}
+	} else {
+		users, err = client.Users().List(ctx, tailscale.PointerTo(tailscale.UserType("all")), nil)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to list users: %v", err)), nil
+		}
+	}
+
+	devices, err := client.Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+
+	type userOwnership struct {
+		UserID              string   `json:"user_id"`
+		LoginName           string   `json:"login_name"`
+		Role                string   `json:"role"`
+		Groups              []string `json:"groups"`
+		Autogroups          []string `json:"autogroups"`
+		OwnedTags           []string `json:"owned_tags"`
+		OwnedDevices        []string `json:"owned_devices"`
+		AdministeredDevices []string `json:"administered_tagged_devices"`
+	}
+	type groupOwnership struct {
+		Group     string   `json:"group"`
+		Members   []string `json:"members"`
+		OwnedTags []string `json:"owned_tags"`
+	}
+	report := struct {
+		Users  []userOwnership  `json:"users"`
+		Groups []groupOwnership `json:"groups"`
+	}{
+		Users:  []userOwnership{},
+		Groups: []groupOwnership{},
+	}
+
+	reportedGroups := make(map[string]bool)
+	for _, user := range users {
+		entry := userOwnership{
+			UserID:              user.ID,
+			LoginName:           user.LoginName,
+			Role:                string(user.Role),
+			Groups:              userGroups(policy, user.LoginName),
+			Autogroups:          userAutogroups(user),
+			OwnedTags:           tagsOwnedBy(policy, userPrincipals(policy, user)),
+			OwnedDevices:        []string{},
+			AdministeredDevices: []string{},
+		}
+		for _, device := range devices {
+			if strings.EqualFold(device.User, user.LoginName) && len(device.Tags) == 0 {
+				entry.OwnedDevices = append(entry.OwnedDevices, device.Name)
+			}
+			if slices.ContainsFunc(device.Tags, func(tag string) bool { return slices.Contains(entry.OwnedTags, tag) }) {
+				entry.AdministeredDevices = append(entry.AdministeredDevices, device.Name)
+			}
+		}
+		for _, group := range entry.Groups {
+			reportedGroups[group] = true
+		}
+		report.Users = append(report.Users, entry)
+	}
+
+	for _, group := range slices.Sorted(maps.Keys(policy.Groups)) {
+		if args.User != "" && !reportedGroups[group] {
+			continue
+		}
+		report.Groups = append(report.Groups, groupOwnership{
+			Group:     group,
+			Members:   expandGroup(policy, group),
+			OwnedTags: tagsOwnedBy(policy, []string{group}),
+		})
+	}
+
+	reportJSON, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal ownership map: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(reportJSON)), nil
+}
+
+func (ut *UserTools) ExportUser(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := struct {
+		User      string `json:"user"`
+		Format    string `json:"format"`
+		AuditDays int    `json:"audit_days"`
+		Path      string `json:"path"`
+	}{Format: "json", AuditDays: 30}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+	if args.Format != "json" && args.Format != "csv" {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid format: %s", args.Format)), nil
+	}
+	if args.AuditDays <= 0 {
+		return mcp.NewToolResultError("audit_days must be positive"), nil
+	}
+
+	user, err := resolveUser(ctx, ut.client.GetClient(), args.User)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get user: %v", err)), nil
+	}
+
+	allDevices, err := ut.client.GetClient().Devices().List(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+	}
+	devices := []tailscale.Device{}
+	for _, device := range allDevices {
+		if strings.EqualFold(device.User, user.LoginName) {
+			devices = append(devices, device)
+		}
+	}
+
+	allKeys, err := ut.client.ListKeysWithDetails(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list keys: %v", err)), nil
+	}
+	keys := []tailscale.Key{}
+	for _, key := range allKeys {
+		if key.UserID == user.ID {
+			key.Key = ""
+			keys = append(keys, key)
+		}
+	}
+
+	end := time.Now()
+	allLogs, err := ut.client.AuditLogs(ctx, end.AddDate(0, 0, -args.AuditDays), end)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to get audit logs: %v", err)), nil
+	}
+	logs := []client.AuditLogEntry{}
+	for _, entry := range allLogs {
+		if entry.Actor.ID == user.ID || strings.EqualFold(entry.Actor.LoginName, user.LoginName) ||
+			entry.Target.ID == user.ID || strings.EqualFold(entry.Target.Name, user.LoginName) {
+			logs = append(logs, entry)
+		}
+	}
+
+	if args.Format == "csv" {
+		rows := [][]string{
+			{"profile", user.ID, user.LoginName, fmt.Sprintf("display_name=%s role=%s status=%s type=%s", user.DisplayName, user.Role, user.Status, user.Type), formatTime(user.Created), formatTime(user.LastSeen)},
+		}
+		for _, device := range devices {
+			rows = append(rows, []string{"device", device.NodeID, device.Name, fmt.Sprintf("os=%s addresses=%s authorized=%t", device.OS, strings.Join(device.Addresses, " "), device.Authorized), formatTime(device.Created.Time), formatTime(device.LastSeen.Time)})
+		}
+		for _, key := range keys {
+			rows = append(rows, []string{"key", key.ID, key.Description, fmt.Sprintf("type=%s status=%s expires=%s", key.KeyType, keyStatus(key, end), formatTime(key.Expires)), formatTime(key.Created), ""})
+		}
+		for _, entry := range logs {
+			rows = append(rows, []string{"audit_log", entry.EventGroupID, entry.Type + " " + entry.Target.Type, fmt.Sprintf("actor=%s target=%s property=%s", entry.Actor.LoginName, entry.Target.Name, entry.Target.Property), formatTime(entry.EventTime), ""})
+		}
+
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"record", "id", "name", "details", "time", "last_seen"})
+		w.WriteAll(rows)
+		if err := w.Error(); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to write CSV: %v", err)), nil
+		}
+		return exportResult(ctx, ut.config, args.Path, buf.String(), "user export"), nil
+	}
+
+	bundle := struct {
+		GeneratedAt time.Time              `json:"generated_at"`
+		AuditSince  time.Time              `json:"audit_since"`
+		Profile     *tailscale.User        `json:"profile"`
+		Devices     []tailscale.Device     `json:"devices"`
+		Keys        []tailscale.Key        `json:"keys"`
+		AuditLog    []client.AuditLogEntry `json:"audit_log"`
+	}{
+		GeneratedAt: end.UTC(),
+		AuditSince:  end.AddDate(0, 0, -args.AuditDays).UTC(),
+		Profile:     user,
+		Devices:     devices,
+		Keys:        keys,
+		AuditLog:    logs,
+	}
+
+	bundleJSON, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal user export: %v", err)), nil
+	}
+
+	return exportResult(ctx, ut.config, args.Path, string(bundleJSON), "user export"), nil
+}
+
+func (ut *UserTools) GroupMembers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Group string `json:"group"`
+	}
+
+	if err := request.BindArguments(&args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid arguments: %v", err)), nil
+	}
+
+	client := ut.client.GetClient()
+	result := struct {
+		Group          string   `json:"group"`
+		Members        []string `json:"members"`
+		NestedGroups   []string `json:"nested_groups,omitempty"`
+		UnknownMembers []string `json:"unknown_members,omitempty"`
+	}{
+		Group:   args.Group,
+		Members: []string{},
+	}
+
+	switch {
+	case args.Group == "autogroup:tagged":
+		devices, err := client.Devices().List(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to list devices: %v", err)), nil
+		}
+		for _, device := range devices {
+			if len(device.Tags) > 0 {
+				result.Members = append(result.Members, device.Name)
+			}
+		}
+	case strings.HasPrefix(args.Group, "autogroup:"):
+		users, err := client.Users().List(ctx, tailscale.PointerTo(tailscale.UserType("all")), nil)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to list users: %v", err)), nil
+		}
+		for _, user := range users {
+			if slices.Contains(userAutogroups(user), args.Group) {
+				result.Members = append(result.Members, user.LoginName)
+			}
+		}
+	case strings.HasPrefix(args.Group, "group:"):
+		policy, err := client.PolicyFile().Get(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to get policy file: %v", err)), nil
+		}
+		if _, ok := policy.Groups[args.Group]; !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Group %s is not defined in the policy file", args.Group)), nil
+		}
+
+		users, err := client.Users().List(ctx, tailscale.PointerTo(tailscale.UserType("all")), nil)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to list users: %v", err)), nil
+		}
+		known := make(map[string]bool, len(users))
+		for _, user := range users {
+			known[strings.ToLower(user.LoginName)] = true
+		}
+
+		result.Members = expandGroup(policy, args.Group)
+		result.NestedGroups = nestedGroups(policy, args.Group)
+		for _, member := range result.Members {
+			if !known[strings.ToLower(member)] {
+				result.UnknownMembers = append(result.UnknownMembers, member)
+			}
+		}
+	default:
+		return mcp.NewToolResultError("Group must start with 'group:' or 'autogroup:'"), nil
+	}
+
+	slices.Sort(result.Members)
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to marshal group members: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(string(resultJSON)), nil
+}
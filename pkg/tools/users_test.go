@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/pnocera/tailscale-mcp-server/internal/client"
+	"github.com/pnocera/tailscale-mcp-server/internal/config"
+)
+
+func TestUserInviteInviter(t *testing.T) {
+	usersStatus := http.StatusOK
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v2/tailnet/-/users":
+			w.WriteHeader(usersStatus)
+			w.Write([]byte(`{"users": [{"id": "42", "loginName": "alice@example.com"}]}`))
+		case "/api/v2/user-invites/i1":
+			w.Write([]byte(`{"id": "i1", "role": "member", "inviterId": 42, "email": "bob@example.com"}`))
+		case "/api/v2/user-invites/i2":
+			w.Write([]byte(`{"id": "i2", "role": "member", "inviterId": 7, "email": "carol@example.com"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	cfg := &config.Config{TailscaleAPIKey: "test", TailscaleTailnet: "-"}
+	tc, err := client.NewTailscaleClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.GetClient().BaseURL, err = url.Parse(api.URL); err != nil {
+		t.Fatal(err)
+	}
+	ut := NewUserTools(tc, cfg)
+	get := func(id string) UserInvite {
+		t.Helper()
+		request := mcp.CallToolRequest{}
+		request.Params.Name = "tailscale_user_invite_get"
+		request.Params.Arguments = map[string]any{"invite_id": id}
+		result, err := ut.GetUserInvite(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.IsError {
+			t.Fatalf("GetUserInvite returned an error: %v", result.Content)
+		}
+		return result.StructuredContent.(UserInvite)
+	}
+
+	if invite := get("i1"); invite.InviterLoginName != "alice@example.com" {
+		t.Errorf("got inviter %q, want alice@example.com", invite.InviterLoginName)
+	}
+	// An inviter who left the tailnet has no login name.
+	if invite := get("i2"); invite.InviterLoginName != "" || invite.InviterID != 7 {
+		t.Errorf("got inviter %d %q, want 7 without a login name", invite.InviterID, invite.InviterLoginName)
+	}
+	// Nor does any inviter if the users cannot be listed, but the invite is
+	// still returned.
+	usersStatus = http.StatusForbidden
+	if invite := get("i1"); invite.InviterLoginName != "" || invite.Email != "bob@example.com" {
+		t.Errorf("got %+v when users cannot be listed, want the invite without a login name", invite)
+	}
+}